@@ -0,0 +1,943 @@
+package cer_ca_tools
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ACME 挑战与标识类型
+const (
+	ACMEChallengeHTTP01  = "http-01"
+	ACMEChallengeAnonCRT = "anoncert-crt-01" // 主体提交CRT/XOR匿名化材料完成的自定义挑战
+	ACMEIdentifierDNS    = "dns"
+	ACMEIdentifierAnon   = "anon" // 匿名身份标识，值仅用于区分订单，不写入证书
+)
+
+// ACME 对象状态
+const (
+	acmeStatusPending     = "pending"
+	acmeStatusProcessing  = "processing"
+	acmeStatusReady       = "ready"
+	acmeStatusValid       = "valid"
+	acmeStatusInvalid     = "invalid"
+	acmeStatusDeactivated = "deactivated"
+)
+
+const (
+	acmePathPrefix    = "/acme"
+	acmeMaxNonces     = 10000
+	acmeMaxBodyBytes  = 64 << 10
+	acmeErrorTypeBase = "urn:ietf:params:acme:error:"
+)
+
+// ACMEAnonCRTResponse anoncert-crt-01 挑战的响应载荷，与 /certificate/issue 中的匿名化材料一致
+type ACMEAnonCRTResponse struct {
+	KeyAuthorization string     `json:"keyAuthorization"`
+	SubjectInfo      pkix.Name  `json:"subject"`
+	XORResult        []byte     `json:"xor_result"`
+	Remainders       []*big.Int `json:"remainders"`
+}
+
+type acmeIdentifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// acmeProblem RFC 7807 错误文档
+type acmeProblem struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+	Status int    `json:"status"`
+}
+
+func newACMEProblem(status int, errorType string, format string, args ...interface{}) *acmeProblem {
+	return &acmeProblem{Type: acmeErrorTypeBase + errorType, Detail: fmt.Sprintf(format, args...), Status: status}
+}
+
+type acmeAccount struct {
+	ID         string
+	Status     string
+	Contact    []string
+	Key        crypto.PublicKey
+	Thumbprint string
+	Orders     []string
+}
+
+type acmeOrder struct {
+	ID             string
+	AccountID      string
+	Status         string
+	Expires        time.Time
+	Identifiers    []acmeIdentifier
+	Authorizations []string
+	CertificateID  string
+	Error          *acmeProblem
+}
+
+type acmeAuthorization struct {
+	ID         string
+	AccountID  string
+	Status     string
+	Expires    time.Time
+	Identifier acmeIdentifier
+	Challenges []*acmeChallenge
+	subject    *pkix.Name // anoncert-crt-01 验证通过后的匿名主体
+}
+
+type acmeChallenge struct {
+	ID        string
+	AuthzID   string
+	Type      string
+	Status    string
+	Token     string
+	Validated time.Time
+	Error     *acmeProblem
+}
+
+// ACMEServer 位于 CAManager 之前的 RFC 8555 ACME 服务，为单个CA签发匿名证书
+//
+// dns 标识通过 http-01 验证，证书主体由账户密钥派生为匿名主体；
+// anon 标识通过 anoncert-crt-01 验证，证书主体与 /certificate/issue 一样由 XOR 匿名化材料生成。
+type ACMEServer struct {
+	manager       *CAManager
+	CAName        string
+	Profile       string        // 签发使用的证书模板，为空时使用CA默认模板
+	BaseURL       string        // ACME 服务对外地址，为空时使用CA的 BaseURL
+	HTTP01Address string        // http-01 验证的替身地址（host:port），为空时连接标识域名的80端口
+	OrderLifetime time.Duration // 订单与授权的有效期
+	HTTPClient    *http.Client  // http-01 验证使用的客户端
+
+	nonces         map[string]time.Time
+	accounts       map[string]*acmeAccount
+	accountsByKey  map[string]*acmeAccount
+	orders         map[string]*acmeOrder
+	authorizations map[string]*acmeAuthorization
+	challenges     map[string]*acmeChallenge
+	certificates   map[string][]byte
+	mutex          sync.Mutex
+	nonceMutex     sync.Mutex
+}
+
+// NewACMEServer 为指定CA创建ACME服务，订单有效期默认24小时
+func NewACMEServer(manager *CAManager, caName string) *ACMEServer {
+	return &ACMEServer{
+		manager:        manager,
+		CAName:         caName,
+		OrderLifetime:  24 * time.Hour,
+		HTTPClient:     &http.Client{Timeout: 10 * time.Second},
+		nonces:         make(map[string]time.Time),
+		accounts:       make(map[string]*acmeAccount),
+		accountsByKey:  make(map[string]*acmeAccount),
+		orders:         make(map[string]*acmeOrder),
+		authorizations: make(map[string]*acmeAuthorization),
+		challenges:     make(map[string]*acmeChallenge),
+		certificates:   make(map[string][]byte),
+	}
+}
+
+// DirectoryURL 返回ACME目录地址，供ACME客户端配置
+func (server *ACMEServer) DirectoryURL() string {
+	return server.url("/directory")
+}
+
+func (server *ACMEServer) url(path string) string {
+	baseURL := server.BaseURL
+	if baseURL == "" {
+		if ca, exists := server.manager.GetCAInfo(server.CAName); exists {
+			baseURL = ca.BaseURL
+		}
+	}
+	return strings.TrimSuffix(baseURL, "/") + acmePathPrefix + path
+}
+
+func (server *ACMEServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, acmePathPrefix)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Link", fmt.Sprintf("<%s>;rel=\"index\"", server.DirectoryURL()))
+
+	switch {
+	case path == "/directory":
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		server.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"newNonce":   server.url("/new-nonce"),
+			"newAccount": server.url("/new-account"),
+			"newOrder":   server.url("/new-order"),
+			"meta": map[string]interface{}{
+				"externalAccountRequired": false,
+			},
+		})
+		return
+	case path == "/new-nonce":
+		server.setNonce(w)
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusOK)
+		} else if r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if contentType := r.Header.Get("Content-Type"); contentType != "application/jose+json" {
+		server.writeProblem(w, newACMEProblem(http.StatusUnsupportedMediaType, "malformed", "content type must be application/jose+json"))
+		return
+	}
+
+	var problem *acmeProblem
+	switch {
+	case path == "/new-account":
+		problem = server.handleNewAccount(w, r)
+	case strings.HasPrefix(path, "/account/") && strings.HasSuffix(path, "/orders"):
+		problem = server.handleAccountOrders(w, r, strings.TrimSuffix(strings.TrimPrefix(path, "/account/"), "/orders"))
+	case strings.HasPrefix(path, "/account/"):
+		problem = server.handleAccount(w, r, strings.TrimPrefix(path, "/account/"))
+	case path == "/new-order":
+		problem = server.handleNewOrder(w, r)
+	case strings.HasPrefix(path, "/order/") && strings.HasSuffix(path, "/finalize"):
+		problem = server.handleFinalize(w, r, strings.TrimSuffix(strings.TrimPrefix(path, "/order/"), "/finalize"))
+	case strings.HasPrefix(path, "/order/"):
+		problem = server.handleOrder(w, r, strings.TrimPrefix(path, "/order/"))
+	case strings.HasPrefix(path, "/authz/"):
+		problem = server.handleAuthorization(w, r, strings.TrimPrefix(path, "/authz/"))
+	case strings.HasPrefix(path, "/chall/"):
+		problem = server.handleChallenge(w, r, strings.TrimPrefix(path, "/chall/"))
+	case strings.HasPrefix(path, "/cert/"):
+		problem = server.handleCertificate(w, r, strings.TrimPrefix(path, "/cert/"))
+	default:
+		problem = newACMEProblem(http.StatusNotFound, "malformed", "unknown ACME resource %s", path)
+	}
+	if problem != nil {
+		server.writeProblem(w, problem)
+	}
+}
+
+// handleNewAccount 创建账户，同一公钥重复注册时返回已有账户
+func (server *ACMEServer) handleNewAccount(w http.ResponseWriter, r *http.Request) *acmeProblem {
+	request, problem := server.readJWS(r, true)
+	if problem != nil {
+		return problem
+	}
+	var payload struct {
+		Contact              []string `json:"contact"`
+		TermsOfServiceAgreed bool     `json:"termsOfServiceAgreed"`
+		OnlyReturnExisting   bool     `json:"onlyReturnExisting"`
+	}
+	if err := json.Unmarshal(request.payload, &payload); err != nil {
+		return newACMEProblem(http.StatusBadRequest, "malformed", "invalid newAccount payload: %v", err)
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if account, exists := server.accountsByKey[request.thumbprint]; exists {
+		w.Header().Set("Location", server.url("/account/"+account.ID))
+		server.writeJSON(w, http.StatusOK, server.accountJSON(account))
+		return nil
+	}
+	if payload.OnlyReturnExisting {
+		return newACMEProblem(http.StatusBadRequest, "accountDoesNotExist", "no account for this key")
+	}
+
+	account := &acmeAccount{
+		ID:         newACMEID(),
+		Status:     acmeStatusValid,
+		Contact:    payload.Contact,
+		Key:        request.key,
+		Thumbprint: request.thumbprint,
+	}
+	server.accounts[account.ID] = account
+	server.accountsByKey[account.Thumbprint] = account
+	log.Printf("ACME account %s registered for CA %s", account.ID, server.CAName)
+
+	w.Header().Set("Location", server.url("/account/"+account.ID))
+	server.writeJSON(w, http.StatusCreated, server.accountJSON(account))
+	return nil
+}
+
+// handleAccount 查询、更新联系方式或注销账户
+func (server *ACMEServer) handleAccount(w http.ResponseWriter, r *http.Request, accountID string) *acmeProblem {
+	request, problem := server.readJWS(r, false)
+	if problem != nil {
+		return problem
+	}
+	if request.account.ID != accountID {
+		return newACMEProblem(http.StatusUnauthorized, "unauthorized", "account URL does not match the request key")
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	account := request.account
+	if len(request.payload) > 0 {
+		var payload struct {
+			Contact []string `json:"contact"`
+			Status  string   `json:"status"`
+		}
+		if err := json.Unmarshal(request.payload, &payload); err != nil {
+			return newACMEProblem(http.StatusBadRequest, "malformed", "invalid account payload: %v", err)
+		}
+		if payload.Contact != nil {
+			account.Contact = payload.Contact
+		}
+		if payload.Status == acmeStatusDeactivated {
+			account.Status = acmeStatusDeactivated
+		}
+	}
+	server.writeJSON(w, http.StatusOK, server.accountJSON(account))
+	return nil
+}
+
+// handleAccountOrders POST-as-GET 列出账户的订单
+func (server *ACMEServer) handleAccountOrders(w http.ResponseWriter, r *http.Request, accountID string) *acmeProblem {
+	request, problem := server.readJWS(r, false)
+	if problem != nil {
+		return problem
+	}
+	if request.account.ID != accountID {
+		return newACMEProblem(http.StatusUnauthorized, "unauthorized", "account URL does not match the request key")
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	orders := make([]string, 0, len(request.account.Orders))
+	for _, orderID := range request.account.Orders {
+		orders = append(orders, server.url("/order/"+orderID))
+	}
+	server.writeJSON(w, http.StatusOK, map[string]interface{}{"orders": orders})
+	return nil
+}
+
+// handleNewOrder 创建订单：每个标识生成一个授权，dns 标识使用 http-01，anon 标识使用 anoncert-crt-01
+func (server *ACMEServer) handleNewOrder(w http.ResponseWriter, r *http.Request) *acmeProblem {
+	request, problem := server.readJWS(r, false)
+	if problem != nil {
+		return problem
+	}
+	var payload struct {
+		Identifiers []acmeIdentifier `json:"identifiers"`
+		NotBefore   string           `json:"notBefore"`
+		NotAfter    string           `json:"notAfter"`
+	}
+	if err := json.Unmarshal(request.payload, &payload); err != nil {
+		return newACMEProblem(http.StatusBadRequest, "malformed", "invalid newOrder payload: %v", err)
+	}
+	if payload.NotBefore != "" || payload.NotAfter != "" {
+		return newACMEProblem(http.StatusBadRequest, "malformed", "notBefore and notAfter are decided by the certificate profile")
+	}
+	if len(payload.Identifiers) == 0 {
+		return newACMEProblem(http.StatusBadRequest, "malformed", "order has no identifiers")
+	}
+
+	anonIdentifiers := 0
+	seen := make(map[acmeIdentifier]bool)
+	for i, identifier := range payload.Identifiers {
+		switch identifier.Type {
+		case ACMEIdentifierDNS:
+			identifier.Value = strings.ToLower(strings.TrimSuffix(identifier.Value, "."))
+			if identifier.Value == "" || strings.Contains(identifier.Value, "*") || net.ParseIP(identifier.Value) != nil {
+				return newACMEProblem(http.StatusBadRequest, "rejectedIdentifier", "%q cannot be validated with http-01", identifier.Value)
+			}
+		case ACMEIdentifierAnon:
+			if anonIdentifiers++; anonIdentifiers > 1 {
+				return newACMEProblem(http.StatusBadRequest, "rejectedIdentifier", "an order may carry only one anon identifier")
+			}
+		default:
+			return newACMEProblem(http.StatusBadRequest, "unsupportedIdentifier", "identifier type %q is not supported", identifier.Type)
+		}
+		if seen[identifier] {
+			return newACMEProblem(http.StatusBadRequest, "malformed", "duplicate identifier %s", identifier.Value)
+		}
+		seen[identifier] = true
+		payload.Identifiers[i] = identifier
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	now := time.Now()
+	order := &acmeOrder{
+		ID:          newACMEID(),
+		AccountID:   request.account.ID,
+		Status:      acmeStatusPending,
+		Expires:     now.Add(server.OrderLifetime),
+		Identifiers: payload.Identifiers,
+	}
+	for _, identifier := range payload.Identifiers {
+		authorization := &acmeAuthorization{
+			ID:         newACMEID(),
+			AccountID:  request.account.ID,
+			Status:     acmeStatusPending,
+			Expires:    order.Expires,
+			Identifier: identifier,
+		}
+		challengeType := ACMEChallengeHTTP01
+		if identifier.Type == ACMEIdentifierAnon {
+			challengeType = ACMEChallengeAnonCRT
+		}
+		challenge := &acmeChallenge{
+			ID:      newACMEID(),
+			AuthzID: authorization.ID,
+			Type:    challengeType,
+			Status:  acmeStatusPending,
+			Token:   newACMEID(),
+		}
+		authorization.Challenges = []*acmeChallenge{challenge}
+		server.challenges[challenge.ID] = challenge
+		server.authorizations[authorization.ID] = authorization
+		order.Authorizations = append(order.Authorizations, authorization.ID)
+	}
+	server.orders[order.ID] = order
+	request.account.Orders = append(request.account.Orders, order.ID)
+
+	w.Header().Set("Location", server.url("/order/"+order.ID))
+	server.writeJSON(w, http.StatusCreated, server.orderJSON(order))
+	return nil
+}
+
+// handleOrder POST-as-GET 查询订单
+func (server *ACMEServer) handleOrder(w http.ResponseWriter, r *http.Request, orderID string) *acmeProblem {
+	request, problem := server.readJWS(r, false)
+	if problem != nil {
+		return problem
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	order, problem := server.ownedOrder(request.account, orderID)
+	if problem != nil {
+		return problem
+	}
+	server.writeJSON(w, http.StatusOK, server.orderJSON(order))
+	return nil
+}
+
+// handleAuthorization POST-as-GET 查询授权，载荷 {"status":"deactivated"} 时注销授权
+func (server *ACMEServer) handleAuthorization(w http.ResponseWriter, r *http.Request, authzID string) *acmeProblem {
+	request, problem := server.readJWS(r, false)
+	if problem != nil {
+		return problem
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	authorization, exists := server.authorizations[authzID]
+	if !exists || authorization.AccountID != request.account.ID {
+		return newACMEProblem(http.StatusNotFound, "malformed", "authorization %s not found", authzID)
+	}
+	if len(request.payload) > 0 {
+		var payload struct {
+			Status string `json:"status"`
+		}
+		if err := json.Unmarshal(request.payload, &payload); err != nil || payload.Status != acmeStatusDeactivated {
+			return newACMEProblem(http.StatusBadRequest, "malformed", "only deactivation may be requested")
+		}
+		authorization.Status = acmeStatusDeactivated
+	}
+	server.writeJSON(w, http.StatusOK, server.authorizationJSON(authorization, time.Now()))
+	return nil
+}
+
+// handleChallenge 客户端通知挑战已就绪，服务端同步完成验证
+func (server *ACMEServer) handleChallenge(w http.ResponseWriter, r *http.Request, challengeID string) *acmeProblem {
+	request, problem := server.readJWS(r, false)
+	if problem != nil {
+		return problem
+	}
+
+	server.mutex.Lock()
+	challenge, exists := server.challenges[challengeID]
+	var authorization *acmeAuthorization
+	if exists {
+		authorization = server.authorizations[challenge.AuthzID]
+	}
+	if !exists || authorization.AccountID != request.account.ID {
+		server.mutex.Unlock()
+		return newACMEProblem(http.StatusNotFound, "malformed", "challenge %s not found", challengeID)
+	}
+	// POST-as-GET 或挑战已处理过时只返回当前状态
+	if len(request.payload) == 0 || challenge.Status != acmeStatusPending || authorization.Status != acmeStatusPending {
+		server.writeChallenge(w, challenge, authorization)
+		server.mutex.Unlock()
+		return nil
+	}
+	challenge.Status = acmeStatusProcessing
+	identifier, token := authorization.Identifier, challenge.Token
+	server.mutex.Unlock()
+
+	// 验证可能访问网络，不持有锁
+	keyAuthorization := token + "." + request.account.Thumbprint
+	var subject *pkix.Name
+	var failure *acmeProblem
+	switch challenge.Type {
+	case ACMEChallengeHTTP01:
+		failure = server.validateHTTP01(r.Context(), identifier.Value, token, keyAuthorization)
+	case ACMEChallengeAnonCRT:
+		subject, failure = server.validateAnonCRT(request.payload, keyAuthorization)
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if failure != nil {
+		challenge.Status, challenge.Error = acmeStatusInvalid, failure
+		authorization.Status = acmeStatusInvalid
+		log.Printf("ACME %s challenge for %s failed: %s", challenge.Type, identifier.Value, failure.Detail)
+	} else {
+		challenge.Status, challenge.Validated = acmeStatusValid, time.Now()
+		authorization.Status = acmeStatusValid
+		authorization.subject = subject
+	}
+	server.writeChallenge(w, challenge, authorization)
+	return nil
+}
+
+// validateHTTP01 请求 http://<域名>/.well-known/acme-challenge/<token>，响应必须为 keyAuthorization
+func (server *ACMEServer) validateHTTP01(ctx context.Context, domain, token, keyAuthorization string) *acmeProblem {
+	address := server.HTTP01Address
+	if address == "" {
+		address = net.JoinHostPort(domain, "80")
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+address+"/.well-known/acme-challenge/"+token, nil)
+	if err != nil {
+		return newACMEProblem(http.StatusBadRequest, "connection", "invalid validation request: %v", err)
+	}
+	// 替身地址按 Host 头区分被验证的域名
+	request.Host = domain
+	response, err := server.HTTPClient.Do(request)
+	if err != nil {
+		return newACMEProblem(http.StatusBadRequest, "connection", "failed to fetch http-01 response for %s: %v", domain, err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1024))
+	if err != nil || response.StatusCode != http.StatusOK {
+		return newACMEProblem(http.StatusBadRequest, "unauthorized", "http-01 response for %s has status %d", domain, response.StatusCode)
+	}
+	if strings.TrimSpace(string(body)) != keyAuthorization {
+		return newACMEProblem(http.StatusBadRequest, "incorrectResponse", "http-01 key authorization for %s does not match", domain)
+	}
+	return nil
+}
+
+// validateAnonCRT 校验挑战载荷中的 XOR 匿名化材料，通过后生成匿名主体
+func (server *ACMEServer) validateAnonCRT(payload []byte, keyAuthorization string) (*pkix.Name, *acmeProblem) {
+	var response ACMEAnonCRTResponse
+	if err := json.Unmarshal(payload, &response); err != nil {
+		return nil, newACMEProblem(http.StatusBadRequest, "malformed", "invalid %s response: %v", ACMEChallengeAnonCRT, err)
+	}
+	if response.KeyAuthorization != keyAuthorization {
+		return nil, newACMEProblem(http.StatusBadRequest, "incorrectResponse", "key authorization does not match")
+	}
+	// XOR结果与余数为空、余数不为正时同样验证失败
+	if !server.manager.VerifyXORResult(response.XORResult, response.SubjectInfo, response.Remainders) {
+		return nil, newACMEProblem(http.StatusBadRequest, "incorrectResponse", "XOR inverse with positive remainders does not reproduce the subject information")
+	}
+	subject := derivedAnonymousSubject(response.XORResult)
+	return &subject, nil
+}
+
+// handleFinalize 订单全部授权通过后按CSR签发证书
+func (server *ACMEServer) handleFinalize(w http.ResponseWriter, r *http.Request, orderID string) *acmeProblem {
+	request, problem := server.readJWS(r, false)
+	if problem != nil {
+		return problem
+	}
+	var payload struct {
+		CSR string `json:"csr"`
+	}
+	if err := json.Unmarshal(request.payload, &payload); err != nil {
+		return newACMEProblem(http.StatusBadRequest, "malformed", "invalid finalize payload: %v", err)
+	}
+	csrDER, err := b64Decode(payload.CSR)
+	if err != nil {
+		return newACMEProblem(http.StatusBadRequest, "badCSR", "CSR is not base64url encoded")
+	}
+
+	server.mutex.Lock()
+	order, problem := server.ownedOrder(request.account, orderID)
+	if problem == nil && order.Status != acmeStatusReady {
+		problem = newACMEProblem(http.StatusForbidden, "orderNotReady", "order is %s", order.Status)
+	}
+	if problem != nil {
+		server.mutex.Unlock()
+		return problem
+	}
+	subject := derivedAnonymousSubject([]byte(request.account.Thumbprint))
+	var dnsNames []string
+	for _, authzID := range order.Authorizations {
+		authorization := server.authorizations[authzID]
+		if authorization.subject != nil {
+			subject = *authorization.subject
+		}
+		if authorization.Identifier.Type == ACMEIdentifierDNS {
+			dnsNames = append(dnsNames, authorization.Identifier.Value)
+		}
+	}
+	order.Status = acmeStatusProcessing
+	server.mutex.Unlock()
+
+	response, problem := server.issue(csrDER, subject, dnsNames)
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if problem != nil {
+		order.Status, order.Error = acmeStatusInvalid, problem
+		return problem
+	}
+	certificateID := newACMEID()
+	chain := response.Chain
+	if chain == "" {
+		chain = response.Certificate
+	}
+	server.certificates[certificateID] = []byte(chain)
+	order.CertificateID = certificateID
+	order.Status = acmeStatusValid
+
+	w.Header().Set("Location", server.url("/order/"+order.ID))
+	server.writeJSON(w, http.StatusOK, server.orderJSON(order))
+	return nil
+}
+
+// issue 校验CSR中的名称与已验证标识一致后由CA签发
+func (server *ACMEServer) issue(csrDER []byte, subject pkix.Name, dnsNames []string) (*CertificateResponse, *acmeProblem) {
+	ca, exists := server.manager.GetCAInfo(server.CAName)
+	if !exists {
+		return nil, newACMEProblem(http.StatusInternalServerError, "serverInternal", "CA %s not found", server.CAName)
+	}
+	csr, err := ParseCSR(csrDER)
+	if err != nil {
+		return nil, newACMEProblem(http.StatusBadRequest, "badCSR", "%v", err)
+	}
+	request, err := ca.CSRPolicy.ValidateCSR(csr, subject)
+	if err != nil {
+		return nil, newACMEProblem(http.StatusBadRequest, "badCSR", "%v", err)
+	}
+	if len(request.EmailAddresses) > 0 || len(request.IPAddresses) > 0 || len(request.URIs) > 0 {
+		return nil, newACMEProblem(http.StatusBadRequest, "badCSR", "CSR may only request DNS names")
+	}
+	// 标准客户端把第一个域名放在CSR的CN中，这里同样视为请求的名称
+	requested := append([]string{}, request.DNSNames...)
+	if csr.Subject.CommonName != "" && len(dnsNames) > 0 {
+		requested = append(requested, csr.Subject.CommonName)
+	}
+	if !sameNames(requested, dnsNames) {
+		return nil, newACMEProblem(http.StatusBadRequest, "badCSR", "CSR names %v do not match the order identifiers %v", request.DNSNames, dnsNames)
+	}
+	request.DNSNames, request.dnsValidated = dnsNames, true
+
+	response := ca.Issue(server.Profile, request)
+	if !response.Success {
+		if isCSRRejection(response.Err) {
+			return nil, newACMEProblem(http.StatusBadRequest, "badCSR", "%s", response.Message)
+		}
+		return nil, newACMEProblem(http.StatusInternalServerError, "serverInternal", "%s", response.Message)
+	}
+	return &response, nil
+}
+
+// handleCertificate POST-as-GET 下载证书链
+func (server *ACMEServer) handleCertificate(w http.ResponseWriter, r *http.Request, certificateID string) *acmeProblem {
+	request, problem := server.readJWS(r, false)
+	if problem != nil {
+		return problem
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	chain, exists := server.certificates[certificateID]
+	if !exists || !server.accountOwnsCertificate(request.account, certificateID) {
+		return newACMEProblem(http.StatusNotFound, "malformed", "certificate %s not found", certificateID)
+	}
+	w.Header().Set("Content-Type", "application/pem-certificate-chain")
+	w.WriteHeader(http.StatusOK)
+	w.Write(chain)
+	return nil
+}
+
+func (server *ACMEServer) accountOwnsCertificate(account *acmeAccount, certificateID string) bool {
+	for _, orderID := range account.Orders {
+		if server.orders[orderID].CertificateID == certificateID {
+			return true
+		}
+	}
+	return false
+}
+
+// ownedOrder 返回账户自己的订单，并按授权状态与有效期刷新订单状态；调用方持有锁
+func (server *ACMEServer) ownedOrder(account *acmeAccount, orderID string) (*acmeOrder, *acmeProblem) {
+	order, exists := server.orders[orderID]
+	if !exists || order.AccountID != account.ID {
+		return nil, newACMEProblem(http.StatusNotFound, "malformed", "order %s not found", orderID)
+	}
+	server.refreshOrder(order, time.Now())
+	return order, nil
+}
+
+func (server *ACMEServer) refreshOrder(order *acmeOrder, now time.Time) {
+	if order.Status != acmeStatusPending {
+		return
+	}
+	if now.After(order.Expires) {
+		order.Status = acmeStatusInvalid
+		return
+	}
+	ready := true
+	for _, authzID := range order.Authorizations {
+		switch server.authorizations[authzID].Status {
+		case acmeStatusValid:
+		case acmeStatusPending:
+			ready = false
+		default:
+			order.Status = acmeStatusInvalid
+			return
+		}
+	}
+	if ready {
+		order.Status = acmeStatusReady
+	}
+}
+
+// acmeRequest 验证通过的JWS请求
+type acmeRequest struct {
+	payload    []byte
+	key        crypto.PublicKey
+	thumbprint string
+	account    *acmeAccount
+}
+
+// readJWS 解析并验证JWS：检查 nonce、url 与签名；newAccount 使用 jwk，其余请求使用 kid
+func (server *ACMEServer) readJWS(r *http.Request, useJWK bool) (*acmeRequest, *acmeProblem) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, acmeMaxBodyBytes))
+	if err != nil {
+		return nil, newACMEProblem(http.StatusBadRequest, "malformed", "failed to read request body")
+	}
+	var jws acmeJWS
+	if err := json.Unmarshal(body, &jws); err != nil {
+		return nil, newACMEProblem(http.StatusBadRequest, "malformed", "request is not a flattened JWS: %v", err)
+	}
+	protected, errProtected := b64Decode(jws.Protected)
+	payload, errPayload := b64Decode(jws.Payload)
+	signature, errSignature := b64Decode(jws.Signature)
+	if errProtected != nil || errPayload != nil || errSignature != nil {
+		return nil, newACMEProblem(http.StatusBadRequest, "malformed", "JWS fields must be base64url encoded")
+	}
+	var header acmeJWSHeader
+	if err := json.Unmarshal(protected, &header); err != nil {
+		return nil, newACMEProblem(http.StatusBadRequest, "malformed", "invalid JWS protected header: %v", err)
+	}
+
+	if header.URL != server.url(strings.TrimPrefix(r.URL.Path, acmePathPrefix)) {
+		return nil, newACMEProblem(http.StatusUnauthorized, "unauthorized", "JWS url %q does not match the request URL", header.URL)
+	}
+	if !server.consumeNonce(header.Nonce) {
+		return nil, newACMEProblem(http.StatusBadRequest, "badNonce", "nonce is invalid or has been used")
+	}
+
+	request := &acmeRequest{payload: payload}
+	if useJWK {
+		if len(header.JWK) == 0 || header.KID != "" {
+			return nil, newACMEProblem(http.StatusBadRequest, "malformed", "newAccount must be signed with a jwk")
+		}
+		var jwk jsonWebKey
+		if err := json.Unmarshal(header.JWK, &jwk); err != nil {
+			return nil, newACMEProblem(http.StatusBadRequest, "malformed", "invalid jwk: %v", err)
+		}
+		if request.key, err = jwk.publicKey(); err != nil {
+			return nil, newACMEProblem(http.StatusBadRequest, "badPublicKey", "%v", err)
+		}
+		request.thumbprint = jwk.thumbprint()
+	} else {
+		if header.KID == "" || len(header.JWK) != 0 {
+			return nil, newACMEProblem(http.StatusBadRequest, "malformed", "request must be signed with an account kid")
+		}
+		server.mutex.Lock()
+		account, exists := server.accounts[strings.TrimPrefix(header.KID, server.url("/account/"))]
+		server.mutex.Unlock()
+		if !exists || header.KID != server.url("/account/"+account.ID) {
+			return nil, newACMEProblem(http.StatusBadRequest, "accountDoesNotExist", "account %s not found", header.KID)
+		}
+		if account.Status != acmeStatusValid {
+			return nil, newACMEProblem(http.StatusUnauthorized, "unauthorized", "account is %s", account.Status)
+		}
+		request.account, request.key, request.thumbprint = account, account.Key, account.Thumbprint
+	}
+
+	if err := verifyJWSSignature(request.key, header.Alg, []byte(jws.Protected+"."+jws.Payload), signature); err != nil {
+		if errors.Is(err, errJWSAlgorithm) {
+			return nil, newACMEProblem(http.StatusBadRequest, "badSignatureAlgorithm", "%v", err)
+		}
+		return nil, newACMEProblem(http.StatusBadRequest, "malformed", "%v", err)
+	}
+	return request, nil
+}
+
+// setNonce 生成新 nonce 写入 Replay-Nonce 头
+func (server *ACMEServer) setNonce(w http.ResponseWriter) {
+	nonce := newACMEID()
+	now := time.Now()
+
+	server.nonceMutex.Lock()
+	defer server.nonceMutex.Unlock()
+	if len(server.nonces) >= acmeMaxNonces {
+		// 丢弃最早的一半，客户端遇到 badNonce 会重试
+		issued := make([]time.Time, 0, len(server.nonces))
+		for _, t := range server.nonces {
+			issued = append(issued, t)
+		}
+		sort.Slice(issued, func(i, j int) bool { return issued[i].Before(issued[j]) })
+		cutoff := issued[len(issued)/2]
+		for n, t := range server.nonces {
+			if t.Before(cutoff) {
+				delete(server.nonces, n)
+			}
+		}
+	}
+	server.nonces[nonce] = now
+
+	w.Header().Set("Replay-Nonce", nonce)
+}
+
+func (server *ACMEServer) consumeNonce(nonce string) bool {
+	server.nonceMutex.Lock()
+	defer server.nonceMutex.Unlock()
+
+	if _, exists := server.nonces[nonce]; !exists {
+		return false
+	}
+	delete(server.nonces, nonce)
+	return true
+}
+
+func (server *ACMEServer) writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	server.setNonce(w)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func (server *ACMEServer) writeProblem(w http.ResponseWriter, problem *acmeProblem) {
+	server.setNonce(w)
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+func (server *ACMEServer) writeChallenge(w http.ResponseWriter, challenge *acmeChallenge, authorization *acmeAuthorization) {
+	w.Header().Add("Link", fmt.Sprintf("<%s>;rel=\"up\"", server.url("/authz/"+authorization.ID)))
+	server.writeJSON(w, http.StatusOK, server.challengeJSON(challenge))
+}
+
+func (server *ACMEServer) accountJSON(account *acmeAccount) map[string]interface{} {
+	return map[string]interface{}{
+		"status":  account.Status,
+		"contact": account.Contact,
+		"orders":  server.url("/account/" + account.ID + "/orders"),
+	}
+}
+
+func (server *ACMEServer) orderJSON(order *acmeOrder) map[string]interface{} {
+	authorizations := make([]string, 0, len(order.Authorizations))
+	for _, authzID := range order.Authorizations {
+		authorizations = append(authorizations, server.url("/authz/"+authzID))
+	}
+	value := map[string]interface{}{
+		"status":         order.Status,
+		"expires":        order.Expires.UTC().Format(time.RFC3339),
+		"identifiers":    order.Identifiers,
+		"authorizations": authorizations,
+		"finalize":       server.url("/order/" + order.ID + "/finalize"),
+	}
+	if order.CertificateID != "" {
+		value["certificate"] = server.url("/cert/" + order.CertificateID)
+	}
+	if order.Error != nil {
+		value["error"] = order.Error
+	}
+	return value
+}
+
+func (server *ACMEServer) authorizationJSON(authorization *acmeAuthorization, now time.Time) map[string]interface{} {
+	if authorization.Status == acmeStatusPending && now.After(authorization.Expires) {
+		authorization.Status = acmeStatusInvalid
+	}
+	challenges := make([]map[string]interface{}, 0, len(authorization.Challenges))
+	for _, challenge := range authorization.Challenges {
+		challenges = append(challenges, server.challengeJSON(challenge))
+	}
+	return map[string]interface{}{
+		"status":     authorization.Status,
+		"expires":    authorization.Expires.UTC().Format(time.RFC3339),
+		"identifier": authorization.Identifier,
+		"challenges": challenges,
+	}
+}
+
+func (server *ACMEServer) challengeJSON(challenge *acmeChallenge) map[string]interface{} {
+	value := map[string]interface{}{
+		"type":   challenge.Type,
+		"url":    server.url("/chall/" + challenge.ID),
+		"token":  challenge.Token,
+		"status": challenge.Status,
+	}
+	if !challenge.Validated.IsZero() {
+		value["validated"] = challenge.Validated.UTC().Format(time.RFC3339)
+	}
+	if challenge.Error != nil {
+		value["error"] = challenge.Error
+	}
+	return value
+}
+
+// newACMEID 生成随机标识，同时用作 nonce 与挑战 token
+func newACMEID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return b64Encode(id)
+}
+
+// sameNames 比较两组名称是否相同（忽略顺序、大小写与重复）
+func sameNames(a, b []string) bool {
+	set := func(names []string) map[string]bool {
+		result := make(map[string]bool, len(names))
+		for _, name := range names {
+			result[strings.ToLower(name)] = true
+		}
+		return result
+	}
+	setA, setB := set(a), set(b)
+	if len(setA) != len(setB) {
+		return false
+	}
+	for name := range setA {
+		if !setB[name] {
+			return false
+		}
+	}
+	return true
+}
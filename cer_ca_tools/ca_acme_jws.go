@@ -0,0 +1,245 @@
+package cer_ca_tools
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// ACME 请求使用 RFC 7515 flattened JSON 序列化的 JWS
+type acmeJWS struct {
+	Protected string `json:"protected"`
+	Payload   string `json:"payload"`
+	Signature string `json:"signature"`
+}
+
+type acmeJWSHeader struct {
+	Alg   string          `json:"alg"`
+	Nonce string          `json:"nonce"`
+	URL   string          `json:"url"`
+	JWK   json.RawMessage `json:"jwk,omitempty"`
+	KID   string          `json:"kid,omitempty"`
+}
+
+// jsonWebKey RFC 7517 公钥，支持 EC（P-256/P-384）、OKP（Ed25519，RFC 8037）与 RSA
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+var errJWSAlgorithm = errors.New("unsupported JWS algorithm")
+
+func b64Encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func b64Decode(data string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(data)
+}
+
+// publicKey 将JWK转换为公钥
+func (jwk *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported JWK curve %q", jwk.Crv)
+		}
+		x, errX := b64Decode(jwk.X)
+		y, errY := b64Decode(jwk.Y)
+		if errX != nil || errY != nil {
+			return nil, fmt.Errorf("invalid EC JWK coordinates")
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("EC JWK point is not on curve %s", jwk.Crv)
+		}
+		return key, nil
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported JWK curve %q", jwk.Crv)
+		}
+		x, err := b64Decode(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 JWK")
+		}
+		return ed25519.PublicKey(x), nil
+	case "RSA":
+		n, errN := b64Decode(jwk.N)
+		e, errE := b64Decode(jwk.E)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid RSA JWK")
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("RSA JWK must be at least 2048 bits")
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported JWK key type %q", jwk.Kty)
+}
+
+// thumbprint RFC 7638 JWK 指纹（SHA-256，base64url）
+func (jwk *jsonWebKey) thumbprint() string {
+	var canonical string
+	switch jwk.Kty {
+	case "EC":
+		canonical = fmt.Sprintf(`{"crv":%q,"kty":"EC","x":%q,"y":%q}`, jwk.Crv, jwk.X, jwk.Y)
+	case "OKP":
+		canonical = fmt.Sprintf(`{"crv":%q,"kty":"OKP","x":%q}`, jwk.Crv, jwk.X)
+	case "RSA":
+		canonical = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, jwk.E, jwk.N)
+	}
+	digest := sha256.Sum256([]byte(canonical))
+	return b64Encode(digest[:])
+}
+
+// newJSONWebKey 将公钥编码为JWK
+func newJSONWebKey(pub crypto.PublicKey) (*jsonWebKey, error) {
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		return &jsonWebKey{
+			Kty: "EC",
+			Crv: key.Curve.Params().Name,
+			X:   b64Encode(key.X.FillBytes(make([]byte, size))),
+			Y:   b64Encode(key.Y.FillBytes(make([]byte, size))),
+		}, nil
+	case ed25519.PublicKey:
+		return &jsonWebKey{Kty: "OKP", Crv: "Ed25519", X: b64Encode(key)}, nil
+	case *rsa.PublicKey:
+		return &jsonWebKey{
+			Kty: "RSA",
+			N:   b64Encode(key.N.Bytes()),
+			E:   b64Encode(big.NewInt(int64(key.E)).Bytes()),
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported ACME account key type %T", pub)
+}
+
+// ACMEKeyThumbprint 返回账户公钥的 RFC 7638 指纹，用于计算 keyAuthorization
+func ACMEKeyThumbprint(pub crypto.PublicKey) (string, error) {
+	jwk, err := newJSONWebKey(pub)
+	if err != nil {
+		return "", err
+	}
+	return jwk.thumbprint(), nil
+}
+
+// jwsAlgorithm 返回公钥对应的JWS算法与摘要算法，EdDSA 直接对签名输入签名，摘要算法为 0
+func jwsAlgorithm(pub crypto.PublicKey) (string, crypto.Hash, error) {
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return "ES256", crypto.SHA256, nil
+		case elliptic.P384():
+			return "ES384", crypto.SHA384, nil
+		}
+	case ed25519.PublicKey:
+		return "EdDSA", crypto.Hash(0), nil
+	case *rsa.PublicKey:
+		return "RS256", crypto.SHA256, nil
+	}
+	return "", 0, errJWSAlgorithm
+}
+
+// verifyJWSSignature 按 alg 验证JWS签名；ECDSA签名为定长 r||s
+func verifyJWSSignature(pub crypto.PublicKey, alg string, signingInput, signature []byte) error {
+	expected, hash, err := jwsAlgorithm(pub)
+	if err != nil || expected != alg {
+		return fmt.Errorf("%w: %s", errJWSAlgorithm, alg)
+	}
+	if key, ok := pub.(ed25519.PublicKey); ok {
+		if !ed25519.Verify(key, signingInput, signature) {
+			return fmt.Errorf("invalid %s signature", alg)
+		}
+		return nil
+	}
+	h := hash.New()
+	h.Write(signingInput)
+	digest := h.Sum(nil)
+
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return fmt.Errorf("invalid %s signature length", alg)
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return fmt.Errorf("invalid %s signature", alg)
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, hash, digest, signature); err != nil {
+			return fmt.Errorf("invalid %s signature", alg)
+		}
+	}
+	return nil
+}
+
+// SignACMERequest 用账户私钥生成ACME请求体；kid 为空时在保护头中携带JWK（仅用于 newAccount）。
+// payload 为 nil 时生成 POST-as-GET 请求
+func SignACMERequest(key crypto.Signer, kid, nonce, url string, payload []byte) ([]byte, error) {
+	alg, hash, err := jwsAlgorithm(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	header := acmeJWSHeader{Alg: alg, Nonce: nonce, URL: url, KID: kid}
+	if kid == "" {
+		jwk, err := newJSONWebKey(key.Public())
+		if err != nil {
+			return nil, err
+		}
+		if header.JWK, err = json.Marshal(jwk); err != nil {
+			return nil, err
+		}
+	}
+	protected, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+
+	jws := acmeJWS{Protected: b64Encode(protected), Payload: b64Encode(payload)}
+	signed := []byte(jws.Protected + "." + jws.Payload)
+	if hash != 0 {
+		h := hash.New()
+		h.Write(signed)
+		signed = h.Sum(nil)
+	}
+	signature, err := key.Sign(rand.Reader, signed, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign ACME request: %w", err)
+	}
+	// ECDSA 签名由ASN.1转换为JWS要求的定长 r||s
+	if ecKey, ok := key.Public().(*ecdsa.PublicKey); ok {
+		var sig struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(signature, &sig); err != nil {
+			return nil, fmt.Errorf("failed to parse ECDSA signature: %w", err)
+		}
+		size := (ecKey.Curve.Params().BitSize + 7) / 8
+		signature = append(sig.R.FillBytes(make([]byte, size)), sig.S.FillBytes(make([]byte, size))...)
+	}
+	jws.Signature = b64Encode(signature)
+	return json.Marshal(jws)
+}
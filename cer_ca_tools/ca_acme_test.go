@@ -0,0 +1,170 @@
+package cer_ca_tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/acme"
+)
+
+func TestACMEServer(t *testing.T) {
+	manager := NewCAManagerWithStore(NewMemoryStore())
+	ca, err := manager.CreateCA("ca_acme_test")
+	if err != nil {
+		t.Fatalf("create CA failed: %v", err)
+	}
+	acmeServer := NewACMEServer(manager, "ca_acme_test")
+	httpServer := httptest.NewServer(acmeServer)
+	defer httpServer.Close()
+	acmeServer.BaseURL = httpServer.URL
+
+	// http-01 替身：按 Host 头返回对应域名的 keyAuthorization
+	var responses sync.Map
+	standIn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.URL.Path, "/.well-known/acme-challenge/")
+		if keyAuth, ok := responses.Load(r.Host + "/" + token); ok {
+			w.Write([]byte(keyAuth.(string)))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer standIn.Close()
+	acmeServer.HTTP01Address = strings.TrimPrefix(standIn.URL, "http://")
+
+	ctx := context.Background()
+	accountKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	client := &acme.Client{Key: accountKey, DirectoryURL: acmeServer.DirectoryURL()}
+	if _, err := client.Register(ctx, &acme.Account{}, acme.AcceptTOS); err != nil {
+		t.Fatalf("register account failed: %v", err)
+	}
+
+	// 标准客户端：http-01 验证域名，证书主体匿名化
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs("service.example"))
+	if err != nil {
+		t.Fatalf("create order failed: %v", err)
+	}
+	authz, err := client.GetAuthorization(ctx, order.AuthzURLs[0])
+	if err != nil {
+		t.Fatalf("get authorization failed: %v", err)
+	}
+	challenge := authz.Challenges[0]
+	if challenge.Type != ACMEChallengeHTTP01 {
+		t.Fatalf("dns identifiers should be validated with http-01, have %s", challenge.Type)
+	}
+	keyAuth, _ := client.HTTP01ChallengeResponse(challenge.Token)
+	responses.Store("service.example/"+challenge.Token, keyAuth)
+	if _, err := client.Accept(ctx, challenge); err != nil {
+		t.Fatalf("accept challenge failed: %v", err)
+	}
+	if _, err := client.WaitAuthorization(ctx, authz.URI); err != nil {
+		t.Fatalf("authorization failed: %v", err)
+	}
+	if order, err = client.WaitOrder(ctx, order.URI); err != nil || order.Status != acme.StatusReady {
+		t.Fatalf("order should be ready: %v", err)
+	}
+	leafKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	csrDER, _ := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "service.example"},
+		DNSNames: []string{"service.example"},
+	}, leafKey)
+	chain, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csrDER, true)
+	if err != nil {
+		t.Fatalf("finalize order failed: %v", err)
+	}
+	leaf, _ := x509.ParseCertificate(chain[0])
+	if err := leaf.CheckSignatureFrom(ca.Certificate); err != nil {
+		t.Fatalf("certificate not issued by the CA: %v", err)
+	}
+	if !strings.HasPrefix(leaf.Subject.CommonName, "anonymous-") || len(leaf.DNSNames) != 1 || leaf.DNSNames[0] != "service.example" {
+		t.Fatalf("certificate should carry an anonymous subject and the validated name, have %s %v", leaf.Subject, leaf.DNSNames)
+	}
+
+	// anoncert-crt-01：提交 XOR 匿名化材料，证书主体与 /certificate/issue 相同
+	subjectInfo := pkix.Name{CommonName: "acme subject", Organization: []string{"Test Org"}}
+	subjectJSON, _ := json.Marshal(subjectInfo)
+	remainders := []*big.Int{big.NewInt(0x1234567), big.NewInt(0x89abcdef)}
+	xorResult := append([]byte{}, subjectJSON...)
+	for _, remainder := range remainders {
+		for i := range xorResult {
+			xorResult[i] ^= remainder.Bytes()[i%len(remainder.Bytes())]
+		}
+	}
+
+	anonOrder := func(xorResult []byte) *acme.Order {
+		order, err := client.AuthorizeOrder(ctx, []acme.AuthzID{{Type: ACMEIdentifierAnon, Value: "device"}})
+		if err != nil {
+			t.Fatalf("create anon order failed: %v", err)
+		}
+		authz, _ := client.GetAuthorization(ctx, order.AuthzURLs[0])
+		challenge := authz.Challenges[0]
+		if challenge.Type != ACMEChallengeAnonCRT {
+			t.Fatalf("anon identifiers should use %s, have %s", ACMEChallengeAnonCRT, challenge.Type)
+		}
+		thumbprint, _ := ACMEKeyThumbprint(accountKey.Public())
+		payload, _ := json.Marshal(ACMEAnonCRTResponse{
+			KeyAuthorization: challenge.Token + "." + thumbprint,
+			SubjectInfo:      subjectInfo,
+			XORResult:        xorResult,
+			Remainders:       remainders,
+		})
+		nonceResponse, _ := http.Head(httpServer.URL + "/acme/new-nonce")
+		body, _ := SignACMERequest(accountKey, string(client.KID), nonceResponse.Header.Get("Replay-Nonce"), challenge.URI, payload)
+		response, err := http.Post(challenge.URI, "application/jose+json", bytes.NewReader(body))
+		if err != nil || response.StatusCode != http.StatusOK {
+			t.Fatalf("respond to challenge failed: %v", err)
+		}
+		response.Body.Close()
+		order, _ = client.GetOrder(ctx, order.URI)
+		return order
+	}
+
+	tampered := append([]byte{}, xorResult...)
+	tampered[0] ^= 1
+	if order := anonOrder(tampered); order.Status != acme.StatusInvalid {
+		t.Fatalf("order with wrong XOR material should be invalid, have %s", order.Status)
+	}
+
+	submitted := append([]byte{}, xorResult...)
+	order = anonOrder(xorResult)
+	if order.Status != acme.StatusReady {
+		t.Fatalf("anon order should be ready, have %s", order.Status)
+	}
+	csrDER, _ = x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{}, leafKey)
+	chain, _, err = client.CreateOrderCert(ctx, order.FinalizeURL, csrDER, true)
+	if err != nil {
+		t.Fatalf("finalize anon order failed: %v", err)
+	}
+	leaf, _ = x509.ParseCertificate(chain[0])
+	// 假名由提交的XOR结果派生，不能由主体信息推出
+	if leaf.Subject.CommonName != derivedAnonymousSubject(submitted).CommonName || len(leaf.DNSNames) != 0 ||
+		leaf.Subject.CommonName == derivedAnonymousSubject(subjectJSON).CommonName {
+		t.Fatalf("anon certificate should use the subject derived from the XOR material, have %s", leaf.Subject)
+	}
+
+	// 重放的 nonce 被拒绝
+	nonceResponse, _ := http.Head(httpServer.URL + "/acme/new-nonce")
+	nonce := nonceResponse.Header.Get("Replay-Nonce")
+	for i, expected := range []int{http.StatusOK, http.StatusBadRequest} {
+		body, _ := SignACMERequest(accountKey, string(client.KID), nonce, string(client.KID), nil)
+		response, err := http.Post(string(client.KID), "application/jose+json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("post account failed: %v", err)
+		}
+		response.Body.Close()
+		if response.StatusCode != expected {
+			t.Fatalf("request %d with the same nonce: expected status %d, have %d", i, expected, response.StatusCode)
+		}
+	}
+}
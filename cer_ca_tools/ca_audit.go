@@ -0,0 +1,507 @@
+package cer_ca_tools
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// 审计事件类型
+const (
+	AuditEventCACreate = "ca.create"
+	AuditEventRollover = "ca.rollover"
+	AuditEventIssue    = "cert.issue"
+	AuditEventRevoke   = "cert.revoke"
+	AuditEventHold     = "cert.hold"
+	AuditEventUnhold   = "cert.unhold"
+	AuditEventModulus  = "modulus.handout"
+)
+
+var (
+	ErrAuditChainBroken = errors.New("audit log hash chain is broken")
+	ErrAuditSignature   = errors.New("audit entry signature verification failed")
+	ErrAuditAnchor      = errors.New("audit anchor does not match the log")
+)
+
+// AuditEntry 审计日志条目，Hash 覆盖除 Hash 与 Signature 外的全部字段（含上一条的 Hash），由执行操作的CA签名
+type AuditEntry struct {
+	Sequence  uint64            `json:"seq"`
+	Time      time.Time         `json:"time"`
+	Actor     string            `json:"actor"` // 执行操作的CA
+	Event     string            `json:"event"`
+	Serial    string            `json:"serial,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+	PrevHash  string            `json:"prev_hash"`
+	KeyID     string            `json:"key_id"` // 签名CA证书的主体密钥标识（十六进制）
+	Hash      string            `json:"hash"`
+	Signature []byte            `json:"signature"`
+}
+
+// AuditAnchorRecord 一次将日志头摘要锚定到区块链的记录
+type AuditAnchorRecord struct {
+	Sequence uint64    `json:"seq"`
+	Head     string    `json:"head"`
+	Time     time.Time `json:"time"`
+	Receipt  string    `json:"receipt"` // 交易哈希等链上凭据
+}
+
+// AuditAnchor 将日志头摘要写入外部可信存储（如 FISCO BCOS 链上合约）
+type AuditAnchor interface {
+	Anchor(sequence uint64, head string) (string, error)
+}
+
+// AuditAnchorLookup 读取外部可信存储中锚定的日志头摘要，未锚定时返回空字符串。ChainAuditAnchor 实现了该接口
+type AuditAnchorLookup interface {
+	Lookup(sequence uint64) (string, error)
+}
+
+// AuditExport 审计日志导出格式
+type AuditExport struct {
+	HeadSequence uint64              `json:"head_seq"`
+	Head         string              `json:"head"`
+	Entries      []*AuditEntry       `json:"entries"`
+	Anchors      []AuditAnchorRecord `json:"anchors,omitempty"`
+}
+
+// AuditLog 仅追加、哈希链接的审计日志；path 为空时只保存在内存中
+//
+// 每条记录以 JSON 行追加写入并立即落盘，锚定记录写入 path + ".anchors"。
+type AuditLog struct {
+	path    string
+	file    *os.File
+	entries []*AuditEntry
+	anchors []AuditAnchorRecord
+	lookup  AuditAnchorLookup // 周期锚定的目标支持查询时用于校验
+	mutex   sync.Mutex
+	stop    chan struct{}
+}
+
+// digest 计算条目摘要：对清空 Hash 与 Signature 后的 JSON 编码求 SHA-256
+func (entry *AuditEntry) digest() ([]byte, error) {
+	unsigned := *entry
+	unsigned.Hash, unsigned.Signature = "", nil
+	data, err := json.Marshal(&unsigned)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(data)
+	return digest[:], nil
+}
+
+// OpenAuditLog 打开审计日志并检查已有记录的哈希链，链断裂时返回 ErrAuditChainBroken
+func OpenAuditLog(path string) (*AuditLog, error) {
+	auditLog := &AuditLog{path: path}
+	if path == "" {
+		return auditLog, nil
+	}
+
+	entries, err := readAuditEntries(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAuditChain(entries); err != nil {
+		return nil, err
+	}
+	anchorData, err := os.ReadFile(path + ".anchors")
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read audit anchors: %w", err)
+	}
+	for _, line := range bytes.Split(anchorData, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var record AuditAnchorRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, fmt.Errorf("failed to parse audit anchor: %w", err)
+		}
+		auditLog.anchors = append(auditLog.anchors, record)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	auditLog.file = file
+	auditLog.entries = entries
+	return auditLog, nil
+}
+
+func readAuditEntries(path string) ([]*AuditEntry, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	var entries []*AuditEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%w: entry %d cannot be parsed: %v", ErrAuditChainBroken, len(entries), err)
+		}
+		entries = append(entries, &entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return entries, nil
+}
+
+// Append 追加一条由 signer 签名的审计记录
+func (auditLog *AuditLog) Append(signer crypto.Signer, keyID string, actor string, event string, serial string, details map[string]string) (*AuditEntry, error) {
+	if signer == nil {
+		return nil, fmt.Errorf("no signing key for audit entry of %s", actor)
+	}
+
+	auditLog.mutex.Lock()
+	defer auditLog.mutex.Unlock()
+
+	entry := &AuditEntry{
+		Sequence: uint64(len(auditLog.entries)),
+		Time:     time.Now().UTC(),
+		Actor:    actor,
+		Event:    event,
+		Serial:   serial,
+		Details:  details,
+		KeyID:    keyID,
+	}
+	if len(auditLog.entries) > 0 {
+		entry.PrevHash = auditLog.entries[len(auditLog.entries)-1].Hash
+	}
+	digest, err := entry.digest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash audit entry: %w", err)
+	}
+	entry.Hash = hex.EncodeToString(digest)
+	if entry.Signature, err = signDigest(signer, digest, crypto.SHA256); err != nil {
+		return nil, fmt.Errorf("failed to sign audit entry: %w", err)
+	}
+
+	if auditLog.file != nil {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode audit entry: %w", err)
+		}
+		if _, err := auditLog.file.Write(append(line, '\n')); err != nil {
+			return nil, fmt.Errorf("failed to write audit entry: %w", err)
+		}
+		if err := auditLog.file.Sync(); err != nil {
+			return nil, fmt.Errorf("failed to sync audit log: %w", err)
+		}
+	}
+	auditLog.entries = append(auditLog.entries, entry)
+	return entry, nil
+}
+
+// Head 返回日志头的序号与摘要，空日志返回 0 和空字符串
+func (auditLog *AuditLog) Head() (uint64, string) {
+	auditLog.mutex.Lock()
+	defer auditLog.mutex.Unlock()
+
+	if len(auditLog.entries) == 0 {
+		return 0, ""
+	}
+	head := auditLog.entries[len(auditLog.entries)-1]
+	return head.Sequence, head.Hash
+}
+
+// Entries 返回全部审计记录的副本
+func (auditLog *AuditLog) Entries() []*AuditEntry {
+	auditLog.mutex.Lock()
+	defer auditLog.mutex.Unlock()
+
+	return append([]*AuditEntry(nil), auditLog.entries...)
+}
+
+// Verify 校验哈希链、每条记录的签名与锚定记录，resolve 按密钥标识返回签名CA的公钥。
+// StartAnchoring 的锚定目标实现了 AuditAnchorLookup 时，同时确认外部存储中的日志头
+func (auditLog *AuditLog) Verify(resolve func(keyID string) crypto.PublicKey) error {
+	auditLog.mutex.Lock()
+	entries := append([]*AuditEntry(nil), auditLog.entries...)
+	anchors := append([]AuditAnchorRecord(nil), auditLog.anchors...)
+	lookup := auditLog.lookup
+	auditLog.mutex.Unlock()
+
+	if err := VerifyAuditEntries(entries, resolve); err != nil {
+		return err
+	}
+	return VerifyAuditAnchors(entries, anchors, lookup)
+}
+
+// VerifyAuditExport 校验导出的审计日志：记录本身、日志头与锚定记录，lookup 为 nil 时不查询外部存储
+func VerifyAuditExport(export *AuditExport, resolve func(keyID string) crypto.PublicKey, lookup AuditAnchorLookup) error {
+	if err := VerifyAuditEntries(export.Entries, resolve); err != nil {
+		return err
+	}
+	head := ""
+	if n := len(export.Entries); n > 0 {
+		head = export.Entries[n-1].Hash
+	}
+	if export.Head != head || head != "" && export.HeadSequence != uint64(len(export.Entries)-1) {
+		return fmt.Errorf("%w: head %d %s is not the last entry", ErrAuditChainBroken, export.HeadSequence, export.Head)
+	}
+	return VerifyAuditAnchors(export.Entries, export.Anchors, lookup)
+}
+
+// VerifyAuditAnchors 校验锚定记录指向已有的记录且摘要与该记录的 Hash 一致；
+// lookup 非空时还要求外部存储中锚定的摘要与锚定记录相同
+func VerifyAuditAnchors(entries []*AuditEntry, anchors []AuditAnchorRecord, lookup AuditAnchorLookup) error {
+	for _, anchor := range anchors {
+		if anchor.Sequence >= uint64(len(entries)) {
+			return fmt.Errorf("%w: anchored entry %d is past the end of the log (%d entries)", ErrAuditAnchor, anchor.Sequence, len(entries))
+		}
+		if entry := entries[anchor.Sequence]; entry.Sequence != anchor.Sequence || entry.Hash != anchor.Head {
+			return fmt.Errorf("%w: entry %d differs from the anchored head", ErrAuditAnchor, anchor.Sequence)
+		}
+		if lookup == nil {
+			continue
+		}
+		head, err := lookup.Lookup(anchor.Sequence)
+		if err != nil {
+			return fmt.Errorf("failed to look up anchor of entry %d: %w", anchor.Sequence, err)
+		}
+		if head != anchor.Head {
+			return fmt.Errorf("%w: entry %d is anchored as %q externally", ErrAuditAnchor, anchor.Sequence, head)
+		}
+	}
+	return nil
+}
+
+// VerifyAuditEntries 校验导出的审计记录：序号连续、摘要正确、逐条链接且签名有效
+func VerifyAuditEntries(entries []*AuditEntry, resolve func(keyID string) crypto.PublicKey) error {
+	if err := checkAuditChain(entries); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		publicKey := resolve(entry.KeyID)
+		if publicKey == nil {
+			return fmt.Errorf("%w: entry %d is signed by unknown key %s", ErrAuditSignature, entry.Sequence, entry.KeyID)
+		}
+		digest, _ := hex.DecodeString(entry.Hash)
+		if err := verifyDigestSignature(publicKey, digest, entry.Signature); err != nil {
+			return fmt.Errorf("%w: entry %d: %v", ErrAuditSignature, entry.Sequence, err)
+		}
+	}
+	return nil
+}
+
+// checkAuditChain 只检查序号、摘要与链接，不验证签名
+func checkAuditChain(entries []*AuditEntry) error {
+	prevHash := ""
+	for i, entry := range entries {
+		if entry.Sequence != uint64(i) {
+			return fmt.Errorf("%w: entry %d has sequence %d", ErrAuditChainBroken, i, entry.Sequence)
+		}
+		if entry.PrevHash != prevHash {
+			return fmt.Errorf("%w: entry %d does not link to its predecessor", ErrAuditChainBroken, i)
+		}
+		digest, err := entry.digest()
+		if err != nil || hex.EncodeToString(digest) != entry.Hash {
+			return fmt.Errorf("%w: entry %d has been modified", ErrAuditChainBroken, i)
+		}
+		prevHash = entry.Hash
+	}
+	return nil
+}
+
+// Export 以 JSON 导出全部记录、日志头与锚定记录
+func (auditLog *AuditLog) Export(w io.Writer) error {
+	auditLog.mutex.Lock()
+	export := AuditExport{
+		Entries: append([]*AuditEntry{}, auditLog.entries...),
+		Anchors: append([]AuditAnchorRecord(nil), auditLog.anchors...),
+	}
+	if len(auditLog.entries) > 0 {
+		head := auditLog.entries[len(auditLog.entries)-1]
+		export.HeadSequence, export.Head = head.Sequence, head.Hash
+	}
+	auditLog.mutex.Unlock()
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(export)
+}
+
+// Anchors 返回已完成的锚定记录
+func (auditLog *AuditLog) Anchors() []AuditAnchorRecord {
+	auditLog.mutex.Lock()
+	defer auditLog.mutex.Unlock()
+
+	return append([]AuditAnchorRecord(nil), auditLog.anchors...)
+}
+
+// AnchorHead 将当前日志头锚定一次；日志为空或日志头已锚定时不做任何事
+func (auditLog *AuditLog) AnchorHead(anchor AuditAnchor) error {
+	auditLog.mutex.Lock()
+	if len(auditLog.entries) == 0 {
+		auditLog.mutex.Unlock()
+		return nil
+	}
+	head := auditLog.entries[len(auditLog.entries)-1]
+	if n := len(auditLog.anchors); n > 0 && auditLog.anchors[n-1].Head == head.Hash {
+		auditLog.mutex.Unlock()
+		return nil
+	}
+	auditLog.mutex.Unlock()
+
+	// 上链可能较慢，不持有锁
+	receipt, err := anchor.Anchor(head.Sequence, head.Hash)
+	if err != nil {
+		return fmt.Errorf("failed to anchor audit head %d: %w", head.Sequence, err)
+	}
+	record := AuditAnchorRecord{Sequence: head.Sequence, Head: head.Hash, Time: time.Now().UTC(), Receipt: receipt}
+
+	auditLog.mutex.Lock()
+	defer auditLog.mutex.Unlock()
+
+	if auditLog.path != "" {
+		line, _ := json.Marshal(record)
+		file, err := os.OpenFile(auditLog.path+".anchors", os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return fmt.Errorf("failed to open audit anchors: %w", err)
+		}
+		defer file.Close()
+		if _, err := file.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("failed to write audit anchor: %w", err)
+		}
+	}
+	auditLog.anchors = append(auditLog.anchors, record)
+	log.Printf("audit log head %d anchored: %s", record.Sequence, receipt)
+	return nil
+}
+
+// StartAnchoring 每隔 interval 将日志头锚定一次，直到 Close。anchor 实现了 AuditAnchorLookup 时，Verify 会查询已锚定的日志头
+func (auditLog *AuditLog) StartAnchoring(anchor AuditAnchor, interval time.Duration) {
+	auditLog.mutex.Lock()
+	if auditLog.stop != nil {
+		auditLog.mutex.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	auditLog.stop = stop
+	if lookup, ok := anchor.(AuditAnchorLookup); ok {
+		auditLog.lookup = lookup
+	}
+	auditLog.mutex.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := auditLog.AnchorHead(anchor); err != nil {
+					log.Printf("%v", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close 停止周期锚定并关闭日志文件
+func (auditLog *AuditLog) Close() error {
+	auditLog.mutex.Lock()
+	defer auditLog.mutex.Unlock()
+
+	if auditLog.stop != nil {
+		close(auditLog.stop)
+		auditLog.stop = nil
+	}
+	if auditLog.file == nil {
+		return nil
+	}
+	err := auditLog.file.Close()
+	auditLog.file = nil
+	return err
+}
+
+// AuditKeyID 返回CA证书的密钥标识，用于审计记录的 KeyID
+func AuditKeyID(caCert *x509.Certificate) string {
+	return hex.EncodeToString(caCert.SubjectKeyId)
+}
+
+// EnableAuditLog 打开审计日志，此后CA的创建、签发、撤销、密钥轮换与模数分发都会记录到日志
+func (manager *CAManager) EnableAuditLog(path string) error {
+	auditLog, err := OpenAuditLog(path)
+	if err != nil {
+		return err
+	}
+
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	manager.Audit = auditLog
+	for _, ca := range manager.CAs {
+		ca.audit = auditLog
+	}
+	return nil
+}
+
+// VerifyAuditLog 用各CA当前与已轮换的证书校验审计日志
+func (manager *CAManager) VerifyAuditLog() error {
+	manager.mutex.RLock()
+	auditLog := manager.Audit
+	manager.mutex.RUnlock()
+
+	if auditLog == nil {
+		return fmt.Errorf("audit log is not enabled")
+	}
+	return auditLog.Verify(manager.auditPublicKey)
+}
+
+// auditPublicKey 按密钥标识查找CA公钥，包括已轮换的旧密钥
+func (manager *CAManager) auditPublicKey(keyID string) crypto.PublicKey {
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+
+	for _, ca := range manager.CAs {
+		if AuditKeyID(ca.Certificate) == keyID {
+			return ca.Certificate.PublicKey
+		}
+		for _, retired := range ca.RetiredKeys {
+			if retired.KeyID() == keyID {
+				return retired.Certificate.PublicKey
+			}
+		}
+	}
+	return nil
+}
+
+// recordAudit 以CA当前密钥签名并追加审计记录，未启用审计日志时不做任何事。
+// 审计记录先于操作写入：调用方在持久化操作结果之前调用，返回错误时放弃该操作
+func (ca *CA) recordAudit(event string, serial string, details map[string]string) error {
+	return ca.recordAuditWithKey(ca.Certificate, ca.PrivateKey, event, serial, details)
+}
+
+// recordAuditWithKey 以指定的CA证书与私钥签名审计记录，用于在新密钥生效之前记录密钥轮换
+func (ca *CA) recordAuditWithKey(cert *x509.Certificate, key crypto.Signer, event string, serial string, details map[string]string) error {
+	if ca.audit == nil {
+		return nil
+	}
+	_, err := ca.audit.Append(key, AuditKeyID(cert), ca.Name.CommonName, event, serial, details)
+	if err != nil {
+		log.Printf("CA %s failed to record %s audit entry: %v", ca.Name.CommonName, event, err)
+	}
+	return err
+}
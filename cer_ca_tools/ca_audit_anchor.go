@@ -0,0 +1,77 @@
+package cer_ca_tools
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/FISCO-BCOS/go-sdk/abi"
+	"github.com/FISCO-BCOS/go-sdk/abi/bind"
+	"github.com/FISCO-BCOS/go-sdk/client"
+	"github.com/FISCO-BCOS/go-sdk/core/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// auditAnchorABI 与 helloworld 的 CertOperKV 合约兼容的 set/get 接口
+const auditAnchorABI = `[{"inputs":[{"internalType":"bytes32","name":"oper_id","type":"bytes32"}],"name":"get","outputs":[{"internalType":"string","name":"","type":"string"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"bytes32","name":"oper_id","type":"bytes32"},{"internalType":"string","name":"value","type":"string"}],"name":"set","outputs":[],"stateMutability":"nonpayable","type":"function"}]`
+
+// ChainAuditAnchor 通过 SDK client 将审计日志头写入链上 KV 合约（CertOperKV）
+type ChainAuditAnchor struct {
+	client   *client.Client
+	contract *bind.BoundContract
+}
+
+type chainAuditHead struct {
+	Sequence uint64 `json:"seq"`
+	Head     string `json:"head"`
+}
+
+// NewChainAuditAnchor 绑定已部署在 address 的 KV 合约
+func NewChainAuditAnchor(c *client.Client, address common.Address) (*ChainAuditAnchor, error) {
+	parsed, err := abi.JSON(strings.NewReader(auditAnchorABI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse audit anchor ABI: %w", err)
+	}
+	return &ChainAuditAnchor{
+		client:   c,
+		contract: bind.NewBoundContract(address, parsed, c, c, c),
+	}, nil
+}
+
+// auditAnchorKey 日志头在合约中的键
+func auditAnchorKey(sequence uint64) [32]byte {
+	return sha256.Sum256([]byte(fmt.Sprintf("anoncert-audit-head:%d", sequence)))
+}
+
+// Anchor 将第 sequence 条记录的摘要写入链上，返回交易哈希
+func (anchor *ChainAuditAnchor) Anchor(sequence uint64, head string) (string, error) {
+	value, err := json.Marshal(chainAuditHead{Sequence: sequence, Head: head})
+	if err != nil {
+		return "", err
+	}
+	tx, receipt, err := anchor.contract.Transact(anchor.client.GetTransactOpts(), "set", auditAnchorKey(sequence), string(value))
+	if err != nil {
+		return "", fmt.Errorf("failed to send audit anchor transaction: %w", err)
+	}
+	if receipt.Status != types.Success {
+		return "", fmt.Errorf("audit anchor transaction failed: %s", receipt.GetErrorMessage())
+	}
+	return tx.Hash().Hex(), nil
+}
+
+// Lookup 读取链上锚定的第 sequence 条记录摘要，未锚定时返回空字符串
+func (anchor *ChainAuditAnchor) Lookup(sequence uint64) (string, error) {
+	var value string
+	if err := anchor.contract.Call(anchor.client.GetCallOpts(), &value, "get", auditAnchorKey(sequence)); err != nil {
+		return "", fmt.Errorf("failed to read audit anchor: %w", err)
+	}
+	if value == "" {
+		return "", nil
+	}
+	var record chainAuditHead
+	if err := json.Unmarshal([]byte(value), &record); err != nil {
+		return "", fmt.Errorf("invalid audit anchor on chain: %w", err)
+	}
+	return record.Head, nil
+}
@@ -0,0 +1,219 @@
+package cer_ca_tools
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeAuditAnchor struct {
+	heads map[uint64]string
+}
+
+func (anchor *fakeAuditAnchor) Anchor(sequence uint64, head string) (string, error) {
+	anchor.heads[sequence] = head
+	return fmt.Sprintf("tx-%d", sequence), nil
+}
+
+func (anchor *fakeAuditAnchor) Lookup(sequence uint64) (string, error) {
+	return anchor.heads[sequence], nil
+}
+
+func TestAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	manager := NewCAManagerWithStore(NewMemoryStore())
+	if err := manager.EnableAuditLog(path); err != nil {
+		t.Fatalf("enable audit log failed: %v", err)
+	}
+	ca, err := manager.CreateCA("ca_audit_test")
+	if err != nil {
+		t.Fatalf("create CA failed: %v", err)
+	}
+
+	subjectKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	response := ca.IssueCertificate(pkix.Name{CommonName: "audit subject"}, &subjectKey.PublicKey)
+	if !response.Success {
+		t.Fatalf("issue certificate failed: %s", response.Message)
+	}
+	cert := parseTestCertificate(t, response.Certificate)
+	if response := ca.RevokeCertificate("ca_audit_test", cert.SerialNumber.String(), 1); !response.Success {
+		t.Fatalf("revoke certificate failed: %s", response.Message)
+	}
+	// 轮换后旧密钥签名的记录仍可验证
+	if _, err := manager.RolloverCA("ca_audit_test", time.Hour); err != nil {
+		t.Fatalf("rollover failed: %v", err)
+	}
+
+	entries := manager.Audit.Entries()
+	var events []string
+	for _, entry := range entries {
+		events = append(events, entry.Event)
+	}
+	expected := []string{AuditEventCACreate, AuditEventIssue, AuditEventRevoke, AuditEventRollover}
+	if strings.Join(events, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected events %v, have %v", expected, events)
+	}
+	if entries[3].KeyID == entries[2].KeyID {
+		t.Fatalf("rollover entry should be signed with the new key")
+	}
+	if err := manager.VerifyAuditLog(); err != nil {
+		t.Fatalf("verify audit log failed: %v", err)
+	}
+
+	// 锚定日志头，日志头未变化时不重复锚定
+	anchor := &fakeAuditAnchor{heads: make(map[uint64]string)}
+	for i := 0; i < 2; i++ {
+		if err := manager.Audit.AnchorHead(anchor); err != nil {
+			t.Fatalf("anchor audit head failed: %v", err)
+		}
+	}
+	sequence, head := manager.Audit.Head()
+	if len(anchor.heads) != 1 || anchor.heads[sequence] != head {
+		t.Fatalf("head should be anchored exactly once, have %v", anchor.heads)
+	}
+	manager.Audit.Close()
+
+	// 重新打开后记录与锚定都被恢复
+	reopened, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatalf("reopen audit log failed: %v", err)
+	}
+	if reopenedSequence, reopenedHead := reopened.Head(); reopenedSequence != sequence || reopenedHead != head {
+		t.Fatalf("reopened head %d %s, expected %d %s", reopenedSequence, reopenedHead, sequence, head)
+	}
+	if anchors := reopened.Anchors(); len(anchors) != 1 || anchors[0].Receipt != fmt.Sprintf("tx-%d", sequence) {
+		t.Fatalf("anchors were not restored: %v", anchors)
+	}
+	if err := reopened.Verify(manager.auditPublicKey); err != nil {
+		t.Fatalf("verify reopened audit log failed: %v", err)
+	}
+
+	// 锚定记录必须与日志及链上的日志头一致
+	var exported bytes.Buffer
+	var export AuditExport
+	if err := reopened.Export(&exported); err != nil || json.Unmarshal(exported.Bytes(), &export) != nil {
+		t.Fatalf("export audit log failed: %v", err)
+	}
+	if err := VerifyAuditExport(&export, manager.auditPublicKey, anchor); err != nil {
+		t.Fatalf("verify exported audit log failed: %v", err)
+	}
+	reopened.Close()
+	anchors := export.Anchors
+	for name, test := range map[string]struct {
+		entries []*AuditEntry
+		anchors []AuditAnchorRecord
+		lookup  AuditAnchorLookup
+	}{
+		"truncated log":    {export.Entries[:sequence], anchors, nil},
+		"rewritten head":   {export.Entries, []AuditAnchorRecord{{Sequence: sequence, Head: export.Entries[0].Hash}}, nil},
+		"not on chain":     {export.Entries, anchors, &fakeAuditAnchor{heads: map[uint64]string{}}},
+		"forged on chain":  {export.Entries, anchors, &fakeAuditAnchor{heads: map[uint64]string{sequence: "forged"}}},
+		"anchor past head": {export.Entries, append(anchors, AuditAnchorRecord{Sequence: sequence + 1, Head: head}), nil},
+	} {
+		if err := VerifyAuditAnchors(test.entries, test.anchors, test.lookup); !errors.Is(err, ErrAuditAnchor) {
+			t.Fatalf("%s: anchors should be rejected, have %v", name, err)
+		}
+	}
+
+	// 篡改任一记录都会使哈希链断裂
+	data, _ := os.ReadFile(path)
+	tampered := strings.Replace(string(data), `"reason":"1"`, `"reason":"4"`, 1)
+	if tampered == string(data) {
+		t.Fatalf("revocation entry not found in the audit log")
+	}
+	os.WriteFile(path, []byte(tampered), 0o600)
+	if _, err := OpenAuditLog(path); !errors.Is(err, ErrAuditChainBroken) {
+		t.Fatalf("tampered audit log should be rejected, have %v", err)
+	}
+
+	// 签名密钥未知时校验失败
+	if err := VerifyAuditEntries(entries, func(string) crypto.PublicKey { return nil }); !errors.Is(err, ErrAuditSignature) {
+		t.Fatalf("entries signed by unknown keys should be rejected, have %v", err)
+	}
+}
+
+func TestAuditFailureAbortsOperation(t *testing.T) {
+	manager := NewCAManagerWithStore(NewMemoryStore())
+	if err := manager.EnableAuditLog(filepath.Join(t.TempDir(), "audit.log")); err != nil {
+		t.Fatalf("enable audit log failed: %v", err)
+	}
+	ca, err := manager.CreateCA("ca_audit_failure_test")
+	if err != nil {
+		t.Fatalf("create CA failed: %v", err)
+	}
+	held := issueTestCert(t, ca, "anonymous-test")
+	if response := ca.RevokeCertificate(ca.Name.CommonName, held.SerialNumber.String(), ReasonCertificateHold); !response.Success {
+		t.Fatalf("hold certificate failed: %s", response.Message)
+	}
+	valid := issueTestCert(t, ca, "anonymous-test")
+
+	// 日志文件不可写后，任何操作都不生效
+	manager.Audit.file.Close()
+	issued := len(ca.IssuedCerts)
+	subjectKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if response := ca.IssueCertificate(pkix.Name{CommonName: "unaudited"}, &subjectKey.PublicKey); response.Success || len(ca.IssuedCerts) != issued {
+		t.Fatalf("issuance without audit entry should be aborted")
+	}
+	if response := ca.RevokeCertificate(ca.Name.CommonName, valid.SerialNumber.String(), ReasonKeyCompromise); response.Success {
+		t.Fatalf("revocation without audit entry should fail")
+	}
+	if _, revoked := ca.revocation(valid.SerialNumber.String()); revoked {
+		t.Fatalf("revocation without audit entry should not take effect")
+	}
+	if response := ca.ReleaseCertificateHold(ca.Name.CommonName, held.SerialNumber.String()); response.Success {
+		t.Fatalf("hold release without audit entry should fail")
+	}
+	if current, _ := ca.revocation(held.SerialNumber.String()); revocationReason(current) != ReasonCertificateHold {
+		t.Fatalf("hold release without audit entry should not take effect")
+	}
+	if _, err := manager.CreateCA("ca_audit_failure_unaudited"); err == nil {
+		t.Fatalf("CA creation without audit entry should fail")
+	}
+	if _, exists := manager.GetCAInfo("ca_audit_failure_unaudited"); exists {
+		t.Fatalf("CA creation without audit entry should not take effect")
+	}
+}
+
+func TestAuditHandlersWhileEnabling(t *testing.T) {
+	manager := NewCAManagerWithStore(NewMemoryStore())
+	handler := manager.Handler()
+	done := make(chan error)
+	go func() {
+		done <- manager.EnableAuditLog(filepath.Join(t.TempDir(), "audit.log"))
+	}()
+
+	// 启用审计日志的同时请求导出与校验，两个接口只能看到未启用或已启用的日志
+	for enabled := false; !enabled; {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("enable audit log failed: %v", err)
+			}
+			enabled = true
+		default:
+		}
+		for _, path := range []string{"/certificate/audit/export", "/certificate/audit/verify"} {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+			if recorder.Code != http.StatusOK && recorder.Code != http.StatusNotFound {
+				t.Fatalf("GET %s: unexpected status %d", path, recorder.Code)
+			}
+			if enabled && recorder.Code != http.StatusOK {
+				t.Fatalf("GET %s should succeed once the audit log is enabled, have %d", path, recorder.Code)
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package cer_ca_tools
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"runtime"
+	"sync"
+)
+
+// MaxBatchIssueSize 单次批量签发的最大请求数
+const MaxBatchIssueSize = 1000
+
+var (
+	ErrBatchTooLarge   = fmt.Errorf("batch exceeds %d requests", MaxBatchIssueSize)
+	ErrXORVerification = errors.New("subject XOR/CRT verification failed")
+)
+
+// BatchIssueItem 批量签发中的一项，字段与 /certificate/issue 的请求体相同
+type BatchIssueItem struct {
+	SubjectInfo pkix.Name  `json:"subject"`
+	CSR         []byte     `json:"csr"`
+	XORResult   []byte     `json:"xor_result"`
+	Remainders  []*big.Int `json:"remainders"`
+}
+
+// BatchIssueRequest 批量签发请求，所有请求使用同一CA与证书模板
+type BatchIssueRequest struct {
+	Items []BatchIssueItem `json:"items"`
+}
+
+// BatchIssueResponse 批量签发结果，Results 与请求按下标一一对应
+type BatchIssueResponse struct {
+	Success bool                  `json:"success"` // 全部签发成功
+	Message string                `json:"message"`
+	Results []CertificateResponse `json:"results"`
+}
+
+// IssueBatch 并发校验每一项的 XOR/CRT 材料与CSR并签发证书，逐项返回结果，单项失败不影响其他项
+//
+// 并发度为 GOMAXPROCS，签名不持有CA锁，只在更新已签发证书索引时短暂加锁。
+func (manager *CAManager) IssueBatch(caName string, profileName string, items []BatchIssueItem) ([]CertificateResponse, error) {
+	return manager.IssueBatchContext(context.Background(), caName, profileName, items)
+}
+
+// IssueBatchContext 同 IssueBatch，ctx 取消或超时后尚未开始的项以 ctx 的错误失败
+func (manager *CAManager) IssueBatchContext(ctx context.Context, caName string, profileName string, items []BatchIssueItem) ([]CertificateResponse, error) {
+	if len(items) > MaxBatchIssueSize {
+		return nil, ErrBatchTooLarge
+	}
+	ca, exists := manager.GetCAInfo(caName)
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrCANotFound, caName)
+	}
+
+	results := make([]CertificateResponse, len(items))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < runtime.GOMAXPROCS(0) && worker < len(items); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := ctx.Err(); err != nil {
+					results[i] = batchItemFailure(err)
+					continue
+				}
+				results[i] = manager.issueBatchItem(ca, profileName, &items[i])
+			}
+		}()
+	}
+	for i := range items {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	issued := 0
+	for _, result := range results {
+		if result.Success {
+			issued++
+		}
+	}
+	log.Printf("CA %s issued %d of %d certificates in batch", caName, issued, len(items))
+	return results, nil
+}
+
+func (manager *CAManager) issueBatchItem(ca *CA, profileName string, item *BatchIssueItem) CertificateResponse {
+	subject, csr, err := manager.verifyAnonymousRequest(item)
+	if err != nil {
+		return batchItemFailure(err)
+	}
+	return ca.IssueCertificateFromCSR(profileName, subject, csr)
+}
+
+// verifyAnonymousRequest 解析CSR并校验 XOR/CRT 材料，返回随机生成的匿名证书主体
+func (manager *CAManager) verifyAnonymousRequest(item *BatchIssueItem) (pkix.Name, *x509.CertificateRequest, error) {
+	if len(item.CSR) == 0 {
+		return pkix.Name{}, nil, fmt.Errorf("%w: csr is required", ErrCSRMalformed)
+	}
+	csr, err := ParseCSR(item.CSR)
+	if err != nil {
+		return pkix.Name{}, nil, err
+	}
+	if !manager.VerifyXORResult(item.XORResult, item.SubjectInfo, item.Remainders) {
+		return pkix.Name{}, nil, ErrXORVerification
+	}
+	subject, err := anonymousSubject()
+	if err != nil {
+		return pkix.Name{}, nil, err
+	}
+	return subject, csr, nil
+}
+
+func batchItemFailure(err error) CertificateResponse {
+	return CertificateResponse{
+		Success: false,
+		Message: err.Error(),
+		Err:     err,
+	}
+}
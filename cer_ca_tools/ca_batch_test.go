@@ -0,0 +1,117 @@
+package cer_ca_tools
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIssueBatch(t *testing.T) {
+	manager := NewCAManagerWithStore(NewMemoryStore())
+	ca, err := manager.CreateCA("ca_batch_test")
+	if err != nil {
+		t.Fatalf("create CA failed: %v", err)
+	}
+
+	newItem := func(i int) BatchIssueItem {
+		subject := pkix.Name{CommonName: fmt.Sprintf("batch subject %d", i)}
+		subjectJSON, _ := json.Marshal(subject)
+		remainders := []*big.Int{big.NewInt(int64(1000 + i)), big.NewInt(int64(7 + i))}
+		xorResult := append([]byte{}, subjectJSON...)
+		for _, remainder := range remainders {
+			for j := range xorResult {
+				xorResult[j] ^= remainder.Bytes()[j%len(remainder.Bytes())]
+			}
+		}
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		csrDER, _ := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{}, key)
+		return BatchIssueItem{SubjectInfo: subject, CSR: csrDER, XORResult: xorResult, Remainders: remainders}
+	}
+
+	items := make([]BatchIssueItem, 32)
+	for i := range items {
+		items[i] = newItem(i)
+	}
+	items[3].XORResult[0] ^= 0xff
+	items[5].CSR = items[5].CSR[:10]
+	items[7].Remainders = append(items[7].Remainders, big.NewInt(0))
+	// 没有余数时明文主体信息不能通过验证
+	items[9].XORResult, _ = json.Marshal(items[9].SubjectInfo)
+	items[9].Remainders = nil
+	items[11].Remainders = append(items[11].Remainders, nil)
+	submitted := make([][]byte, len(items))
+	for i := range items {
+		submitted[i] = bytes.Clone(items[i].XORResult)
+	}
+
+	// 批量签发与密钥轮换并发进行，轮换等待进行中的签发完成
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, err := manager.RolloverCA("ca_batch_test", time.Hour); err != nil {
+			t.Errorf("rollover failed: %v", err)
+		}
+	}()
+	results, err := manager.IssueBatch("ca_batch_test", "", items)
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("issue batch failed: %v", err)
+	}
+	if len(results) != len(items) {
+		t.Fatalf("expected %d results, have %d", len(items), len(results))
+	}
+
+	serials := make(map[string]bool)
+	names := make(map[string]bool)
+	for i, result := range results {
+		if !bytes.Equal(items[i].XORResult, submitted[i]) {
+			t.Fatalf("item %d XOR result was modified during verification", i)
+		}
+		switch i {
+		case 3, 7, 9, 11:
+			if result.Success || !errors.Is(result.Err, ErrXORVerification) {
+				t.Fatalf("item %d with wrong XOR material should fail, have %v", i, result.Err)
+			}
+			continue
+		case 5:
+			if result.Success || !errors.Is(result.Err, ErrCSRMalformed) {
+				t.Fatalf("item %d with malformed CSR should fail, have %v", i, result.Err)
+			}
+			continue
+		}
+		if !result.Success {
+			t.Fatalf("item %d failed: %s", i, result.Message)
+		}
+		cert := parseTestCertificate(t, result.Certificate)
+		// 假名随机生成，不能由主体信息推出
+		subjectJSON, _ := json.Marshal(items[i].SubjectInfo)
+		identityDigest := sha256.Sum256(subjectJSON)
+		if !strings.HasPrefix(cert.Subject.CommonName, "anonymous-") || names[cert.Subject.CommonName] ||
+			strings.Contains(cert.Subject.CommonName, hex.EncodeToString(identityDigest[:16])) {
+			t.Fatalf("item %d has subject %s", i, cert.Subject.CommonName)
+		}
+		names[cert.Subject.CommonName] = true
+		serials[cert.SerialNumber.String()] = true
+	}
+	if len(serials) != len(items)-5 || len(ca.IssuedCerts) != len(serials) {
+		t.Fatalf("expected %d distinct issued certificates, have %d serials and %d indexed", len(items)-5, len(serials), len(ca.IssuedCerts))
+	}
+
+	if _, err := manager.IssueBatch("ca_batch_test", "", make([]BatchIssueItem, MaxBatchIssueSize+1)); !errors.Is(err, ErrBatchTooLarge) {
+		t.Fatalf("oversized batch should be rejected, have %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+package cer_ca_tools
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"math/big"
+	"time"
+)
+
+// FilterCascade CRLite 式的布隆过滤器级联。第 0 层包含被撤销集合 R，第 1 层包含第 0 层对有效集合 S 的误判，
+// 第 2 层包含第 1 层对 R 的误判，依此类推，直到某一层没有误判。对构建时 R ∪ S 中的元素，查询结果是精确的；
+// 对集合之外的元素（如其他CA签发或已过期的证书）结果没有意义。
+//
+// 二进制格式（大端）：magic "FCS" | version(1) | layers(1) | 每层 hashCount(1) bits(8) 位数组 | crc32(4)
+type FilterCascade struct {
+	layers []*bloomLayer
+}
+
+// bloomLayer 级联中的一层普通布隆过滤器，下标由加入层号的 SHA-256 双重哈希得到，各层误判相互独立
+type bloomLayer struct {
+	bits      []uint64
+	size      uint64 // 位数
+	hashCount uint
+	salt      uint32
+}
+
+const (
+	FilterCascadeFormatVersion = 1
+
+	// cascadeMaxLayers 层数上限；元素互不相同时每层误判约减半，实际层数远小于该值
+	cascadeMaxLayers = 64
+	// cascadeLayerRate 第 1 层之后各层的误判率
+	cascadeLayerRate = 0.5
+)
+
+var cascadeMagic = [3]byte{'F', 'C', 'S'}
+
+var (
+	ErrCascadeOverlap     = errors.New("revoked and valid sets overlap")
+	ErrCascadeTooDeep     = errors.New("filter cascade does not converge")
+	ErrInvalidCascadeData = errors.New("invalid filter cascade data")
+)
+
+func newBloomLayer(elements int, falsePositiveRate float64, salt uint32) *bloomLayer {
+	size := uint64(calculateOptimalSize(uint(max(elements, 1)), falsePositiveRate))
+	hashCount := max(uint(math.Round(-math.Log2(falsePositiveRate))), 1)
+	return &bloomLayer{
+		bits:      make([]uint64, (size+63)/64),
+		size:      size,
+		hashCount: hashCount,
+		salt:      salt,
+	}
+}
+
+func (layer *bloomLayer) indexes(data []byte, visit func(index uint64) bool) bool {
+	hash := sha256.New()
+	var salt [4]byte
+	binary.BigEndian.PutUint32(salt[:], layer.salt)
+	hash.Write(salt[:])
+	hash.Write(data)
+	var sum [sha256.Size]byte
+	hash.Sum(sum[:0])
+
+	h1 := binary.BigEndian.Uint64(sum[:8])
+	h2 := binary.BigEndian.Uint64(sum[8:16]) | 1
+	for i := uint64(0); i < uint64(layer.hashCount); i++ {
+		if !visit((h1 + i*h2) % layer.size) {
+			return false
+		}
+	}
+	return true
+}
+
+func (layer *bloomLayer) add(data []byte) {
+	layer.indexes(data, func(index uint64) bool {
+		layer.bits[index/64] |= 1 << (index % 64)
+		return true
+	})
+}
+
+func (layer *bloomLayer) contains(data []byte) bool {
+	return layer.indexes(data, func(index uint64) bool {
+		return layer.bits[index/64]&(1<<(index%64)) != 0
+	})
+}
+
+// BuildFilterCascade 由被撤销集合与有效集合构建级联，两个集合不能有公共元素
+func BuildFilterCascade(revoked, valid [][]byte) (*FilterCascade, error) {
+	seen := make(map[string]bool, len(revoked))
+	for _, element := range revoked {
+		seen[string(element)] = true
+	}
+	for _, element := range valid {
+		if seen[string(element)] {
+			return nil, fmt.Errorf("%w: %q", ErrCascadeOverlap, element)
+		}
+	}
+
+	// 第 0 层误判率取 CRLite 建议的 |R|·√2/|S|，使级联总大小最小
+	rate := cascadeLayerRate
+	if len(valid) > 0 {
+		rate = min(float64(len(revoked))*math.Sqrt2/float64(len(valid)), cascadeLayerRate)
+	}
+	rate = max(rate, 1e-9)
+
+	cascade := &FilterCascade{}
+	include, exclude := revoked, valid
+	for len(include) > 0 {
+		if len(cascade.layers) == cascadeMaxLayers {
+			return nil, ErrCascadeTooDeep
+		}
+		layer := newBloomLayer(len(include), rate, uint32(len(cascade.layers)))
+		for _, element := range include {
+			layer.add(element)
+		}
+		cascade.layers = append(cascade.layers, layer)
+
+		var falsePositives [][]byte
+		for _, element := range exclude {
+			if layer.contains(element) {
+				falsePositives = append(falsePositives, element)
+			}
+		}
+		include, exclude = falsePositives, include
+		rate = cascadeLayerRate
+	}
+	return cascade, nil
+}
+
+// QueryElement 返回元素是否属于被撤销集合，对构建时的全部元素结果精确
+func (cascade *FilterCascade) QueryElement(data []byte) bool {
+	for i, layer := range cascade.layers {
+		if !layer.contains(data) {
+			// 不在第 i 层：偶数层对应被撤销集合，奇数层对应有效集合
+			return i%2 == 1
+		}
+	}
+	return len(cascade.layers)%2 == 1
+}
+
+// IsRevoked 按序列号查询证书是否被撤销，元素与撤销过滤器一致为十进制序列号
+func (cascade *FilterCascade) IsRevoked(serial *big.Int) bool {
+	return cascade.QueryElement(filterKey(serial))
+}
+
+// Layers 返回级联层数
+func (cascade *FilterCascade) Layers() int {
+	return len(cascade.layers)
+}
+
+// GetMemoryUsage 返回各层位数组占用的字节数
+func (cascade *FilterCascade) GetMemoryUsage() uint {
+	usage := uint(0)
+	for _, layer := range cascade.layers {
+		usage += uint(len(layer.bits)) * 8
+	}
+	return usage
+}
+
+// MarshalBinary 实现 encoding.BinaryMarshaler，位数组按字节紧凑编码
+func (cascade *FilterCascade) MarshalBinary() ([]byte, error) {
+	data := append(cascadeMagic[:], FilterCascadeFormatVersion, byte(len(cascade.layers)))
+	for _, layer := range cascade.layers {
+		data = append(data, byte(layer.hashCount))
+		data = binary.BigEndian.AppendUint64(data, layer.size)
+		for i := uint64(0); i < (layer.size+7)/8; i++ {
+			data = append(data, byte(layer.bits[i/8]>>(i%8*8)))
+		}
+	}
+	return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(data)), nil
+}
+
+// UnmarshalBinary 实现 encoding.BinaryUnmarshaler
+func (cascade *FilterCascade) UnmarshalBinary(data []byte) error {
+	if len(data) < 5+cbfChecksumSize || [3]byte(data[:3]) != cascadeMagic {
+		return ErrInvalidCascadeData
+	}
+	if data[3] != FilterCascadeFormatVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidCascadeData, data[3])
+	}
+	body := data[:len(data)-cbfChecksumSize]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(data[len(body):]) {
+		return fmt.Errorf("%w: checksum mismatch", ErrInvalidCascadeData)
+	}
+
+	count := int(body[4])
+	if count > cascadeMaxLayers {
+		return fmt.Errorf("%w: %d layers", ErrInvalidCascadeData, count)
+	}
+	body = body[5:]
+	layers := make([]*bloomLayer, 0, count)
+	for i := 0; i < count; i++ {
+		if len(body) < 9 {
+			return fmt.Errorf("%w: truncated layer %d", ErrInvalidCascadeData, i)
+		}
+		hashCount := uint(body[0])
+		size := binary.BigEndian.Uint64(body[1:9])
+		body = body[9:]
+		length := (size + 7) / 8
+		if hashCount == 0 || size == 0 || length > uint64(len(body)) {
+			return fmt.Errorf("%w: bad layer %d", ErrInvalidCascadeData, i)
+		}
+		layer := &bloomLayer{bits: make([]uint64, (size+63)/64), size: size, hashCount: hashCount, salt: uint32(i)}
+		for j := uint64(0); j < length; j++ {
+			layer.bits[j/8] |= uint64(body[j]) << (j % 8 * 8)
+		}
+		if size%64 != 0 && layer.bits[len(layer.bits)-1]>>(size%64) != 0 {
+			return fmt.Errorf("%w: padding bits are set in layer %d", ErrInvalidCascadeData, i)
+		}
+		body = body[length:]
+		layers = append(layers, layer)
+	}
+	if len(body) != 0 {
+		return fmt.Errorf("%w: trailing data", ErrInvalidCascadeData)
+	}
+	cascade.layers = layers
+	return nil
+}
+
+// BuildRevocationCascade 由CA签发的全部未过期证书构建级联，被撤销（含暂停）的证书进入被撤销集合。
+// 过期证书不在级联中，依赖方应先检查有效期
+func (ca *CA) BuildRevocationCascade(now time.Time) (*FilterCascade, error) {
+	ca.Mutex.Lock()
+	var revoked, valid [][]byte
+	for serial, cert := range ca.IssuedCerts {
+		if now.After(cert.NotAfter) {
+			continue
+		}
+		if _, isRevoked := ca.revocation(serial); isRevoked {
+			revoked = append(revoked, filterKey(cert.SerialNumber))
+		} else {
+			valid = append(valid, filterKey(cert.SerialNumber))
+		}
+	}
+	ca.Mutex.Unlock()
+
+	return BuildFilterCascade(revoked, valid)
+}
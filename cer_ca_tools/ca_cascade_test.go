@@ -0,0 +1,146 @@
+package cer_ca_tools
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// cascadeTestSets 返回 [0, revoked) 为被撤销集合、[revoked, total) 为有效集合的序列号
+func cascadeTestSets(revoked, total int) ([][]byte, [][]byte) {
+	var r, s [][]byte
+	for i := 0; i < total; i++ {
+		if element := []byte(fmt.Sprint(i)); i < revoked {
+			r = append(r, element)
+		} else {
+			s = append(s, element)
+		}
+	}
+	return r, s
+}
+
+func TestFilterCascadeExact(t *testing.T) {
+	revoked, valid := cascadeTestSets(500, 50000)
+	cascade, err := BuildFilterCascade(revoked, valid)
+	if err != nil {
+		t.Fatalf("build cascade failed: %v", err)
+	}
+	if cascade.Layers() < 2 {
+		t.Fatalf("expected false positives to require more than one layer, have %d", cascade.Layers())
+	}
+	for _, element := range revoked {
+		if !cascade.QueryElement(element) {
+			t.Fatalf("revoked element %s should be reported as revoked", element)
+		}
+	}
+	for _, element := range valid {
+		if cascade.QueryElement(element) {
+			t.Fatalf("valid element %s should not be reported as revoked", element)
+		}
+	}
+
+	data, err := cascade.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal cascade failed: %v", err)
+	}
+	restored := &FilterCascade{}
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatalf("unmarshal cascade failed: %v", err)
+	}
+	if restored.Layers() != cascade.Layers() {
+		t.Fatalf("restored cascade has %d layers, want %d", restored.Layers(), cascade.Layers())
+	}
+	for i := 0; i < 50000; i += 7 {
+		element := []byte(fmt.Sprint(i))
+		if restored.QueryElement(element) != (i < 500) {
+			t.Fatalf("restored cascade answers wrongly for %d", i)
+		}
+	}
+
+	data[len(data)/2] ^= 0x01
+	if err := restored.UnmarshalBinary(data); !errors.Is(err, ErrInvalidCascadeData) {
+		t.Fatalf("corrupted data should be rejected, have %v", err)
+	}
+	if _, err := BuildFilterCascade(revoked, append(valid, revoked[0])); !errors.Is(err, ErrCascadeOverlap) {
+		t.Fatalf("overlapping sets should be rejected, have %v", err)
+	}
+
+	empty, err := BuildFilterCascade(nil, valid)
+	if err != nil || empty.Layers() != 0 || empty.QueryElement(valid[0]) {
+		t.Fatalf("cascade without revocations should report nothing: %v", err)
+	}
+}
+
+func TestBuildRevocationCascade(t *testing.T) {
+	manager := NewCAManagerWithStore(NewMemoryStore())
+	ca, err := manager.CreateCA("ca_cascade_test")
+	if err != nil {
+		t.Fatalf("create CA failed: %v", err)
+	}
+	revoked := issueTestCert(t, ca, "anonymous-test")
+	held := issueTestCert(t, ca, "anonymous-test")
+	released := issueTestCert(t, ca, "anonymous-test")
+	valid := issueTestCert(t, ca, "anonymous-test")
+	ca.RevokeCertificate(ca.Name.CommonName, revoked.SerialNumber.String(), ReasonKeyCompromise)
+	ca.RevokeCertificate(ca.Name.CommonName, held.SerialNumber.String(), ReasonCertificateHold)
+	ca.RevokeCertificate(ca.Name.CommonName, released.SerialNumber.String(), ReasonCertificateHold)
+	ca.ReleaseCertificateHold(ca.Name.CommonName, released.SerialNumber.String())
+
+	cascade, err := ca.BuildRevocationCascade(time.Now())
+	if err != nil {
+		t.Fatalf("build revocation cascade failed: %v", err)
+	}
+	if !cascade.IsRevoked(revoked.SerialNumber) || !cascade.IsRevoked(held.SerialNumber) {
+		t.Fatalf("revoked and held certificates should be in the cascade")
+	}
+	if cascade.IsRevoked(released.SerialNumber) || cascade.IsRevoked(valid.SerialNumber) {
+		t.Fatalf("released and valid certificates should not be in the cascade")
+	}
+}
+
+const (
+	benchmarkRevoked = 1000
+	benchmarkIssued  = 100000
+)
+
+func BenchmarkFilterCascadeQuery(b *testing.B) {
+	revoked, valid := cascadeTestSets(benchmarkRevoked, benchmarkIssued)
+	cascade, err := BuildFilterCascade(revoked, valid)
+	if err != nil {
+		b.Fatalf("build cascade failed: %v", err)
+	}
+	data, _ := cascade.MarshalBinary()
+	elements := append(revoked, valid...)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cascade.QueryElement(elements[i%len(elements)])
+	}
+	b.ReportMetric(float64(len(data)), "bytes")
+	b.ReportMetric(float64(cascade.Layers()), "layers")
+}
+
+// BenchmarkCountingBloomFilterQuery 同样规模下的计数布隆过滤器，误判率取 0.001，对有效证书仍有误判
+func BenchmarkCountingBloomFilterQuery(b *testing.B) {
+	revoked, valid := cascadeTestSets(benchmarkRevoked, benchmarkIssued)
+	filter := NewCountingBloomFilter(benchmarkRevoked, 0.001, 4)
+	for _, element := range revoked {
+		filter.AddElement(element)
+	}
+	data, _ := filter.MarshalBinary()
+	elements := append(revoked, valid...)
+	falsePositives := 0
+	for _, element := range valid {
+		if filter.QueryElement(element) {
+			falsePositives++
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		filter.QueryElement(elements[i%len(elements)])
+	}
+	b.ReportMetric(float64(len(data)), "bytes")
+	b.ReportMetric(float64(falsePositives), "false-positives")
+}
@@ -3,174 +3,191 @@ package cer_ca_tools
 import (
 	"crypto/sha256"
 	"encoding/binary"
+	"errors"
 	"fmt"
-	"hash/fnv"
 	"math"
 	"sync"
+	"sync/atomic"
 )
 
+// ErrFilterUninitialized 过滤器未经 NewCountingBloomFilter 或 UnmarshalBinary 初始化
+var ErrFilterUninitialized = errors.New("counting bloom filter is not initialized")
+
+// CountingBloomFilter 计数布隆过滤器。每个位置一个 4 或 8 位计数器，紧凑存放在 uint64 数组中；
+// k 个下标由 SHA-256 摘要双重哈希得到，k 不受计数器宽度限制。计数器达到上限后保持饱和，
+// 不再递减，避免删除产生假阴性。QueryElement 不加锁，写操作之间由 mutex 串行化。零值过滤器视为空过滤器，AddElement 返回 ErrFilterUninitialized。
 type CountingBloomFilter struct {
-	cells        []uint8
+	counters  atomic.Pointer[cbfCounters]
+	mutex     sync.Mutex
+	overflows uint
+	elements  uint
+}
+
+// cbfCounters 计数器数组及其参数；参数不变，words 中的字以原子操作读写
+type cbfCounters struct {
+	words        []uint64
 	size         uint
 	hashCount    uint
 	bitsPerCount uint
-	maxCount     uint8
-	mutex        sync.RWMutex
-	overflows    uint
+	maxCount     uint64
 }
 
+// NewCountingBloomFilter 按预期元素数和误判率计算最优的位置数与哈希函数个数。
+// bitsPerCount 支持 4 和 8，其他取值向上取为 4 或 8
 func NewCountingBloomFilter(Elements uint, falsePositiveRate float64, bitsPerCount uint) *CountingBloomFilter {
 	size := calculateOptimalSize(Elements, falsePositiveRate)
 	hashCount := calculateOptimalHashCount(size, Elements)
-	println(size, hashCount)
-	maxHashCount := 8 / bitsPerCount
-	if hashCount > maxHashCount {
-		hashCount = maxHashCount
-		fmt.Println("hash count too big")
+	if bitsPerCount <= 4 {
+		bitsPerCount = 4
+	} else {
+		bitsPerCount = 8
 	}
 
-	maxCount := uint8((1 << bitsPerCount) - 1) // 2^b - 1
+	cbf := &CountingBloomFilter{}
+	cbf.counters.Store(newCBFCounters(size, hashCount, bitsPerCount))
+	return cbf
+}
 
-	return &CountingBloomFilter{
-		cells:        make([]uint8, size),
+func newCBFCounters(size, hashCount, bitsPerCount uint) *cbfCounters {
+	perWord := 64 / bitsPerCount
+	return &cbfCounters{
+		words:        make([]uint64, (size+perWord-1)/perWord),
 		size:         size,
 		hashCount:    hashCount,
 		bitsPerCount: bitsPerCount,
-		maxCount:     maxCount,
-		mutex:        sync.RWMutex{},
-		overflows:    0,
+		maxCount:     1<<bitsPerCount - 1,
 	}
 }
 
 func calculateOptimalSize(n uint, p float64) uint {
 	m := -(float64(n) * math.Log(p)) / (math.Ln2 * math.Ln2)
-	return uint(math.Ceil(m))
+	return max(uint(math.Ceil(m)), 1)
 }
 
 func calculateOptimalHashCount(m, n uint) uint {
-	k := (float64(m) / float64(n)) * math.Ln2
-	return uint(math.Ceil(k))
+	k := (float64(m) / float64(max(n, 1))) * math.Ln2
+	return max(uint(math.Ceil(k)), 1)
 }
 
-func (cbf *CountingBloomFilter) hashSha256(data []byte) uint {
+// getHashValues 双重哈希 (h1 + i*h2) mod size，h1、h2 取自同一 SHA-256 摘要，h2 为奇数
+func (c *cbfCounters) getHashValues(data []byte) []uint {
 	dataHash := sha256.Sum256(data)
-	return uint(binary.BigEndian.Uint64(dataHash[:8])) % cbf.size
-}
-
-func (cbf *CountingBloomFilter) hashFNV(data []byte) uint {
-	dataHash := fnv.New64a()
-	dataHash.Write(data)
-	return uint(dataHash.Sum64()) % cbf.size
-}
-
-func (cbf *CountingBloomFilter) getHashValues(data []byte) []uint {
-	dataHash1 := cbf.hashSha256(data)
-	dataHash2 := cbf.hashFNV(data)
+	h1 := binary.BigEndian.Uint64(dataHash[:8])
+	h2 := binary.BigEndian.Uint64(dataHash[8:16]) | 1
 
-	dataHashes := make([]uint, cbf.hashCount)
-	for i := 0; i < int(cbf.hashCount); i++ {
-		dataHashes[i] = (dataHash1 + uint(i)*dataHash2) % cbf.size
+	dataHashes := make([]uint, c.hashCount)
+	for i := range dataHashes {
+		dataHashes[i] = uint((h1 + uint64(i)*h2) % uint64(c.size))
 	}
 	return dataHashes
 }
 
-func (cbf *CountingBloomFilter) getCounterMask(hashIndex uint) uint8 {
-	shift := hashIndex * cbf.bitsPerCount
-	mask := uint8((1 << cbf.bitsPerCount) - 1)
-	return mask << shift
+func (c *cbfCounters) position(index uint) (word *uint64, shift uint) {
+	bitOffset := index * c.bitsPerCount
+	return &c.words[bitOffset/64], bitOffset % 64
 }
 
-func (cbf *CountingBloomFilter) getCounter(cell uint8, hashIndex uint) uint8 {
-	shift := hashIndex * cbf.bitsPerCount
-	mask := uint8((1 << cbf.bitsPerCount) - 1)
-	return (cell >> shift) & mask
+func (c *cbfCounters) getCounter(index uint) uint64 {
+	word, shift := c.position(index)
+	return (atomic.LoadUint64(word) >> shift) & c.maxCount
 }
 
-func (cbf *CountingBloomFilter) setCounter(cell uint8, hashIndex uint, value uint8) uint8 {
-	shift := hashIndex * cbf.bitsPerCount
-	mask := uint8((1 << cbf.bitsPerCount) - 1)
-	cell &= ^(mask << shift)
-	cell |= (value & mask) << shift
-	return cell
+// setCounter 只能在持有 CountingBloomFilter.mutex 时调用
+func (c *cbfCounters) setCounter(index uint, value uint64) {
+	word, shift := c.position(index)
+	current := atomic.LoadUint64(word)
+	atomic.StoreUint64(word, current&^(c.maxCount<<shift)|(value&c.maxCount)<<shift)
 }
 
-func (cbf *CountingBloomFilter) AddElement(data []byte) {
+// AddElement 添加元素，过滤器未初始化时返回 ErrFilterUninitialized
+func (cbf *CountingBloomFilter) AddElement(data []byte) error {
 	cbf.mutex.Lock()
 	defer cbf.mutex.Unlock()
 
-	hashes := cbf.getHashValues(data)
-
-	for hashIndex, hash := range hashes {
-		cell := cbf.cells[hash]
-		counter := cbf.getCounter(cell, uint(hashIndex))
-
-		if counter == cbf.maxCount {
+	c := cbf.counters.Load()
+	if c == nil {
+		return ErrFilterUninitialized
+	}
+	for _, index := range c.getHashValues(data) {
+		counter := c.getCounter(index)
+		if counter == c.maxCount {
 			cbf.overflows++
 		} else {
-			cbf.cells[hash] = cbf.setCounter(cell, uint(hashIndex), counter+1)
+			c.setCounter(index, counter+1)
 		}
 	}
+	cbf.elements++
+	return nil
 }
 
+// RemoveElement 删除元素，元素不在过滤器中（或过滤器未初始化）时返回 false；饱和的计数器保持不变。
+// 同一元素的多个下标可能重合，重合的下标需要相应次数的计数，计数不足时不做任何修改，计数器不会下溢
 func (cbf *CountingBloomFilter) RemoveElement(data []byte) bool {
 	cbf.mutex.Lock()
 	defer cbf.mutex.Unlock()
 
-	hashes := cbf.getHashValues(data)
-
-	for hashIndex, hash := range hashes {
-		cell := cbf.cells[hash]
-		counter := cbf.getCounter(cell, uint(hashIndex))
-		if counter == 0 {
+	c := cbf.counters.Load()
+	if c == nil {
+		return false
+	}
+	decrements := make(map[uint]uint64, c.hashCount)
+	for _, index := range c.getHashValues(data) {
+		decrements[index]++
+	}
+	for index, count := range decrements {
+		if counter := c.getCounter(index); counter == 0 || counter != c.maxCount && counter < count {
 			return false
 		}
 	}
-	for hashIndex, hash := range hashes {
-		cell := cbf.cells[hash]
-		counter := cbf.getCounter(cell, uint(hashIndex))
-		cbf.cells[hash] = cbf.setCounter(cell, uint(hashIndex), counter-1)
+	for index, count := range decrements {
+		if counter := c.getCounter(index); counter != c.maxCount {
+			c.setCounter(index, counter-count)
+		}
+	}
+	if cbf.elements > 0 {
+		cbf.elements--
 	}
 	return true
 }
 
+// QueryElement 查询元素是否可能在过滤器中，不加锁，可与写操作并发
 func (cbf *CountingBloomFilter) QueryElement(data []byte) bool {
-	cbf.mutex.Lock()
-	defer cbf.mutex.Unlock()
-
-	hashes := cbf.getHashValues(data)
-
-	for hashIndex, hash := range hashes {
-		cell := cbf.cells[hash]
-		counter := cbf.getCounter(cell, uint(hashIndex))
-		if counter == 0 {
+	c := cbf.counters.Load()
+	if c == nil {
+		return false
+	}
+	for _, index := range c.getHashValues(data) {
+		if c.getCounter(index) == 0 {
 			return false
 		}
 	}
 	return true
 }
 
+// GetStats 返回过滤器统计信息，未初始化的过滤器各项均为零
 func (cbf *CountingBloomFilter) GetStats() map[string]interface{} {
 	cbf.mutex.Lock()
 	defer cbf.mutex.Unlock()
 
+	c := cbf.counters.Load()
+	if c == nil {
+		c = &cbfCounters{}
+	}
 	nonZeroCounters := uint(0)
+	saturatedCounters := uint(0)
 	totalCounts := uint64(0)
-	maxCountInCell := uint8(0)
-
-	for _, cell := range cbf.cells {
-		if cell != 0 {
-			nonZeroCounters++
-
-			cellSum := uint8(0)
-			for i := uint(0); i < cbf.hashCount; i++ {
-				counter := cbf.getCounter(cell, i)
-				cellSum += counter
-			}
-			totalCounts += uint64(cellSum)
-
-			if cellSum > maxCountInCell {
-				maxCountInCell = cellSum
-			}
+	maxCounter := uint64(0)
+
+	for i := uint(0); i < c.size; i++ {
+		counter := c.getCounter(i)
+		if counter == 0 {
+			continue
+		}
+		nonZeroCounters++
+		totalCounts += counter
+		maxCounter = max(maxCounter, counter)
+		if counter == c.maxCount {
+			saturatedCounters++
 		}
 	}
 
@@ -179,39 +196,44 @@ func (cbf *CountingBloomFilter) GetStats() map[string]interface{} {
 		avgCount = float64(totalCounts) / float64(nonZeroCounters)
 	}
 
-	loadFactor := float64(nonZeroCounters) / float64(cbf.size)
-	estimatedFPR := math.Pow(loadFactor, float64(cbf.hashCount))
+	loadFactor := float64(0)
+	if c.size > 0 {
+		loadFactor = float64(nonZeroCounters) / float64(c.size)
+	}
+	estimatedFPR := math.Pow(loadFactor, float64(c.hashCount))
 
 	return map[string]interface{}{
-		"cells":             cbf.cells,
-		"size":              cbf.size,
-		"hash_count":        cbf.hashCount,
-		"bit_per_count":     cbf.bitsPerCount,
-		"max_count":         cbf.maxCount,
-		"non_zero_counters": nonZeroCounters,
-		"total_counters":    totalCounts,
-		"max_counters":      maxCountInCell,
-		"avg_count":         avgCount,
-		"load_factor":       loadFactor,
-		"estimated_fpr":     estimatedFPR,
-		"overflows":         cbf.overflows,
+		"size":               c.size,
+		"hash_count":         c.hashCount,
+		"bit_per_count":      c.bitsPerCount,
+		"max_count":          c.maxCount,
+		"non_zero_counters":  nonZeroCounters,
+		"saturated_counters": saturatedCounters,
+		"total_counters":     totalCounts,
+		"max_counters":       maxCounter,
+		"avg_count":          avgCount,
+		"load_factor":        loadFactor,
+		"estimated_fpr":      estimatedFPR,
+		"overflows":          cbf.overflows,
+		"elements":           cbf.elements,
 	}
 }
 
 func (cbf *CountingBloomFilter) PrintStats() {
 	stats := cbf.GetStats()
 	fmt.Println("=======Counting Bloom Filter Info=======")
-	fmt.Println("cells", stats["cells"])
 	fmt.Println("size: ", stats["size"])
-	fmt.Println("hash_count: ", stats["hashCountstats"])
+	fmt.Println("hash_count: ", stats["hash_count"])
 	fmt.Println("bit_per_count: ", stats["bit_per_count"])
-	fmt.Println("non_zero_counters: ", stats["nonZeroCounters"])
+	fmt.Println("non_zero_counters: ", stats["non_zero_counters"])
+	fmt.Println("saturated_counters: ", stats["saturated_counters"])
 	fmt.Println("total_counters: ", stats["total_counters"])
-	fmt.Println("max_counters: ", stats["maxCountInCell"])
-	fmt.Println("avg_count: ", stats["avgCount"])
-	fmt.Println("load_factor: ", stats["loadFactor"])
-	fmt.Println("estimated_fpr", stats["estimatedFPR"])
+	fmt.Println("max_counters: ", stats["max_counters"])
+	fmt.Println("avg_count: ", stats["avg_count"])
+	fmt.Println("load_factor: ", stats["load_factor"])
+	fmt.Println("estimated_fpr", stats["estimated_fpr"])
 	fmt.Println("overflows: ", stats["overflows"])
+	fmt.Println("elements: ", stats["elements"])
 	fmt.Println("=======Counting Bloom Filter Info End=======")
 }
 
@@ -219,12 +241,20 @@ func (cbf *CountingBloomFilter) Reset() {
 	cbf.mutex.Lock()
 	defer cbf.mutex.Unlock()
 
-	for i := range cbf.cells {
-		cbf.cells[i] = 0
+	c := cbf.counters.Load()
+	if c == nil {
+		return
 	}
+	cbf.counters.Store(newCBFCounters(c.size, c.hashCount, c.bitsPerCount))
 	cbf.overflows = 0
+	cbf.elements = 0
 }
 
+// GetMemoryUsage 返回计数器数组占用的字节数
 func (cbf *CountingBloomFilter) GetMemoryUsage() uint {
-	return cbf.size
+	c := cbf.counters.Load()
+	if c == nil {
+		return 0
+	}
+	return uint(len(c.words)) * 8
 }
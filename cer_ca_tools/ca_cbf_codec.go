@@ -0,0 +1,255 @@
+package cer_ca_tools
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sync/atomic"
+)
+
+// CountingBloomFilter 二进制格式（大端）：
+//
+//	magic "CBF" | version(1) | hashScheme(1) | encoding(1) | hashCount(1) | bitsPerCount(1)
+//	size(8) | elements(8) | overflows(8) | payload | crc32(4)
+//
+// 计数器按下标顺序紧凑排列为 (size*bitsPerCount+7)/8 字节，4 位计数器先占低半字节。
+// payload 为 dense 时是全部字节；为 sparse 时是非零字节个数(uvarint)，随后每个非零字节依次为
+// 与上一个非零字节的下标间隔(uvarint)和字节值。MarshalBinary 选择较短的一种。
+// crc32 (IEEE) 覆盖其前的全部字节。版本 1 的单元布局已废弃，不再支持。
+
+const (
+	CBFFormatVersion = 2
+
+	// CBFHashSchemeSHA256Double 下标 (h1 + i*h2) mod size，h1、h2 为 SHA-256 摘要前两个 64 位（h2 置最低位）
+	CBFHashSchemeSHA256Double = 2
+)
+
+const (
+	cbfEncodingDense  = 0
+	cbfEncodingSparse = 1
+
+	cbfHeaderSize   = 32
+	cbfChecksumSize = 4
+	// cbfMaxSize 反序列化时允许的最大计数器数，避免畸形数据导致过量分配
+	cbfMaxSize = 1 << 30
+)
+
+var cbfMagic = [3]byte{'C', 'B', 'F'}
+
+var (
+	ErrInvalidFilterData        = errors.New("invalid counting bloom filter data")
+	ErrFilterChecksum           = errors.New("counting bloom filter checksum mismatch")
+	ErrUnsupportedFilterVersion = errors.New("unsupported counting bloom filter format version")
+)
+
+// MarshalBinary 实现 encoding.BinaryMarshaler，相同内容的过滤器产生相同的字节
+func (cbf *CountingBloomFilter) MarshalBinary() ([]byte, error) {
+	cbf.mutex.Lock()
+	defer cbf.mutex.Unlock()
+
+	c := cbf.counters.Load()
+	if c == nil {
+		return nil, ErrFilterUninitialized
+	}
+	cells := c.bytes()
+	nonZero := 0
+	sparseSize := 0
+	previous := -1
+	for i, cell := range cells {
+		if cell == 0 {
+			continue
+		}
+		nonZero++
+		sparseSize += uvarintLen(uint64(i-previous-1)) + 1
+		previous = i
+	}
+	sparseSize += uvarintLen(uint64(nonZero))
+
+	encoding := byte(cbfEncodingDense)
+	payloadSize := len(cells)
+	if sparseSize < payloadSize {
+		encoding = cbfEncodingSparse
+		payloadSize = sparseSize
+	}
+
+	data := make([]byte, cbfHeaderSize, cbfHeaderSize+payloadSize+cbfChecksumSize)
+	copy(data, cbfMagic[:])
+	data[3] = CBFFormatVersion
+	data[4] = CBFHashSchemeSHA256Double
+	data[5] = encoding
+	data[6] = byte(c.hashCount)
+	data[7] = byte(c.bitsPerCount)
+	binary.BigEndian.PutUint64(data[8:], uint64(c.size))
+	binary.BigEndian.PutUint64(data[16:], uint64(cbf.elements))
+	binary.BigEndian.PutUint64(data[24:], uint64(cbf.overflows))
+
+	if encoding == cbfEncodingDense {
+		data = append(data, cells...)
+	} else {
+		data = binary.AppendUvarint(data, uint64(nonZero))
+		previous = -1
+		for i, cell := range cells {
+			if cell == 0 {
+				continue
+			}
+			data = binary.AppendUvarint(data, uint64(i-previous-1))
+			data = append(data, cell)
+			previous = i
+		}
+	}
+	return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(data)), nil
+}
+
+// UnmarshalBinary 实现 encoding.BinaryUnmarshaler，校验格式版本、参数与校验和后替换过滤器内容
+func (cbf *CountingBloomFilter) UnmarshalBinary(data []byte) error {
+	if len(data) < cbfHeaderSize+cbfChecksumSize || [3]byte(data[:3]) != cbfMagic {
+		return ErrInvalidFilterData
+	}
+	if data[3] != CBFFormatVersion {
+		return fmt.Errorf("%w: %d", ErrUnsupportedFilterVersion, data[3])
+	}
+	body := data[:len(data)-cbfChecksumSize]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(data[len(body):]) {
+		return ErrFilterChecksum
+	}
+	if data[4] != CBFHashSchemeSHA256Double {
+		return fmt.Errorf("%w: unknown hash scheme %d", ErrInvalidFilterData, data[4])
+	}
+
+	hashCount := uint(data[6])
+	bitsPerCount := uint(data[7])
+	if (bitsPerCount != 4 && bitsPerCount != 8) || hashCount == 0 {
+		return fmt.Errorf("%w: %d hash functions with %d-bit counters", ErrInvalidFilterData, hashCount, bitsPerCount)
+	}
+	size := binary.BigEndian.Uint64(data[8:])
+	if size == 0 || size > cbfMaxSize {
+		return fmt.Errorf("%w: size %d", ErrInvalidFilterData, size)
+	}
+
+	cells := make([]uint8, (size*uint64(bitsPerCount)+7)/8)
+	payload := body[cbfHeaderSize:]
+	switch data[5] {
+	case cbfEncodingDense:
+		if len(payload) != len(cells) {
+			return fmt.Errorf("%w: dense payload of %d bytes for %d counters", ErrInvalidFilterData, len(payload), size)
+		}
+		copy(cells, payload)
+	case cbfEncodingSparse:
+		if err := decodeSparseCells(cells, payload); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: unknown encoding %d", ErrInvalidFilterData, data[5])
+	}
+
+	// 4 位计数器个数为奇数时，最后一个字节的高半字节不属于任何计数器
+	if size*uint64(bitsPerCount)%8 != 0 && cells[len(cells)-1]>>4 != 0 {
+		return fmt.Errorf("%w: padding bits are set", ErrInvalidFilterData)
+	}
+	c := newCBFCounters(uint(size), hashCount, bitsPerCount)
+	for i, cell := range cells {
+		c.words[i/8] |= uint64(cell) << (i % 8 * 8)
+	}
+
+	cbf.mutex.Lock()
+	defer cbf.mutex.Unlock()
+
+	cbf.counters.Store(c)
+	cbf.elements = uint(binary.BigEndian.Uint64(data[16:]))
+	cbf.overflows = uint(binary.BigEndian.Uint64(data[24:]))
+	return nil
+}
+
+// bytes 返回计数器数组的紧凑字节表示，调用方持有 CountingBloomFilter.mutex
+func (c *cbfCounters) bytes() []byte {
+	cells := make([]byte, (c.size*c.bitsPerCount+7)/8)
+	for i := range cells {
+		cells[i] = byte(atomic.LoadUint64(&c.words[i/8]) >> (i % 8 * 8))
+	}
+	return cells
+}
+
+// decodeSparseCells 解码 sparse payload 到 cells
+func decodeSparseCells(cells []uint8, payload []byte) error {
+	count, n := binary.Uvarint(payload)
+	if n <= 0 || count > uint64(len(cells)) {
+		return fmt.Errorf("%w: bad sparse cell count", ErrInvalidFilterData)
+	}
+	payload = payload[n:]
+	next := uint64(0)
+	for i := uint64(0); i < count; i++ {
+		gap, n := binary.Uvarint(payload)
+		if n <= 0 || n >= len(payload) {
+			return fmt.Errorf("%w: truncated sparse payload", ErrInvalidFilterData)
+		}
+		index := next + gap
+		if index < next || index >= uint64(len(cells)) || payload[n] == 0 {
+			return fmt.Errorf("%w: bad sparse cell", ErrInvalidFilterData)
+		}
+		cells[index] = payload[n]
+		payload = payload[n+1:]
+		next = index + 1
+	}
+	if len(payload) != 0 {
+		return fmt.Errorf("%w: trailing sparse payload", ErrInvalidFilterData)
+	}
+	return nil
+}
+
+func uvarintLen(x uint64) int {
+	n := 1
+	for x >= 0x80 {
+		x >>= 7
+		n++
+	}
+	return n
+}
+
+// MarshalCells 返回过滤器的定长表示：32 字节头部（dense 编码）与紧凑排列的计数器字节。
+// 计数器的位置固定，便于按块比较与增量存储（如链上存储）；UnmarshalCells 为其逆操作
+func (cbf *CountingBloomFilter) MarshalCells() (header []byte, cells []byte) {
+	cbf.mutex.Lock()
+	defer cbf.mutex.Unlock()
+
+	c := cbf.counters.Load()
+	cells = c.bytes()
+	header = make([]byte, cbfHeaderSize)
+	copy(header, cbfMagic[:])
+	header[3] = CBFFormatVersion
+	header[4] = CBFHashSchemeSHA256Double
+	header[5] = cbfEncodingDense
+	header[6] = byte(c.hashCount)
+	header[7] = byte(c.bitsPerCount)
+	binary.BigEndian.PutUint64(header[8:], uint64(c.size))
+	binary.BigEndian.PutUint64(header[16:], uint64(cbf.elements))
+	binary.BigEndian.PutUint64(header[24:], uint64(cbf.overflows))
+	return header, cells
+}
+
+// UnmarshalCells 由 MarshalCells 的结果重建过滤器，cells 可带有补齐的尾部零字节
+func UnmarshalCells(header, cells []byte) (*CountingBloomFilter, error) {
+	if len(header) != cbfHeaderSize || header[5] != cbfEncodingDense {
+		return nil, fmt.Errorf("%w: bad cells header", ErrInvalidFilterData)
+	}
+	size := binary.BigEndian.Uint64(header[8:])
+	length := (size*uint64(header[7]) + 7) / 8
+	if size > cbfMaxSize || uint64(len(cells)) < length {
+		return nil, fmt.Errorf("%w: %d cell bytes for %d counters", ErrInvalidFilterData, len(cells), size)
+	}
+	for _, padding := range cells[length:] {
+		if padding != 0 {
+			return nil, fmt.Errorf("%w: padding bytes are set", ErrInvalidFilterData)
+		}
+	}
+
+	data := make([]byte, 0, cbfHeaderSize+length+cbfChecksumSize)
+	data = append(data, header...)
+	data = append(data, cells[:length]...)
+	data = binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(data))
+	cbf := &CountingBloomFilter{}
+	if err := cbf.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return cbf, nil
+}
@@ -0,0 +1,79 @@
+package cer_ca_tools
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCountingBloomFilterBinary(t *testing.T) {
+	for _, count := range []int{3, 2000} {
+		cbf := NewCountingBloomFilter(1000, 0.01, 8)
+		for i := 0; i < count; i++ {
+			cbf.AddElement([]byte(fmt.Sprintf("%017d", i)))
+		}
+		cbf.RemoveElement([]byte(fmt.Sprintf("%017d", 0)))
+
+		data, err := cbf.MarshalBinary()
+		if err != nil {
+			t.Fatalf("marshal filter failed: %v", err)
+		}
+		sparse := data[5] == cbfEncodingSparse
+		if sparse != (count == 3) {
+			t.Fatalf("%d elements: expected sparse=%v encoding", count, count == 3)
+		}
+		if sparse && len(data) >= int(cbf.GetMemoryUsage()) {
+			t.Fatalf("sparse encoding of %d bytes is not compact", len(data))
+		}
+
+		restored := &CountingBloomFilter{}
+		if err := restored.UnmarshalBinary(data); err != nil {
+			t.Fatalf("unmarshal filter failed: %v", err)
+		}
+		original, have := cbf.counters.Load(), restored.counters.Load()
+		if !bytes.Equal(have.bytes(), original.bytes()) || have.size != original.size || have.hashCount != original.hashCount ||
+			have.maxCount != original.maxCount || restored.elements != uint(count-1) || restored.overflows != cbf.overflows {
+			t.Fatalf("%d elements: filter did not round trip", count)
+		}
+		for i := 0; i < count+100; i++ {
+			element := []byte(fmt.Sprintf("%017d", i))
+			if restored.QueryElement(element) != cbf.QueryElement(element) {
+				t.Fatalf("restored filter answers differently for element %d", i)
+			}
+		}
+		again, _ := restored.MarshalBinary()
+		if !bytes.Equal(again, data) {
+			t.Fatalf("identical filters should marshal to identical bytes")
+		}
+		if _, leaked := cbf.GetStats()["cells"]; leaked {
+			t.Fatalf("stats should not expose the counters")
+		}
+	}
+}
+
+func TestCountingBloomFilterBinaryInvalid(t *testing.T) {
+	cbf := NewCountingBloomFilter(100, 0.01, 4)
+	cbf.AddElement([]byte("revoked"))
+	data, _ := cbf.MarshalBinary()
+	restored := &CountingBloomFilter{}
+
+	corrupted := bytes.Clone(data)
+	corrupted[len(corrupted)-5] ^= 0x01
+	if err := restored.UnmarshalBinary(corrupted); !errors.Is(err, ErrFilterChecksum) {
+		t.Fatalf("corrupted payload should fail the checksum, have %v", err)
+	}
+	future := bytes.Clone(data)
+	future[3] = CBFFormatVersion + 1
+	if err := restored.UnmarshalBinary(future); !errors.Is(err, ErrUnsupportedFilterVersion) {
+		t.Fatalf("unknown version should be rejected, have %v", err)
+	}
+	for _, truncated := range [][]byte{nil, data[:10], data[:len(data)-1]} {
+		if err := restored.UnmarshalBinary(truncated); err == nil {
+			t.Fatalf("truncated data of %d bytes should be rejected", len(truncated))
+		}
+	}
+	if restored.counters.Load() != nil {
+		t.Fatalf("failed unmarshal should leave the filter untouched")
+	}
+}
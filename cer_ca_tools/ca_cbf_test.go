@@ -0,0 +1,144 @@
+package cer_ca_tools
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestCountingBloomFilterFalsePositiveRate(t *testing.T) {
+	for _, target := range []float64{0.01, 0.001} {
+		const elements = 5000
+		cbf := NewCountingBloomFilter(elements, target, 4)
+		for i := 0; i < elements; i++ {
+			cbf.AddElement([]byte(fmt.Sprintf("revoked-%d", i)))
+		}
+		for i := 0; i < elements; i++ {
+			if !cbf.QueryElement([]byte(fmt.Sprintf("revoked-%d", i))) {
+				t.Fatalf("false negative for element %d", i)
+			}
+		}
+
+		const queries = 200000
+		falsePositives := 0
+		for i := 0; i < queries; i++ {
+			if cbf.QueryElement([]byte(fmt.Sprintf("valid-%d", i))) {
+				falsePositives++
+			}
+		}
+		measured := float64(falsePositives) / queries
+		stats := cbf.GetStats()
+		estimated := stats["estimated_fpr"].(float64)
+		if math.Abs(measured-estimated) > 0.25*estimated {
+			t.Fatalf("target %v: measured FPR %v does not match estimated %v", target, measured, estimated)
+		}
+		if estimated > 1.5*target {
+			t.Fatalf("target %v: estimated FPR %v is too high", target, estimated)
+		}
+		if k := stats["hash_count"].(uint); k != calculateOptimalHashCount(stats["size"].(uint), elements) {
+			t.Fatalf("hash count %d should not be truncated", k)
+		}
+	}
+}
+
+func TestCountingBloomFilterCounters(t *testing.T) {
+	cbf := NewCountingBloomFilter(100, 0.01, 4)
+	element := []byte("00000000000000020")
+	cbf.AddElement(element)
+	cbf.AddElement(element)
+	if !cbf.RemoveElement(element) || !cbf.QueryElement(element) {
+		t.Fatalf("element added twice should remain after one removal")
+	}
+	if !cbf.RemoveElement(element) || cbf.QueryElement(element) {
+		t.Fatalf("element should be gone after both removals")
+	}
+	if cbf.RemoveElement(element) {
+		t.Fatalf("removing an absent element should fail")
+	}
+
+	// 计数器饱和后保持不变，删除不会产生假阴性
+	for i := 0; i < 20; i++ {
+		cbf.AddElement(element)
+	}
+	for i := 0; i < 20; i++ {
+		cbf.RemoveElement(element)
+	}
+	stats := cbf.GetStats()
+	if !cbf.QueryElement(element) || stats["overflows"].(uint) == 0 || stats["saturated_counters"].(uint) == 0 {
+		t.Fatalf("saturated counters should be sticky: %v", stats)
+	}
+
+	cbf.Reset()
+	if cbf.QueryElement(element) || cbf.GetStats()["non_zero_counters"].(uint) != 0 {
+		t.Fatalf("reset should clear all counters")
+	}
+	if cbf.GetMemoryUsage() != uint((cbf.GetStats()["size"].(uint)+15)/16*8) {
+		t.Fatalf("4-bit counters should pack 16 per word")
+	}
+}
+
+func TestCountingBloomFilterConcurrentQuery(t *testing.T) {
+	cbf := NewCountingBloomFilter(1000, 0.01, 8)
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 250; i++ {
+				element := []byte(fmt.Sprintf("%d-%d", w, i))
+				cbf.AddElement(element)
+				if !cbf.QueryElement(element) {
+					t.Errorf("element %s missing right after insertion", element)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	if elements := cbf.GetStats()["elements"].(uint); elements != 1000 {
+		t.Fatalf("expected 1000 elements, have %d", elements)
+	}
+}
+
+func TestCountingBloomFilterUninitialized(t *testing.T) {
+	var cbf CountingBloomFilter
+	if err := cbf.AddElement([]byte("revoked")); !errors.Is(err, ErrFilterUninitialized) {
+		t.Fatalf("zero-value filter should reject additions, have %v", err)
+	}
+	if cbf.RemoveElement([]byte("revoked")) || cbf.QueryElement([]byte("revoked")) {
+		t.Fatalf("zero-value filter should be empty")
+	}
+	if stats := cbf.GetStats(); stats["size"].(uint) != 0 || stats["load_factor"].(float64) != 0 || cbf.GetMemoryUsage() != 0 {
+		t.Fatalf("zero-value filter should report empty stats: %v", stats)
+	}
+	cbf.Reset()
+	if _, err := cbf.MarshalBinary(); !errors.Is(err, ErrFilterUninitialized) {
+		t.Fatalf("zero-value filter should not be encoded, have %v", err)
+	}
+}
+
+func TestCountingBloomFilterRemoveRepeatedIndex(t *testing.T) {
+	// 3 个位置 4 个下标，同一元素必然有重合的下标
+	cbf := &CountingBloomFilter{}
+	cbf.counters.Store(newCBFCounters(3, 4, 4))
+	c := cbf.counters.Load()
+	element := []byte("revoked")
+	for _, index := range c.getHashValues(element) {
+		c.setCounter(index, 1)
+	}
+	if cbf.RemoveElement(element) {
+		t.Fatalf("removal should fail when a repeated index lacks counts")
+	}
+	for index := uint(0); index < c.size; index++ {
+		if counter := c.getCounter(index); counter > 1 {
+			t.Fatalf("counter %d underflowed to %d", index, counter)
+		}
+	}
+
+	cbf.Reset()
+	cbf.AddElement(element)
+	if !cbf.RemoveElement(element) || cbf.GetStats()["non_zero_counters"].(uint) != 0 {
+		t.Fatalf("element with repeated indexes should be removed cleanly")
+	}
+}
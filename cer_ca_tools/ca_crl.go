@@ -0,0 +1,276 @@
+package cer_ca_tools
+
+import (
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math/big"
+	"net/url"
+	"time"
+)
+
+// Delta CRL Indicator 扩展 (RFC 5280 5.2.4)
+var oidExtensionDeltaCRLIndicator = asn1.ObjectIdentifier{2, 5, 29, 27}
+
+// CRL reasonCode 扩展 (RFC 5280 5.3.1)
+var oidExtensionReasonCode = asn1.ObjectIdentifier{2, 5, 29, 21}
+
+// CRLConfig CRL发布参数
+type CRLConfig struct {
+	Validity    time.Duration // nextUpdate 与 thisUpdate 的间隔
+	EnableDelta bool          // 是否发布增量CRL
+}
+
+// DefaultCRLConfig 默认每24小时发布一次完整CRL，不发布增量CRL
+func DefaultCRLConfig() CRLConfig {
+	return CRLConfig{
+		Validity:    24 * time.Hour,
+		EnableDelta: false,
+	}
+}
+
+// CRLState 需要持久化的CRL状态，保证重启后CRL编号仍单调递增
+type CRLState struct {
+	Number     *big.Int  `json:"number"`      // 最近一次发布的CRL编号（完整与增量CRL共用）
+	BaseNumber *big.Int  `json:"base_number"` // 最近一次完整CRL的编号
+	BaseTime   time.Time `json:"base_time"`   // 最近一次完整CRL的 thisUpdate
+
+	FilterVersion uint64 `json:"filter_version,omitempty"` // 最近一次发布的撤销过滤器版本
+}
+
+type cachedCRL struct {
+	der        []byte
+	nextUpdate time.Time
+}
+
+// CRLURL 返回该CA的CRL分发点地址，密钥轮换后的新密钥带 key 参数（主体密钥标识），与旧密钥的CRL区分
+func (ca *CA) CRLURL() string {
+	crlURL := fmt.Sprintf("%s%s/certificate/crl?caName=%s", ca.BaseURL, APIVersionPrefix, url.QueryEscape(ca.Name.CommonName))
+	if len(ca.RetiredKeys) > 0 {
+		crlURL += "&key=" + hex.EncodeToString(ca.Certificate.SubjectKeyId)
+	}
+	return crlURL
+}
+
+// CRLForKey 返回指定CA密钥签名的CRL，keyID 为主体密钥标识（十六进制）
+//
+// keyID 为空对应不带 key 参数的分发点，即轮换前的原始密钥：原始密钥仍在使用时由它签名，否则由当前密钥签名。
+// 旧密钥只发布完整CRL。
+func (ca *CA) CRLForKey(keyID string, delta bool) ([]byte, error) {
+	now := time.Now()
+	ca.Mutex.Lock()
+	var retired *RetiredCAKey
+	if keyID == "" {
+		if len(ca.RetiredKeys) > 0 && ca.RetiredKeys[0].Active(now) {
+			retired = ca.RetiredKeys[0]
+		}
+	} else if keyID != hex.EncodeToString(ca.Certificate.SubjectKeyId) {
+		if retired = ca.retiredKey(keyID, now); retired == nil {
+			ca.Mutex.Unlock()
+			return nil, ErrUnknownCAKey
+		}
+	}
+	ca.Mutex.Unlock()
+
+	if retired == nil {
+		return ca.CurrentCRL(delta)
+	}
+	if delta {
+		return nil, fmt.Errorf("delta CRL is not published for retired keys of CA %s", ca.Name.CommonName)
+	}
+	return ca.retiredCRL(retired, now)
+}
+
+// retiredCRL 用旧密钥签名完整CRL，只推进CRL编号，不改变当前密钥增量CRL的基准
+func (ca *CA) retiredCRL(retired *RetiredCAKey, now time.Time) ([]byte, error) {
+	ca.Mutex.Lock()
+	defer ca.Mutex.Unlock()
+
+	if retired.crl != nil && now.Before(retired.crl.nextUpdate) {
+		return retired.crl.der, nil
+	}
+	if retired.PrivateKey == nil {
+		return nil, fmt.Errorf("retired key %s of CA %s is offline", retired.KeyID(), ca.Name.CommonName)
+	}
+	crlDER, number, nextUpdate, err := ca.signCRL(now, false, retired.Certificate, retired.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	state := ca.crlState
+	state.Number = number
+	if err := ca.saveCRLState(state); err != nil {
+		return nil, err
+	}
+	retired.crl = &cachedCRL{der: crlDER, nextUpdate: nextUpdate}
+	return crlDER, nil
+}
+
+// GenerateCRL 根据 RevokedCerts 生成并签名完整CRL
+func (ca *CA) GenerateCRL(now time.Time) ([]byte, error) {
+	ca.Mutex.Lock()
+	defer ca.Mutex.Unlock()
+
+	return ca.generateCRL(now, false)
+}
+
+// GenerateDeltaCRL 生成自上一次完整CRL以来新增撤销的增量CRL
+func (ca *CA) GenerateDeltaCRL(now time.Time) ([]byte, error) {
+	ca.Mutex.Lock()
+	defer ca.Mutex.Unlock()
+
+	return ca.generateCRL(now, true)
+}
+
+// CurrentCRL 返回当前有效的CRL，缓存过期或有新撤销时重新生成
+func (ca *CA) CurrentCRL(delta bool) ([]byte, error) {
+	ca.Mutex.Lock()
+	defer ca.Mutex.Unlock()
+
+	if delta && !ca.CRLConfig.EnableDelta {
+		return nil, fmt.Errorf("delta CRL is not enabled for CA %s", ca.Name.CommonName)
+	}
+
+	now := time.Now()
+	cached := ca.fullCRL
+	if delta {
+		cached = ca.deltaCRL
+	}
+	if cached != nil && now.Before(cached.nextUpdate) {
+		return cached.der, nil
+	}
+
+	// 增量CRL依赖完整CRL，完整CRL过期时先重新发布
+	if delta && (ca.fullCRL == nil || !now.Before(ca.fullCRL.nextUpdate)) {
+		if _, err := ca.generateCRL(now, false); err != nil {
+			return nil, err
+		}
+	}
+	return ca.generateCRL(now, delta)
+}
+
+func (ca *CA) generateCRL(now time.Time, delta bool) ([]byte, error) {
+	if ca.IsOffline() {
+		return nil, fmt.Errorf("CA %s is offline", ca.Name.CommonName)
+	}
+	if delta && ca.crlState.BaseNumber == nil {
+		return nil, fmt.Errorf("no base CRL has been issued for CA %s", ca.Name.CommonName)
+	}
+
+	crlDER, number, nextUpdate, err := ca.signCRL(now, delta, ca.Certificate, ca.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	state := ca.crlState
+	state.Number = number
+	if !delta {
+		state.BaseNumber = number
+		state.BaseTime = now
+	}
+	if err := ca.saveCRLState(state); err != nil {
+		return nil, err
+	}
+
+	cached := &cachedCRL{der: crlDER, nextUpdate: nextUpdate}
+	if delta {
+		ca.deltaCRL = cached
+	} else {
+		ca.fullCRL = cached
+		ca.deltaCRL = nil
+	}
+	return crlDER, nil
+}
+
+// signCRL 用指定的CA证书与私钥签名包含撤销记录的CRL，返回CRL与它使用的编号
+func (ca *CA) signCRL(now time.Time, delta bool, issuer *x509.Certificate, key crypto.Signer) ([]byte, *big.Int, time.Time, error) {
+	number := big.NewInt(1)
+	if ca.crlState.Number != nil {
+		number.Add(ca.crlState.Number, big.NewInt(1))
+	}
+
+	validity := ca.CRLConfig.Validity
+	if validity <= 0 {
+		validity = DefaultCRLConfig().Validity
+	}
+
+	template := &x509.RevocationList{
+		Number:     number,
+		ThisUpdate: now,
+		NextUpdate: now.Add(validity),
+	}
+
+	// 撤销记录的时间为最近一次状态变化的时间：暂停改为永久撤销、解除暂停都会出现在之后的增量CRL中；
+	// 解除暂停的证书不再列入完整CRL
+	for _, revoked := range ca.RevokedCerts {
+		if delta && !revoked.RevocationTime.After(ca.crlState.BaseTime) {
+			continue
+		}
+		if !delta && revocationReason(revoked) == ReasonRemoveFromCRL {
+			continue
+		}
+		template.RevokedCertificateEntries = append(template.RevokedCertificateEntries, revocationListEntry(revoked))
+	}
+
+	if delta {
+		baseNumber, err := asn1.Marshal(ca.crlState.BaseNumber)
+		if err != nil {
+			return nil, nil, time.Time{}, fmt.Errorf("failed to marshal base CRL number: %w", err)
+		}
+		template.ExtraExtensions = append(template.ExtraExtensions, pkix.Extension{
+			Id:       oidExtensionDeltaCRLIndicator,
+			Critical: true,
+			Value:    baseNumber,
+		})
+	}
+
+	crlDER, err := createRevocationList(template, issuer, key)
+	if err != nil {
+		return nil, nil, time.Time{}, fmt.Errorf("failed to create CRL: %w", err)
+	}
+
+	log.Printf("CA %s published CRL #%s (delta=%t, entries=%d, key=%s)",
+		ca.Name.CommonName, number, delta, len(template.RevokedCertificateEntries), hex.EncodeToString(issuer.SubjectKeyId))
+	return crlDER, number, template.NextUpdate, nil
+}
+
+func (ca *CA) saveCRLState(state CRLState) error {
+	if ca.store != nil {
+		if err := ca.store.SaveCRLState(ca.Name.CommonName, state); err != nil {
+			return fmt.Errorf("failed to persist CRL state: %w", err)
+		}
+	}
+	ca.crlState = state
+	return nil
+}
+
+// invalidateCRL 撤销状态变化后丢弃缓存的CRL；启用增量CRL时完整CRL按计划更新，新撤销由增量CRL发布
+func (ca *CA) invalidateCRL() {
+	ca.deltaCRL = nil
+	if !ca.CRLConfig.EnableDelta {
+		ca.fullCRL = nil
+	}
+	// 旧密钥只发布完整CRL，新撤销需要立即体现
+	for _, retired := range ca.RetiredKeys {
+		retired.crl = nil
+	}
+}
+
+// revocationListEntry 将撤销记录转换为CRL条目，reasonCode 由 x509 按 ENUMERATED 重新编码，
+// invalidityDate 等其他扩展原样保留
+func revocationListEntry(revoked *pkix.RevokedCertificate) x509.RevocationListEntry {
+	entry := x509.RevocationListEntry{
+		SerialNumber:   revoked.SerialNumber,
+		RevocationTime: revoked.RevocationTime,
+		ReasonCode:     revocationReason(revoked),
+	}
+	for _, ext := range revoked.Extensions {
+		if !ext.Id.Equal(oidExtensionReasonCode) {
+			entry.ExtraExtensions = append(entry.ExtraExtensions, ext)
+		}
+	}
+	return entry
+}
@@ -0,0 +1,74 @@
+package cer_ca_tools
+
+import (
+	"crypto/x509"
+	"testing"
+	"time"
+)
+
+func TestGenerateCRL(t *testing.T) {
+	store := NewMemoryStore()
+	manager := NewCAManagerWithStore(store)
+	ca, err := manager.CreateCA("ca_crl_test")
+	if err != nil {
+		t.Fatalf("create CA failed: %v", err)
+	}
+	ca.CRLConfig.EnableDelta = true
+
+	first := issueTestCert(t, ca, "anonymous-test")
+	second := issueTestCert(t, ca, "anonymous-test")
+	if len(first.CRLDistributionPoints) != 1 || first.CRLDistributionPoints[0] != ca.CRLURL() {
+		t.Fatalf("unexpected CRL distribution points: %v", first.CRLDistributionPoints)
+	}
+
+	ca.RevokeCertificate(ca.Name.CommonName, first.SerialNumber.String(), 1)
+	baseDER, err := ca.CurrentCRL(false)
+	if err != nil {
+		t.Fatalf("generate CRL failed: %v", err)
+	}
+	base, err := x509.ParseRevocationList(baseDER)
+	if err != nil {
+		t.Fatalf("parse CRL failed: %v", err)
+	}
+	if err := base.CheckSignatureFrom(ca.Certificate); err != nil {
+		t.Fatalf("CRL signature invalid: %v", err)
+	}
+	if len(base.RevokedCertificateEntries) != 1 || base.RevokedCertificateEntries[0].ReasonCode != 1 {
+		t.Fatalf("unexpected CRL entries: %+v", base.RevokedCertificateEntries)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	ca.RevokeCertificate(ca.Name.CommonName, second.SerialNumber.String(), 4)
+	deltaDER, err := ca.CurrentCRL(true)
+	if err != nil {
+		t.Fatalf("generate delta CRL failed: %v", err)
+	}
+	delta, err := x509.ParseRevocationList(deltaDER)
+	if err != nil {
+		t.Fatalf("parse delta CRL failed: %v", err)
+	}
+	if delta.Number.Cmp(base.Number) <= 0 {
+		t.Fatalf("delta CRL number %s is not greater than base %s", delta.Number, base.Number)
+	}
+	if len(delta.RevokedCertificateEntries) != 1 || delta.RevokedCertificateEntries[0].SerialNumber.Cmp(second.SerialNumber) != 0 {
+		t.Fatalf("delta CRL should only contain the new revocation: %+v", delta.RevokedCertificateEntries)
+	}
+
+	// CRL编号在重启后继续递增
+	restarted := NewCAManagerWithStore(store)
+	if err := restarted.LoadState(); err != nil {
+		t.Fatalf("load state failed: %v", err)
+	}
+	restored, _ := restarted.GetCAInfo("ca_crl_test")
+	nextDER, err := restored.GenerateCRL(time.Now())
+	if err != nil {
+		t.Fatalf("generate CRL after restart failed: %v", err)
+	}
+	next, _ := x509.ParseRevocationList(nextDER)
+	if next.Number.Cmp(delta.Number) <= 0 {
+		t.Fatalf("CRL number went backwards after restart: %s <= %s", next.Number, delta.Number)
+	}
+	if len(next.RevokedCertificateEntries) != 2 {
+		t.Fatalf("full CRL should contain both revocations, have %d", len(next.RevokedCertificateEntries))
+	}
+}
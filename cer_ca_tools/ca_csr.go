@@ -0,0 +1,186 @@
+package cer_ca_tools
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/FISCO-BCOS/go-sdk/smcrypto"
+)
+
+// CSR 拒绝原因，handler 根据这些错误返回具体的失败信息
+var (
+	ErrCSRMalformed    = errors.New("malformed CSR")
+	ErrCSRSignature    = errors.New("CSR signature verification failed")
+	ErrCSRKeyAlgorithm = errors.New("CSR key algorithm not allowed")
+	ErrCSRSAN          = errors.New("CSR subject alternative names rejected")
+	ErrCSRExtension    = errors.New("CSR extension rejected")
+)
+
+var (
+	oidExtensionSubjectAltName   = asn1.ObjectIdentifier{2, 5, 29, 17}
+	oidExtensionKeyUsage         = asn1.ObjectIdentifier{2, 5, 29, 15}
+	oidExtensionExtendedKeyUsage = asn1.ObjectIdentifier{2, 5, 29, 37}
+	oidExtensionBasicConstraints = asn1.ObjectIdentifier{2, 5, 29, 19}
+
+	oidExtKeyUsageServerAuth = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 1}
+	oidExtKeyUsageClientAuth = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 2}
+)
+
+// CSR 中可以请求的扩展密钥用途，是否允许由证书模板决定
+var requestableExtKeyUsages = []struct {
+	oid   asn1.ObjectIdentifier
+	usage x509.ExtKeyUsage
+}{
+	{oidExtKeyUsageServerAuth, x509.ExtKeyUsageServerAuth},
+	{oidExtKeyUsageClientAuth, x509.ExtKeyUsageClientAuth},
+	{asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 3}, x509.ExtKeyUsageCodeSigning},
+	{asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 4}, x509.ExtKeyUsageEmailProtection},
+	{asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 8}, x509.ExtKeyUsageTimeStamping},
+}
+
+// CSRPolicy CA接受CSR的策略，曲线与扩展的限制由证书模板决定
+type CSRPolicy struct {
+	AllowedKeyAlgorithms []x509.PublicKeyAlgorithm // 允许的公钥算法
+	MaxSANs              int                       // 主体备用名称数量上限
+}
+
+// DefaultCSRPolicy 默认接受 ECDSA（含 SM2）与 Ed25519 公钥，具体曲线由证书模板限制
+func DefaultCSRPolicy() CSRPolicy {
+	return CSRPolicy{
+		AllowedKeyAlgorithms: []x509.PublicKeyAlgorithm{x509.ECDSA, x509.Ed25519},
+		MaxSANs:              100,
+	}
+}
+
+// CreateCSR 用私钥生成DER编码的PKCS#10证书请求，SM2 私钥生成 SM2-with-SM3 签名的国密CSR
+func CreateCSR(template *x509.CertificateRequest, signer crypto.Signer) ([]byte, error) {
+	if smcrypto.IsSM2PublicKey(signer.Public()) {
+		return smcrypto.CreateCertificateRequest(template, signer)
+	}
+	return x509.CreateCertificateRequest(rand.Reader, template, signer)
+}
+
+// ParseCSR 解析PEM或DER编码的PKCS#10证书请求，支持国密CSR
+func ParseCSR(data []byte) (*x509.CertificateRequest, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		if block.Type != "CERTIFICATE REQUEST" && block.Type != "NEW CERTIFICATE REQUEST" {
+			return nil, fmt.Errorf("%w: unexpected PEM block type %q", ErrCSRMalformed, block.Type)
+		}
+		data = block.Bytes
+	}
+	csr, err := smcrypto.ParseCertificateRequest(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCSRMalformed, err)
+	}
+	return csr, nil
+}
+
+// ValidateCSR 验证CSR签名（私钥持有证明）并按策略检查公钥算法与扩展，返回签发请求
+// 签发证书的主体由调用方决定，CSR中的 Subject 不会被直接使用
+func (policy CSRPolicy) ValidateCSR(csr *x509.CertificateRequest, subject pkix.Name) (*IssuanceRequest, error) {
+	if err := smcrypto.CheckCertificateRequestSignature(csr); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCSRSignature, err)
+	}
+
+	if !policy.allowsAlgorithm(csr.PublicKeyAlgorithm) {
+		return nil, fmt.Errorf("%w: %s", ErrCSRKeyAlgorithm, csr.PublicKeyAlgorithm)
+	}
+
+	sanCount := len(csr.DNSNames) + len(csr.EmailAddresses) + len(csr.IPAddresses) + len(csr.URIs)
+	if policy.MaxSANs > 0 && sanCount > policy.MaxSANs {
+		return nil, fmt.Errorf("%w: %d names requested, at most %d allowed", ErrCSRSAN, sanCount, policy.MaxSANs)
+	}
+	for _, uri := range csr.URIs {
+		if uri.Scheme == "" {
+			return nil, fmt.Errorf("%w: URI %q has no scheme", ErrCSRSAN, uri.String())
+		}
+	}
+
+	request := &IssuanceRequest{
+		Subject:        subject,
+		PublicKey:      csr.PublicKey,
+		DNSNames:       csr.DNSNames,
+		EmailAddresses: csr.EmailAddresses,
+		IPAddresses:    csr.IPAddresses,
+		URIs:           csr.URIs,
+	}
+
+	for _, ext := range csr.Extensions {
+		switch {
+		case ext.Id.Equal(oidExtensionSubjectAltName):
+			// 已由 x509 解析到 DNSNames 等字段
+		case ext.Id.Equal(oidExtensionExtendedKeyUsage):
+			extKeyUsage, err := parseRequestedExtKeyUsage(ext.Value)
+			if err != nil {
+				return nil, err
+			}
+			request.ExtKeyUsage = extKeyUsage
+		case ext.Id.Equal(oidExtensionKeyUsage):
+			// 密钥用途由CA决定，忽略请求值
+		case ext.Id.Equal(oidExtensionBasicConstraints):
+			var constraints struct {
+				IsCA bool `asn1:"optional"`
+			}
+			if _, err := asn1.Unmarshal(ext.Value, &constraints); err != nil {
+				return nil, fmt.Errorf("%w: invalid basicConstraints: %v", ErrCSRExtension, err)
+			}
+			if constraints.IsCA {
+				return nil, fmt.Errorf("%w: CA certificates cannot be requested", ErrCSRExtension)
+			}
+		default:
+			// 其余扩展是否写入证书由证书模板的 optional_extensions 决定
+			request.Extensions = append(request.Extensions, ext)
+		}
+	}
+	return request, nil
+}
+
+func (policy CSRPolicy) allowsAlgorithm(algorithm x509.PublicKeyAlgorithm) bool {
+	for _, allowed := range policy.AllowedKeyAlgorithms {
+		if allowed == algorithm {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRequestedExtKeyUsage 解析CSR请求的扩展密钥用途
+func parseRequestedExtKeyUsage(value []byte) ([]x509.ExtKeyUsage, error) {
+	var oids []asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(value, &oids); err != nil {
+		return nil, fmt.Errorf("%w: invalid extKeyUsage: %v", ErrCSRExtension, err)
+	}
+
+	extKeyUsage := make([]x509.ExtKeyUsage, 0, len(oids))
+	for _, oid := range oids {
+		known := false
+		for _, requestable := range requestableExtKeyUsages {
+			if oid.Equal(requestable.oid) {
+				extKeyUsage = append(extKeyUsage, requestable.usage)
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("%w: extended key usage %s cannot be requested", ErrCSRExtension, oid)
+		}
+	}
+	return extKeyUsage, nil
+}
+
+// isCSRRejection 是否为CSR或证书模板拒绝签发，此类错误返回 400
+func isCSRRejection(err error) bool {
+	for _, csrErr := range []error{ErrCSRMalformed, ErrCSRSignature, ErrCSRKeyAlgorithm, ErrCSRSAN, ErrCSRExtension,
+		ErrProfileNotFound, ErrProfileKey, ErrProfileEKU, ErrProfileExtension, ErrProfileNameConstraint} {
+		if errors.Is(err, csrErr) {
+			return true
+		}
+	}
+	return false
+}
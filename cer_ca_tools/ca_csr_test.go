@@ -0,0 +1,142 @@
+package cer_ca_tools
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+)
+
+func createTestCSR(t *testing.T, curve elliptic.Curve, template *x509.CertificateRequest) []byte {
+	subjectSK, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key failed: %v", err)
+	}
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, template, subjectSK)
+	if err != nil {
+		t.Fatalf("create CSR failed: %v", err)
+	}
+	return csrDER
+}
+
+func TestIssueCertificateFromCSR(t *testing.T) {
+	manager := NewCAManagerWithStore(NewMemoryStore())
+	ca, err := manager.CreateCA("ca_csr_test")
+	if err != nil {
+		t.Fatalf("create CA failed: %v", err)
+	}
+	subject := pkix.Name{CommonName: "anonymous-csr"}
+
+	extKeyUsage, _ := asn1.Marshal([]asn1.ObjectIdentifier{oidExtKeyUsageClientAuth})
+	csrDER := createTestCSR(t, elliptic.P256(), &x509.CertificateRequest{
+		Subject:         pkix.Name{CommonName: "requested"},
+		ExtraExtensions: []pkix.Extension{{Id: oidExtensionExtendedKeyUsage, Value: extKeyUsage}},
+	})
+	csr, err := ParseCSR(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}))
+	if err != nil {
+		t.Fatalf("parse PEM CSR failed: %v", err)
+	}
+	response := ca.IssueCertificateFromCSR("", subject, csr)
+	if !response.Success {
+		t.Fatalf("issue from CSR failed: %s", response.Message)
+	}
+	cert := parseTestCertificate(t, response.Certificate)
+	if cert.Subject.CommonName != "anonymous-csr" {
+		t.Fatalf("certificate subject should come from the caller, have %s", cert.Subject.CommonName)
+	}
+	if len(cert.ExtKeyUsage) != 1 || cert.ExtKeyUsage[0] != x509.ExtKeyUsageClientAuth {
+		t.Fatalf("requested extKeyUsage not carried over: %v", cert.ExtKeyUsage)
+	}
+
+	// 名称约束允许的SAN照常签发
+	csr, _ = ParseCSR(createTestCSR(t, elliptic.P256(), &x509.CertificateRequest{
+		Subject:     subject,
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+	}))
+	response = ca.IssueCertificateFromCSR(ProfileVerifierServer, subject, csr)
+	if !response.Success {
+		t.Fatalf("issue verifier-server certificate from CSR failed: %s", response.Message)
+	}
+	cert = parseTestCertificate(t, response.Certificate)
+	if len(cert.DNSNames) != 1 || cert.DNSNames[0] != "localhost" || len(cert.IPAddresses) != 1 {
+		t.Fatalf("permitted SANs not carried over: %v %v", cert.DNSNames, cert.IPAddresses)
+	}
+
+	rejections := []struct {
+		name string
+		csr  []byte
+		want error
+	}{
+		{
+			name: "tampered signature",
+			csr: func() []byte {
+				der := createTestCSR(t, elliptic.P256(), &x509.CertificateRequest{Subject: subject})
+				der[len(der)-1] ^= 0xff
+				return der
+			}(),
+			want: ErrCSRSignature,
+		},
+		{
+			name: "curve not allowed",
+			csr:  createTestCSR(t, elliptic.P521(), &x509.CertificateRequest{Subject: subject}),
+			want: ErrProfileKey,
+		},
+		{
+			name: "CA requested",
+			csr: createTestCSR(t, elliptic.P256(), &x509.CertificateRequest{
+				Subject:         subject,
+				ExtraExtensions: []pkix.Extension{{Id: oidExtensionBasicConstraints, Critical: true, Value: []byte{0x30, 0x03, 0x01, 0x01, 0xff}}},
+			}),
+			want: ErrCSRExtension,
+		},
+		{
+			name: "unknown critical extension",
+			csr: createTestCSR(t, elliptic.P256(), &x509.CertificateRequest{
+				Subject:         subject,
+				ExtraExtensions: []pkix.Extension{{Id: asn1.ObjectIdentifier{1, 2, 3, 4}, Critical: true, Value: asn1.NullBytes}},
+			}),
+			want: ErrProfileExtension,
+		},
+		{
+			name: "DNS SAN in anonymous certificate",
+			csr:  createTestCSR(t, elliptic.P256(), &x509.CertificateRequest{Subject: subject, DNSNames: []string{"bank.example.com"}}),
+			want: ErrProfileNameConstraint,
+		},
+		{
+			name: "email SAN in anonymous certificate",
+			csr:  createTestCSR(t, elliptic.P256(), &x509.CertificateRequest{Subject: subject, EmailAddresses: []string{"alice@example.com"}}),
+			want: ErrProfileNameConstraint,
+		},
+		{
+			name: "IP SAN in anonymous certificate",
+			csr:  createTestCSR(t, elliptic.P256(), &x509.CertificateRequest{Subject: subject, IPAddresses: []net.IP{net.ParseIP("10.0.0.1")}}),
+			want: ErrProfileNameConstraint,
+		},
+		{
+			name: "URI SAN in anonymous certificate",
+			csr:  createTestCSR(t, elliptic.P256(), &x509.CertificateRequest{Subject: subject, URIs: []*url.URL{{Scheme: "https", Host: "alice.example.com"}}}),
+			want: ErrProfileNameConstraint,
+		},
+	}
+	for _, rejection := range rejections {
+		csr, err := ParseCSR(rejection.csr)
+		if err == nil {
+			response := ca.IssueCertificateFromCSR("", subject, csr)
+			if response.Success {
+				t.Fatalf("%s: CSR should be rejected", rejection.name)
+			}
+			err = response.Err
+		}
+		if !errors.Is(err, rejection.want) {
+			t.Fatalf("%s: expected %v, have %v", rejection.name, rejection.want, err)
+		}
+	}
+}
@@ -0,0 +1,426 @@
+package cer_ca_tools
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/bits"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FISCO-BCOS/go-sdk/smcrypto"
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// CTMaxGetEntries get-entries 单次返回的最大条目数
+const CTMaxGetEntries = 256
+
+// ctLogEntry 日志条目：MerkleTreeLeaf 与 PrecertChainEntry，对应 get-entries 的 leaf_input 与 extra_data
+type ctLogEntry struct {
+	LeafInput []byte `json:"leaf_input"`
+	ExtraData []byte `json:"extra_data"`
+}
+
+// CTLog RFC 6962 风格的证书透明日志，记录CA签发的每一张证书的预证书并返回SCT
+//
+// 证书在签发SCT时即并入Merkle树（最大合并延迟为0），条目以 JSON 行追加写入 path。
+type CTLog struct {
+	signer     crypto.Signer
+	logID      [32]byte
+	path       string
+	file       *os.File
+	entries    []ctLogEntry
+	leafHashes [][32]byte
+	leafIndex  map[[32]byte]uint64
+	mutex      sync.RWMutex
+}
+
+// NewCTLog 使用 ECDSA P-256 日志密钥打开证书透明日志；path 为空时只保存在内存中
+func NewCTLog(signer crypto.Signer, path string) (*CTLog, error) {
+	if err := checkCTLogKey(signer.Public()); err != nil {
+		return nil, err
+	}
+	logID, err := CTLogID(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	ctLog := &CTLog{signer: signer, logID: logID, path: path, leafIndex: make(map[[32]byte]uint64)}
+	if path == "" {
+		return ctLog, nil
+	}
+
+	if data, err := os.ReadFile(path); err == nil {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+		for scanner.Scan() {
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+			var entry ctLogEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				return nil, fmt.Errorf("failed to parse CT log entry %d: %w", len(ctLog.entries), err)
+			}
+			ctLog.appendLocked(entry)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read CT log: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read CT log: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CT log: %w", err)
+	}
+	ctLog.file = file
+	return ctLog, nil
+}
+
+// LogID 返回日志标识
+func (ctLog *CTLog) LogID() [32]byte {
+	return ctLog.logID
+}
+
+// PublicKey 返回日志公钥，验证者据此验证SCT与树头
+func (ctLog *CTLog) PublicKey() crypto.PublicKey {
+	return ctLog.signer.Public()
+}
+
+// PublicKeyPEM 返回 PEM 编码的日志公钥，供分发给验证者
+func (ctLog *CTLog) PublicKeyPEM() ([]byte, error) {
+	spki, err := smcrypto.MarshalPKIXPublicKey(ctLog.signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: spki}), nil
+}
+
+// TreeSize 返回日志当前条目数
+func (ctLog *CTLog) TreeSize() uint64 {
+	ctLog.mutex.RLock()
+	defer ctLog.mutex.RUnlock()
+
+	return uint64(len(ctLog.entries))
+}
+
+func (ctLog *CTLog) appendLocked(entry ctLogEntry) uint64 {
+	index := uint64(len(ctLog.entries))
+	leafHash := CTLeafHash(entry.LeafInput)
+	ctLog.entries = append(ctLog.entries, entry)
+	ctLog.leafHashes = append(ctLog.leafHashes, leafHash)
+	if _, exists := ctLog.leafIndex[leafHash]; !exists {
+		ctLog.leafIndex[leafHash] = index
+	}
+	return index
+}
+
+// AddPrecertificate 将预证书并入日志并返回SCT；预证书必须带毒化扩展且由 issuer 签发
+func (ctLog *CTLog) AddPrecertificate(precert, issuer *x509.Certificate) (*SignedCertificateTimestamp, error) {
+	if !hasExtension(precert.Extensions, oidCTPoison) {
+		return nil, fmt.Errorf("certificate is not a precertificate")
+	}
+	if err := checkSignatureFrom(precert, issuer); err != nil {
+		return nil, fmt.Errorf("precertificate is not signed by its issuer: %w", err)
+	}
+	tbs, err := tbsWithoutExtension(precert.RawTBSCertificate, oidCTPoison)
+	if err != nil {
+		return nil, err
+	}
+
+	sct := &SignedCertificateTimestamp{
+		Version:   ctVersionV1,
+		LogID:     ctLog.logID,
+		Timestamp: uint64(time.Now().UnixMilli()),
+	}
+	keyHash := issuerKeyHash(issuer)
+	digest := sha256.Sum256(ctSCTSignatureInput(sct.Timestamp, keyHash, tbs, sct.Extensions))
+	if sct.Signature, err = ctLog.signer.Sign(rand.Reader, digest[:], crypto.SHA256); err != nil {
+		return nil, fmt.Errorf("failed to sign SCT: %w", err)
+	}
+	entry := ctLogEntry{
+		LeafInput: ctMerkleTreeLeaf(sct.Timestamp, keyHash, tbs, sct.Extensions),
+		ExtraData: ctPrecertChainEntry(precert, issuer),
+	}
+
+	ctLog.mutex.Lock()
+	defer ctLog.mutex.Unlock()
+
+	if ctLog.file != nil {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode CT log entry: %w", err)
+		}
+		if _, err := ctLog.file.Write(append(line, '\n')); err != nil {
+			return nil, fmt.Errorf("failed to write CT log entry: %w", err)
+		}
+		if err := ctLog.file.Sync(); err != nil {
+			return nil, fmt.Errorf("failed to sync CT log: %w", err)
+		}
+	}
+	index := ctLog.appendLocked(entry)
+	log.Printf("CT log added precertificate %s as entry %d", precert.SerialNumber, index)
+	return sct, nil
+}
+
+// ctPrecertChainEntry 编码 PrecertChainEntry：预证书与签发链
+func ctPrecertChainEntry(precert, issuer *x509.Certificate) []byte {
+	var b cryptobyte.Builder
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(precert.Raw) })
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(issuer.Raw) })
+	})
+	return b.BytesOrPanic()
+}
+
+func hasExtension(extensions []pkix.Extension, oid []int) bool {
+	for _, extension := range extensions {
+		if extension.Id.Equal(oid) {
+			return true
+		}
+	}
+	return false
+}
+
+// SignedTreeHead 对当前树签发树头
+func (ctLog *CTLog) SignedTreeHead() (*SignedTreeHead, error) {
+	ctLog.mutex.RLock()
+	root := ctMerkleTreeHash(ctLog.leafHashes)
+	sth := &SignedTreeHead{
+		TreeSize:  uint64(len(ctLog.leafHashes)),
+		Timestamp: uint64(time.Now().UnixMilli()),
+		RootHash:  root[:],
+	}
+	ctLog.mutex.RUnlock()
+
+	digest := sha256.Sum256(ctTreeHeadSignatureInput(sth))
+	signature, err := ctLog.signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign tree head: %w", err)
+	}
+	var b cryptobyte.Builder
+	addDigitallySigned(&b, signature)
+	sth.TreeHeadSignature = b.BytesOrPanic()
+	return sth, nil
+}
+
+// InclusionProof 返回叶子在大小为 treeSize 的树中的序号与审计路径
+func (ctLog *CTLog) InclusionProof(leafHash [32]byte, treeSize uint64) (uint64, [][]byte, error) {
+	ctLog.mutex.RLock()
+	defer ctLog.mutex.RUnlock()
+
+	if treeSize == 0 || treeSize > uint64(len(ctLog.leafHashes)) {
+		return 0, nil, fmt.Errorf("tree size %d is not available", treeSize)
+	}
+	index, exists := ctLog.leafIndex[leafHash]
+	if !exists || index >= treeSize {
+		return 0, nil, fmt.Errorf("leaf is not included in tree of size %d", treeSize)
+	}
+	return index, ctInclusionPath(index, ctLog.leafHashes[:treeSize]), nil
+}
+
+// ConsistencyProof 返回两个树大小之间的一致性证明
+func (ctLog *CTLog) ConsistencyProof(first, second uint64) ([][]byte, error) {
+	ctLog.mutex.RLock()
+	defer ctLog.mutex.RUnlock()
+
+	if first == 0 || first > second || second > uint64(len(ctLog.leafHashes)) {
+		return nil, fmt.Errorf("invalid tree sizes %d and %d", first, second)
+	}
+	return ctConsistencyProof(first, ctLog.leafHashes[:second], true), nil
+}
+
+// Close 关闭日志文件
+func (ctLog *CTLog) Close() error {
+	ctLog.mutex.Lock()
+	defer ctLog.mutex.Unlock()
+
+	if ctLog.file == nil {
+		return nil
+	}
+	err := ctLog.file.Close()
+	ctLog.file = nil
+	return err
+}
+
+// ctSplit 小于 n 的最大的2的幂
+func ctSplit(n int) int {
+	return 1 << (bits.Len(uint(n-1)) - 1)
+}
+
+// ctMerkleTreeHash RFC 6962 2.1 MTH
+func ctMerkleTreeHash(leaves [][32]byte) [32]byte {
+	switch len(leaves) {
+	case 0:
+		return sha256.Sum256(nil)
+	case 1:
+		return leaves[0]
+	}
+	k := ctSplit(len(leaves))
+	left, right := ctMerkleTreeHash(leaves[:k]), ctMerkleTreeHash(leaves[k:])
+	return ctNodeHash(left[:], right[:])
+}
+
+// ctInclusionPath RFC 6962 2.1.1 PATH(m, D[n])
+func ctInclusionPath(m uint64, leaves [][32]byte) [][]byte {
+	if len(leaves) <= 1 {
+		return nil
+	}
+	k := ctSplit(len(leaves))
+	if m < uint64(k) {
+		sibling := ctMerkleTreeHash(leaves[k:])
+		return append(ctInclusionPath(m, leaves[:k]), sibling[:])
+	}
+	sibling := ctMerkleTreeHash(leaves[:k])
+	return append(ctInclusionPath(m-uint64(k), leaves[k:]), sibling[:])
+}
+
+// ctConsistencyProof RFC 6962 2.1.2 SUBPROOF(m, D[n], b)
+func ctConsistencyProof(m uint64, leaves [][32]byte, complete bool) [][]byte {
+	n := uint64(len(leaves))
+	if m == n {
+		if complete {
+			return nil
+		}
+		root := ctMerkleTreeHash(leaves)
+		return [][]byte{root[:]}
+	}
+	k := ctSplit(len(leaves))
+	if m <= uint64(k) {
+		sibling := ctMerkleTreeHash(leaves[k:])
+		return append(ctConsistencyProof(m, leaves[:k], complete), sibling[:])
+	}
+	sibling := ctMerkleTreeHash(leaves[:k])
+	return append(ctConsistencyProof(m-uint64(k), leaves[k:], false), sibling[:])
+}
+
+// ServeHTTP 提供 RFC 6962 第4节的只读接口：get-sth、get-sth-consistency、get-proof-by-hash 与 get-entries
+func (ctLog *CTLog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	query := r.URL.Query()
+	var response interface{}
+	switch strings.TrimPrefix(r.URL.Path, "/ct/v1/") {
+	case "get-sth":
+		sth, err := ctLog.SignedTreeHead()
+		if err != nil {
+			writeErrorResponse(w, err)
+			return
+		}
+		response = sth
+	case "get-sth-consistency":
+		first, errFirst := strconv.ParseUint(query.Get("first"), 10, 64)
+		second, errSecond := strconv.ParseUint(query.Get("second"), 10, 64)
+		if errFirst != nil || errSecond != nil {
+			writeAPIError(w, http.StatusBadRequest, APIErrorBadRequest, "first and second are required", nil)
+			return
+		}
+		proof, err := ctLog.ConsistencyProof(first, second)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, APIErrorBadRequest, err.Error(), nil)
+			return
+		}
+		response = struct {
+			Consistency [][]byte `json:"consistency"`
+		}{proof}
+	case "get-proof-by-hash":
+		hash, errHash := base64.StdEncoding.DecodeString(query.Get("hash"))
+		treeSize, errSize := strconv.ParseUint(query.Get("tree_size"), 10, 64)
+		if errHash != nil || len(hash) != sha256.Size || errSize != nil {
+			writeAPIError(w, http.StatusBadRequest, APIErrorBadRequest, "hash and tree_size are required", nil)
+			return
+		}
+		var leafHash [32]byte
+		copy(leafHash[:], hash)
+		index, proof, err := ctLog.InclusionProof(leafHash, treeSize)
+		if err != nil {
+			writeAPIError(w, http.StatusNotFound, APIErrorNotFound, err.Error(), nil)
+			return
+		}
+		response = struct {
+			LeafIndex uint64   `json:"leaf_index"`
+			AuditPath [][]byte `json:"audit_path"`
+		}{index, proof}
+	case "get-entries":
+		start, errStart := strconv.ParseUint(query.Get("start"), 10, 64)
+		end, errEnd := strconv.ParseUint(query.Get("end"), 10, 64)
+		if errStart != nil || errEnd != nil || start > end {
+			writeAPIError(w, http.StatusBadRequest, APIErrorBadRequest, "start and end are required", nil)
+			return
+		}
+		ctLog.mutex.RLock()
+		size := uint64(len(ctLog.entries))
+		if end >= size {
+			end = size - 1
+		}
+		if end-start >= CTMaxGetEntries {
+			end = start + CTMaxGetEntries - 1
+		}
+		var entries []ctLogEntry
+		if start < size {
+			entries = append(entries, ctLog.entries[start:end+1]...)
+		}
+		ctLog.mutex.RUnlock()
+		if len(entries) == 0 {
+			writeAPIError(w, http.StatusBadRequest, APIErrorBadRequest, "no entries in range", nil)
+			return
+		}
+		response = struct {
+			Entries []ctLogEntry `json:"entries"`
+		}{entries}
+	default:
+		writeAPIError(w, http.StatusNotFound, APIErrorNotFound, fmt.Sprintf("no such endpoint %s", r.URL.Path), nil)
+		return
+	}
+
+	writeAPIJSON(w, http.StatusOK, response)
+}
+
+// EnableCTLog 启用证书透明日志，此后签发的证书都先以预证书记入日志，并嵌入返回的SCT
+func (manager *CAManager) EnableCTLog(signer crypto.Signer, path string) error {
+	ctLog, err := NewCTLog(signer, path)
+	if err != nil {
+		return err
+	}
+
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	manager.CT = ctLog
+	for _, ca := range manager.CAs {
+		ca.ct = ctLog
+	}
+	return nil
+}
+
+// logPrecertificate 按模板签发带毒化扩展的预证书并记入日志，返回的SCT嵌入最终证书
+func (ca *CA) logPrecertificate(template *x509.Certificate, publicKey crypto.PublicKey) (*SignedCertificateTimestamp, error) {
+	precertTemplate := *template
+	precertTemplate.ExtraExtensions = append(append([]pkix.Extension{}, template.ExtraExtensions...),
+		pkix.Extension{Id: oidCTPoison, Critical: true, Value: asn1.NullBytes})
+	precertDER, err := CreateCertificate(&precertTemplate, ca.Certificate, publicKey, ca.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create precertificate: %w", err)
+	}
+	precert, err := ParseCertificate(precertDER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse precertificate: %w", err)
+	}
+	return ca.ct.AddPrecertificate(precert, ca.Certificate)
+}
@@ -0,0 +1,454 @@
+package cer_ca_tools
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+
+	"github.com/FISCO-BCOS/go-sdk/smcrypto"
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// RFC 6962 定义的扩展：预证书毒化扩展与嵌入式SCT列表
+var (
+	oidCTPoison  = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3}
+	oidCTSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+)
+
+const (
+	ctVersionV1           = 0
+	ctSignatureTypeCert   = 0 // certificate_timestamp
+	ctSignatureTypeTree   = 1 // tree_hash
+	ctEntryTypePrecert    = 1 // precert_entry
+	ctHashAlgorithmSHA256 = 4
+	ctSignatureAlgECDSA   = 3
+	ctMerkleLeafPrefix    = 0x00
+	ctMerkleNodePrefix    = 0x01
+	ctLeafTypeTimestamped = 0 // timestamped_entry
+	ctMaxEmbeddedSCTs     = 16
+)
+
+var (
+	ErrSCTMissing      = errors.New("certificate carries no valid SCT from a trusted log")
+	ErrSCTSignature    = errors.New("SCT signature verification failed")
+	ErrCTProof         = errors.New("Merkle proof verification failed")
+	ErrCTTreeHead      = errors.New("signed tree head verification failed")
+	errCTMalformedData = errors.New("malformed CT structure")
+)
+
+// SignedCertificateTimestamp RFC 6962 SCT，LogID 为日志公钥 SubjectPublicKeyInfo 的 SHA-256
+type SignedCertificateTimestamp struct {
+	Version    uint8
+	LogID      [32]byte
+	Timestamp  uint64 // 毫秒
+	Extensions []byte
+	Signature  []byte // ECDSA ASN.1 签名
+}
+
+// SignedTreeHead RFC 6962 get-sth 响应，TreeHeadSignature 为 TLS DigitallySigned 编码
+type SignedTreeHead struct {
+	TreeSize          uint64 `json:"tree_size"`
+	Timestamp         uint64 `json:"timestamp"`
+	RootHash          []byte `json:"sha256_root_hash"`
+	TreeHeadSignature []byte `json:"tree_head_signature"`
+}
+
+// CTLogID 返回日志公钥的 LogID
+func CTLogID(pub crypto.PublicKey) ([32]byte, error) {
+	spki, err := smcrypto.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return [32]byte{}, fmt.Errorf("failed to marshal CT log key: %w", err)
+	}
+	return sha256.Sum256(spki), nil
+}
+
+// checkCTLogKey RFC 6962 日志密钥使用 ECDSA P-256
+func checkCTLogKey(pub crypto.PublicKey) error {
+	key, ok := pub.(*ecdsa.PublicKey)
+	if !ok || key.Curve != elliptic.P256() {
+		return fmt.Errorf("CT log key must be ECDSA P-256, have %T", pub)
+	}
+	return nil
+}
+
+// Marshal 按 RFC 6962 TLS 格式编码 SCT
+func (sct *SignedCertificateTimestamp) Marshal() []byte {
+	var b cryptobyte.Builder
+	b.AddUint8(sct.Version)
+	b.AddBytes(sct.LogID[:])
+	b.AddUint64(sct.Timestamp)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(sct.Extensions) })
+	addDigitallySigned(&b, sct.Signature)
+	return b.BytesOrPanic()
+}
+
+// ParseSCT 解析 TLS 编码的 SCT
+func ParseSCT(data []byte) (*SignedCertificateTimestamp, error) {
+	input := cryptobyte.String(data)
+	sct := &SignedCertificateTimestamp{}
+	var logID, extensions []byte
+	if !input.ReadUint8(&sct.Version) || !input.ReadBytes(&logID, 32) || !input.ReadUint64(&sct.Timestamp) ||
+		!readUint16Bytes(&input, &extensions) {
+		return nil, fmt.Errorf("%w: SCT", errCTMalformedData)
+	}
+	signature, err := readDigitallySigned(&input)
+	if err != nil || !input.Empty() {
+		return nil, fmt.Errorf("%w: SCT signature", errCTMalformedData)
+	}
+	if sct.Version != ctVersionV1 {
+		return nil, fmt.Errorf("%w: unsupported SCT version %d", errCTMalformedData, sct.Version)
+	}
+	copy(sct.LogID[:], logID)
+	sct.Extensions, sct.Signature = extensions, signature
+	return sct, nil
+}
+
+func readUint16Bytes(input *cryptobyte.String, out *[]byte) bool {
+	var value cryptobyte.String
+	if !input.ReadUint16LengthPrefixed(&value) {
+		return false
+	}
+	*out = append([]byte{}, value...)
+	return true
+}
+
+func addDigitallySigned(b *cryptobyte.Builder, signature []byte) {
+	b.AddUint8(ctHashAlgorithmSHA256)
+	b.AddUint8(ctSignatureAlgECDSA)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(signature) })
+}
+
+func readDigitallySigned(input *cryptobyte.String) ([]byte, error) {
+	var hashAlg, sigAlg uint8
+	var signature []byte
+	if !input.ReadUint8(&hashAlg) || !input.ReadUint8(&sigAlg) || !readUint16Bytes(input, &signature) {
+		return nil, errCTMalformedData
+	}
+	if hashAlg != ctHashAlgorithmSHA256 || sigAlg != ctSignatureAlgECDSA {
+		return nil, fmt.Errorf("%w: unsupported signature algorithm %d/%d", errCTMalformedData, hashAlg, sigAlg)
+	}
+	return signature, nil
+}
+
+// ctPrecertEntry 预证书条目的公共部分：timestamp、entry_type、signed_entry 与 extensions，
+// MerkleTreeLeaf 与 SCT 签名输入都由它构成
+func ctPrecertEntry(b *cryptobyte.Builder, timestamp uint64, issuerKeyHash [32]byte, tbs []byte, extensions []byte) {
+	b.AddUint64(timestamp)
+	b.AddUint16(ctEntryTypePrecert)
+	b.AddBytes(issuerKeyHash[:])
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(tbs) })
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(extensions) })
+}
+
+// ctMerkleTreeLeaf 编码预证书的 MerkleTreeLeaf（RFC 6962 3.4）
+func ctMerkleTreeLeaf(timestamp uint64, issuerKeyHash [32]byte, tbs []byte, extensions []byte) []byte {
+	var b cryptobyte.Builder
+	b.AddUint8(ctVersionV1)
+	b.AddUint8(ctLeafTypeTimestamped)
+	ctPrecertEntry(&b, timestamp, issuerKeyHash, tbs, extensions)
+	return b.BytesOrPanic()
+}
+
+// ctSCTSignatureInput SCT 签名覆盖的数据（RFC 6962 3.2）
+func ctSCTSignatureInput(timestamp uint64, issuerKeyHash [32]byte, tbs []byte, extensions []byte) []byte {
+	var b cryptobyte.Builder
+	b.AddUint8(ctVersionV1)
+	b.AddUint8(ctSignatureTypeCert)
+	ctPrecertEntry(&b, timestamp, issuerKeyHash, tbs, extensions)
+	return b.BytesOrPanic()
+}
+
+// ctTreeHeadSignatureInput STH 签名覆盖的数据（RFC 6962 3.5）
+func ctTreeHeadSignatureInput(sth *SignedTreeHead) []byte {
+	var b cryptobyte.Builder
+	b.AddUint8(ctVersionV1)
+	b.AddUint8(ctSignatureTypeTree)
+	b.AddUint64(sth.Timestamp)
+	b.AddUint64(sth.TreeSize)
+	b.AddBytes(sth.RootHash)
+	return b.BytesOrPanic()
+}
+
+// CTLeafHash 叶子哈希 SHA-256(0x00 || leaf)
+func CTLeafHash(leaf []byte) [32]byte {
+	return sha256.Sum256(append([]byte{ctMerkleLeafPrefix}, leaf...))
+}
+
+func ctNodeHash(left, right []byte) [32]byte {
+	data := make([]byte, 0, 1+len(left)+len(right))
+	data = append(data, ctMerkleNodePrefix)
+	data = append(data, left...)
+	return sha256.Sum256(append(data, right...))
+}
+
+// issuerKeyHash 签发CA公钥 SubjectPublicKeyInfo 的 SHA-256
+func issuerKeyHash(issuer *x509.Certificate) [32]byte {
+	return sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+}
+
+// tbsWithoutExtension 删除 TBSCertificate 中指定的扩展，用于从预证书或最终证书还原日志中的 TBS
+func tbsWithoutExtension(tbsDER []byte, oid asn1.ObjectIdentifier) ([]byte, error) {
+	input := cryptobyte.String(tbsDER)
+	var tbs cryptobyte.String
+	if !input.ReadASN1(&tbs, cryptobyte_asn1.SEQUENCE) || !input.Empty() {
+		return nil, fmt.Errorf("%w: TBSCertificate", errCTMalformedData)
+	}
+
+	extensionsTag := cryptobyte_asn1.Tag(3).Constructed().ContextSpecific()
+	var b cryptobyte.Builder
+	var parseErr error
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		for !tbs.Empty() {
+			var element cryptobyte.String
+			var tag cryptobyte_asn1.Tag
+			if !tbs.ReadAnyASN1Element(&element, &tag) {
+				parseErr = fmt.Errorf("%w: TBSCertificate field", errCTMalformedData)
+				return
+			}
+			if tag != extensionsTag {
+				b.AddBytes(element)
+				continue
+			}
+
+			var wrapper, extensions cryptobyte.String
+			if !element.ReadASN1(&wrapper, extensionsTag) || !wrapper.ReadASN1(&extensions, cryptobyte_asn1.SEQUENCE) {
+				parseErr = fmt.Errorf("%w: certificate extensions", errCTMalformedData)
+				return
+			}
+			var kept [][]byte
+			for !extensions.Empty() {
+				var extension, body cryptobyte.String
+				var extensionOID asn1.ObjectIdentifier
+				if !extensions.ReadASN1Element(&extension, cryptobyte_asn1.SEQUENCE) {
+					parseErr = fmt.Errorf("%w: certificate extension", errCTMalformedData)
+					return
+				}
+				element := extension
+				if !element.ReadASN1(&body, cryptobyte_asn1.SEQUENCE) || !body.ReadASN1ObjectIdentifier(&extensionOID) {
+					parseErr = fmt.Errorf("%w: certificate extension", errCTMalformedData)
+					return
+				}
+				if !extensionOID.Equal(oid) {
+					kept = append(kept, extension)
+				}
+			}
+			if len(kept) == 0 {
+				continue
+			}
+			b.AddASN1(extensionsTag, func(b *cryptobyte.Builder) {
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					for _, extension := range kept {
+						b.AddBytes(extension)
+					}
+				})
+			})
+		}
+	})
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	return b.Bytes()
+}
+
+// sctListExtension 将SCT编码为嵌入证书的 SignedCertificateTimestampList 扩展
+func sctListExtension(scts ...*SignedCertificateTimestamp) (pkix.Extension, error) {
+	var b cryptobyte.Builder
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, sct := range scts {
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(sct.Marshal()) })
+		}
+	})
+	list, err := b.Bytes()
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	value, err := asn1.Marshal(list)
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	return pkix.Extension{Id: oidCTSCTList, Value: value}, nil
+}
+
+// EmbeddedSCTs 解析证书中嵌入的SCT列表，证书未嵌入SCT时返回空列表
+func EmbeddedSCTs(cert *x509.Certificate) ([]*SignedCertificateTimestamp, error) {
+	for _, extension := range cert.Extensions {
+		if !extension.Id.Equal(oidCTSCTList) {
+			continue
+		}
+		var list []byte
+		if rest, err := asn1.Unmarshal(extension.Value, &list); err != nil || len(rest) > 0 {
+			return nil, fmt.Errorf("%w: SCT list extension", errCTMalformedData)
+		}
+		input := cryptobyte.String(list)
+		var entries cryptobyte.String
+		if !input.ReadUint16LengthPrefixed(&entries) || !input.Empty() {
+			return nil, fmt.Errorf("%w: SCT list", errCTMalformedData)
+		}
+		var scts []*SignedCertificateTimestamp
+		for !entries.Empty() && len(scts) < ctMaxEmbeddedSCTs {
+			var entry []byte
+			if !readUint16Bytes(&entries, &entry) {
+				return nil, fmt.Errorf("%w: SCT list", errCTMalformedData)
+			}
+			sct, err := ParseSCT(entry)
+			if err != nil {
+				return nil, err
+			}
+			scts = append(scts, sct)
+		}
+		return scts, nil
+	}
+	return nil, nil
+}
+
+// precertTBS 由嵌入SCT的最终证书还原日志记录的预证书 TBS
+func precertTBS(cert *x509.Certificate) ([]byte, error) {
+	return tbsWithoutExtension(cert.RawTBSCertificate, oidCTSCTList)
+}
+
+// EmbeddedSCTLeaf 返回最终证书中某个SCT对应的 MerkleTreeLeaf，用于向日志查询包含证明
+func EmbeddedSCTLeaf(cert, issuer *x509.Certificate, sct *SignedCertificateTimestamp) ([]byte, error) {
+	tbs, err := precertTBS(cert)
+	if err != nil {
+		return nil, err
+	}
+	return ctMerkleTreeLeaf(sct.Timestamp, issuerKeyHash(issuer), tbs, sct.Extensions), nil
+}
+
+// VerifyEmbeddedSCT 验证最终证书中嵌入的一个SCT是否由 logKey 对应的日志签发
+func VerifyEmbeddedSCT(cert, issuer *x509.Certificate, sct *SignedCertificateTimestamp, logKey crypto.PublicKey) error {
+	logID, err := CTLogID(logKey)
+	if err != nil {
+		return err
+	}
+	if logID != sct.LogID {
+		return fmt.Errorf("%w: SCT is from another log", ErrSCTSignature)
+	}
+	tbs, err := precertTBS(cert)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(ctSCTSignatureInput(sct.Timestamp, issuerKeyHash(issuer), tbs, sct.Extensions))
+	if err := verifyDigestSignature(logKey, digest[:], sct.Signature); err != nil {
+		return fmt.Errorf("%w: %v", ErrSCTSignature, err)
+	}
+	return nil
+}
+
+// VerifyEmbeddedSCTs 要求证书至少嵌入一个由可信日志签发的有效SCT
+func VerifyEmbeddedSCTs(cert, issuer *x509.Certificate, logKeys []crypto.PublicKey) error {
+	scts, err := EmbeddedSCTs(cert)
+	if err != nil {
+		return err
+	}
+	for _, sct := range scts {
+		for _, logKey := range logKeys {
+			if VerifyEmbeddedSCT(cert, issuer, sct, logKey) == nil {
+				return nil
+			}
+		}
+	}
+	return ErrSCTMissing
+}
+
+// VerifySignedTreeHead 验证日志树头签名
+func VerifySignedTreeHead(sth *SignedTreeHead, logKey crypto.PublicKey) error {
+	if len(sth.RootHash) != sha256.Size {
+		return fmt.Errorf("%w: invalid root hash length", ErrCTTreeHead)
+	}
+	input := cryptobyte.String(sth.TreeHeadSignature)
+	signature, err := readDigitallySigned(&input)
+	if err != nil || !input.Empty() {
+		return fmt.Errorf("%w: malformed signature", ErrCTTreeHead)
+	}
+	digest := sha256.Sum256(ctTreeHeadSignatureInput(sth))
+	if err := verifyDigestSignature(logKey, digest[:], signature); err != nil {
+		return fmt.Errorf("%w: %v", ErrCTTreeHead, err)
+	}
+	return nil
+}
+
+// VerifyInclusionProof 验证叶子 index 在大小为 treeSize、根为 root 的树中的包含证明（RFC 9162 2.1.3.2）
+func VerifyInclusionProof(leafHash [32]byte, index, treeSize uint64, proof [][]byte, root []byte) error {
+	if index >= treeSize {
+		return fmt.Errorf("%w: leaf index %d out of tree size %d", ErrCTProof, index, treeSize)
+	}
+	fn, sn := index, treeSize-1
+	r := leafHash
+	for _, p := range proof {
+		if sn == 0 {
+			return fmt.Errorf("%w: inclusion proof too long", ErrCTProof)
+		}
+		if fn&1 == 1 || fn == sn {
+			r = ctNodeHash(p, r[:])
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = ctNodeHash(r[:], p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 || !bytes.Equal(r[:], root) {
+		return fmt.Errorf("%w: inclusion proof does not match root", ErrCTProof)
+	}
+	return nil
+}
+
+// VerifyConsistencyProof 验证大小为 first 的树是大小为 second 的树的前缀（RFC 9162 2.1.4.2）
+func VerifyConsistencyProof(first, second uint64, firstRoot, secondRoot []byte, proof [][]byte) error {
+	switch {
+	case first == 0 || first > second:
+		return fmt.Errorf("%w: invalid tree sizes %d and %d", ErrCTProof, first, second)
+	case first == second:
+		if len(proof) != 0 || !bytes.Equal(firstRoot, secondRoot) {
+			return fmt.Errorf("%w: trees of equal size differ", ErrCTProof)
+		}
+		return nil
+	case len(proof) == 0:
+		return fmt.Errorf("%w: empty consistency proof", ErrCTProof)
+	}
+
+	if first&(first-1) == 0 {
+		proof = append([][]byte{firstRoot}, proof...)
+	}
+	fn, sn := first-1, second-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+	var fr, sr [32]byte
+	copy(fr[:], proof[0])
+	copy(sr[:], proof[0])
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return fmt.Errorf("%w: consistency proof too long", ErrCTProof)
+		}
+		if fn&1 == 1 || fn == sn {
+			fr = ctNodeHash(c, fr[:])
+			sr = ctNodeHash(c, sr[:])
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = ctNodeHash(sr[:], c)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 || !bytes.Equal(fr[:], firstRoot) || !bytes.Equal(sr[:], secondRoot) {
+		return fmt.Errorf("%w: consistency proof does not match roots", ErrCTProof)
+	}
+	return nil
+}
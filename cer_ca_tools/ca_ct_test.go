@@ -0,0 +1,138 @@
+package cer_ca_tools
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestCTMerkleProofs(t *testing.T) {
+	var leaves [][32]byte
+	for i := 0; i < 20; i++ {
+		leaves = append(leaves, CTLeafHash([]byte{byte(i)}))
+	}
+	for n := 1; n <= len(leaves); n++ {
+		root := ctMerkleTreeHash(leaves[:n])
+		for m := 0; m < n; m++ {
+			proof := ctInclusionPath(uint64(m), leaves[:n])
+			if err := VerifyInclusionProof(leaves[m], uint64(m), uint64(n), proof, root[:]); err != nil {
+				t.Fatalf("inclusion proof of leaf %d in tree %d failed: %v", m, n, err)
+			}
+			if err := VerifyInclusionProof(leaves[(m+1)%n], uint64(m), uint64(n), proof, root[:]); n > 1 && err == nil {
+				t.Fatalf("inclusion proof of leaf %d in tree %d accepted a wrong leaf", m, n)
+			}
+
+			firstRoot := ctMerkleTreeHash(leaves[:m+1])
+			proof = ctConsistencyProof(uint64(m+1), leaves[:n], true)
+			if err := VerifyConsistencyProof(uint64(m+1), uint64(n), firstRoot[:], root[:], proof); err != nil {
+				t.Fatalf("consistency proof %d -> %d failed: %v", m+1, n, err)
+			}
+			if m+1 < n {
+				forged := ctMerkleTreeHash(append([][32]byte{leaves[1]}, leaves[1:m+1]...))
+				if err := VerifyConsistencyProof(uint64(m+1), uint64(n), forged[:], root[:], proof); err == nil {
+					t.Fatalf("consistency proof %d -> %d accepted a forged root", m+1, n)
+				}
+			}
+		}
+	}
+}
+
+func TestCTLog(t *testing.T) {
+	manager := NewCAManagerWithStore(NewMemoryStore())
+	logKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err := manager.EnableCTLog(logKey, ""); err != nil {
+		t.Fatalf("enable CT log failed: %v", err)
+	}
+	ca, err := manager.CreateCA("ca_ct_test")
+	if err != nil {
+		t.Fatalf("create CA failed: %v", err)
+	}
+	server := httptest.NewServer(manager.CT)
+	defer server.Close()
+
+	getJSON := func(path string, query url.Values, out interface{}) {
+		response, err := http.Get(server.URL + path + "?" + query.Encode())
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s failed: %s", path, response.Status)
+		}
+		json.NewDecoder(response.Body).Decode(out)
+	}
+
+	var roots [][]byte
+	for i := 0; i < 3; i++ {
+		subjectKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		response := ca.IssueCertificate(pkix.Name{CommonName: fmt.Sprintf("ct subject %d", i)}, &subjectKey.PublicKey)
+		if !response.Success {
+			t.Fatalf("issue certificate failed: %s", response.Message)
+		}
+		cert := parseTestCertificate(t, response.Certificate)
+
+		// SCT 嵌入证书，并随响应返回
+		scts, err := EmbeddedSCTs(cert)
+		if err != nil || len(scts) != 1 || len(response.SCTs) != 1 || string(scts[0].Marshal()) != string(response.SCTs[0]) {
+			t.Fatalf("certificate should embed the returned SCT: %v", err)
+		}
+		if err := VerifyEmbeddedSCTs(cert, ca.Certificate, []crypto.PublicKey{logKey.Public()}); err != nil {
+			t.Fatalf("verify embedded SCT failed: %v", err)
+		}
+		otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err := VerifyEmbeddedSCTs(cert, ca.Certificate, []crypto.PublicKey{otherKey.Public()}); !errors.Is(err, ErrSCTMissing) {
+			t.Fatalf("SCT from an unknown log should be rejected, have %v", err)
+		}
+
+		var sth SignedTreeHead
+		getJSON("/ct/v1/get-sth", nil, &sth)
+		if err := VerifySignedTreeHead(&sth, logKey.Public()); err != nil || sth.TreeSize != uint64(i+1) {
+			t.Fatalf("invalid tree head of size %d: %v", sth.TreeSize, err)
+		}
+		roots = append(roots, sth.RootHash)
+
+		// 由最终证书还原日志叶子，并验证包含证明
+		leaf, err := EmbeddedSCTLeaf(cert, ca.Certificate, scts[0])
+		if err != nil {
+			t.Fatalf("rebuild CT leaf failed: %v", err)
+		}
+		leafHash := CTLeafHash(leaf)
+		var inclusion struct {
+			LeafIndex uint64   `json:"leaf_index"`
+			AuditPath [][]byte `json:"audit_path"`
+		}
+		getJSON("/ct/v1/get-proof-by-hash", url.Values{
+			"hash":      {base64.StdEncoding.EncodeToString(leafHash[:])},
+			"tree_size": {fmt.Sprint(sth.TreeSize)},
+		}, &inclusion)
+		if err := VerifyInclusionProof(leafHash, inclusion.LeafIndex, sth.TreeSize, inclusion.AuditPath, sth.RootHash); err != nil {
+			t.Fatalf("verify inclusion proof failed: %v", err)
+		}
+	}
+
+	var consistency struct {
+		Consistency [][]byte `json:"consistency"`
+	}
+	getJSON("/ct/v1/get-sth-consistency", url.Values{"first": {"1"}, "second": {"3"}}, &consistency)
+	if err := VerifyConsistencyProof(1, 3, roots[0], roots[2], consistency.Consistency); err != nil {
+		t.Fatalf("verify consistency proof failed: %v", err)
+	}
+
+	var entries struct {
+		Entries []ctLogEntry `json:"entries"`
+	}
+	getJSON("/ct/v1/get-entries", url.Values{"start": {"0"}, "end": {"10"}}, &entries)
+	if len(entries.Entries) != 3 {
+		t.Fatalf("expected 3 entries, have %d", len(entries.Entries))
+	}
+}
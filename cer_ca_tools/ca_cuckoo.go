@@ -0,0 +1,258 @@
+package cer_ca_tools
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math/bits"
+	"math/rand/v2"
+	"sync"
+)
+
+// CuckooFilter 布谷鸟过滤器（Fan et al. 2014）。每个桶 4 个 16 位指纹，元素可放在两个候选桶之一，
+// 另一个桶下标由当前桶下标与指纹的哈希异或得到，因此删除与迁移都只需指纹。
+// 两个桶都满时随机踢出已有指纹，迁移 cuckooMaxKicks 次仍失败则把最后一个指纹放入 victim，
+// 之后的插入返回 ErrFilterFull，直到删除腾出位置。
+//
+// 二进制格式（大端）：magic "CKF" | version(1) | buckets(8) | elements(8) |
+// victim 标志(1) victim 指纹(2) victim 桶(8) | 指纹(2 字节 × buckets × 4) | crc32(4)
+type CuckooFilter struct {
+	mutex    sync.RWMutex
+	table    []uint16 // 指纹，0 表示空位
+	mask     uint64   // 桶数为 2 的幂，mask = 桶数 - 1
+	elements uint
+	victim   cuckooVictim
+}
+
+type cuckooVictim struct {
+	used        bool
+	fingerprint uint16
+	index       uint64
+}
+
+const (
+	CuckooFormatVersion = 1
+
+	cuckooBucketSize = 4
+	cuckooMaxKicks   = 500
+	// cuckooMaxLoad 预期元素数按该装载率换算为桶数
+	cuckooMaxLoad = 0.95
+	// cuckooMaxBuckets 反序列化时允许的最大桶数
+	cuckooMaxBuckets = 1 << 28
+)
+
+var cuckooMagic = [3]byte{'C', 'K', 'F'}
+
+var ErrInvalidCuckooData = errors.New("invalid cuckoo filter data")
+
+// NewCuckooFilter 按预期元素数创建过滤器，桶数向上取为 2 的幂
+func NewCuckooFilter(elements uint) *CuckooFilter {
+	buckets := uint64(float64(elements)/cuckooBucketSize/cuckooMaxLoad) + 1
+	buckets = 1 << bits.Len64(buckets-1)
+	return &CuckooFilter{
+		table: make([]uint16, buckets*cuckooBucketSize),
+		mask:  buckets - 1,
+	}
+}
+
+// cuckooHash 由 SHA-256 摘要得到第一个候选桶与非零指纹
+func (cf *CuckooFilter) cuckooHash(data []byte) (uint64, uint16) {
+	sum := sha256.Sum256(data)
+	fingerprint := binary.BigEndian.Uint16(sum[8:10])
+	if fingerprint == 0 {
+		fingerprint = 1
+	}
+	return binary.BigEndian.Uint64(sum[:8]) & cf.mask, fingerprint
+}
+
+func (cf *CuckooFilter) altIndex(index uint64, fingerprint uint16) uint64 {
+	return (index ^ uint64(fingerprint)*0x5bd1e995) & cf.mask
+}
+
+func (cf *CuckooFilter) bucket(index uint64) []uint16 {
+	return cf.table[index*cuckooBucketSize : (index+1)*cuckooBucketSize]
+}
+
+func (cf *CuckooFilter) insert(index uint64, fingerprint uint16) bool {
+	for i, slot := range cf.bucket(index) {
+		if slot == 0 {
+			cf.bucket(index)[i] = fingerprint
+			return true
+		}
+	}
+	return false
+}
+
+func (cf *CuckooFilter) delete(index uint64, fingerprint uint16) bool {
+	for i, slot := range cf.bucket(index) {
+		if slot == fingerprint {
+			cf.bucket(index)[i] = 0
+			return true
+		}
+	}
+	return false
+}
+
+func (cf *CuckooFilter) Add(data []byte) error {
+	cf.mutex.Lock()
+	defer cf.mutex.Unlock()
+
+	if cf.victim.used {
+		return ErrFilterFull
+	}
+	index, fingerprint := cf.cuckooHash(data)
+	if !cf.insert(index, fingerprint) && !cf.insert(cf.altIndex(index, fingerprint), fingerprint) {
+		cf.kick(cf.altIndex(index, fingerprint), fingerprint)
+	}
+	cf.elements++
+	return nil
+}
+
+// kick 从 index 开始迁移指纹，失败时把最后被踢出的指纹放入 victim，元素不会丢失
+func (cf *CuckooFilter) kick(index uint64, fingerprint uint16) {
+	for i := 0; i < cuckooMaxKicks; i++ {
+		slot := rand.IntN(cuckooBucketSize)
+		bucket := cf.bucket(index)
+		fingerprint, bucket[slot] = bucket[slot], fingerprint
+		index = cf.altIndex(index, fingerprint)
+		if cf.insert(index, fingerprint) {
+			return
+		}
+	}
+	cf.victim = cuckooVictim{used: true, fingerprint: fingerprint, index: index}
+}
+
+func (cf *CuckooFilter) Remove(data []byte) error {
+	cf.mutex.Lock()
+	defer cf.mutex.Unlock()
+
+	index, fingerprint := cf.cuckooHash(data)
+	alt := cf.altIndex(index, fingerprint)
+	switch {
+	case cf.delete(index, fingerprint) || cf.delete(alt, fingerprint):
+	case cf.victim.used && cf.victim.fingerprint == fingerprint && (cf.victim.index == index || cf.victim.index == alt):
+		cf.victim = cuckooVictim{}
+		cf.elements--
+		return nil
+	default:
+		return ErrFilterElementNotFound
+	}
+	cf.elements--
+
+	// 腾出位置后重新放入 victim
+	if victim := cf.victim; victim.used {
+		cf.victim = cuckooVictim{}
+		if !cf.insert(victim.index, victim.fingerprint) && !cf.insert(cf.altIndex(victim.index, victim.fingerprint), victim.fingerprint) {
+			cf.kick(cf.altIndex(victim.index, victim.fingerprint), victim.fingerprint)
+		}
+	}
+	return nil
+}
+
+func (cf *CuckooFilter) Contains(data []byte) bool {
+	cf.mutex.RLock()
+	defer cf.mutex.RUnlock()
+
+	index, fingerprint := cf.cuckooHash(data)
+	alt := cf.altIndex(index, fingerprint)
+	for _, candidate := range [2]uint64{index, alt} {
+		for _, slot := range cf.bucket(candidate) {
+			if slot == fingerprint {
+				return true
+			}
+		}
+	}
+	return cf.victim.used && cf.victim.fingerprint == fingerprint && (cf.victim.index == index || cf.victim.index == alt)
+}
+
+func (cf *CuckooFilter) Marshal() ([]byte, error) {
+	return cf.MarshalBinary()
+}
+
+func (cf *CuckooFilter) Stats() map[string]interface{} {
+	cf.mutex.RLock()
+	defer cf.mutex.RUnlock()
+
+	buckets := cf.mask + 1
+	loadFactor := float64(cf.elements) / float64(len(cf.table))
+	return map[string]interface{}{
+		"buckets":          buckets,
+		"bucket_size":      cuckooBucketSize,
+		"fingerprint_bits": 16,
+		"elements":         cf.elements,
+		"load_factor":      loadFactor,
+		"full":             cf.victim.used,
+		// 查询比较两个桶共 2b 个指纹，每个以 2^-16 的概率相同
+		"estimated_fpr": 2 * cuckooBucketSize * loadFactor / (1 << 16),
+		"memory_bytes":  uint(len(cf.table)) * 2,
+	}
+}
+
+// MarshalBinary 实现 encoding.BinaryMarshaler
+func (cf *CuckooFilter) MarshalBinary() ([]byte, error) {
+	cf.mutex.RLock()
+	defer cf.mutex.RUnlock()
+
+	data := make([]byte, 0, 31+len(cf.table)*2+cbfChecksumSize)
+	data = append(data, cuckooMagic[:]...)
+	data = append(data, CuckooFormatVersion)
+	data = binary.BigEndian.AppendUint64(data, cf.mask+1)
+	data = binary.BigEndian.AppendUint64(data, uint64(cf.elements))
+	if cf.victim.used {
+		data = append(data, 1)
+	} else {
+		data = append(data, 0)
+	}
+	data = binary.BigEndian.AppendUint16(data, cf.victim.fingerprint)
+	data = binary.BigEndian.AppendUint64(data, cf.victim.index)
+	for _, fingerprint := range cf.table {
+		data = binary.BigEndian.AppendUint16(data, fingerprint)
+	}
+	return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(data)), nil
+}
+
+// UnmarshalBinary 实现 encoding.BinaryUnmarshaler
+func (cf *CuckooFilter) UnmarshalBinary(data []byte) error {
+	const headerSize = 31
+	if len(data) < headerSize+cbfChecksumSize || [3]byte(data[:3]) != cuckooMagic {
+		return ErrInvalidCuckooData
+	}
+	if data[3] != CuckooFormatVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidCuckooData, data[3])
+	}
+	body := data[:len(data)-cbfChecksumSize]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(data[len(body):]) {
+		return fmt.Errorf("%w: checksum mismatch", ErrInvalidCuckooData)
+	}
+
+	buckets := binary.BigEndian.Uint64(body[4:12])
+	if buckets == 0 || buckets > cuckooMaxBuckets || buckets&(buckets-1) != 0 {
+		return fmt.Errorf("%w: %d buckets", ErrInvalidCuckooData, buckets)
+	}
+	if uint64(len(body)-headerSize) != buckets*cuckooBucketSize*2 {
+		return fmt.Errorf("%w: table length mismatch", ErrInvalidCuckooData)
+	}
+	victim := cuckooVictim{
+		used:        body[20] == 1,
+		fingerprint: binary.BigEndian.Uint16(body[21:23]),
+		index:       binary.BigEndian.Uint64(body[23:31]),
+	}
+	if body[20] > 1 || victim.index >= buckets {
+		return fmt.Errorf("%w: bad victim", ErrInvalidCuckooData)
+	}
+	table := make([]uint16, buckets*cuckooBucketSize)
+	for i := range table {
+		table[i] = binary.BigEndian.Uint16(body[headerSize+2*i:])
+	}
+
+	cf.mutex.Lock()
+	defer cf.mutex.Unlock()
+
+	cf.table = table
+	cf.mask = buckets - 1
+	cf.elements = uint(binary.BigEndian.Uint64(body[12:20]))
+	cf.victim = victim
+	return nil
+}
@@ -0,0 +1,351 @@
+package cer_ca_tools
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/FISCO-BCOS/go-sdk/smcrypto"
+)
+
+// 重签请求签名的上下文前缀，与续期请求区分
+const reissueSignatureContext = "AnonCert short-lived reissue v1"
+
+// DefaultEnrollmentLifetime 登记记录的默认有效期
+const DefaultEnrollmentLifetime = 8760 * time.Hour
+
+var (
+	ErrEnrollmentNotFound = errors.New("enrollment not found")
+	ErrEnrollmentRevoked  = errors.New("enrollment is revoked")
+	ErrEnrollmentExpired  = errors.New("enrollment is expired")
+)
+
+// 审计事件类型：短期证书登记与撤销登记
+const (
+	AuditEventEnroll           = "enrollment.create"
+	AuditEventEnrollmentRevoke = "enrollment.revoke"
+)
+
+// Enrollment 短期证书的登记记录
+//
+// 主体完成一次匿名签发流程后获得长期登记，此后凭登记密钥签名的重签请求即可持续获得短期证书，
+// 不再需要 CRT/模数流程。撤销登记即停止重签，已签发的短期证书自然过期，不进入CRL。
+type Enrollment struct {
+	ID         string    `json:"id"`
+	CAName     string    `json:"ca_name"`
+	Subject    pkix.Name `json:"subject"`
+	PublicKey  []byte    `json:"public_key"` // 登记密钥，PKIX DER
+	Profile    string    `json:"profile"`
+	CreatedAt  time.Time `json:"created_at"`
+	NotAfter   time.Time `json:"not_after"`
+	LastSerial string    `json:"last_serial,omitempty"` // 最近一次签发的短期证书序列号
+	Revoked    bool      `json:"revoked,omitempty"`
+	RevokedAt  time.Time `json:"revoked_at,omitempty"`
+}
+
+// check 登记必须未撤销且在有效期内
+func (enrollment *Enrollment) check(now time.Time) error {
+	if enrollment.Revoked {
+		return fmt.Errorf("%w: %s", ErrEnrollmentRevoked, enrollment.ID)
+	}
+	if now.After(enrollment.NotAfter) {
+		return fmt.Errorf("%w: %s", ErrEnrollmentExpired, enrollment.ID)
+	}
+	return nil
+}
+
+// EnrollmentResponse 登记或重签的结果，附带登记ID与登记有效期
+type EnrollmentResponse struct {
+	CertificateResponse
+	EnrollmentID       string    `json:"enrollment_id,omitempty"`
+	EnrollmentNotAfter time.Time `json:"enrollment_not_after,omitempty"`
+}
+
+// ReissueRequest 短期证书重签请求，由登记密钥签名
+//
+// CSR 为空时新证书沿用登记公钥；携带 CSR 时使用CSR中的新公钥，登记密钥本身不变。
+type ReissueRequest struct {
+	EnrollmentID string `json:"enrollment_id"`
+	CSR          []byte `json:"csr,omitempty"`       // 新证书密钥的PKCS#10请求，PEM或DER
+	Timestamp    int64  `json:"timestamp"`           // Unix 秒
+	Signature    []byte `json:"signature,omitempty"` // 登记私钥对 SignedData 的 SHA-256 摘要的签名
+}
+
+// SignedData 返回被签名的内容：各字段按长度前缀依次编码
+func (request *ReissueRequest) SignedData() []byte {
+	var data bytes.Buffer
+	data.WriteString(reissueSignatureContext)
+	for _, field := range [][]byte{
+		[]byte(request.EnrollmentID),
+		request.CSR,
+		[]byte(strconv.FormatInt(request.Timestamp, 10)),
+	} {
+		binary.Write(&data, binary.BigEndian, uint32(len(field)))
+		data.Write(field)
+	}
+	return data.Bytes()
+}
+
+// Sign 使用登记私钥签名重签请求，未设置时间戳时使用当前时间
+func (request *ReissueRequest) Sign(key crypto.Signer) error {
+	if request.Timestamp == 0 {
+		request.Timestamp = time.Now().Unix()
+	}
+	digest := sha256.Sum256(request.SignedData())
+	signature, err := signDigest(key, digest[:], crypto.SHA256)
+	if err != nil {
+		return fmt.Errorf("failed to sign reissue request: %w", err)
+	}
+	request.Signature = signature
+	return nil
+}
+
+// Verify 以登记公钥校验重签请求的时间戳与签名，返回可选的CSR
+func (request *ReissueRequest) Verify(now time.Time, publicKey crypto.PublicKey) (*x509.CertificateRequest, error) {
+	requestTime := time.Unix(request.Timestamp, 0)
+	if requestTime.Before(now.Add(-RenewalMaxClockSkew)) || requestTime.After(now.Add(RenewalMaxClockSkew)) {
+		return nil, ErrRenewalStale
+	}
+	digest := sha256.Sum256(request.SignedData())
+	if err := verifyDigestSignature(publicKey, digest[:], request.Signature); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRenewalSignature, err)
+	}
+	if len(request.CSR) == 0 {
+		return nil, nil
+	}
+	return ParseCSR(request.CSR)
+}
+
+// Enroll 校验CSR后为主体建立登记记录，并以短期证书模板签发第一张证书，CSR中的公钥即登记密钥
+func (manager *CAManager) Enroll(caName string, subject pkix.Name, csr *x509.CertificateRequest) EnrollmentResponse {
+	ca, exists := manager.GetCAInfo(caName)
+	if !exists {
+		return enrollmentFailure(fmt.Errorf("%w: %s", ErrCANotFound, caName))
+	}
+	request, err := ca.CSRPolicy.ValidateCSR(csr, subject)
+	if err != nil {
+		log.Printf("CA %s rejected enrollment CSR: %v", caName, err)
+		return enrollmentFailure(err)
+	}
+	publicKey, err := smcrypto.MarshalPKIXPublicKey(request.PublicKey)
+	if err != nil {
+		return enrollmentFailure(fmt.Errorf("failed to marshal enrollment key: %w", err))
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return enrollmentFailure(fmt.Errorf("failed to generate enrollment id: %w", err))
+	}
+
+	response := ca.Issue(ProfileShortLived, request)
+	if !response.Success {
+		return EnrollmentResponse{CertificateResponse: response}
+	}
+	now := time.Now()
+	enrollment := &Enrollment{
+		ID:         hex.EncodeToString(id),
+		CAName:     caName,
+		Subject:    subject,
+		PublicKey:  publicKey,
+		Profile:    ProfileShortLived,
+		CreatedAt:  now,
+		NotAfter:   now.Add(manager.EnrollmentLifetime),
+		LastSerial: certificateSerial(response.Certificate),
+	}
+	if err := ca.recordAudit(AuditEventEnroll, enrollment.LastSerial, map[string]string{
+		"enrollment": enrollment.ID,
+		"not_after":  enrollment.NotAfter.UTC().Format(time.RFC3339),
+	}); err != nil {
+		return enrollmentFailure(fmt.Errorf("failed to record enrollment in audit log: %w", err))
+	}
+	if err := manager.saveEnrollment(enrollment); err != nil {
+		return enrollmentFailure(err)
+	}
+	log.Printf("CA %s created enrollment %s", caName, enrollment.ID)
+
+	return EnrollmentResponse{
+		CertificateResponse: response,
+		EnrollmentID:        enrollment.ID,
+		EnrollmentNotAfter:  enrollment.NotAfter,
+	}
+}
+
+// Reissue 为有效登记签发新的短期证书
+//
+// 短期证书的有效期由模板决定，可能略超出登记有效期，但登记过期后不再重签。
+func (manager *CAManager) Reissue(request *ReissueRequest) EnrollmentResponse {
+	enrollment, exists := manager.GetEnrollment(request.EnrollmentID)
+	if !exists {
+		return enrollmentFailure(fmt.Errorf("%w: %s", ErrEnrollmentNotFound, request.EnrollmentID))
+	}
+	publicKey, err := smcrypto.ParsePKIXPublicKey(enrollment.PublicKey)
+	if err != nil {
+		return enrollmentFailure(fmt.Errorf("failed to parse enrollment key: %w", err))
+	}
+
+	now := time.Now()
+	csr, err := request.Verify(now, publicKey)
+	if err == nil {
+		err = enrollment.check(now)
+	}
+	if err != nil {
+		log.Printf("rejected reissue for enrollment %s: %v", enrollment.ID, err)
+		return enrollmentFailure(err)
+	}
+
+	ca, exists := manager.GetCAInfo(enrollment.CAName)
+	if !exists {
+		return enrollmentFailure(fmt.Errorf("%w: %s", ErrCANotFound, enrollment.CAName))
+	}
+	issuance := &IssuanceRequest{
+		Subject:   enrollment.Subject,
+		PublicKey: publicKey,
+	}
+	if csr != nil {
+		if issuance, err = ca.CSRPolicy.ValidateCSR(csr, enrollment.Subject); err != nil {
+			return enrollmentFailure(err)
+		}
+	}
+
+	response := ca.Issue(enrollment.Profile, issuance)
+	if !response.Success {
+		return EnrollmentResponse{CertificateResponse: response}
+	}
+	serial := certificateSerial(response.Certificate)
+	if _, err := manager.updateEnrollment(enrollment.ID, func(record *Enrollment) {
+		record.LastSerial = serial
+	}); err != nil {
+		// 证书已签发，登记记录更新失败只记录日志
+		log.Printf("failed to update enrollment %s: %v", enrollment.ID, err)
+	}
+	return EnrollmentResponse{
+		CertificateResponse: response,
+		EnrollmentID:        enrollment.ID,
+		EnrollmentNotAfter:  enrollment.NotAfter,
+	}
+}
+
+// RevokeEnrollment 撤销登记，此后重签请求被拒绝，已签发的短期证书在到期后失效
+func (manager *CAManager) RevokeEnrollment(id string) error {
+	enrollment, exists := manager.GetEnrollment(id)
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrEnrollmentNotFound, id)
+	}
+	if enrollment.Revoked {
+		return nil
+	}
+	if ca, exists := manager.GetCAInfo(enrollment.CAName); exists {
+		if err := ca.recordAudit(AuditEventEnrollmentRevoke, enrollment.LastSerial, map[string]string{
+			"enrollment": enrollment.ID,
+		}); err != nil {
+			return fmt.Errorf("failed to record enrollment revocation in audit log: %w", err)
+		}
+	}
+
+	alreadyRevoked := false
+	enrollment, err := manager.updateEnrollment(id, func(record *Enrollment) {
+		if alreadyRevoked = record.Revoked; !alreadyRevoked {
+			record.Revoked = true
+			record.RevokedAt = time.Now()
+		}
+	})
+	if err != nil || alreadyRevoked {
+		return err
+	}
+	log.Printf("enrollment %s revoked", id)
+	return nil
+}
+
+// GetEnrollment 返回登记记录的副本
+func (manager *CAManager) GetEnrollment(id string) (*Enrollment, bool) {
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+
+	enrollment, exists := manager.Enrollments[id]
+	if !exists {
+		return nil, false
+	}
+	record := *enrollment
+	return &record, true
+}
+
+// saveEnrollment 持久化登记记录后再更新内存索引
+func (manager *CAManager) saveEnrollment(enrollment *Enrollment) error {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	if manager.Store != nil {
+		if err := manager.Store.SaveEnrollment(enrollment); err != nil {
+			return fmt.Errorf("failed to persist enrollment: %w", err)
+		}
+	}
+	record := *enrollment
+	manager.Enrollments[enrollment.ID] = &record
+	return nil
+}
+
+// updateEnrollment 在持有锁时修改登记记录的副本，持久化成功后替换内存记录，返回修改后的副本
+func (manager *CAManager) updateEnrollment(id string, update func(record *Enrollment)) (*Enrollment, error) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	current, exists := manager.Enrollments[id]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrEnrollmentNotFound, id)
+	}
+	record := *current
+	update(&record)
+	if manager.Store != nil {
+		if err := manager.Store.SaveEnrollment(&record); err != nil {
+			return nil, fmt.Errorf("failed to persist enrollment: %w", err)
+		}
+	}
+	manager.Enrollments[id] = &record
+	updated := record
+	return &updated, nil
+}
+
+// IsShortLived 证书总有效期不超过 maxLifetime 时视为短期证书，验证方可不查询撤销状态
+func IsShortLived(cert *x509.Certificate, maxLifetime time.Duration) bool {
+	return maxLifetime > 0 && cert.NotAfter.Sub(cert.NotBefore) <= maxLifetime
+}
+
+func certificateSerial(certPEM string) string {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return ""
+	}
+	cert, err := ParseCertificate(block.Bytes)
+	if err != nil {
+		return ""
+	}
+	return cert.SerialNumber.String()
+}
+
+func enrollmentFailure(err error) EnrollmentResponse {
+	return EnrollmentResponse{CertificateResponse: CertificateResponse{
+		Success: false,
+		Message: err.Error(),
+		Err:     err,
+	}}
+}
+
+// writeEnrollmentResponse 登记撤销或过期时返回 403，刷新方据此停止重签
+func writeEnrollmentResponse(w http.ResponseWriter, response EnrollmentResponse) {
+	if response.Success {
+		writeAPIJSON(w, http.StatusOK, response)
+		return
+	}
+	writeCertificateResponse(w, response.CertificateResponse)
+}
@@ -0,0 +1,98 @@
+package cer_ca_tools
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestShortLivedEnrollment(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+	manager := NewCAManagerWithStore(store)
+	ca, err := manager.CreateCA("ca_enroll_test")
+	if err != nil {
+		t.Fatalf("create CA failed: %v", err)
+	}
+
+	enrollKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	csrDER, _ := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{}, enrollKey)
+	csr, _ := x509.ParseCertificateRequest(csrDER)
+	subject := pkix.Name{CommonName: "anonymous-enroll", OrganizationalUnit: []string{"AnonCert"}}
+
+	enrolled := manager.Enroll("ca_enroll_test", subject, csr)
+	if !enrolled.Success || enrolled.EnrollmentID == "" {
+		t.Fatalf("enroll failed: %s", enrolled.Message)
+	}
+	first := parseTestCertificate(t, enrolled.Certificate)
+	if !IsShortLived(first, 2*time.Hour) || IsShortLived(first, time.Hour) {
+		t.Fatalf("unexpected short-lived certificate lifetime %s", first.NotAfter.Sub(first.NotBefore))
+	}
+
+	// 登记密钥签名的请求可重签，沿用登记主体与公钥
+	reissue := &ReissueRequest{EnrollmentID: enrolled.EnrollmentID}
+	if err := reissue.Sign(enrollKey); err != nil {
+		t.Fatalf("sign reissue request failed: %v", err)
+	}
+	reissued := manager.Reissue(reissue)
+	if !reissued.Success {
+		t.Fatalf("reissue failed: %s", reissued.Message)
+	}
+	second := parseTestCertificate(t, reissued.Certificate)
+	if second.SerialNumber.Cmp(first.SerialNumber) == 0 || second.Subject.CommonName != subject.CommonName ||
+		!enrollKey.PublicKey.Equal(second.PublicKey) {
+		t.Fatalf("reissued certificate does not match the enrollment")
+	}
+
+	// 其他密钥签名或过期的请求被拒绝
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	forged := &ReissueRequest{EnrollmentID: enrolled.EnrollmentID}
+	forged.Sign(otherKey)
+	if response := manager.Reissue(forged); !errors.Is(response.Err, ErrRenewalSignature) {
+		t.Fatalf("reissue signed by another key should be rejected, have %v", response.Err)
+	}
+	stale := &ReissueRequest{EnrollmentID: enrolled.EnrollmentID, Timestamp: time.Now().Add(-time.Hour).Unix()}
+	stale.Sign(enrollKey)
+	if response := manager.Reissue(stale); !errors.Is(response.Err, ErrRenewalStale) {
+		t.Fatalf("stale reissue request should be rejected, have %v", response.Err)
+	}
+
+	// 重启后登记记录恢复，撤销登记后停止重签且不进入CRL
+	restarted := NewCAManagerWithStore(store)
+	if err := restarted.LoadState(); err != nil {
+		t.Fatalf("load state failed: %v", err)
+	}
+	restored, exists := restarted.GetEnrollment(enrolled.EnrollmentID)
+	if !exists || restored.LastSerial != second.SerialNumber.String() || restored.Subject.CommonName != subject.CommonName {
+		t.Fatalf("enrollment not restored: %+v", restored)
+	}
+	if err := restarted.RevokeEnrollment(enrolled.EnrollmentID); err != nil {
+		t.Fatalf("revoke enrollment failed: %v", err)
+	}
+	reissue = &ReissueRequest{EnrollmentID: enrolled.EnrollmentID}
+	reissue.Sign(enrollKey)
+	if response := restarted.Reissue(reissue); !errors.Is(response.Err, ErrEnrollmentRevoked) {
+		t.Fatalf("reissue after revocation should be rejected, have %v", response.Err)
+	}
+	if restoredCA, _ := restarted.GetCAInfo("ca_enroll_test"); len(restoredCA.RevokedCerts) != 0 {
+		t.Fatalf("revoking an enrollment should not add CRL entries")
+	}
+	if response := restarted.Reissue(&ReissueRequest{EnrollmentID: "unknown"}); !errors.Is(response.Err, ErrEnrollmentNotFound) {
+		t.Fatalf("unknown enrollment should be rejected, have %v", response.Err)
+	}
+
+	// 过期登记同样停止重签
+	expiring := manager.Enroll(ca.Name.CommonName, subject, csr)
+	manager.updateEnrollment(expiring.EnrollmentID, func(record *Enrollment) {
+		record.NotAfter = time.Now().Add(-time.Minute)
+	})
+	reissue = &ReissueRequest{EnrollmentID: expiring.EnrollmentID}
+	reissue.Sign(enrollKey)
+	if response := manager.Reissue(reissue); !errors.Is(response.Err, ErrEnrollmentExpired) {
+		t.Fatalf("reissue for expired enrollment should be rejected, have %v", response.Err)
+	}
+}
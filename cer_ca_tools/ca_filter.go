@@ -0,0 +1,323 @@
+package cer_ca_tools
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+// 撤销过滤器分发：每个CA把当前被撤销（含暂停）证书的序列号放入 CountingBloomFilter，
+// 撤销状态每变化一次版本号加一。依赖方先获取签名的完整快照，之后按版本获取签名的增量，
+// 增量携带应用后过滤器的摘要，依赖方据此确认副本与CA一致。
+
+const (
+	filterSnapshotContext = "AnonCert revocation filter snapshot v1"
+	filterDeltaContext    = "AnonCert revocation filter delta v1"
+)
+
+var (
+	ErrFilterDeltaUnavailable = errors.New("revocation filter delta is no longer available")
+	ErrFilterVersionAhead     = errors.New("revocation filter version is ahead of the CA")
+	ErrFilterSignature        = errors.New("invalid revocation filter signature")
+	ErrFilterRollback         = errors.New("revocation filter rolled back")
+	ErrFilterDigest           = errors.New("revocation filter digest mismatch")
+)
+
+// FilterConfig 撤销过滤器参数
+type FilterConfig struct {
+	ExpectedElements  uint    // 预期撤销证书数
+	FalsePositiveRate float64 // 目标误判率
+	BitsPerCount      uint    // 计数器位数，4 或 8
+	DeltaHistory      int     // 保留的历史变更数，更早版本的依赖方需重新获取快照
+}
+
+// DefaultFilterConfig 默认按 10000 个撤销证书、0.1% 误判率建立过滤器，保留最近 1024 次变更
+func DefaultFilterConfig() FilterConfig {
+	return FilterConfig{
+		ExpectedElements:  10000,
+		FalsePositiveRate: 0.001,
+		BitsPerCount:      4,
+		DeltaHistory:      1024,
+	}
+}
+
+// FilterSnapshot 某一版本的完整过滤器
+type FilterSnapshot struct {
+	CAName    string `json:"ca_name"`
+	Version   uint64 `json:"version"`
+	IssuedAt  int64  `json:"issued_at"`           // Unix 秒
+	Filter    []byte `json:"filter"`              // CountingBloomFilter.MarshalBinary
+	Signature []byte `json:"signature,omitempty"` // CA私钥对 SignedData 的 SHA-256 摘要的签名
+}
+
+// FilterChange 一次撤销状态变化，Removed 为 true 表示证书不再被撤销（解除暂停）
+type FilterChange struct {
+	Serial  string `json:"serial"`
+	Removed bool   `json:"removed,omitempty"`
+}
+
+// FilterDelta 从 FromVersion 到 ToVersion 的变更，需按顺序应用
+type FilterDelta struct {
+	CAName      string         `json:"ca_name"`
+	FromVersion uint64         `json:"from_version"`
+	ToVersion   uint64         `json:"to_version"`
+	IssuedAt    int64          `json:"issued_at"`
+	Changes     []FilterChange `json:"changes"`
+	Digest      []byte         `json:"digest"` // 应用后过滤器 MarshalBinary 的 SHA-256
+	Signature   []byte         `json:"signature,omitempty"`
+}
+
+// revocationFilter CA侧的过滤器状态，由 ca.Mutex 保护
+type revocationFilter struct {
+	filter   *CountingBloomFilter
+	version  uint64
+	base     uint64         // history[0] 把版本 base 推进到 base+1
+	history  []FilterChange // 第 i 项产生版本 base+i+1
+	snapshot []byte         // 当前版本已签名快照的 JSON 编码
+	deltas   map[uint64][]byte
+}
+
+// filterKey 过滤器中的元素为十进制序列号，与 RevokedCerts 的键一致
+func filterKey(serial *big.Int) []byte {
+	return []byte(serial.String())
+}
+
+// inFilter 撤销记录对应的证书是否应在过滤器中
+func inFilter(revoked *pkix.RevokedCertificate) bool {
+	return revoked != nil && revocationReason(revoked) != ReasonRemoveFromCRL
+}
+
+func signedFields(context string, fields ...[]byte) []byte {
+	var data bytes.Buffer
+	data.WriteString(context)
+	for _, field := range fields {
+		binary.Write(&data, binary.BigEndian, uint32(len(field)))
+		data.Write(field)
+	}
+	return data.Bytes()
+}
+
+// SignedData 返回快照被签名的内容：各字段按长度前缀依次编码
+func (snapshot *FilterSnapshot) SignedData() []byte {
+	return signedFields(filterSnapshotContext,
+		[]byte(snapshot.CAName),
+		[]byte(strconv.FormatUint(snapshot.Version, 10)),
+		[]byte(strconv.FormatInt(snapshot.IssuedAt, 10)),
+		snapshot.Filter,
+	)
+}
+
+// Verify 用CA公钥校验快照签名
+func (snapshot *FilterSnapshot) Verify(publicKey crypto.PublicKey) error {
+	digest := sha256.Sum256(snapshot.SignedData())
+	if err := verifyDigestSignature(publicKey, digest[:], snapshot.Signature); err != nil {
+		return fmt.Errorf("%w: %v", ErrFilterSignature, err)
+	}
+	return nil
+}
+
+// SignedData 返回增量被签名的内容：各字段按长度前缀依次编码，变更按顺序编码为 "+序列号" 或 "-序列号"
+func (delta *FilterDelta) SignedData() []byte {
+	fields := [][]byte{
+		[]byte(delta.CAName),
+		[]byte(strconv.FormatUint(delta.FromVersion, 10)),
+		[]byte(strconv.FormatUint(delta.ToVersion, 10)),
+		[]byte(strconv.FormatInt(delta.IssuedAt, 10)),
+		delta.Digest,
+	}
+	for _, change := range delta.Changes {
+		op := "+"
+		if change.Removed {
+			op = "-"
+		}
+		fields = append(fields, []byte(op+change.Serial))
+	}
+	return signedFields(filterDeltaContext, fields...)
+}
+
+// Verify 用CA公钥校验增量签名
+func (delta *FilterDelta) Verify(publicKey crypto.PublicKey) error {
+	digest := sha256.Sum256(delta.SignedData())
+	if err := verifyDigestSignature(publicKey, digest[:], delta.Signature); err != nil {
+		return fmt.Errorf("%w: %v", ErrFilterSignature, err)
+	}
+	return nil
+}
+
+// Apply 把变更按顺序应用到过滤器，序列号无法解析或添加失败时返回错误，此时过滤器只应用了部分变更
+func (delta *FilterDelta) Apply(filter *CountingBloomFilter) error {
+	for _, change := range delta.Changes {
+		serial, ok := new(big.Int).SetString(change.Serial, 10)
+		if !ok {
+			return fmt.Errorf("invalid serial %q in revocation filter delta", change.Serial)
+		}
+		if change.Removed {
+			filter.RemoveElement(filterKey(serial))
+		} else if err := filter.AddElement(filterKey(serial)); err != nil {
+			return fmt.Errorf("failed to apply revocation filter delta: %w", err)
+		}
+	}
+	return nil
+}
+
+// filterConfig 返回CA的过滤器参数，未配置时使用默认值
+func (ca *CA) filterConfig() FilterConfig {
+	if ca.FilterConfig.ExpectedElements == 0 {
+		return DefaultFilterConfig()
+	}
+	return ca.FilterConfig
+}
+
+// currentFilter 返回CA的过滤器，首次使用时由 RevokedCerts 建立。
+// 版本号在持久化的基础上加一，重启后依赖方会重新获取快照。调用方持有 ca.Mutex
+func (ca *CA) currentFilter() (*revocationFilter, error) {
+	if ca.filter != nil {
+		return ca.filter, nil
+	}
+	config := ca.filterConfig()
+	filter := NewCountingBloomFilter(config.ExpectedElements, config.FalsePositiveRate, config.BitsPerCount)
+	for _, revoked := range ca.RevokedCerts {
+		if !inFilter(revoked) {
+			continue
+		}
+		if err := filter.AddElement(filterKey(revoked.SerialNumber)); err != nil {
+			return nil, fmt.Errorf("failed to build revocation filter: %w", err)
+		}
+	}
+
+	state := ca.crlState
+	state.FilterVersion++
+	if err := ca.saveCRLState(state); err != nil {
+		return nil, err
+	}
+	ca.filter = &revocationFilter{filter: filter, version: state.FilterVersion, base: state.FilterVersion}
+	return ca.filter, nil
+}
+
+// updateFilter 撤销记录由 previous 变为 revoked 后更新已建立的过滤器。
+// 添加失败时丢弃过滤器，下次使用时由 RevokedCerts 重建。调用方持有 ca.Mutex
+func (ca *CA) updateFilter(previous, revoked *pkix.RevokedCertificate) error {
+	if ca.filter == nil || inFilter(previous) == inFilter(revoked) {
+		return nil
+	}
+	rf := ca.filter
+	change := FilterChange{Serial: revoked.SerialNumber.String(), Removed: !inFilter(revoked)}
+	if change.Removed {
+		rf.filter.RemoveElement(filterKey(revoked.SerialNumber))
+	} else if err := rf.filter.AddElement(filterKey(revoked.SerialNumber)); err != nil {
+		ca.filter = nil
+		return fmt.Errorf("failed to update revocation filter: %w", err)
+	}
+	rf.version++
+	rf.history = append(rf.history, change)
+	if limit := ca.filterConfig().DeltaHistory; len(rf.history) > limit {
+		drop := len(rf.history) - limit
+		rf.history = append([]FilterChange(nil), rf.history[drop:]...)
+		rf.base += uint64(drop)
+	}
+	rf.snapshot = nil
+	rf.deltas = nil
+
+	state := ca.crlState
+	state.FilterVersion = rf.version
+	if err := ca.saveCRLState(state); err != nil {
+		log.Printf("保存过滤器版本失败: %v", err)
+	}
+	return nil
+}
+
+// FilterSnapshot 返回当前版本的签名快照（JSON 编码）及其版本号
+func (ca *CA) FilterSnapshot() ([]byte, uint64, error) {
+	ca.Mutex.Lock()
+	defer ca.Mutex.Unlock()
+
+	rf, err := ca.currentFilter()
+	if err != nil {
+		return nil, 0, err
+	}
+	if rf.snapshot != nil {
+		return rf.snapshot, rf.version, nil
+	}
+	if ca.PrivateKey == nil {
+		return nil, 0, ErrCAOffline
+	}
+
+	filterData, err := rf.filter.MarshalBinary()
+	if err != nil {
+		return nil, 0, err
+	}
+	snapshot := FilterSnapshot{
+		CAName:   ca.Name.CommonName,
+		Version:  rf.version,
+		IssuedAt: time.Now().Unix(),
+		Filter:   filterData,
+	}
+	digest := sha256.Sum256(snapshot.SignedData())
+	if snapshot.Signature, err = signDigest(ca.PrivateKey, digest[:], crypto.SHA256); err != nil {
+		return nil, 0, fmt.Errorf("failed to sign revocation filter snapshot: %w", err)
+	}
+	if rf.snapshot, err = json.Marshal(snapshot); err != nil {
+		return nil, 0, err
+	}
+	return rf.snapshot, rf.version, nil
+}
+
+// FilterDelta 返回从 from 到当前版本的签名增量（JSON 编码）及当前版本号。
+// from 早于保留的历史时返回 ErrFilterDeltaUnavailable，依赖方应重新获取快照
+func (ca *CA) FilterDelta(from uint64) ([]byte, uint64, error) {
+	ca.Mutex.Lock()
+	defer ca.Mutex.Unlock()
+
+	rf, err := ca.currentFilter()
+	if err != nil {
+		return nil, 0, err
+	}
+	switch {
+	case from > rf.version:
+		return nil, 0, fmt.Errorf("%w: %d > %d", ErrFilterVersionAhead, from, rf.version)
+	case from < rf.base:
+		return nil, 0, fmt.Errorf("%w: version %d, oldest is %d", ErrFilterDeltaUnavailable, from, rf.base)
+	}
+	if cached, ok := rf.deltas[from]; ok {
+		return cached, rf.version, nil
+	}
+	if ca.PrivateKey == nil {
+		return nil, 0, ErrCAOffline
+	}
+
+	filterData, err := rf.filter.MarshalBinary()
+	if err != nil {
+		return nil, 0, err
+	}
+	digest := sha256.Sum256(filterData)
+	delta := FilterDelta{
+		CAName:      ca.Name.CommonName,
+		FromVersion: from,
+		ToVersion:   rf.version,
+		IssuedAt:    time.Now().Unix(),
+		Changes:     append([]FilterChange{}, rf.history[from-rf.base:]...),
+		Digest:      digest[:],
+	}
+	signedDigest := sha256.Sum256(delta.SignedData())
+	if delta.Signature, err = signDigest(ca.PrivateKey, signedDigest[:], crypto.SHA256); err != nil {
+		return nil, 0, fmt.Errorf("failed to sign revocation filter delta: %w", err)
+	}
+	encoded, err := json.Marshal(delta)
+	if err != nil {
+		return nil, 0, err
+	}
+	if rf.deltas == nil {
+		rf.deltas = make(map[uint64][]byte)
+	}
+	rf.deltas[from] = encoded
+	return encoded, rf.version, nil
+}
@@ -0,0 +1,275 @@
+package cer_ca_tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// filterResponseLimit 快照与增量响应的最大长度
+const filterResponseLimit = 64 << 20
+
+// defaultFilterMaxAge 副本距上次成功同步超过该时长即视为过期
+const defaultFilterMaxAge = 15 * time.Minute
+
+// FilterSyncClient 依赖方的撤销过滤器副本，通过CA的 /certificate/filter 接口保持最新。
+// 快照与增量都以 Issuer 的公钥验证签名，版本号不得回退，增量应用后的摘要必须与CA一致，
+// 任何校验失败都保留原有副本。距上次成功同步超过 MaxAge 的副本不再视为已同步
+type FilterSyncClient struct {
+	BaseURL    string            // CA HTTP服务地址
+	CAName     string            // CA名称
+	Issuer     *x509.Certificate // CA证书，用于验证快照与增量签名
+	HTTPClient *http.Client
+	MaxAge     time.Duration // 副本的最长有效时间，不大于 0 时不过期
+
+	mutex    sync.RWMutex
+	filter   *CountingBloomFilter
+	version  uint64
+	syncedAt time.Time // 上次成功同步（含确认无更新）的时间
+}
+
+// NewFilterSyncClient 创建同步客户端，首次 Sync 时获取完整快照
+func NewFilterSyncClient(baseURL, caName string, issuer *x509.Certificate) *FilterSyncClient {
+	return &FilterSyncClient{
+		BaseURL:    baseURL,
+		CAName:     caName,
+		Issuer:     issuer,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		MaxAge:     defaultFilterMaxAge,
+	}
+}
+
+// Version 返回副本的版本号，尚未同步时为 0
+func (client *FilterSyncClient) Version() uint64 {
+	client.mutex.RLock()
+	defer client.mutex.RUnlock()
+
+	return client.version
+}
+
+// SyncedAt 返回上次成功同步的时间，尚未同步时为零值
+func (client *FilterSyncClient) SyncedAt() time.Time {
+	client.mutex.RLock()
+	defer client.mutex.RUnlock()
+
+	return client.syncedAt
+}
+
+// Synced 副本是否已经同步过且未过期；过期的副本仍会被 Sync 以增量更新
+func (client *FilterSyncClient) Synced() bool {
+	client.mutex.RLock()
+	defer client.mutex.RUnlock()
+
+	if client.filter == nil {
+		return false
+	}
+	return client.MaxAge <= 0 || time.Since(client.syncedAt) <= client.MaxAge
+}
+
+// MaybeRevoked 证书是否可能被撤销；过滤器可能误判，返回 true 时应以CRL或OCSP确认。未同步时返回 false
+func (client *FilterSyncClient) MaybeRevoked(serial *big.Int) bool {
+	client.mutex.RLock()
+	filter := client.filter
+	client.mutex.RUnlock()
+
+	return filter != nil && filter.QueryElement(filterKey(serial))
+}
+
+// Sync 更新副本：已有副本时先尝试增量，增量不可用或摘要不一致时获取完整快照。成功时刷新同步时间
+func (client *FilterSyncClient) Sync(ctx context.Context) error {
+	client.mutex.RLock()
+	initialized := client.filter != nil
+	client.mutex.RUnlock()
+
+	var err error
+	if !initialized {
+		err = client.syncSnapshot(ctx)
+	} else if err = client.syncDelta(ctx); errors.Is(err, ErrFilterDeltaUnavailable) || errors.Is(err, ErrFilterDigest) {
+		log.Printf("revocation filter delta of CA %s not applied (%v), fetching snapshot", client.CAName, err)
+		err = client.syncSnapshot(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if client.filter != nil {
+		client.syncedAt = time.Now()
+	}
+	return nil
+}
+
+// Run 每隔 interval 同步一次，直到 ctx 结束
+func (client *FilterSyncClient) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := client.Sync(ctx); err != nil {
+			log.Printf("failed to sync revocation filter of CA %s: %v", client.CAName, err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (client *FilterSyncClient) syncSnapshot(ctx context.Context) error {
+	client.mutex.RLock()
+	current := client.version
+	client.mutex.RUnlock()
+
+	etag := ""
+	if current > 0 {
+		etag = fmt.Sprintf(`"v%d"`, current)
+	}
+	body, err := client.fetch(ctx, "/certificate/filter", nil, etag)
+	if err != nil || body == nil {
+		return err
+	}
+
+	var snapshot FilterSnapshot
+	if err := json.Unmarshal(body, &snapshot); err != nil {
+		return fmt.Errorf("failed to parse revocation filter snapshot: %w", err)
+	}
+	if err := snapshot.Verify(client.Issuer.PublicKey); err != nil {
+		return err
+	}
+	if snapshot.CAName != client.CAName {
+		return fmt.Errorf("%w: snapshot is for CA %s", ErrFilterSignature, snapshot.CAName)
+	}
+	filter := &CountingBloomFilter{}
+	if err := filter.UnmarshalBinary(snapshot.Filter); err != nil {
+		return err
+	}
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if client.filter != nil && snapshot.Version <= client.version {
+		if snapshot.Version < client.version {
+			return fmt.Errorf("%w: snapshot version %d, have %d", ErrFilterRollback, snapshot.Version, client.version)
+		}
+		return nil
+	}
+	client.filter = filter
+	client.version = snapshot.Version
+	return nil
+}
+
+func (client *FilterSyncClient) syncDelta(ctx context.Context) error {
+	client.mutex.RLock()
+	from := client.version
+	current := client.filter
+	client.mutex.RUnlock()
+
+	body, err := client.fetch(ctx, "/certificate/filter/delta", url.Values{"from": {fmt.Sprint(from)}}, "")
+	if err != nil || body == nil {
+		return err
+	}
+
+	var delta FilterDelta
+	if err := json.Unmarshal(body, &delta); err != nil {
+		return fmt.Errorf("failed to parse revocation filter delta: %w", err)
+	}
+	if err := delta.Verify(client.Issuer.PublicKey); err != nil {
+		return err
+	}
+	if delta.CAName != client.CAName {
+		return fmt.Errorf("%w: delta is for CA %s", ErrFilterSignature, delta.CAName)
+	}
+	if delta.FromVersion != from || delta.ToVersion <= from {
+		return fmt.Errorf("%w: delta from version %d to %d, have %d", ErrFilterRollback, delta.FromVersion, delta.ToVersion, from)
+	}
+
+	// 在副本的拷贝上应用增量，摘要一致后再替换
+	data, err := current.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	filter := &CountingBloomFilter{}
+	if err := filter.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	if err := delta.Apply(filter); err != nil {
+		return err
+	}
+	if data, err = filter.MarshalBinary(); err != nil {
+		return err
+	}
+	if digest := sha256.Sum256(data); !bytes.Equal(digest[:], delta.Digest) {
+		return fmt.Errorf("%w: version %d", ErrFilterDigest, delta.ToVersion)
+	}
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if client.version != from {
+		// 并发的同步已经推进了副本
+		return nil
+	}
+	client.filter = filter
+	client.version = delta.ToVersion
+	return nil
+}
+
+// fetch 请求CA接口，304 时返回 nil；CA返回的错误按错误码还原为对应的错误
+func (client *FilterSyncClient) fetch(ctx context.Context, path string, query url.Values, etag string) ([]byte, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("caName", client.CAName)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, client.BaseURL+APIVersionPrefix+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if etag != "" {
+		request.Header.Set("If-None-Match", etag)
+	}
+	response, err := client.HTTPClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", path, err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, filterResponseLimit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	switch response.StatusCode {
+	case http.StatusOK:
+		return body, nil
+	case http.StatusNotModified:
+		return nil, nil
+	}
+
+	var apiError APIErrorResponse
+	if json.Unmarshal(body, &apiError) != nil || apiError.Error == nil {
+		return nil, fmt.Errorf("%s returned status %d", path, response.StatusCode)
+	}
+	switch apiError.Error.Code {
+	case APIErrorNotFound:
+		if path == "/certificate/filter/delta" {
+			return nil, fmt.Errorf("%w: %s", ErrFilterDeltaUnavailable, apiError.Error.Message)
+		}
+	case APIErrorConflict:
+		if path == "/certificate/filter/delta" {
+			return nil, fmt.Errorf("%w: %s", ErrFilterRollback, apiError.Error.Message)
+		}
+	}
+	return nil, fmt.Errorf("%s returned %s: %s", path, apiError.Error.Code, apiError.Error.Message)
+}
@@ -0,0 +1,222 @@
+package cer_ca_tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFilterSnapshotAndDelta(t *testing.T) {
+	manager := NewCAManagerWithStore(NewMemoryStore())
+	ca, err := manager.CreateCA("ca_filter_test")
+	if err != nil {
+		t.Fatalf("create CA failed: %v", err)
+	}
+	revoked := issueTestCert(t, ca, "anonymous-test")
+	held := issueTestCert(t, ca, "anonymous-test")
+	ca.RevokeCertificate(ca.Name.CommonName, revoked.SerialNumber.String(), ReasonKeyCompromise)
+
+	data, base, err := ca.FilterSnapshot()
+	if err != nil {
+		t.Fatalf("snapshot failed: %v", err)
+	}
+	var snapshot FilterSnapshot
+	json.Unmarshal(data, &snapshot)
+	if err := snapshot.Verify(ca.Certificate.PublicKey); err != nil || snapshot.Version != base {
+		t.Fatalf("snapshot should verify at version %d: %v", base, err)
+	}
+	replica := &CountingBloomFilter{}
+	if err := replica.UnmarshalBinary(snapshot.Filter); err != nil || !replica.QueryElement(filterKey(revoked.SerialNumber)) {
+		t.Fatalf("snapshot should contain the revoked certificate: %v", err)
+	}
+
+	// 暂停、暂停转为永久撤销、再撤销同一证书：只有状态真正变化时产生新版本
+	ca.RevokeCertificate(ca.Name.CommonName, held.SerialNumber.String(), ReasonCertificateHold)
+	ca.ReleaseCertificateHold(ca.Name.CommonName, held.SerialNumber.String())
+	ca.RevokeCertificate(ca.Name.CommonName, held.SerialNumber.String(), ReasonCertificateHold)
+	ca.RevokeCertificate(ca.Name.CommonName, held.SerialNumber.String(), ReasonSuperseded)
+
+	data, version, err := ca.FilterDelta(base)
+	if err != nil || version != base+3 {
+		t.Fatalf("expected delta to version %d, have %d %v", base+3, version, err)
+	}
+	var delta FilterDelta
+	json.Unmarshal(data, &delta)
+	if err := delta.Verify(ca.Certificate.PublicKey); err != nil {
+		t.Fatalf("delta should verify: %v", err)
+	}
+	if len(delta.Changes) != 3 || delta.Changes[0].Removed || !delta.Changes[1].Removed || delta.Changes[2].Removed {
+		t.Fatalf("unexpected delta changes: %+v", delta.Changes)
+	}
+	if err := delta.Apply(replica); err != nil {
+		t.Fatalf("apply delta failed: %v", err)
+	}
+	if err := delta.Apply(&CountingBloomFilter{}); !errors.Is(err, ErrFilterUninitialized) {
+		t.Fatalf("applying to an uninitialized filter should fail, have %v", err)
+	}
+	malformed := FilterDelta{Changes: []FilterChange{{Serial: "not-a-serial"}}}
+	if err := malformed.Apply(replica); err == nil {
+		t.Fatalf("malformed serial should be rejected")
+	}
+	replicaData, _ := replica.MarshalBinary()
+	data, _, _ = ca.FilterSnapshot()
+	json.Unmarshal(data, &snapshot)
+	if string(replicaData) != string(snapshot.Filter) {
+		t.Fatalf("replica should match the CA filter after applying the delta")
+	}
+
+	delta.Changes[0].Serial = "1"
+	if err := delta.Verify(ca.Certificate.PublicKey); !errors.Is(err, ErrFilterSignature) {
+		t.Fatalf("tampered delta should not verify, have %v", err)
+	}
+	if _, _, err := ca.FilterDelta(version + 1); !errors.Is(err, ErrFilterVersionAhead) {
+		t.Fatalf("future version should be rejected, have %v", err)
+	}
+}
+
+func TestFilterSyncClient(t *testing.T) {
+	store := NewMemoryStore()
+	manager := NewCAManagerWithStore(store)
+	ca, err := manager.CreateCA("ca_filter_sync_test")
+	if err != nil {
+		t.Fatalf("create CA failed: %v", err)
+	}
+	ca.FilterConfig.DeltaHistory = 2
+
+	// override 非空时替换快照响应，用于模拟篡改与回滚
+	var override atomic.Value
+	handler := manager.Handler()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if body, _ := override.Load().([]byte); body != nil && r.URL.Path == APIVersionPrefix+"/certificate/filter" {
+			w.Write(body)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client := NewFilterSyncClient(server.URL, ca.Name.CommonName, ca.Certificate)
+	first := issueTestCert(t, ca, "anonymous-test")
+	ca.RevokeCertificate(ca.Name.CommonName, first.SerialNumber.String(), ReasonKeyCompromise)
+	if err := client.Sync(ctx); err != nil || !client.MaybeRevoked(first.SerialNumber) {
+		t.Fatalf("initial sync failed: %v", err)
+	}
+	oldSnapshot, _, _ := ca.FilterSnapshot()
+
+	response, _ := http.Get(fmt.Sprintf("%s/v1/certificate/filter/delta?caName=%s&from=%d", server.URL, ca.Name.CommonName, client.Version()))
+	response.Body.Close()
+	if response.StatusCode != http.StatusNotModified {
+		t.Fatalf("delta from the current version should be 304, have %d", response.StatusCode)
+	}
+	request, _ := http.NewRequest(http.MethodGet, server.URL+"/v1/certificate/filter?caName=ca_filter_sync_test", nil)
+	request.Header.Set("If-None-Match", fmt.Sprintf(`"v%d"`, client.Version()))
+	if response, _ = http.DefaultClient.Do(request); response.StatusCode != http.StatusNotModified {
+		t.Fatalf("matching ETag should be 304, have %d", response.StatusCode)
+	}
+	response.Body.Close()
+
+	second := issueTestCert(t, ca, "anonymous-test")
+	ca.RevokeCertificate(ca.Name.CommonName, second.SerialNumber.String(), ReasonCertificateHold)
+	version := client.Version()
+	if err := client.Sync(ctx); err != nil || client.Version() != version+1 || !client.MaybeRevoked(second.SerialNumber) {
+		t.Fatalf("delta sync failed: %v", err)
+	}
+
+	// 超出保留历史的版本改为获取快照
+	for i := 0; i < 3; i++ {
+		cert := issueTestCert(t, ca, "anonymous-test")
+		ca.RevokeCertificate(ca.Name.CommonName, cert.SerialNumber.String(), ReasonSuperseded)
+	}
+	ca.ReleaseCertificateHold(ca.Name.CommonName, second.SerialNumber.String())
+	if err := client.Sync(ctx); err != nil || client.Version() != version+5 {
+		t.Fatalf("snapshot fallback failed: %v", err)
+	}
+	if client.MaybeRevoked(second.SerialNumber) {
+		t.Fatalf("released certificate should no longer be in the replica")
+	}
+
+	// 超过 MaxAge 未成功同步的副本视为过期，再次同步（即使没有更新）后恢复
+	client.mutex.Lock()
+	client.syncedAt = time.Now().Add(-client.MaxAge - time.Second)
+	client.mutex.Unlock()
+	if client.Synced() {
+		t.Fatalf("replica older than MaxAge should not be synced")
+	}
+	if err := client.Sync(ctx); err != nil || !client.Synced() || client.Version() != version+5 {
+		t.Fatalf("sync without changes should refresh a stale replica: %v", err)
+	}
+
+	client.mutex.Lock()
+	client.filter = nil
+	client.mutex.Unlock()
+	var tampered FilterSnapshot
+	json.Unmarshal(oldSnapshot, &tampered)
+	tampered.Version += 100
+	tamperedData, _ := json.Marshal(tampered)
+	override.Store(tamperedData)
+	if err := client.Sync(ctx); !errors.Is(err, ErrFilterSignature) {
+		t.Fatalf("tampered snapshot should be rejected, have %v", err)
+	}
+	override.Store(oldSnapshot)
+	client.mutex.Lock()
+	client.filter = &CountingBloomFilter{}
+	client.mutex.Unlock()
+	if err := client.syncSnapshot(ctx); !errors.Is(err, ErrFilterRollback) || client.Version() != version+5 {
+		t.Fatalf("rolled back snapshot should be rejected, have %v", err)
+	}
+	override.Store([]byte(nil))
+
+	// 重启后版本号继续递增，依赖方重新获取快照
+	restarted := NewCAManagerWithStore(store)
+	if err := restarted.LoadState(); err != nil {
+		t.Fatalf("load state failed: %v", err)
+	}
+	restored, _ := restarted.GetCAInfo(ca.Name.CommonName)
+	if _, restoredVersion, err := restored.FilterSnapshot(); err != nil || restoredVersion <= version+5 {
+		t.Fatalf("restored filter version %d should advance past %d: %v", restoredVersion, version+5, err)
+	}
+}
+
+func TestFilterVersionSurvivesCRLPublish(t *testing.T) {
+	store := NewMemoryStore()
+	manager := NewCAManagerWithStore(store)
+	ca, err := manager.CreateCA("ca_filter_crl_test")
+	if err != nil {
+		t.Fatalf("create CA failed: %v", err)
+	}
+	ca.CRLConfig.EnableDelta = true
+	for i := 0; i < 3; i++ {
+		cert := issueTestCert(t, ca, "anonymous-test")
+		ca.RevokeCertificate(ca.Name.CommonName, cert.SerialNumber.String(), ReasonSuperseded)
+	}
+	_, version, err := ca.FilterSnapshot()
+	if err != nil {
+		t.Fatalf("snapshot failed: %v", err)
+	}
+	if _, err := ca.GenerateCRL(time.Now()); err != nil {
+		t.Fatalf("generate CRL failed: %v", err)
+	}
+	if _, err := ca.GenerateDeltaCRL(time.Now()); err != nil {
+		t.Fatalf("generate delta CRL failed: %v", err)
+	}
+
+	// 发布CRL不能覆盖已持久化的过滤器版本，重启后版本只能前进
+	restarted := NewCAManagerWithStore(store)
+	if err := restarted.LoadState(); err != nil {
+		t.Fatalf("load state failed: %v", err)
+	}
+	restored, _ := restarted.GetCAInfo(ca.Name.CommonName)
+	if restored.crlState.FilterVersion != version {
+		t.Fatalf("persisted filter version is %d, want %d", restored.crlState.FilterVersion, version)
+	}
+	if _, restoredVersion, err := restored.FilterSnapshot(); err != nil || restoredVersion <= version {
+		t.Fatalf("restored filter version %d should advance past %d: %v", restoredVersion, version, err)
+	}
+}
@@ -0,0 +1,289 @@
+package cer_ca_tools
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+// BinaryFuseFilter 三路 binary fuse 过滤器（Graf & Lemire 2022），XOR 过滤器的改进版本。
+// 每个元素映射到相邻三个段中的各一个位置，三个 16 位指纹的异或等于元素指纹；
+// 约 18 位/元素，误判率约 2^-16。过滤器是静态的：Add 与 Remove 返回 ErrFilterStatic，
+// 每个发布周期由全部被撤销证书调用 NewBinaryFuseFilter 重建。
+//
+// 二进制格式（大端）：magic "BFF" | version(1) | seed(8) | segmentLength(4) | segmentCount(4) |
+// elements(8) | 指纹(2 字节 × (segmentCount+2) × segmentLength) | crc32(4)
+type BinaryFuseFilter struct {
+	seed               uint64
+	segmentLength      uint32
+	segmentCount       uint32
+	segmentCountLength uint32
+	elements           uint
+	fingerprints       []uint16
+}
+
+const (
+	BinaryFuseFormatVersion = 1
+
+	fuseMaxSegmentLength = 1 << 18
+	// fuseMaxAttempts 更换种子重新构建的次数上限，元素互不相同时极少需要重试
+	fuseMaxAttempts = 100
+	// fuseMaxLength 允许的最大指纹数，构建与反序列化时都不得超过
+	fuseMaxLength = 1 << 30
+	// fuseMaxSegmentCount 段数上限，段长至少为 4
+	fuseMaxSegmentCount = fuseMaxLength/4 - 2
+)
+
+var fuseMagic = [3]byte{'B', 'F', 'F'}
+
+var (
+	ErrInvalidFuseData  = errors.New("invalid binary fuse filter data")
+	ErrFuseConstruction = errors.New("binary fuse filter construction failed")
+)
+
+// NewBinaryFuseFilter 由全部元素构建过滤器，重复元素只计一次
+func NewBinaryFuseFilter(elements [][]byte) (*BinaryFuseFilter, error) {
+	keys := make([]uint64, 0, len(elements))
+	for _, element := range elements {
+		sum := sha256.Sum256(element)
+		keys = append(keys, binary.BigEndian.Uint64(sum[:8]))
+	}
+	slices.Sort(keys)
+	keys = slices.Compact(keys)
+
+	filter, err := newBinaryFuseFilter(uint64(len(keys)))
+	if err != nil {
+		return nil, err
+	}
+	rng := uint64(1)
+	for attempt := 0; attempt < fuseMaxAttempts; attempt++ {
+		filter.seed = splitmix64(&rng)
+		if filter.populate(keys) {
+			return filter, nil
+		}
+	}
+	return nil, fmt.Errorf("%w after %d attempts", ErrFuseConstruction, fuseMaxAttempts)
+}
+
+func newBinaryFuseFilter(size uint64) (*BinaryFuseFilter, error) {
+	segmentLength := uint64(4)
+	if size > 0 {
+		segmentLength = 1 << int(math.Floor(math.Log(float64(size))/math.Log(3.33)+2.25))
+	}
+	segmentLength = min(segmentLength, fuseMaxSegmentLength)
+
+	capacity := uint64(0)
+	if size > 1 {
+		sizeFactor := max(1.125, 0.875+0.25*math.Log(1000000)/math.Log(float64(size)))
+		capacity = uint64(math.Round(float64(size) * sizeFactor))
+	}
+	segmentCount := max((capacity+segmentLength-1)/segmentLength, 3) - 2
+	if segmentCount > fuseMaxSegmentCount {
+		return nil, fmt.Errorf("%w: %d elements exceed the maximum filter size", ErrFuseConstruction, size)
+	}
+	return newBinaryFuseLayout(uint32(segmentLength), uint32(segmentCount), uint(size))
+}
+
+// newBinaryFuseLayout 按段长与段数分配指纹，长度在 uint64 中计算并检查上限
+func newBinaryFuseLayout(segmentLength, segmentCount uint32, elements uint) (*BinaryFuseFilter, error) {
+	length := (uint64(segmentCount) + 2) * uint64(segmentLength)
+	if segmentLength == 0 || segmentLength > fuseMaxSegmentLength || segmentLength&(segmentLength-1) != 0 ||
+		segmentCount == 0 || segmentCount > fuseMaxSegmentCount || length > fuseMaxLength {
+		return nil, fmt.Errorf("%w: bad layout (segment length %d, segment count %d)", ErrInvalidFuseData, segmentLength, segmentCount)
+	}
+	return &BinaryFuseFilter{
+		segmentLength:      segmentLength,
+		segmentCount:       segmentCount,
+		segmentCountLength: uint32(uint64(segmentCount) * uint64(segmentLength)),
+		elements:           elements,
+		fingerprints:       make([]uint16, length),
+	}, nil
+}
+
+func splitmix64(state *uint64) uint64 {
+	*state += 0x9e3779b97f4a7c15
+	z := *state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// mix 以种子打散元素键（murmur3 终结函数）
+func (filter *BinaryFuseFilter) mix(key uint64) uint64 {
+	h := key + filter.seed
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	return h ^ h>>33
+}
+
+// positions 返回哈希在相邻三个段中的位置
+func (filter *BinaryFuseFilter) positions(hash uint64) [3]uint32 {
+	hi, _ := bits.Mul64(hash, uint64(filter.segmentCountLength))
+	mask := filter.segmentLength - 1
+	h0 := uint32(hi)
+	h1 := (h0 + filter.segmentLength) ^ uint32(hash>>18)&mask
+	h2 := (h0 + 2*filter.segmentLength) ^ uint32(hash)&mask
+	return [3]uint32{h0, h1, h2}
+}
+
+func fuseFingerprint(hash uint64) uint16 {
+	return uint16(hash ^ hash>>32)
+}
+
+// populate 剥离（peeling）超图：反复取出只被一个元素占用的位置，按相反顺序赋值指纹
+func (filter *BinaryFuseFilter) populate(keys []uint64) bool {
+	capacity := len(filter.fingerprints)
+	// counts 高位为占用该位置的元素数，低 2 位为这些元素在该位置上的序号（0、1、2）的异或
+	counts := make([]uint32, capacity)
+	hashes := make([]uint64, capacity)
+	for _, key := range keys {
+		hash := filter.mix(key)
+		for i, position := range filter.positions(hash) {
+			counts[position] += 4
+			counts[position] ^= uint32(i)
+			hashes[position] ^= hash
+		}
+	}
+
+	queue := make([]uint32, 0, capacity)
+	for position, count := range counts {
+		if count>>2 == 1 {
+			queue = append(queue, uint32(position))
+		}
+	}
+	type peeled struct {
+		hash     uint64
+		position uint32
+		index    uint32
+	}
+	stack := make([]peeled, 0, len(keys))
+	for len(queue) > 0 {
+		position := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		if counts[position]>>2 != 1 {
+			continue
+		}
+		hash, index := hashes[position], counts[position]&3
+		stack = append(stack, peeled{hash: hash, position: position, index: index})
+		for i, other := range filter.positions(hash) {
+			counts[other] -= 4
+			counts[other] ^= uint32(i)
+			hashes[other] ^= hash
+			if counts[other]>>2 == 1 {
+				queue = append(queue, other)
+			}
+		}
+	}
+	if len(stack) != len(keys) {
+		return false
+	}
+
+	clear(filter.fingerprints)
+	for i := len(stack) - 1; i >= 0; i-- {
+		entry := stack[i]
+		positions := filter.positions(entry.hash)
+		fingerprint := fuseFingerprint(entry.hash)
+		for j, other := range positions {
+			if uint32(j) != entry.index {
+				fingerprint ^= filter.fingerprints[other]
+			}
+		}
+		filter.fingerprints[entry.position] = fingerprint
+	}
+	return true
+}
+
+func (filter *BinaryFuseFilter) Add([]byte) error {
+	return ErrFilterStatic
+}
+
+func (filter *BinaryFuseFilter) Remove([]byte) error {
+	return ErrFilterStatic
+}
+
+func (filter *BinaryFuseFilter) Contains(data []byte) bool {
+	if filter.elements == 0 {
+		return false
+	}
+	sum := sha256.Sum256(data)
+	hash := filter.mix(binary.BigEndian.Uint64(sum[:8]))
+	fingerprint := fuseFingerprint(hash)
+	for _, position := range filter.positions(hash) {
+		fingerprint ^= filter.fingerprints[position]
+	}
+	return fingerprint == 0
+}
+
+func (filter *BinaryFuseFilter) Marshal() ([]byte, error) {
+	return filter.MarshalBinary()
+}
+
+func (filter *BinaryFuseFilter) Stats() map[string]interface{} {
+	bitsPerElement := float64(0)
+	if filter.elements > 0 {
+		bitsPerElement = float64(len(filter.fingerprints)*16) / float64(filter.elements)
+	}
+	return map[string]interface{}{
+		"segment_length":   filter.segmentLength,
+		"segment_count":    filter.segmentCount,
+		"fingerprint_bits": 16,
+		"elements":         filter.elements,
+		"bits_per_element": bitsPerElement,
+		"estimated_fpr":    1.0 / (1 << 16),
+		"memory_bytes":     uint(len(filter.fingerprints)) * 2,
+	}
+}
+
+// MarshalBinary 实现 encoding.BinaryMarshaler
+func (filter *BinaryFuseFilter) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 0, 28+len(filter.fingerprints)*2+cbfChecksumSize)
+	data = append(data, fuseMagic[:]...)
+	data = append(data, BinaryFuseFormatVersion)
+	data = binary.BigEndian.AppendUint64(data, filter.seed)
+	data = binary.BigEndian.AppendUint32(data, filter.segmentLength)
+	data = binary.BigEndian.AppendUint32(data, filter.segmentCount)
+	data = binary.BigEndian.AppendUint64(data, uint64(filter.elements))
+	for _, fingerprint := range filter.fingerprints {
+		data = binary.BigEndian.AppendUint16(data, fingerprint)
+	}
+	return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(data)), nil
+}
+
+// UnmarshalBinary 实现 encoding.BinaryUnmarshaler
+func (filter *BinaryFuseFilter) UnmarshalBinary(data []byte) error {
+	const headerSize = 28
+	if len(data) < headerSize+cbfChecksumSize || [3]byte(data[:3]) != fuseMagic {
+		return ErrInvalidFuseData
+	}
+	if data[3] != BinaryFuseFormatVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidFuseData, data[3])
+	}
+	body := data[:len(data)-cbfChecksumSize]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(data[len(body):]) {
+		return fmt.Errorf("%w: checksum mismatch", ErrInvalidFuseData)
+	}
+
+	segmentLength := binary.BigEndian.Uint32(body[12:16])
+	segmentCount := binary.BigEndian.Uint32(body[16:20])
+	// 先按数据长度检查指纹数，避免按伪造的头部分配内存
+	if (uint64(segmentCount)+2)*uint64(segmentLength) != uint64(len(body)-headerSize)/2 || (len(body)-headerSize)%2 != 0 {
+		return fmt.Errorf("%w: fingerprint length mismatch", ErrInvalidFuseData)
+	}
+	restored, err := newBinaryFuseLayout(segmentLength, segmentCount, uint(binary.BigEndian.Uint64(body[20:28])))
+	if err != nil {
+		return err
+	}
+	restored.seed = binary.BigEndian.Uint64(body[4:12])
+	for i := range restored.fingerprints {
+		restored.fingerprints[i] = binary.BigEndian.Uint16(body[headerSize+2*i:])
+	}
+	*filter = *restored
+	return nil
+}
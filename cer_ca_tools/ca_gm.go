@@ -0,0 +1,52 @@
+package cer_ca_tools
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+
+	"github.com/FISCO-BCOS/go-sdk/smcrypto"
+)
+
+// 国密（SM2/SM3）证书支持：SM2 密钥的CA签发 SM2-with-SM3 证书与CRL，
+// 其余CA仍走 crypto/x509。证书解析与签名验证统一经由 smcrypto，两类证书均可处理。
+
+// CreateCertificate 签发证书，签名密钥为 SM2 时生成国密证书，否则与 x509.CreateCertificate 相同
+func CreateCertificate(template, parent *x509.Certificate, publicKey crypto.PublicKey, signer crypto.Signer) ([]byte, error) {
+	if smcrypto.IsSM2PublicKey(signer.Public()) {
+		return smcrypto.CreateCertificate(template, parent, publicKey, signer)
+	}
+	// crypto/x509 无法编码 SM2 公钥，SM2 证书主体只能由国密CA签发
+	if smcrypto.IsSM2PublicKey(publicKey) {
+		return nil, ErrGMCertificate
+	}
+	return x509.CreateCertificate(rand.Reader, template, parent, publicKey, signer)
+}
+
+// ParseCertificate 解析 DER 证书，支持国密证书；国密证书的签名需用 smcrypto.CheckSignatureFrom 验证
+func ParseCertificate(der []byte) (*x509.Certificate, error) {
+	return smcrypto.ParseCertificate(der)
+}
+
+// checkSignatureFrom 验证 cert 由 parent 签发，支持国密证书
+func checkSignatureFrom(cert, parent *x509.Certificate) error {
+	return smcrypto.CheckSignatureFrom(cert, parent)
+}
+
+// createRevocationList 生成CRL，签名密钥为 SM2 时使用 SM2-with-SM3
+func createRevocationList(template *x509.RevocationList, issuer *x509.Certificate, signer crypto.Signer) ([]byte, error) {
+	if smcrypto.IsSM2PublicKey(signer.Public()) {
+		return smcrypto.CreateRevocationList(template, issuer, signer)
+	}
+	return x509.CreateRevocationList(rand.Reader, template, issuer, signer)
+}
+
+// CheckCRLSignature 验证CRL由 issuer 签发，支持国密CRL
+func CheckCRLSignature(crl *x509.RevocationList, issuer *x509.Certificate) error {
+	return smcrypto.CheckRevocationListSignature(crl, issuer)
+}
+
+// isGMCertificate 证书使用 SM2 公钥或 SM2-with-SM3 签名
+func isGMCertificate(cert *x509.Certificate) bool {
+	return smcrypto.IsSM2PublicKey(cert.PublicKey) || smcrypto.IsSM2Signed(cert.Raw)
+}
@@ -0,0 +1,109 @@
+package cer_ca_tools
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/FISCO-BCOS/go-sdk/smcrypto"
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestGMCA(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+	manager := NewCAManagerWithStore(store)
+	ca, err := manager.CreateCAWithAlgorithm("ca_gm_test", KeyAlgorithmSM2)
+	if err != nil {
+		t.Fatalf("create SM2 CA failed: %v", err)
+	}
+	if ca.KeyAlgorithm() != KeyAlgorithmSM2 || !ca.IsRoot() {
+		t.Fatalf("expected a self-signed SM2 root CA, have %s", ca.KeyAlgorithm())
+	}
+
+	subjectSK, _ := GenerateKey(KeyAlgorithmSM2)
+	csrDER, err := CreateCSR(&x509.CertificateRequest{}, subjectSK)
+	if err != nil {
+		t.Fatalf("create SM2 CSR failed: %v", err)
+	}
+	csr, err := ParseCSR(csrDER)
+	if err != nil {
+		t.Fatalf("parse SM2 CSR failed: %v", err)
+	}
+	response := ca.IssueCertificateFromCSR("", pkix.Name{CommonName: "gm subject"}, csr)
+	if !response.Success {
+		t.Fatalf("GM CA issue failed: %s %v", response.Message, response.Err)
+	}
+	cert := parseTestCertificate(t, response.Certificate)
+	if !smcrypto.IsSM2Signed(cert.Raw) || !smcrypto.IsSM2PublicKey(cert.PublicKey) {
+		t.Fatalf("certificate issued by a GM CA should be an SM2 certificate")
+	}
+	if _, err := VerifyCertChain(cert, nil, []*x509.Certificate{ca.Certificate}, nil); err != nil {
+		t.Fatalf("GM certificate should verify: %v", err)
+	}
+
+	intermediate, err := manager.CreateIntermediateCA("ca_gm_sub_test", "ca_gm_test", 30)
+	if err != nil || intermediate.KeyAlgorithm() != KeyAlgorithmSM2 {
+		t.Fatalf("create SM2 intermediate CA failed: %v", err)
+	}
+	leaf := issueTestCert(t, intermediate, "gm leaf")
+	if !smcrypto.IsSM2Signed(leaf.Raw) || !smcrypto.IsSM2PublicKey(leaf.PublicKey) {
+		t.Fatalf("certificate issued by a GM intermediate CA should be an SM2 certificate")
+	}
+	chain, err := VerifyCertChain(leaf, []*x509.Certificate{intermediate.Certificate}, []*x509.Certificate{ca.Certificate}, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth})
+	if err != nil || len(chain) != 3 {
+		t.Fatalf("GM chain should verify, have %d %v", len(chain), err)
+	}
+	if _, err := VerifyCertChain(leaf, nil, []*x509.Certificate{ca.Certificate}, nil); err == nil {
+		t.Fatalf("GM chain without the intermediate should not verify")
+	}
+
+	// NIST 曲线CA不能为 SM2 公钥签发证书
+	nistCA, err := manager.CreateCAWithAlgorithm("ca_gm_p384_test", KeyAlgorithmP384)
+	if err != nil {
+		t.Fatalf("create P-384 CA failed: %v", err)
+	}
+	sm2SK, _ := GenerateKey(KeyAlgorithmSM2)
+	if response := nistCA.IssueCertificate(pkix.Name{CommonName: "gm subject"}, sm2SK.Public()); response.Success || !errors.Is(response.Err, ErrGMCertificate) {
+		t.Fatalf("P-384 CA should refuse SM2 keys, have %v", response.Err)
+	}
+
+	serial := cert.SerialNumber.String()
+	if response := ca.RevokeCertificate(ca.Name.CommonName, serial, 1); !response.Success {
+		t.Fatalf("revoke certificate failed: %s", response.Message)
+	}
+	crlDER, err := ca.GenerateCRL(time.Now())
+	if err != nil {
+		t.Fatalf("generate GM CRL failed: %v", err)
+	}
+	crl, err := x509.ParseRevocationList(crlDER)
+	if err != nil || len(crl.RevokedCertificateEntries) != 1 {
+		t.Fatalf("parse GM CRL failed: %v", err)
+	}
+	if err := CheckCRLSignature(crl, ca.Certificate); err != nil {
+		t.Fatalf("GM CRL signature should verify: %v", err)
+	}
+
+	// x/crypto/ocsp 不识别 SM2-with-SM3 签名算法，只检查响应内容
+	requestDER, _ := ocsp.CreateRequest(cert, ca.Certificate, nil)
+	status, err := ocsp.ParseResponse(manager.OCSP.Respond(requestDER), nil)
+	if err != nil || status.Status != ocsp.Revoked {
+		t.Fatalf("GM CA OCSP response should report revoked, have %v", err)
+	}
+
+	restarted := NewCAManagerWithStore(store)
+	if err := restarted.LoadState(); err != nil {
+		t.Fatalf("load state failed: %v", err)
+	}
+	restored, exists := restarted.GetCAInfo("ca_gm_sub_test")
+	if !exists || restored.KeyAlgorithm() != KeyAlgorithmSM2 || restored.Parent == nil {
+		t.Fatalf("SM2 intermediate CA not restored with its parent")
+	}
+	if !smcrypto.IsSM2PublicKey(restored.PrivateKey.Public()) {
+		t.Fatalf("restored CA key should be an SM2 key, have %T", restored.PrivateKey)
+	}
+	if cert := issueTestCert(t, restored, "gm subject after restart"); !smcrypto.IsSM2Signed(cert.Raw) {
+		t.Fatalf("restored GM CA should issue SM2 certificates")
+	}
+}
@@ -0,0 +1,292 @@
+package cer_ca_tools
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/FISCO-BCOS/go-sdk/smcrypto"
+)
+
+// IsRoot 是否为自签名根CA
+func (ca *CA) IsRoot() bool {
+	ca.Mutex.Lock()
+	defer ca.Mutex.Unlock()
+
+	return ca.Parent == nil && checkSignatureFrom(ca.Certificate, ca.Certificate) == nil
+}
+
+// IsOffline 私钥不在本进程中（例如离线保存的根CA），此时CA只能用于链验证
+func (ca *CA) IsOffline() bool {
+	return ca.PrivateKey == nil
+}
+
+// CertificateChain 返回从本CA到根CA的证书链，上级CA轮换过密钥时使用实际签发下级证书的那张上级证书。
+// 依次持有各级CA的锁读取证书与上级，不同时持有两把锁；调用方不能持有 ca.Mutex
+func (ca *CA) CertificateChain() []*x509.Certificate {
+	ca.Mutex.Lock()
+	chain := []*x509.Certificate{ca.Certificate}
+	parent := ca.Parent
+	ca.Mutex.Unlock()
+
+	for parent != nil {
+		parent.Mutex.Lock()
+		chain = append(chain, parent.issuerCertificate(chain[len(chain)-1]))
+		next := parent.Parent
+		parent.Mutex.Unlock()
+		parent = next
+	}
+	return chain
+}
+
+// ChainPEM 将叶子证书与本CA到根CA的证书链编码为PEM证书包
+func (ca *CA) ChainPEM(leaf *x509.Certificate) string {
+	var bundle []byte
+	if leaf != nil {
+		bundle = append(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw})...)
+	}
+	for _, cert := range ca.CertificateChain() {
+		bundle = append(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	return string(bundle)
+}
+
+// CreateIntermediateCA 按 intermediate-ca 模板由已注册的父CA签发中间CA证书，默认模板路径长度为0，只能签发终端证书
+// 中间CA密钥沿用父CA的密钥算法
+func (manager *CAManager) CreateIntermediateCA(caName string, parentName string, days int) (*CA, error) {
+	return manager.CreateIntermediateCAWithAlgorithm(caName, parentName, days, "")
+}
+
+// CreateIntermediateCAWithAlgorithm 同 CreateIntermediateCA，中间CA密钥使用指定算法，为空时沿用父CA的算法
+func (manager *CAManager) CreateIntermediateCAWithAlgorithm(caName string, parentName string, days int, algorithm KeyAlgorithm) (*CA, error) {
+	if _, exists := manager.GetCAInfo(caName); exists {
+		return nil, fmt.Errorf("CA %s already exists", caName)
+	}
+	parent, exists := manager.GetCAInfo(parentName)
+	if !exists {
+		return nil, fmt.Errorf("parent CA %s not found", parentName)
+	}
+
+	// 持有父CA的签发读锁，签发期间父CA不会轮换密钥；父CA证书与私钥在同一临界区内读取，
+	// 之后的检查、模板与签名都使用这份快照
+	parent.issuing.RLock()
+	defer parent.issuing.RUnlock()
+	parent.Mutex.Lock()
+	parentCert, parentSK := parent.Certificate, parent.PrivateKey
+	parent.Mutex.Unlock()
+
+	if algorithm == "" {
+		algorithm, _ = PublicKeyAlgorithm(parentCert.PublicKey)
+	}
+	if err := checkKeyAlgorithm(algorithm); err != nil {
+		return nil, fmt.Errorf("failed to create CA %s: %w", caName, err)
+	}
+	if parentSK == nil {
+		return nil, fmt.Errorf("parent CA %s is offline", parentName)
+	}
+	if parentCert.MaxPathLen == 0 && parentCert.MaxPathLenZero {
+		return nil, fmt.Errorf("parent CA %s is not allowed to issue CA certificates (pathLen 0)", parentName)
+	}
+
+	intermediateSK, err := manager.KeyGenerator(caName, algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate intermediate CA key: %w", err)
+	}
+
+	manager.mutex.RLock()
+	profile, err := manager.Profiles.Get(ProfileIntermediateCA)
+	manager.mutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template, err := profile.NewTemplate(&IssuanceRequest{
+		Subject: pkix.Name{
+			CommonName:         caName,
+			Organization:       parentCert.Subject.Organization,
+			OrganizationalUnit: parentCert.Subject.OrganizationalUnit,
+			Country:            parentCert.Subject.Country,
+			Province:           parentCert.Subject.Province,
+		},
+		PublicKey: intermediateSK.Public(),
+	}, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build intermediate CA certificate: %w", err)
+	}
+	// days 为 0 时使用模板的有效期，且不超过父CA证书的有效期
+	if days > 0 {
+		template.NotAfter = now.Add(time.Duration(days) * 24 * time.Hour)
+	}
+	if template.NotAfter.After(parentCert.NotAfter) {
+		template.NotAfter = parentCert.NotAfter
+	}
+
+	intermediateDER, err := CreateCertificate(template, parentCert, intermediateSK.Public(), parentSK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create intermediate CA certificate: %w", err)
+	}
+	intermediateCert, err := ParseCertificate(intermediateDER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse intermediate CA certificate: %w", err)
+	}
+
+	ca := newCA(intermediateCert, intermediateSK)
+	ca.Parent = parent
+	if err := parent.recordAudit(AuditEventCACreate, intermediateCert.SerialNumber.String(), map[string]string{
+		"type": "intermediate",
+		"ca":   caName,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to record creation of CA %s in audit log: %w", caName, err)
+	}
+	if err := manager.Store.SaveCA(ca); err != nil {
+		return nil, fmt.Errorf("failed to save CA %s: %w", caName, err)
+	}
+	manager.AddCAToManager(ca)
+
+	log.Printf("Intermediate CA %s issued by %s", caName, parentName)
+	return ca, nil
+}
+
+// linkHierarchy 根据证书签名关系恢复CA之间的父子关系
+func (manager *CAManager) linkHierarchy() {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	for _, ca := range manager.CAs {
+		if ca.Parent != nil || checkSignatureFrom(ca.Certificate, ca.Certificate) == nil {
+			continue
+		}
+		for _, candidate := range manager.CAs {
+			if candidate == ca {
+				continue
+			}
+			candidate.Mutex.Lock()
+			issuer := candidate.issuerCertificate(ca.Certificate)
+			candidate.Mutex.Unlock()
+			if checkSignatureFrom(ca.Certificate, issuer) == nil {
+				ca.Mutex.Lock()
+				ca.Parent = candidate
+				ca.Mutex.Unlock()
+				break
+			}
+		}
+		if ca.Parent == nil {
+			log.Printf("CA %s: issuer %s is not registered", ca.Name.CommonName, ca.Certificate.Issuer)
+		}
+	}
+}
+
+// VerifyCertChain 验证多级证书链，除 x509 路径构建外还检查每一级CA的基本约束、密钥用途与路径长度
+func VerifyCertChain(leaf *x509.Certificate, intermediates []*x509.Certificate, roots []*x509.Certificate, keyUsages []x509.ExtKeyUsage) ([]*x509.Certificate, error) {
+	rootPool := x509.NewCertPool()
+	for _, root := range roots {
+		rootPool.AddCert(root)
+	}
+	intermediatePool := x509.NewCertPool()
+	for _, intermediate := range intermediates {
+		intermediatePool.AddCert(intermediate)
+	}
+	if len(keyUsages) == 0 {
+		keyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+	}
+	if containsGMCertificate(leaf, intermediates, roots) {
+		return verifyGMCertChain(leaf, intermediates, roots, keyUsages)
+	}
+
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         rootPool,
+		Intermediates: intermediatePool,
+		KeyUsages:     keyUsages,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, chain := range chains {
+		if lastErr = checkChainConstraints(chain); lastErr == nil {
+			return chain, nil
+		}
+	}
+	return nil, lastErr
+}
+
+// verifyGMCertChain 验证含国密证书的证书链，crypto/x509 无法验证 SM2-with-SM3 签名，
+// 路径构建与签名验证由 smcrypto 完成，扩展密钥用途只检查终端证书
+func verifyGMCertChain(leaf *x509.Certificate, intermediates []*x509.Certificate, roots []*x509.Certificate, keyUsages []x509.ExtKeyUsage) ([]*x509.Certificate, error) {
+	chain, err := smcrypto.VerifyCertificate(leaf, smcrypto.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !allowsExtKeyUsage(leaf, keyUsages) {
+		return nil, x509.CertificateInvalidError{Cert: leaf, Reason: x509.IncompatibleUsage}
+	}
+	if err := checkChainConstraints(chain); err != nil {
+		return nil, err
+	}
+	return chain, nil
+}
+
+func containsGMCertificate(leaf *x509.Certificate, pools ...[]*x509.Certificate) bool {
+	if isGMCertificate(leaf) {
+		return true
+	}
+	for _, pool := range pools {
+		for _, cert := range pool {
+			if isGMCertificate(cert) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allowsExtKeyUsage 证书未限制扩展密钥用途，或包含任一要求的用途
+func allowsExtKeyUsage(cert *x509.Certificate, keyUsages []x509.ExtKeyUsage) bool {
+	if len(cert.ExtKeyUsage) == 0 {
+		return true
+	}
+	for _, usage := range cert.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageAny {
+			return true
+		}
+		for _, wanted := range keyUsages {
+			if wanted == x509.ExtKeyUsageAny || wanted == usage {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func checkChainConstraints(chain []*x509.Certificate) error {
+	leaf := chain[0]
+	if leaf.KeyUsage != 0 && leaf.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+		return fmt.Errorf("leaf certificate %s lacks digitalSignature key usage", leaf.Subject.CommonName)
+	}
+
+	for i := 1; i < len(chain); i++ {
+		caCert := chain[i]
+		if !caCert.BasicConstraintsValid || !caCert.IsCA {
+			return fmt.Errorf("certificate %s in chain is not a CA", caCert.Subject.CommonName)
+		}
+		if caCert.KeyUsage != 0 && caCert.KeyUsage&x509.KeyUsageCertSign == 0 {
+			return fmt.Errorf("CA certificate %s lacks keyCertSign key usage", caCert.Subject.CommonName)
+		}
+		// 当前CA下方的中间CA数量不能超过其 pathLenConstraint
+		intermediatesBelow := i - 1
+		if caCert.MaxPathLen > 0 || caCert.MaxPathLenZero {
+			if intermediatesBelow > caCert.MaxPathLen {
+				return fmt.Errorf("CA certificate %s path length %d exceeded (%d intermediates below)",
+					caCert.Subject.CommonName, caCert.MaxPathLen, intermediatesBelow)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package cer_ca_tools
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIntermediateCA(t *testing.T) {
+	dir := t.TempDir()
+	manager := NewCAManagerWithStore(NewFileStore(dir))
+	root, err := manager.CreateCA("root_test")
+	if err != nil {
+		t.Fatalf("create root CA failed: %v", err)
+	}
+	intermediate, err := manager.CreateIntermediateCA("intermediate_test", "root_test", 30)
+	if err != nil {
+		t.Fatalf("create intermediate CA failed: %v", err)
+	}
+	if _, err := manager.CreateIntermediateCA("sub_intermediate_test", "intermediate_test", 30); err == nil {
+		t.Fatalf("intermediate CA with pathLen 0 must not issue CA certificates")
+	}
+
+	subjectSK, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	response := intermediate.IssueCertificate(pkix.Name{CommonName: "leaf-test"}, &subjectSK.PublicKey)
+	if !response.Success {
+		t.Fatalf("issue certificate failed: %s", response.Message)
+	}
+	bundle, err := parseCertBundle([]byte(response.Chain))
+	if err != nil {
+		t.Fatalf("parse chain bundle failed: %v", err)
+	}
+	if len(bundle) != 3 || !bundle[2].Equal(root.Certificate) {
+		t.Fatalf("chain bundle should be leaf, intermediate, root; have %d certificates", len(bundle))
+	}
+	chain, err := VerifyCertChain(bundle[0], bundle[1:2], []*x509.Certificate{root.Certificate}, nil)
+	if err != nil {
+		t.Fatalf("verify chain failed: %v", err)
+	}
+	if len(chain) != 3 {
+		t.Fatalf("unexpected chain length %d", len(chain))
+	}
+
+	// 根CA私钥移出线上环境后，重启仍能恢复层级关系，根CA不能再签发证书
+	if err := os.Rename(filepath.Join(dir, "ca", "root_test.key"), filepath.Join(t.TempDir(), "root_test.key")); err != nil {
+		t.Fatalf("move root key failed: %v", err)
+	}
+	restarted := NewCAManagerWithStore(NewFileStore(dir))
+	if err := restarted.LoadState(); err != nil {
+		t.Fatalf("load state failed: %v", err)
+	}
+	offlineRoot, _ := restarted.GetCAInfo("root_test")
+	restoredIntermediate, _ := restarted.GetCAInfo("intermediate_test")
+	if !offlineRoot.IsOffline() || !offlineRoot.IsRoot() {
+		t.Fatalf("root CA should be loaded as an offline root")
+	}
+	if restoredIntermediate.Parent != offlineRoot {
+		t.Fatalf("intermediate CA parent was not restored")
+	}
+	if response := offlineRoot.IssueCertificate(pkix.Name{CommonName: "leaf-test"}, &subjectSK.PublicKey); response.Success {
+		t.Fatalf("offline root CA must not issue certificates")
+	}
+	if response := restoredIntermediate.IssueCertificate(pkix.Name{CommonName: "leaf-test"}, &subjectSK.PublicKey); !response.Success {
+		t.Fatalf("intermediate CA issue after restart failed: %s", response.Message)
+	}
+}
+
+func TestChainDuringParentRollover(t *testing.T) {
+	manager := NewCAManagerWithStore(NewMemoryStore())
+	if _, err := manager.CreateCA("root_chain_test"); err != nil {
+		t.Fatalf("create root CA failed: %v", err)
+	}
+	intermediate, err := manager.CreateIntermediateCA("intermediate_chain_test", "root_chain_test", 30)
+	if err != nil {
+		t.Fatalf("create intermediate CA failed: %v", err)
+	}
+
+	// 上级CA轮换密钥时并发构建证书链，go test -race 下不应出现数据竞争
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 3; i++ {
+			if _, err := manager.RolloverCA("root_chain_test", time.Hour); err != nil {
+				t.Errorf("rollover failed: %v", err)
+			}
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		if chain := intermediate.CertificateChain(); len(chain) != 2 {
+			t.Fatalf("chain should have 2 certificates, have %d", len(chain))
+		}
+	}
+	<-done
+	chain := intermediate.CertificateChain()
+	if checkSignatureFrom(chain[0], chain[1]) != nil {
+		t.Fatalf("chain should use the parent certificate that issued the intermediate CA")
+	}
+}
+
+func TestIntermediateDuringParentRollover(t *testing.T) {
+	manager := NewCAManagerWithStore(NewMemoryStore())
+	root, err := manager.CreateCA("root_snapshot_test")
+	if err != nil {
+		t.Fatalf("create root CA failed: %v", err)
+	}
+	first, err := manager.CreateIntermediateCA("intermediate_snapshot_0", "root_snapshot_test", 30)
+	if err != nil {
+		t.Fatalf("create intermediate CA failed: %v", err)
+	}
+
+	// 父CA轮换与中间CA的创建、重新签发并发进行，中间CA证书须由签发时父CA的某个密钥签名
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5; i++ {
+			if _, err := manager.RolloverCA("root_snapshot_test", time.Hour); err != nil {
+				t.Errorf("root rollover failed: %v", err)
+				return
+			}
+		}
+	}()
+	intermediates := []*CA{first}
+	for i := 1; ; i++ {
+		intermediate, err := manager.CreateIntermediateCA(fmt.Sprintf("intermediate_snapshot_%d", i), "root_snapshot_test", 30)
+		if err != nil {
+			t.Fatalf("create intermediate CA failed: %v", err)
+		}
+		intermediates = append(intermediates, intermediate)
+		if _, err := manager.RolloverCA("intermediate_snapshot_0", time.Hour); err != nil {
+			t.Fatalf("intermediate rollover failed: %v", err)
+		}
+		select {
+		case <-done:
+		default:
+			continue
+		}
+		break
+	}
+
+	for _, intermediate := range intermediates {
+		if err := checkSignatureFrom(intermediate.Certificate, root.issuerCertificate(intermediate.Certificate)); err != nil {
+			t.Fatalf("%s is not signed by a key of its parent: %v", intermediate.Name.CommonName, err)
+		}
+	}
+}
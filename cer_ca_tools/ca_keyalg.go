@@ -0,0 +1,172 @@
+package cer_ca_tools
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	gmecdsa "github.com/FISCO-BCOS/crypto/ecdsa"
+	"github.com/FISCO-BCOS/go-sdk/smcrypto"
+)
+
+// KeyAlgorithm CA与证书主体的密钥算法，名称与证书模板 allowed_curves 中的取值一致
+type KeyAlgorithm string
+
+const (
+	KeyAlgorithmP256    KeyAlgorithm = "P-256"
+	KeyAlgorithmP384    KeyAlgorithm = "P-384"
+	KeyAlgorithmEd25519 KeyAlgorithm = "Ed25519"
+	KeyAlgorithmSM2     KeyAlgorithm = "SM2" // sm2p256v1，SM2 签名内部使用 SM3
+)
+
+// DefaultKeyAlgorithm 未指定算法时使用的密钥算法
+const DefaultKeyAlgorithm = KeyAlgorithmP256
+
+var (
+	ErrUnsupportedKeyAlgorithm = errors.New("unsupported key algorithm")
+	// ErrGMCertificate SM2 公钥只能出现在 SM2-with-SM3 签名的国密证书中，须由 SM2 密钥的CA签发
+	ErrGMCertificate = errors.New("SM2 keys must be certified by an SM2 (GM) CA")
+	// ErrGMTLSUnsupported crypto/tls 无法解析国密证书，SM2 主体与验证方不能建立 TLS 连接，
+	// SM2 密钥只用于证书签发与 VRF 证明；国密 TLS（TLCP）握手需要单独的实现
+	ErrGMTLSUnsupported = errors.New("SM2 (GM) certificates are not supported by crypto/tls")
+)
+
+// 算法名称的别名，解析时不区分大小写
+var keyAlgorithmNames = map[string]KeyAlgorithm{
+	"p-256":      KeyAlgorithmP256,
+	"p256":       KeyAlgorithmP256,
+	"prime256v1": KeyAlgorithmP256,
+	"secp256r1":  KeyAlgorithmP256,
+	"es256":      KeyAlgorithmP256,
+	"p-384":      KeyAlgorithmP384,
+	"p384":       KeyAlgorithmP384,
+	"secp384r1":  KeyAlgorithmP384,
+	"es384":      KeyAlgorithmP384,
+	"ed25519":    KeyAlgorithmEd25519,
+	"eddsa":      KeyAlgorithmEd25519,
+	"sm2":        KeyAlgorithmSM2,
+	"sm2p256v1":  KeyAlgorithmSM2,
+}
+
+// ParseKeyAlgorithm 解析密钥算法名称，空字符串返回 DefaultKeyAlgorithm
+func ParseKeyAlgorithm(name string) (KeyAlgorithm, error) {
+	if name == "" {
+		return DefaultKeyAlgorithm, nil
+	}
+	algorithm, ok := keyAlgorithmNames[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedKeyAlgorithm, name)
+	}
+	return algorithm, nil
+}
+
+// GenerateKey 按算法生成私钥，空算法使用 DefaultKeyAlgorithm
+func GenerateKey(algorithm KeyAlgorithm) (crypto.Signer, error) {
+	switch algorithm {
+	case "", KeyAlgorithmP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyAlgorithmP384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case KeyAlgorithmEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	case KeyAlgorithmSM2:
+		return smcrypto.GenerateSM2Key()
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedKeyAlgorithm, algorithm)
+}
+
+// PublicKeyAlgorithm 返回公钥对应的密钥算法
+func PublicKeyAlgorithm(publicKey crypto.PublicKey) (KeyAlgorithm, error) {
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return KeyAlgorithmP256, nil
+		case elliptic.P384():
+			return KeyAlgorithmP384, nil
+		}
+		return "", fmt.Errorf("%w: curve %s", ErrUnsupportedKeyAlgorithm, key.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return KeyAlgorithmEd25519, nil
+	case *gmecdsa.PublicKey:
+		if smcrypto.IsSM2PublicKey(key) {
+			return KeyAlgorithmSM2, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %T", ErrUnsupportedKeyAlgorithm, publicKey)
+}
+
+// CheckTLSCertificate 检查 PEM 证书能否用于 crypto/tls 握手，含 SM2 公钥或 SM2 签名的证书返回 ErrGMTLSUnsupported
+func CheckTLSCertificate(certPEM []byte) error {
+	found := false
+	for block, rest := pem.Decode(certPEM); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		found = true
+		cert, err := smcrypto.ParseCertificate(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("failed to parse certificate: %w", err)
+		}
+		if algorithm, _ := PublicKeyAlgorithm(cert.PublicKey); algorithm == KeyAlgorithmSM2 || smcrypto.IsSM2Signed(block.Bytes) {
+			return fmt.Errorf("%w: %s", ErrGMTLSUnsupported, cert.Subject)
+		}
+	}
+	if !found {
+		return fmt.Errorf("no certificate found in PEM data")
+	}
+	return nil
+}
+
+// KeyAlgorithm 返回CA当前密钥的算法
+func (ca *CA) KeyAlgorithm() KeyAlgorithm {
+	algorithm, _ := PublicKeyAlgorithm(ca.Certificate.PublicKey)
+	return algorithm
+}
+
+// checkKeyAlgorithm 检查算法是否受支持，SM2 CA 签发国密证书
+func checkKeyAlgorithm(algorithm KeyAlgorithm) error {
+	if _, err := ParseKeyAlgorithm(string(algorithm)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// signDigest 对摘要签名。Ed25519 与 SM2 不接受预先计算的摘要，直接把摘要作为消息签名，
+// 验证时 verifyDigestSignature 做相同处理
+func signDigest(signer crypto.Signer, digest []byte, hash crypto.Hash) ([]byte, error) {
+	switch algorithm, _ := PublicKeyAlgorithm(signer.Public()); algorithm {
+	case KeyAlgorithmEd25519, KeyAlgorithmSM2:
+		hash = crypto.Hash(0)
+	}
+	return signer.Sign(rand.Reader, digest, hash)
+}
+
+// verifyDigestSignature 用公钥验证对摘要的签名，ECDSA 与 SM2 签名为 ASN.1 编码
+func verifyDigestSignature(publicKey crypto.PublicKey, digest []byte, signature []byte) error {
+	switch pub := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, digest, signature) {
+			return fmt.Errorf("invalid ECDSA signature")
+		}
+		return nil
+	case ed25519.PublicKey:
+		if !ed25519.Verify(pub, digest, signature) {
+			return fmt.Errorf("invalid Ed25519 signature")
+		}
+		return nil
+	case *gmecdsa.PublicKey:
+		if !smcrypto.SM2VerifyASN1(pub, digest, signature) {
+			return fmt.Errorf("invalid SM2 signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported public key type %T", publicKey)
+}
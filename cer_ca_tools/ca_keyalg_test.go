@@ -0,0 +1,140 @@
+package cer_ca_tools
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestParseKeyAlgorithm(t *testing.T) {
+	for name, expected := range map[string]KeyAlgorithm{
+		"":          DefaultKeyAlgorithm,
+		"secp256r1": KeyAlgorithmP256,
+		"P384":      KeyAlgorithmP384,
+		"EdDSA":     KeyAlgorithmEd25519,
+		"sm2p256v1": KeyAlgorithmSM2,
+	} {
+		if have, err := ParseKeyAlgorithm(name); err != nil || have != expected {
+			t.Fatalf("ParseKeyAlgorithm(%q): expected %s, have %s %v", name, expected, have, err)
+		}
+	}
+	if _, err := ParseKeyAlgorithm("rsa"); !errors.Is(err, ErrUnsupportedKeyAlgorithm) {
+		t.Fatalf("rsa should be unsupported, have %v", err)
+	}
+
+	for _, algorithm := range []KeyAlgorithm{KeyAlgorithmP256, KeyAlgorithmP384, KeyAlgorithmEd25519, KeyAlgorithmSM2} {
+		key, err := GenerateKey(algorithm)
+		if err != nil {
+			t.Fatalf("generate %s key failed: %v", algorithm, err)
+		}
+		if have, err := PublicKeyAlgorithm(key.Public()); err != nil || have != algorithm {
+			t.Fatalf("expected %s public key, have %s %v", algorithm, have, err)
+		}
+		digest := []byte("0123456789abcdef0123456789abcdef")
+		signature, err := signDigest(key, digest, crypto.SHA256)
+		if err != nil {
+			t.Fatalf("%s sign failed: %v", algorithm, err)
+		}
+		if err := verifyDigestSignature(key.Public(), digest, signature); err != nil {
+			t.Fatalf("%s signature should verify: %v", algorithm, err)
+		}
+		digest[0] ^= 0xff
+		if err := verifyDigestSignature(key.Public(), digest, signature); err == nil {
+			t.Fatalf("%s signature over a modified digest should not verify", algorithm)
+		}
+	}
+}
+
+func TestKeyAlgorithmCA(t *testing.T) {
+	manager := NewCAManagerWithStore(NewMemoryStore())
+	if err := manager.EnableAuditLog(filepath.Join(t.TempDir(), "audit.log")); err != nil {
+		t.Fatalf("enable audit log failed: %v", err)
+	}
+
+	for _, algorithm := range []KeyAlgorithm{KeyAlgorithmP384, KeyAlgorithmEd25519} {
+		caName := "ca_" + string(algorithm) + "_test"
+		ca, err := manager.CreateCAWithAlgorithm(caName, algorithm)
+		if err != nil {
+			t.Fatalf("create %s CA failed: %v", algorithm, err)
+		}
+		if ca.KeyAlgorithm() != algorithm {
+			t.Fatalf("expected %s CA, have %s", algorithm, ca.KeyAlgorithm())
+		}
+
+		// Ed25519 证书主体通过CSR申请并用同一密钥续期
+		subjectSK, _ := GenerateKey(KeyAlgorithmEd25519)
+		csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{}, subjectSK)
+		if err != nil {
+			t.Fatalf("create Ed25519 CSR failed: %v", err)
+		}
+		response := ca.IssueCertificateFromCSR("", pkix.Name{CommonName: "ed25519 subject"}, mustParseCSR(t, csrDER))
+		if !response.Success {
+			t.Fatalf("%s CA issue failed: %s", algorithm, response.Message)
+		}
+		cert := parseTestCertificate(t, response.Certificate)
+		if err := cert.CheckSignatureFrom(ca.Certificate); err != nil {
+			t.Fatalf("certificate should be signed by the %s CA: %v", algorithm, err)
+		}
+		renewal := &RenewalRequest{Certificate: []byte(response.Certificate)}
+		if err := renewal.Sign(subjectSK); err != nil {
+			t.Fatalf("sign renewal failed: %v", err)
+		}
+		if renewed := manager.RenewCertificate("", renewal); !renewed.Success {
+			t.Fatalf("Ed25519 renewal failed: %s", renewed.Message)
+		}
+
+		// x/crypto/ocsp 不识别 Ed25519 签名算法，只检查响应内容
+		requestDER, _ := ocsp.CreateRequest(cert, ca.Certificate, nil)
+		status, err := ocsp.ParseResponse(manager.OCSP.Respond(requestDER), nil)
+		if err != nil || status.Status != ocsp.Good {
+			t.Fatalf("%s CA OCSP response should report good, have %v", algorithm, err)
+		}
+	}
+	if err := manager.VerifyAuditLog(); err != nil {
+		t.Fatalf("audit log should verify: %v", err)
+	}
+
+	intermediate, err := manager.CreateIntermediateCA("ca_ed25519_sub_test", "ca_Ed25519_test", 30)
+	if err != nil || intermediate.KeyAlgorithm() != KeyAlgorithmEd25519 {
+		t.Fatalf("intermediate CA should inherit the parent algorithm, have %v", err)
+	}
+}
+
+func TestEd25519JWS(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	body, err := SignACMERequest(key, "", "nonce", "https://ca.example/acme/new-account", []byte("{}"))
+	if err != nil {
+		t.Fatalf("sign ACME request failed: %v", err)
+	}
+	var jws acmeJWS
+	json.Unmarshal(body, &jws)
+	protected, _ := b64Decode(jws.Protected)
+	var header acmeJWSHeader
+	json.Unmarshal(protected, &header)
+	if header.Alg != "EdDSA" {
+		t.Fatalf("expected EdDSA, have %s", header.Alg)
+	}
+	var jwk jsonWebKey
+	json.Unmarshal(header.JWK, &jwk)
+	pub, err := jwk.publicKey()
+	if err != nil {
+		t.Fatalf("parse OKP JWK failed: %v", err)
+	}
+	signature, _ := b64Decode(jws.Signature)
+	signingInput := []byte(jws.Protected + "." + jws.Payload)
+	if err := verifyJWSSignature(pub, header.Alg, signingInput, signature); err != nil {
+		t.Fatalf("EdDSA JWS should verify: %v", err)
+	}
+	signingInput[0] ^= 0xff
+	if err := verifyJWSSignature(pub, header.Alg, signingInput, signature); err == nil {
+		t.Fatalf("modified EdDSA JWS should not verify")
+	}
+}
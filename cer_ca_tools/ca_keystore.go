@@ -0,0 +1,166 @@
+package cer_ca_tools
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/FISCO-BCOS/go-sdk/smcrypto"
+	"golang.org/x/crypto/scrypt"
+)
+
+// KeyPassphraseEnv 保存CA私钥口令的环境变量
+const KeyPassphraseEnv = "CA_KEY_PASSPHRASE"
+
+// 加密私钥的PEM类型，头部记录 scrypt 参数与 AES-GCM nonce
+const encryptedKeyPEMType = "ENCRYPTED CA PRIVATE KEY"
+
+// scrypt 默认参数（N=2^15, r=8, p=1），派生 AES-256 密钥
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+	scryptSalt   = 16
+)
+
+// ErrKeyPassphrase 私钥已加密但未提供口令，或口令错误
+var ErrKeyPassphrase = errors.New("CA private key passphrase missing or incorrect")
+
+// PassphraseFromEnv 从环境变量读取CA私钥口令，未设置时返回 nil
+func PassphraseFromEnv() []byte {
+	passphrase := os.Getenv(KeyPassphraseEnv)
+	if passphrase == "" {
+		return nil
+	}
+	return []byte(passphrase)
+}
+
+// EncryptPrivateKey 用 scrypt 派生的 AES-256-GCM 密钥加密 PKCS#8 私钥，返回PEM
+func EncryptPrivateKey(key crypto.Signer, passphrase []byte) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("empty passphrase")
+	}
+	keyDER, err := smcrypto.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal private key: %w", err)
+	}
+
+	salt := make([]byte, scryptSalt)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	aead, err := keystoreAEAD(passphrase, salt, scryptN, scryptR, scryptP)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	block := &pem.Block{
+		Type: encryptedKeyPEMType,
+		Headers: map[string]string{
+			"KDF":      "scrypt",
+			"Scrypt-N": strconv.Itoa(scryptN),
+			"Scrypt-R": strconv.Itoa(scryptR),
+			"Scrypt-P": strconv.Itoa(scryptP),
+			"Salt":     hex.EncodeToString(salt),
+			"Cipher":   "AES-256-GCM",
+			"Nonce":    hex.EncodeToString(nonce),
+		},
+	}
+	// PEM类型作为附加数据，防止密文被挪作他用
+	block.Bytes = aead.Seal(nil, nonce, keyDER, []byte(encryptedKeyPEMType))
+	return pem.EncodeToMemory(block), nil
+}
+
+// ParsePrivateKeyPEM 解析CA私钥PEM，加密私钥使用口令解密，兼容未加密的 PKCS#8 私钥
+func ParsePrivateKeyPEM(keyPEM []byte, passphrase []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode private key PEM")
+	}
+
+	keyDER := block.Bytes
+	if block.Type == encryptedKeyPEMType {
+		if len(passphrase) == 0 {
+			return nil, ErrKeyPassphrase
+		}
+		var err error
+		if keyDER, err = decryptKeyBlock(block, passphrase); err != nil {
+			return nil, err
+		}
+	}
+
+	key, err := smcrypto.ParsePKCS8PrivateKey(keyDER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("private key %T is not a signer", key)
+	}
+	return signer, nil
+}
+
+// MarshalPrivateKeyPEM 有口令时输出加密PEM，否则输出未加密 PKCS#8 PEM 并给出警告
+func MarshalPrivateKeyPEM(key crypto.Signer, passphrase []byte) ([]byte, error) {
+	if len(passphrase) > 0 {
+		return EncryptPrivateKey(key, passphrase)
+	}
+	log.Printf("warning: %s is not set, CA private key is stored unencrypted", KeyPassphraseEnv)
+	keyDER, err := smcrypto.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal private key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), nil
+}
+
+func decryptKeyBlock(block *pem.Block, passphrase []byte) ([]byte, error) {
+	if block.Headers["KDF"] != "scrypt" || block.Headers["Cipher"] != "AES-256-GCM" {
+		return nil, fmt.Errorf("unsupported key encryption %s/%s", block.Headers["KDF"], block.Headers["Cipher"])
+	}
+	n, errN := strconv.Atoi(block.Headers["Scrypt-N"])
+	r, errR := strconv.Atoi(block.Headers["Scrypt-R"])
+	p, errP := strconv.Atoi(block.Headers["Scrypt-P"])
+	salt, errSalt := hex.DecodeString(block.Headers["Salt"])
+	nonce, errNonce := hex.DecodeString(block.Headers["Nonce"])
+	if err := errors.Join(errN, errR, errP, errSalt, errNonce); err != nil {
+		return nil, fmt.Errorf("invalid encrypted key header: %w", err)
+	}
+
+	aead, err := keystoreAEAD(passphrase, salt, n, r, p)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid encrypted key nonce")
+	}
+	keyDER, err := aead.Open(nil, nonce, block.Bytes, []byte(block.Type))
+	if err != nil {
+		return nil, ErrKeyPassphrase
+	}
+	return keyDER, nil
+}
+
+func keystoreAEAD(passphrase, salt []byte, n, r, p int) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, n, r, p, scryptKeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,133 @@
+package cer_ca_tools
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEncryptedKeyStore(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	passphrase := []byte("correct horse battery staple")
+
+	keyPEM, err := EncryptPrivateKey(key, passphrase)
+	if err != nil {
+		t.Fatalf("encrypt key failed: %v", err)
+	}
+	if block, _ := pem.Decode(keyPEM); block == nil || block.Type != encryptedKeyPEMType {
+		t.Fatalf("unexpected PEM output")
+	}
+	restored, err := ParsePrivateKeyPEM(keyPEM, passphrase)
+	if err != nil {
+		t.Fatalf("decrypt key failed: %v", err)
+	}
+	if !key.Equal(restored) {
+		t.Fatalf("key changed after round trip")
+	}
+	if _, err := ParsePrivateKeyPEM(keyPEM, []byte("wrong")); !errors.Is(err, ErrKeyPassphrase) {
+		t.Fatalf("wrong passphrase should be rejected, have %v", err)
+	}
+	if _, err := ParsePrivateKeyPEM(keyPEM, nil); !errors.Is(err, ErrKeyPassphrase) {
+		t.Fatalf("missing passphrase should be rejected, have %v", err)
+	}
+
+	// 加密文件存储：重启后用同一口令恢复私钥
+	store := NewFileStore(t.TempDir())
+	store.Passphrase = passphrase
+	manager := NewCAManagerWithStore(store)
+	ca, err := manager.CreateCA("ca_keystore_test")
+	if err != nil {
+		t.Fatalf("create CA failed: %v", err)
+	}
+	keyFile, _ := os.ReadFile(filepath.Join(store.Dir, "ca", "ca_keystore_test.key"))
+	if block, _ := pem.Decode(keyFile); block == nil || block.Type != encryptedKeyPEMType {
+		t.Fatalf("CA key should be stored encrypted")
+	}
+	store.Passphrase = nil
+	if err := NewCAManagerWithStore(store).LoadState(); !errors.Is(err, ErrKeyPassphrase) {
+		t.Fatalf("loading without passphrase should fail, have %v", err)
+	}
+	store.Passphrase = passphrase
+	restarted := NewCAManagerWithStore(store)
+	if err := restarted.LoadState(); err != nil {
+		t.Fatalf("load state failed: %v", err)
+	}
+	restoredCA, _ := restarted.GetCAInfo("ca_keystore_test")
+	if restoredKey, ok := restoredCA.PrivateKey.(*ecdsa.PrivateKey); !ok || !restoredKey.Equal(ca.PrivateKey) {
+		t.Fatalf("CA key changed after restart")
+	}
+}
+
+func TestSigningDaemon(t *testing.T) {
+	dir := t.TempDir()
+	daemon, err := NewSigningDaemon(filepath.Join(dir, "keys"), []byte("daemon passphrase"))
+	if err != nil {
+		t.Fatalf("create signing daemon failed: %v", err)
+	}
+	socketPath := filepath.Join(dir, "signd.sock")
+	go daemon.ListenAndServe(socketPath)
+	defer daemon.Close()
+	for i := 0; i < 50; i++ {
+		if _, err := os.Stat(socketPath); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if info, err := os.Stat(socketPath); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("socket should exist with 0600 permissions: %v", err)
+	}
+
+	digest := sha256.Sum256([]byte("tbs"))
+	signer, err := GenerateRemoteKey(socketPath, "ca_signd_test")
+	if err != nil {
+		t.Fatalf("generate remote key failed: %v", err)
+	}
+	signature, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatalf("remote sign failed: %v", err)
+	}
+	if !ecdsa.VerifyASN1(signer.Public().(*ecdsa.PublicKey), digest[:], signature) {
+		t.Fatalf("remote signature does not verify")
+	}
+	if _, err := NewRemoteSigner(socketPath, "../escape"); err == nil {
+		t.Fatalf("key names outside the key directory should be rejected")
+	}
+
+	// CA 进程不持有私钥：证书由守护进程签名，文件存储中没有私钥文件
+	store := NewFileStore(filepath.Join(dir, "state"))
+	manager := NewCAManagerWithStore(store)
+	manager.UseSigningDaemon(socketPath)
+	ca, err := manager.CreateCA("ca_signd_manager")
+	if err != nil {
+		t.Fatalf("create CA failed: %v", err)
+	}
+	subjectSK, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	response := ca.IssueCertificate(ca.Name, &subjectSK.PublicKey)
+	if !response.Success {
+		t.Fatalf("issue through signing daemon failed: %s", response.Message)
+	}
+	cert := parseTestCertificate(t, response.Certificate)
+	if err := cert.CheckSignatureFrom(ca.Certificate); err != nil {
+		t.Fatalf("certificate not signed by CA key: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(store.Dir, "ca", "ca_signd_manager.key")); !os.IsNotExist(err) {
+		t.Fatalf("CA process should not persist the private key")
+	}
+
+	restarted := NewCAManagerWithStore(store)
+	restarted.UseSigningDaemon(socketPath)
+	if err := restarted.LoadState(); err != nil {
+		t.Fatalf("load state failed: %v", err)
+	}
+	if restoredCA, _ := restarted.GetCAInfo("ca_signd_manager"); !isRemoteSigner(restoredCA.PrivateKey) {
+		t.Fatalf("restored CA should sign through the daemon")
+	}
+}
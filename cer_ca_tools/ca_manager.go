@@ -2,35 +2,51 @@ package cer_ca_tools
 
 import (
 	"bytes"
-	"crypto/ecdsa"
-	"crypto/elliptic"
+	"crypto"
 	"crypto/rand"
 	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
-	"github.com/FISCO-BCOS/go-sdk/cer_subject_tools"
-	"io"
 	"log"
 	"math/big"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
 )
 
 type CA struct {
 	Name           pkix.Name                           `json:"name"`
-	PublicKey      *ecdsa.PublicKey                    `json:"public_key"`
-	PrivateKey     *ecdsa.PrivateKey                   `json:"private_key"`
+	PublicKey      crypto.PublicKey                    `json:"public_key"`
+	PrivateKey     crypto.Signer                       `json:"-"` // 本地私钥或签名守护进程的远程签名器
 	Certificate    *x509.Certificate                   `json:"certificate"`
 	CertificatePEM []byte                              `json:"certificate_pem"`
 	IssuedCerts    map[string]*x509.Certificate        `json:"issued_certs"`
 	RevokedCerts   map[string]*pkix.RevokedCertificate `json:"revoked_certs"`
 	Mutex          sync.Mutex                          `json:"-"`
+	issuing        sync.RWMutex                        // 签发时持有读锁，密钥轮换时持有写锁
+	renewal        sync.Mutex                          // 续期与撤销互斥：续期的检查、签发与撤销原证书在同一临界区内完成
+	Parent         *CA                                 `json:"-"` // 签发本CA的上级CA，根CA为 nil
+	BaseURL        string                              `json:"-"` // CA对外服务地址，用于CRL分发点
+	CRLConfig      CRLConfig                           `json:"-"`
+	FilterConfig   FilterConfig                        `json:"-"` // 撤销过滤器参数
+	CSRPolicy      CSRPolicy                           `json:"-"`
+	Profiles       CertProfiles                        `json:"-"` // CA可使用的证书模板
+	DefaultProfile string                              `json:"-"`
+	RetiredKeys    []*RetiredCAKey                     `json:"-"` // 密钥轮换后保留的旧CA密钥
+	store          CAStore
+	audit          *AuditLog
+	ct             *CTLog
+	crlState       CRLState
+	fullCRL        *cachedCRL
+	deltaCRL       *cachedCRL
+	filter         *revocationFilter
 }
 
 type CertificateRequest struct {
@@ -41,106 +57,279 @@ type CertificateRequest struct {
 }
 
 type CertificateResponse struct {
-	Success     bool   `json:"success"`
-	Message     string `json:"message"`
-	Certificate string `json:"certificate"`
-	Err         error  `json:"error,omitempty"`
+	Success     bool     `json:"success"`
+	Message     string   `json:"message"`
+	Certificate string   `json:"certificate"`
+	Chain       string   `json:"chain,omitempty"` // 叶子证书、中间CA直至根CA的PEM证书包
+	SCTs        [][]byte `json:"scts,omitempty"`  // 证书中嵌入的SCT（RFC 6962 TLS 编码）
+	Err         error    `json:"error,omitempty"`
 }
 
 type HTTPCertRevokeRequest struct {
-	SerialNumber string `json:"serial_number"`
-	Reason       int    `json:"reason"`
+	SerialNumber   string     `json:"serial_number"`
+	Reason         int        `json:"reason"`                    // RFC 5280 撤销原因，6 (certificateHold) 表示暂停
+	InvalidityDate *time.Time `json:"invalidity_date,omitempty"` // 可选的 invalidityDate
 }
 
 type CAManager struct {
-	CAs       map[string]*CA
-	PrimePool *PrimePool
-	mutex     sync.RWMutex
+	CAs        map[string]*CA
+	PrimePool  *PrimePool
+	Store      CAStore
+	BaseURL    string // CA HTTP服务对外地址
+	OCSP       *OCSPResponder
+	Audit      *AuditLog // 审计日志，为 nil 时不记录
+	CT         *CTLog    // 证书透明日志，为 nil 时签发的证书不嵌入SCT
+	Profiles   CertProfiles
+	caProfiles map[string]CAProfileConfig
+	// Enrollments 短期证书登记记录，按登记ID索引
+	Enrollments map[string]*Enrollment
+	// EnrollmentLifetime 登记记录的有效期，期间持有登记密钥的主体可持续重签短期证书
+	EnrollmentLifetime time.Duration
+	// KeyAlgorithm 新建CA的默认密钥算法，为空时使用 DefaultKeyAlgorithm
+	KeyAlgorithm KeyAlgorithm
+	// KeyGenerator 按算法为新CA生成私钥，默认在本进程生成，使用签名守护进程时由守护进程生成
+	KeyGenerator func(caName string, algorithm KeyAlgorithm) (crypto.Signer, error)
+	signdSocket  string // 签名守护进程 socket，为空时私钥保存在本进程
+	mutex        sync.RWMutex
 }
 
+const defaultBaseURL = "http://localhost:8080"
+
+var (
+	ErrCANotFound         = errors.New("CA not found")
+	ErrCAOffline          = errors.New("CA is offline, issue certificates from an intermediate CA")
+	ErrCertNotFound       = errors.New("certificate not found")
+	ErrCertAlreadyRevoked = errors.New("certificate is already revoked")
+)
+
+// NewCAManager 创建使用默认文件存储（当前目录下 certs）的CA管理器，私钥口令取自 CA_KEY_PASSPHRASE
 func NewCAManager() *CAManager {
-	return &CAManager{
+	currentDir, _ := os.Getwd()
+	store := NewFileStore(filepath.Join(currentDir, "certs"))
+	store.Passphrase = PassphraseFromEnv()
+	return NewCAManagerWithStore(store)
+}
+
+// NewCAManagerWithStore 创建使用指定存储的CA管理器
+func NewCAManagerWithStore(store CAStore) *CAManager {
+	manager := &CAManager{
 		CAs:       make(map[string]*CA),
 		PrimePool: NewPrimePool(),
-		mutex:     sync.RWMutex{},
+		Store:     store,
+		BaseURL:   defaultBaseURL,
+		Profiles:  DefaultCertProfiles(),
+
+		Enrollments:        make(map[string]*Enrollment),
+		EnrollmentLifetime: DefaultEnrollmentLifetime,
+		KeyAlgorithm:       DefaultKeyAlgorithm,
+		KeyGenerator: func(_ string, algorithm KeyAlgorithm) (crypto.Signer, error) {
+			return GenerateKey(algorithm)
+		},
+		mutex: sync.RWMutex{},
 	}
+	manager.OCSP = NewOCSPResponder(manager)
+	return manager
 }
 
-func CreateNewCA(caName string) (*CA, error) {
-	caSK, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+// LoadState 从存储中恢复CA、已签发证书、撤销记录、短期证书登记记录以及质数池
+func (manager *CAManager) LoadState() error {
+	cas, err := manager.Store.LoadCAs()
 	if err != nil {
-		return nil, fmt.Errorf("Error generating CA certificate: %s", err)
+		return fmt.Errorf("failed to load CAs: %w", err)
 	}
-
-	ca := pkix.Name{
-		CommonName:         caName,
-		Organization:       []string{"xidian"},
-		OrganizationalUnit: []string{"Acme Co"},
-		Country:            []string{"CN"},
-		Province:           []string{"xi'an"},
-		Locality:           nil,
+	primePool, err := manager.Store.LoadPrimePool()
+	if err != nil {
+		return fmt.Errorf("failed to load prime pool: %w", err)
 	}
-	//CA self-signature certificate
-	caCert, caCertDER, err := cer_subject_tools.GenerateCert(true, caSK, nil, &caSK.PublicKey, ca, ca, 365)
+	enrollments, err := manager.Store.LoadEnrollments()
 	if err != nil {
-		fmt.Println("failed to generate CA cert:", err)
+		return fmt.Errorf("failed to load enrollments: %w", err)
 	}
 
-	currentDir, _ := os.Getwd()
+	for _, ca := range cas {
+		if manager.signdSocket != "" {
+			attachRemoteSigner(ca, manager.signdSocket)
+		}
+		manager.AddCAToManager(ca)
+		log.Printf("CA %s restored with %d issued and %d revoked certificates",
+			ca.Name.CommonName, len(ca.IssuedCerts), len(ca.RevokedCerts))
+	}
 
-	certsDir := filepath.Join(currentDir, "certs", "ca")
+	manager.linkHierarchy()
 
-	caCertPath := filepath.Join(certsDir, caName+".crt")
-	caCertFile, err := os.Create(caCertPath)
-	if err != nil {
-		return nil, fmt.Errorf("Error creating CA certificate file: %s", err)
+	manager.mutex.Lock()
+	manager.PrimePool = primePool
+	for _, enrollment := range enrollments {
+		manager.Enrollments[enrollment.ID] = enrollment
 	}
-	defer caCertFile.Close()
+	manager.mutex.Unlock()
+	return nil
+}
 
-	err = pem.Encode(caCertFile, &pem.Block{
-		Type:  "CERTIFICATE",
-		Bytes: caCertDER,
-	})
+// LoadProfiles 从配置文件加载证书模板，并按配置限定每个CA可用的模板
+func (manager *CAManager) LoadProfiles(path string) error {
+	config, err := LoadProfileConfig(path)
 	if err != nil {
-		return nil, fmt.Errorf("Error encoding CA certificate: %s", err)
+		return err
+	}
+	manager.SetProfileConfig(config)
+	return nil
+}
+
+// SetProfileConfig 替换证书模板配置，已注册的CA立即生效
+func (manager *CAManager) SetProfileConfig(config *ProfileConfig) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	manager.Profiles = config.ProfileSet()
+	manager.caProfiles = config.CAs
+	for _, ca := range manager.CAs {
+		manager.applyProfiles(ca)
+	}
+}
+
+// applyProfiles 为CA设置可用模板，调用方需持有 manager.mutex
+func (manager *CAManager) applyProfiles(ca *CA) {
+	ca.Profiles = manager.Profiles
+	ca.DefaultProfile = ProfileAnonClient
+
+	caConfig, exists := manager.caProfiles[ca.Name.CommonName]
+	if !exists {
+		return
 	}
+	if len(caConfig.Profiles) > 0 {
+		ca.Profiles = make(CertProfiles, len(caConfig.Profiles))
+		for _, name := range caConfig.Profiles {
+			ca.Profiles[name] = manager.Profiles[name]
+		}
+	}
+	if caConfig.DefaultProfile != "" {
+		ca.DefaultProfile = caConfig.DefaultProfile
+	}
+}
+
+// CreateCA 按 root-ca 模板以管理器的默认密钥算法生成新的CA密钥和自签名证书，持久化后加入管理器
+func (manager *CAManager) CreateCA(caName string) (*CA, error) {
+	return manager.CreateCAWithAlgorithm(caName, manager.KeyAlgorithm)
+}
 
-	caKeyPath := filepath.Join(certsDir, caName+".key")
-	caKeyFile, err := os.Create(caKeyPath)
+// CreateCAWithAlgorithm 同 CreateCA，CA密钥使用指定算法
+func (manager *CAManager) CreateCAWithAlgorithm(caName string, algorithm KeyAlgorithm) (*CA, error) {
+	if _, exists := manager.GetCAInfo(caName); exists {
+		return nil, fmt.Errorf("CA %s already exists", caName)
+	}
+	if err := checkKeyAlgorithm(algorithm); err != nil {
+		return nil, fmt.Errorf("failed to create CA %s: %w", caName, err)
+	}
+
+	manager.mutex.RLock()
+	profile, err := manager.Profiles.Get(ProfileRootCA)
+	manager.mutex.RUnlock()
 	if err != nil {
-		return nil, fmt.Errorf("Error creating CA key file: %s", err)
+		return nil, err
+	}
+	caSK, err := manager.KeyGenerator(caName, algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key for CA %s: %w", caName, err)
+	}
+	ca, err := createRootCA(caName, profile, caSK)
+	if err != nil {
+		return nil, err
+	}
+	manager.mutex.RLock()
+	ca.audit = manager.Audit
+	manager.mutex.RUnlock()
+	if err := ca.recordAudit(AuditEventCACreate, ca.Certificate.SerialNumber.String(), map[string]string{"type": "root"}); err != nil {
+		return nil, fmt.Errorf("failed to record creation of CA %s in audit log: %w", caName, err)
+	}
+	if err := manager.Store.SaveCA(ca); err != nil {
+		return nil, fmt.Errorf("failed to save CA %s: %w", caName, err)
 	}
-	defer caKeyFile.Close()
 
-	caPKDER, err := x509.MarshalPKCS8PrivateKey(caSK)
+	manager.AddCAToManager(ca)
+	return ca, nil
+}
+
+// SavePrimePool 持久化当前质数池
+func (manager *CAManager) SavePrimePool() error {
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+
+	return manager.Store.SavePrimePool(manager.PrimePool)
+}
+
+// CreateNewCA 按内置 root-ca 模板在内存中生成 P-256 CA密钥和自签名证书，不做持久化
+func CreateNewCA(caName string) (*CA, error) {
+	return CreateNewCAWithAlgorithm(caName, DefaultKeyAlgorithm)
+}
+
+// CreateNewCAWithAlgorithm 同 CreateNewCA，CA密钥使用指定算法
+func CreateNewCAWithAlgorithm(caName string, algorithm KeyAlgorithm) (*CA, error) {
+	if err := checkKeyAlgorithm(algorithm); err != nil {
+		return nil, err
+	}
+	caSK, err := GenerateKey(algorithm)
 	if err != nil {
-		return nil, fmt.Errorf("Error marshalling CA private key: %s", err)
+		return nil, fmt.Errorf("Error generating CA certificate: %s", err)
 	}
+	return createRootCA(caName, DefaultCertProfiles()[ProfileRootCA], caSK)
+}
 
-	err = pem.Encode(caKeyFile, &pem.Block{
-		Type:  "PRIVATE KEY",
-		Bytes: caPKDER,
-	})
+func createRootCA(caName string, profile *CertProfile, caSK crypto.Signer) (*CA, error) {
+	ca := pkix.Name{
+		CommonName:         caName,
+		Organization:       []string{"xidian"},
+		OrganizationalUnit: []string{"Acme Co"},
+		Country:            []string{"CN"},
+		Province:           []string{"xi'an"},
+		Locality:           nil,
+	}
+	template, err := profile.NewTemplate(&IssuanceRequest{Subject: ca, PublicKey: caSK.Public()}, time.Now())
 	if err != nil {
-		return nil, fmt.Errorf("Error encoding CA private key: %s", err)
+		return nil, fmt.Errorf("Error generating CA certificate: %s", err)
+	}
+	//CA self-signature certificate
+	caCertDER, err := CreateCertificate(template, template, caSK.Public(), caSK)
+	if err != nil {
+		return nil, fmt.Errorf("Error generating CA certificate: %s", err)
 	}
+	caCert, err := ParseCertificate(caCertDER)
+	if err != nil {
+		return nil, fmt.Errorf("Error generating CA certificate: %s", err)
+	}
+
+	return newCA(caCert, caSK), nil
+}
 
+func newCA(caCert *x509.Certificate, caSK crypto.Signer) *CA {
 	return &CA{
-		Name:           ca,
-		PublicKey:      &caSK.PublicKey,
+		Name:           caCert.Subject,
+		PublicKey:      caCert.PublicKey,
 		PrivateKey:     caSK,
 		Certificate:    caCert,
-		CertificatePEM: caCertDER,
+		CertificatePEM: caCert.Raw,
 		IssuedCerts:    make(map[string]*x509.Certificate),
 		RevokedCerts:   make(map[string]*pkix.RevokedCertificate),
 		Mutex:          sync.Mutex{},
-	}, nil
+		CRLConfig:      DefaultCRLConfig(),
+		FilterConfig:   DefaultFilterConfig(),
+		CSRPolicy:      DefaultCSRPolicy(),
+		Profiles:       DefaultCertProfiles(),
+		DefaultProfile: ProfileAnonClient,
+	}
 }
 
 func (manager *CAManager) AddCAToManager(ca *CA) {
 	manager.mutex.Lock()
 	defer manager.mutex.Unlock()
 
+	ca.store = manager.Store
+	ca.audit = manager.Audit
+	ca.ct = manager.CT
+	manager.applyProfiles(ca)
+	if ca.BaseURL == "" {
+		ca.BaseURL = manager.BaseURL
+	}
 	manager.CAs[ca.Name.CommonName] = ca
 
 	log.Println("Added CA to manager", ca.Name)
@@ -154,168 +343,373 @@ func (manager *CAManager) GetCAInfo(caName string) (*CA, bool) {
 	return ca, exists
 }
 
+// SetupHTTPHandlers 在 http.DefaultServeMux 上注册 Handler，兼容旧的启动方式；新代码应使用 Handler 或 NewServer
 func (manager *CAManager) SetupHTTPHandlers() {
-	http.HandleFunc("/certificate/issue", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != "POST" {
-			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
-			return
-		}
+	handler := manager.Handler()
+	for _, prefix := range legacyAPIPrefixes {
+		http.Handle(prefix, handler)
+	}
+}
 
-		startRequestCertToCA := time.Now()
+// Handler 返回CA的HTTP接口，接口位于 /v1 下，未带版本前缀的旧路径作为别名保留，
+// 以兼容已签发证书中的CRL/OCSP地址与旧客户端。错误统一以 APIErrorResponse 返回
+func (manager *CAManager) Handler() http.Handler {
+	mux := http.NewServeMux()
+	handle := func(path string, handler http.Handler) {
+		mux.Handle(APIVersionPrefix+path, http.StripPrefix(APIVersionPrefix, handler))
+		mux.Handle(path, handler)
+	}
+	handleFunc := func(path string, handler http.HandlerFunc) {
+		handle(path, handler)
+	}
 
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusNotFound, APIErrorNotFound, fmt.Sprintf("no such endpoint %s", r.URL.Path), nil)
+	})
+
+	handleFunc("/certificate/issue", func(w http.ResponseWriter, r *http.Request) {
+		if !requireMethod(w, r, http.MethodPost) {
 			return
 		}
 
-		// 解析带有XOR结果的证书请求
+		// 解析带有XOR结果的证书请求，csr 为PEM或DER编码的PKCS#10请求
 		var anonCertRequest struct {
-			SubjectInfo        pkix.Name  `json:"subject"`
-			PublicKeyAlgorithm int        `json:"public_key_algorithm"`
-			PublicKeyBytes     []byte     `json:"public_key_bytes"`
-			SignatureAlgorithm int        `json:"signature_algorithm"`
-			XORResult          []byte     `json:"xor_result"`
-			Remainders         []*big.Int `json:"remainders"`
+			SubjectInfo pkix.Name  `json:"subject"`
+			CSR         []byte     `json:"csr"`
+			XORResult   []byte     `json:"xor_result"`
+			Remainders  []*big.Int `json:"remainders"`
+		}
+		if !decodeJSONBody(w, r, &anonCertRequest) {
+			return
 		}
 
-		if err := json.Unmarshal(body, &anonCertRequest); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		ca, ok := manager.requireCA(w, r)
+		if !ok {
 			return
 		}
 
-		caName := r.URL.Query().Get("caName")
-		if caName == "" {
-			http.Error(w, "caName is required", http.StatusBadRequest)
+		// profile 参数可选，默认使用CA的默认证书模板
+		response := manager.issueBatchItem(ca, r.URL.Query().Get("profile"), &BatchIssueItem{
+			SubjectInfo: anonCertRequest.SubjectInfo,
+			CSR:         anonCertRequest.CSR,
+			XORResult:   anonCertRequest.XORResult,
+			Remainders:  anonCertRequest.Remainders,
+		})
+
+		writeCertificateResponse(w, response)
+	})
+
+	// 批量签发：并发校验每一项的 XOR/CRT 材料并签发，逐项返回结果
+	handleFunc("/certificate/issue/batch", func(w http.ResponseWriter, r *http.Request) {
+		if !requireMethod(w, r, http.MethodPost) {
 			return
 		}
 
-		ca, exists := manager.CAs[caName]
-		if !exists {
-			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		var batch BatchIssueRequest
+		if !decodeJSONBody(w, r, &batch) {
+			return
+		}
+		ca, ok := manager.requireCA(w, r)
+		if !ok {
 			return
 		}
 
-		subjectPK, err := x509.ParsePKIXPublicKey(anonCertRequest.PublicKeyBytes)
+		results, err := manager.IssueBatchContext(r.Context(), ca.Name.CommonName, r.URL.Query().Get("profile"), batch.Items)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeErrorResponse(w, err)
+			return
+		}
+
+		failed := 0
+		for _, result := range results {
+			if !result.Success {
+				failed++
+			}
+		}
+		writeAPIJSON(w, http.StatusOK, BatchIssueResponse{
+			Success: failed == 0,
+			Message: fmt.Sprintf("%d issued, %d failed", len(results)-failed, failed),
+			Results: results,
+		})
+	})
+
+	// 撤销与暂停：reason 为 certificateHold (6) 时暂停，暂停的证书可以解除暂停或改为永久撤销
+	handleFunc("/certificate/revoke", func(w http.ResponseWriter, r *http.Request) {
+		request, ca, issuerCA, ok := manager.revocationTarget(w, r)
+		if !ok {
+			return
+		}
+		var options RevocationOptions
+		if request.InvalidityDate != nil {
+			options.InvalidityDate = *request.InvalidityDate
+		}
+		writeCertificateResponse(w, issuerCA.RevokeCertificateWithOptions(ca.Name.CommonName, request.SerialNumber, request.Reason, options))
+	})
+
+	handleFunc("/certificate/hold", func(w http.ResponseWriter, r *http.Request) {
+		request, ca, issuerCA, ok := manager.revocationTarget(w, r)
+		if !ok {
 			return
 		}
+		writeCertificateResponse(w, issuerCA.RevokeCertificate(ca.Name.CommonName, request.SerialNumber, ReasonCertificateHold))
+	})
 
-		ecdsaPublicKey, ok := subjectPK.(*ecdsa.PublicKey)
+	// 解除暂停：永久撤销的证书返回 409
+	handleFunc("/certificate/unhold", func(w http.ResponseWriter, r *http.Request) {
+		request, ca, issuerCA, ok := manager.revocationTarget(w, r)
 		if !ok {
-			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		writeCertificateResponse(w, issuerCA.ReleaseCertificateHold(ca.Name.CommonName, request.SerialNumber))
+	})
+
+	// 证书续期/换钥：由仍有效证书的私钥签名请求，沿用原匿名身份签发后继证书
+	handleFunc("/certificate/renew", func(w http.ResponseWriter, r *http.Request) {
+		if !requireMethod(w, r, http.MethodPost) {
+			return
+		}
+
+		var renewal RenewalRequest
+		if !decodeJSONBody(w, r, &renewal) {
 			return
 		}
 
-		if !manager.VerifyXORResult(anonCertRequest.XORResult, anonCertRequest.SubjectInfo, anonCertRequest.Remainders) {
-			http.Error(w, "主体信息异或逆运算验证失败", http.StatusInternalServerError)
+		writeCertificateResponse(w, manager.RenewCertificate(r.URL.Query().Get("profile"), &renewal))
+	})
+
+	// 短期证书登记：与 /certificate/issue 相同的匿名签发流程，签发短期证书并建立登记记录
+	handleFunc("/certificate/enroll", func(w http.ResponseWriter, r *http.Request) {
+		if !requireMethod(w, r, http.MethodPost) {
 			return
 		}
 
-		xorHash := sha256.Sum256(anonCertRequest.XORResult)
-		anonymousSubject := pkix.Name{
-			CommonName:         fmt.Sprintf("anonymous-%x", xorHash[:16]),
-			Organization:       []string{"Anonymous Organization"},
-			OrganizationalUnit: []string{"Anonymous Department"},
-			Country:            []string{"AN"},
-			Province:           []string{"Anonymous Province"},
-			Locality:           []string{"Anonymous Locality"},
+		var enrollRequest BatchIssueItem
+		if !decodeJSONBody(w, r, &enrollRequest) {
+			return
+		}
+		ca, ok := manager.requireCA(w, r)
+		if !ok {
+			return
 		}
 
-		response := ca.IssueCertificate(anonymousSubject, ecdsaPublicKey)
+		subject, csr, err := manager.verifyAnonymousRequest(&enrollRequest)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeErrorResponse(w, err)
 			return
 		}
+		writeEnrollmentResponse(w, manager.Enroll(ca.Name.CommonName, subject, csr))
+	})
 
-		endRequestCertToCA := time.Since(startRequestCertToCA)
-		fmt.Println("CA Issue Cert Time:", endRequestCertToCA)
+	// 短期证书重签：由登记私钥签名请求，登记撤销或过期时返回 403
+	handleFunc("/certificate/reissue", func(w http.ResponseWriter, r *http.Request) {
+		if !requireMethod(w, r, http.MethodPost) {
+			return
+		}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+		var reissue ReissueRequest
+		if !decodeJSONBody(w, r, &reissue) {
+			return
+		}
+
+		writeEnrollmentResponse(w, manager.Reissue(&reissue))
 	})
 
-	http.HandleFunc("/certificate/revoke", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != "POST" {
-			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	// 撤销登记：停止为该登记重签短期证书
+	handleFunc("/certificate/enrollment/revoke", func(w http.ResponseWriter, r *http.Request) {
+		if !requireMethod(w, r, http.MethodPost) {
+			return
+		}
+
+		var revokeRequest struct {
+			EnrollmentID string `json:"enrollment_id"`
+		}
+		if !decodeJSONBody(w, r, &revokeRequest) {
+			return
+		}
+
+		if err := manager.RevokeEnrollment(revokeRequest.EnrollmentID); err != nil {
+			writeErrorResponse(w, err)
+			return
+		}
+		writeAPIJSON(w, http.StatusOK, CertificateResponse{
+			Success: true,
+			Message: "enrollment revoked",
+		})
+	})
+
+	handleFunc("/certificate/crl", func(w http.ResponseWriter, r *http.Request) {
+		if !requireMethod(w, r, http.MethodGet) {
+			return
+		}
+		ca, ok := manager.requireCA(w, r)
+		if !ok {
 			return
 		}
 
-		body, err := io.ReadAll(r.Body)
+		delta := r.URL.Query().Get("delta") == "true"
+		crlDER, err := ca.CRLForKey(r.URL.Query().Get("key"), delta)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeErrorResponse(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/pkix-crl")
+		w.Write(crlDER)
+	})
+
+	// 撤销过滤器快照，ETag 为版本号
+	handleFunc("/certificate/filter", func(w http.ResponseWriter, r *http.Request) {
+		if !requireMethod(w, r, http.MethodGet) {
+			return
+		}
+		ca, ok := manager.requireCA(w, r)
+		if !ok {
 			return
 		}
 
-		var httpCertRevokeRequest HTTPCertRevokeRequest
-		if err := json.Unmarshal(body, &httpCertRevokeRequest); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		snapshot, version, err := ca.FilterSnapshot()
+		if err != nil {
+			writeErrorResponse(w, err)
 			return
 		}
+		writeFilterResponse(w, r, fmt.Sprintf("v%d", version), snapshot)
+	})
 
-		caName := r.URL.Query().Get("caName")
-		if caName == "" {
-			http.Error(w, "HTTP请求缺少CA名称", http.StatusBadRequest)
+	// 撤销过滤器增量：from 为依赖方当前版本，已是最新版本时返回 304
+	handleFunc("/certificate/filter/delta", func(w http.ResponseWriter, r *http.Request) {
+		if !requireMethod(w, r, http.MethodGet) {
 			return
 		}
-		issuerCA, _, err := manager.FindCertIssuer(httpCertRevokeRequest.SerialNumber)
+		from, err := strconv.ParseUint(r.URL.Query().Get("from"), 10, 64)
 		if err != nil {
-			fmt.Errorf("Error finding issuer CA: %s", err)
+			writeAPIError(w, http.StatusBadRequest, APIErrorBadRequest, "from must be a filter version", nil)
+			return
+		}
+		ca, ok := manager.requireCA(w, r)
+		if !ok {
+			return
 		}
 
-		response := issuerCA.RevokeCertificate(caName, httpCertRevokeRequest.SerialNumber, httpCertRevokeRequest.Reason)
+		delta, version, err := ca.FilterDelta(from)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeErrorResponse(w, err)
 			return
 		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+		if from == version {
+			w.Header().Set("ETag", fmt.Sprintf(`"v%d"`, version))
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		writeFilterResponse(w, r, fmt.Sprintf("v%d-v%d", from, version), delta)
 	})
 
-	// 添加模数请求处理
-	http.HandleFunc("/certificate/modulus/request", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+	// CA信任包：当前CA证书，以及密钥轮换过渡期内的旧CA证书与交叉证书
+	handleFunc("/certificate/ca/bundle", func(w http.ResponseWriter, r *http.Request) {
+		if !requireMethod(w, r, http.MethodGet) {
+			return
+		}
+		ca, ok := manager.requireCA(w, r)
+		if !ok {
 			return
 		}
 
-		caName := r.URL.Query().Get("caName")
-		if caName == "" {
-			http.Error(w, "缺少CA名称", http.StatusBadRequest)
+		w.Header().Set("Content-Type", "application/x-pem-file")
+		w.Write(ca.TrustBundlePEM(time.Now()))
+	})
+
+	// 审计日志导出与校验
+	handleFunc("/certificate/audit/export", func(w http.ResponseWriter, r *http.Request) {
+		if !requireMethod(w, r, http.MethodGet) {
+			return
+		}
+		manager.mutex.RLock()
+		auditLog := manager.Audit
+		manager.mutex.RUnlock()
+		if auditLog == nil {
+			writeAPIError(w, http.StatusNotFound, APIErrorNotFound, "audit log is not enabled", nil)
 			return
 		}
 
-		_, exists := manager.GetCAInfo(caName)
-		if !exists {
-			http.Error(w, "CA不存在", http.StatusNotFound)
+		w.Header().Set("Content-Type", "application/json")
+		if err := auditLog.Export(w); err != nil {
+			log.Printf("导出审计日志失败: %v", err)
+		}
+	})
+
+	handleFunc("/certificate/audit/verify", func(w http.ResponseWriter, r *http.Request) {
+		if !requireMethod(w, r, http.MethodGet) {
+			return
+		}
+		manager.mutex.RLock()
+		auditLog := manager.Audit
+		manager.mutex.RUnlock()
+		if auditLog == nil {
+			writeAPIError(w, http.StatusNotFound, APIErrorNotFound, "audit log is not enabled", nil)
 			return
 		}
 
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("读取请求体时出错: %s", err), http.StatusBadRequest)
+		sequence, head := auditLog.Head()
+		response := struct {
+			Success  bool   `json:"success"`
+			Message  string `json:"message"`
+			Sequence uint64 `json:"seq"`
+			Head     string `json:"head"`
+		}{Success: true, Message: "audit log verified", Sequence: sequence, Head: head}
+		if err := auditLog.Verify(manager.auditPublicKey); err != nil {
+			response.Success = false
+			response.Message = err.Error()
+		}
+
+		writeAPIJSON(w, http.StatusOK, response)
+	})
+
+	// 证书透明日志（RFC 6962 get-sth / get-sth-consistency / get-proof-by-hash / get-entries）
+	handleFunc("/ct/v1/", func(w http.ResponseWriter, r *http.Request) {
+		if manager.CT == nil {
+			writeAPIError(w, http.StatusNotFound, APIErrorNotFound, "CT log is not enabled", nil)
+			return
+		}
+		manager.CT.ServeHTTP(w, r)
+	})
+
+	// OCSP 为二进制协议，错误按 RFC 6960 以 OCSP 响应返回
+	handle("/certificate/ocsp", manager.OCSP)
+	handle("/certificate/ocsp/", manager.OCSP)
+
+	// 添加模数请求处理
+	handleFunc("/certificate/modulus/request", func(w http.ResponseWriter, r *http.Request) {
+		if !requireMethod(w, r, http.MethodPost) {
 			return
 		}
 
 		var modulusRequest struct {
 			SubjectID string `json:"subject_id"`
 		}
-
-		if err := json.Unmarshal(body, &modulusRequest); err != nil {
-			http.Error(w, fmt.Sprintf("解析请求体时出错: %s", err), http.StatusBadRequest)
+		if !decodeJSONBody(w, r, &modulusRequest) {
+			return
+		}
+		ca, ok := manager.requireCA(w, r)
+		if !ok {
 			return
 		}
 
 		// 从质数池中随机选择一个质数
 		prime, err := manager.PrimePool.GetRandomPrime()
 		if err != nil {
-			http.Error(w, fmt.Sprintf("获取质数时出错: %s", err), http.StatusInternalServerError)
+			writeErrorResponse(w, fmt.Errorf("failed to get prime: %w", err))
+			return
+		}
+
+		if err := ca.recordAudit(AuditEventModulus, "", map[string]string{
+			"subject_id": modulusRequest.SubjectID,
+			"modulus":    prime.String(),
+		}); err != nil {
+			writeErrorResponse(w, fmt.Errorf("failed to record modulus handout in audit log: %w", err))
 			return
 		}
 
 		// 返回模数
-		response := struct {
+		writeAPIJSON(w, http.StatusOK, struct {
 			Success bool     `json:"success"`
 			Message string   `json:"message"`
 			Modulus *big.Int `json:"modulus"`
@@ -323,36 +717,14 @@ func (manager *CAManager) SetupHTTPHandlers() {
 			Success: true,
 			Message: "成功获取模数",
 			Modulus: prime,
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+		})
 
-		log.Printf("CA %s 为主体 %s 提供了模数 %s", caName, modulusRequest.SubjectID, prime.String())
+		log.Printf("CA %s 为主体 %s 提供了模数 %s", ca.Name.CommonName, modulusRequest.SubjectID, prime.String())
 	})
 
 	// 添加XOR结果处理
-	http.HandleFunc("/certificate/modulus/xor", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
-			return
-		}
-
-		caName := r.URL.Query().Get("caName")
-		if caName == "" {
-			http.Error(w, "HTTP请求缺少CA名称", http.StatusBadRequest)
-			return
-		}
-
-		_, exists := manager.GetCAInfo(caName)
-		if !exists {
-			http.Error(w, "CA不存在", http.StatusNotFound)
-			return
-		}
-
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("读取请求体时出错: %s", err), http.StatusBadRequest)
+	handleFunc("/certificate/modulus/xor", func(w http.ResponseWriter, r *http.Request) {
+		if !requireMethod(w, r, http.MethodPost) {
 			return
 		}
 
@@ -361,9 +733,10 @@ func (manager *CAManager) SetupHTTPHandlers() {
 			XORResult  []byte   `json:"xor_result"`
 			Remainders [][]byte `json:"remainders"`
 		}
-
-		if err := json.Unmarshal(body, &xorRequest); err != nil {
-			http.Error(w, fmt.Sprintf("解析请求体时出错: %s", err), http.StatusBadRequest)
+		if !decodeJSONBody(w, r, &xorRequest) {
+			return
+		}
+		if _, ok := manager.requireCA(w, r); !ok {
 			return
 		}
 
@@ -373,60 +746,126 @@ func (manager *CAManager) SetupHTTPHandlers() {
 			xorRequest.SubjectID, len(xorRequest.XORResult), len(xorRequest.Remainders))
 
 		// 返回成功响应
-		response := struct {
+		writeAPIJSON(w, http.StatusOK, struct {
 			Success bool   `json:"success"`
 			Message string `json:"message"`
 		}{
 			Success: true,
 			Message: "成功接收XOR结果",
-		}
+		})
+	})
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+	return mux
+}
+
+// IssueCertificate 使用CA的默认证书模板为公钥签发证书
+func (ca *CA) IssueCertificate(subject pkix.Name, subjectPublicKey crypto.PublicKey) CertificateResponse {
+	return ca.Issue("", &IssuanceRequest{
+		Subject:   subject,
+		PublicKey: subjectPublicKey,
 	})
 }
 
-func (ca *CA) IssueCertificate(subject pkix.Name, subjectPublicKey *ecdsa.PublicKey) CertificateResponse {
-	ca.Mutex.Lock()
-	defer ca.Mutex.Unlock()
+// IssueCertificateFromCSR 校验CSR（签名即私钥持有证明、公钥算法与扩展）后按模板签发证书，主体由调用方指定
+func (ca *CA) IssueCertificateFromCSR(profileName string, subject pkix.Name, csr *x509.CertificateRequest) CertificateResponse {
+	request, err := ca.CSRPolicy.ValidateCSR(csr, subject)
+	if err != nil {
+		log.Printf("CA %s rejected CSR: %v", ca.Name.CommonName, err)
+		return CertificateResponse{
+			Success: false,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+	return ca.Issue(profileName, request)
+}
+
+// Profile 返回CA可用的证书模板，名称为空时使用默认模板
+func (ca *CA) Profile(name string) (*CertProfile, error) {
+	if name == "" {
+		name = ca.DefaultProfile
+	}
+	return ca.Profiles.Get(name)
+}
+
+// Issue 按命名证书模板签发终端证书
+//
+// 签名期间只持有 issuing 读锁，同一CA的多个请求可以并发签名；ca.Mutex 只保护已签发证书索引。
+// 密钥轮换持有 issuing 写锁，等待进行中的签发完成后再替换CA密钥。
+func (ca *CA) Issue(profileName string, request *IssuanceRequest) CertificateResponse {
+	profile, err := ca.Profile(profileName)
+	if err == nil && profile.IsCA {
+		err = fmt.Errorf("%w: %s is a CA profile", ErrProfileNotFound, profile.Name)
+	}
+	if err != nil {
+		return CertificateResponse{
+			Success: false,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
 
-	log.Printf("CA %s is issuing certificate", ca.Name.CommonName)
+	ca.issuing.RLock()
+	defer ca.issuing.RUnlock()
 
-	//生成随机序列号
-	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 160)
-	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
+	log.Printf("CA %s is issuing certificate with profile %s", ca.Name.CommonName, profile.Name)
 
-	// 创建服务器证书模板
-	serverTemplate := x509.Certificate{
-		SerialNumber:          serialNumber,
-		Subject:               subject,
-		NotBefore:             time.Now(),
-		NotAfter:              time.Now().Add(365 * 24 * time.Hour), // 1年
-		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
-		BasicConstraintsValid: true,
-		IsCA:                  false,
+	if ca.IsOffline() {
+		return CertificateResponse{
+			Success: false,
+			Message: ErrCAOffline.Error(),
+			Err:     ErrCAOffline,
+		}
 	}
 
-	subjectCertDER, err := x509.CreateCertificate(rand.Reader, &serverTemplate, ca.Certificate, subjectPublicKey, ca.PrivateKey)
+	serverTemplate, err := profile.NewTemplate(request, time.Now())
 	if err != nil {
-		log.Printf("生成服务器证书失败: %v", err)
+		log.Printf("CA %s rejected request: %v", ca.Name.CommonName, err)
+		return CertificateResponse{
+			Success: false,
+			Message: err.Error(),
+			Err:     err,
+		}
 	}
+	// 证书有效期不超过CA证书
+	if serverTemplate.NotAfter.After(ca.Certificate.NotAfter) {
+		serverTemplate.NotAfter = ca.Certificate.NotAfter
+	}
+	serverTemplate.CRLDistributionPoints = []string{ca.CRLURL()}
+	serverTemplate.OCSPServer = []string{ca.OCSPURL()}
+	serialNumber := serverTemplate.SerialNumber
 
-	currentDir, _ := os.Getwd()
-	certsDir := filepath.Join(currentDir, "certs")
-	subjectCertPath := filepath.Join(certsDir, "subject.crt")
-	subjectCertFile, err := os.Create(subjectCertPath)
-	if err != nil {
-		log.Printf("创建服务器证书文件失败: %v", err)
+	var scts [][]byte
+	if ca.ct != nil {
+		sct, err := ca.logPrecertificate(serverTemplate, request.PublicKey)
+		if err != nil {
+			log.Printf("CA %s failed to log precertificate: %v", ca.Name.CommonName, err)
+			return CertificateResponse{
+				Success: false,
+				Message: "failed to log certificate to CT log",
+				Err:     err,
+			}
+		}
+		sctList, err := sctListExtension(sct)
+		if err != nil {
+			return CertificateResponse{
+				Success: false,
+				Message: "failed to encode SCT",
+				Err:     err,
+			}
+		}
+		serverTemplate.ExtraExtensions = append(serverTemplate.ExtraExtensions, sctList)
+		scts = append(scts, sct.Marshal())
 	}
-	defer subjectCertFile.Close()
-	err = pem.Encode(subjectCertFile, &pem.Block{
-		Type:  "CERTIFICATE",
-		Bytes: subjectCertDER,
-	})
+
+	subjectCertDER, err := CreateCertificate(serverTemplate, ca.Certificate, request.PublicKey, ca.PrivateKey)
 	if err != nil {
-		fmt.Errorf("Error encoding server certificate: %s", err)
+		log.Printf("生成服务器证书失败: %v", err)
+		return CertificateResponse{
+			Success: false,
+			Message: "failed to create certificate",
+			Err:     err,
+		}
 	}
 
 	certPEM := pem.EncodeToMemory(&pem.Block{
@@ -434,59 +873,49 @@ func (ca *CA) IssueCertificate(subject pkix.Name, subjectPublicKey *ecdsa.Public
 		Bytes: subjectCertDER,
 	})
 
-	subjectCert, err := x509.ParseCertificate(subjectCertDER)
+	subjectCert, err := ParseCertificate(subjectCertDER)
 	if err != nil {
-		log.Fatalf("解析证书失败: %v", err)
-	}
-
-	srtialStr := serialNumber.String()
-	ca.IssuedCerts[srtialStr] = subjectCert
-
-	log.Printf("issue certificate success for CA: %s, Serial: %s", ca.Name.CommonName, srtialStr)
-	return CertificateResponse{
-		Success:     true,
-		Message:     "certificate issued",
-		Certificate: string(certPEM),
-	}
-}
-
-func (ca *CA) RevokeCertificate(caName string, serialNumber string, reason int) CertificateResponse {
-	ca.Mutex.Lock()
-	defer ca.Mutex.Unlock()
-
-	cert, exists := ca.IssuedCerts[serialNumber]
-	if !exists {
+		log.Printf("解析证书失败: %v", err)
 		return CertificateResponse{
 			Success: false,
-			Message: "撤销失败，证书不存在",
+			Message: "failed to parse issued certificate",
+			Err:     err,
 		}
 	}
 
-	if _, revoked := ca.RevokedCerts[serialNumber]; revoked {
+	srtialStr := serialNumber.String()
+	if err := ca.recordAudit(AuditEventIssue, srtialStr, map[string]string{
+		"subject":   subjectCert.Subject.CommonName,
+		"profile":   profile.Name,
+		"not_after": subjectCert.NotAfter.UTC().Format(time.RFC3339),
+	}); err != nil {
 		return CertificateResponse{
 			Success: false,
-			Message: "撤销失败，证书已经被撤销",
+			Message: "failed to record issuance in audit log",
+			Err:     err,
 		}
 	}
-
-	revokedCert := pkix.RevokedCertificate{
-		SerialNumber:   cert.SerialNumber,
-		RevocationTime: time.Now(),
-		Extensions: []pkix.Extension{
-			{
-				Id:    []int{2, 5, 29, 21},
-				Value: []byte{byte(reason)},
-			},
-		},
+	if ca.store != nil {
+		if err := ca.store.SaveIssuedCert(ca.Name.CommonName, subjectCert); err != nil {
+			log.Printf("保存签发证书失败: %v", err)
+			return CertificateResponse{
+				Success: false,
+				Message: "failed to persist issued certificate",
+				Err:     err,
+			}
+		}
 	}
+	ca.Mutex.Lock()
+	ca.IssuedCerts[srtialStr] = subjectCert
+	ca.Mutex.Unlock()
 
-	ca.RevokedCerts[serialNumber] = &revokedCert
-
-	log.Printf(" revoked certificate for CA: %s, Serial: %s", caName, serialNumber)
-
+	log.Printf("issue certificate success for CA: %s, Serial: %s", ca.Name.CommonName, srtialStr)
 	return CertificateResponse{
-		Success: true,
-		Message: "证书撤销成功",
+		Success:     true,
+		Message:     "certificate issued",
+		Certificate: string(certPEM),
+		Chain:       ca.ChainPEM(subjectCert),
+		SCTs:        scts,
 	}
 }
 
@@ -495,31 +924,66 @@ func (manager *CAManager) FindCertIssuer(serialNumber string) (*CA, *x509.Certif
 	defer manager.mutex.RUnlock()
 
 	for _, ca := range manager.CAs {
-		if cert, exists := ca.IssuedCerts[serialNumber]; exists {
+		ca.Mutex.Lock()
+		cert, exists := ca.IssuedCerts[serialNumber]
+		ca.Mutex.Unlock()
+		if exists {
 			return ca, cert, nil
 		}
 	}
-	return nil, nil, fmt.Errorf("the certificate's (Serial: %s) CA is not found", serialNumber)
+	return nil, nil, fmt.Errorf("%w: the certificate's (Serial: %s) CA is not found", ErrCertNotFound, serialNumber)
+}
+
+// anonymousSubject 以随机假名生成匿名证书主体，用于批量签发。假名与主体身份、匿名化材料都无关，
+// 同一主体的多张证书之间也无法关联
+func anonymousSubject() (pkix.Name, error) {
+	pseudonym := make([]byte, 16)
+	if _, err := rand.Read(pseudonym); err != nil {
+		return pkix.Name{}, fmt.Errorf("failed to generate anonymous subject: %w", err)
+	}
+	return anonymousName(pseudonym), nil
+}
+
+// derivedAnonymousSubject 由匿名化材料的摘要生成匿名证书主体，ACME 以提交的XOR结果或账户指纹派生，
+// 同一材料得到同一假名
+func derivedAnonymousSubject(material []byte) pkix.Name {
+	digest := sha256.Sum256(material)
+	return anonymousName(digest[:16])
+}
+
+func anonymousName(pseudonym []byte) pkix.Name {
+	return pkix.Name{
+		CommonName:         fmt.Sprintf("anonymous-%x", pseudonym),
+		Organization:       []string{"Anonymous Organization"},
+		OrganizationalUnit: []string{"Anonymous Department"},
+		Country:            []string{"AN"},
+		Province:           []string{"Anonymous Province"},
+		Locality:           []string{"Anonymous Locality"},
+	}
 }
 
+// VerifyXORResult 以余数还原XOR结果并与主体信息比较，在副本上计算，不修改 xorResult。
+// XOR结果为空、没有余数或余数不为正时返回 false，可被批量签发并发调用
 func (manager *CAManager) VerifyXORResult(xorResult []byte, subjectInfo pkix.Name, remainders []*big.Int) bool {
+	if len(xorResult) == 0 || len(remainders) == 0 {
+		return false
+	}
+	for _, remainder := range remainders {
+		if remainder == nil || remainder.Sign() <= 0 {
+			return false
+		}
+	}
 	subjectInfoBytes, err := json.Marshal(subjectInfo)
 	if err != nil {
-		log.Fatalf("序列化主体信息时出错: %v", err)
+		return false
 	}
 
+	recovered := bytes.Clone(xorResult)
 	for _, remainder := range remainders {
 		remainderBytes := remainder.Bytes()
-
-		for i := 0; i < len(xorResult); i++ {
-			xorResult[i] = xorResult[i] ^ remainderBytes[i%len(remainderBytes)]
+		for i := range recovered {
+			recovered[i] ^= remainderBytes[i%len(remainderBytes)]
 		}
 	}
-	if bytes.Equal(xorResult, subjectInfoBytes) {
-		log.Printf("true")
-		return true
-	} else {
-		log.Printf("false")
-		return false
-	}
+	return bytes.Equal(recovered, subjectInfoBytes)
 }
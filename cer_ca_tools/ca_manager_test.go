@@ -0,0 +1,34 @@
+package cer_ca_tools
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"testing"
+)
+
+// issueTestCert 为与CA同算法的新密钥签发证书，供各测试共用
+func issueTestCert(t *testing.T, ca *CA, commonName string) *x509.Certificate {
+	subjectSK, err := GenerateKey(ca.KeyAlgorithm())
+	if err != nil {
+		t.Fatalf("generate %s key failed: %v", ca.KeyAlgorithm(), err)
+	}
+	response := ca.IssueCertificate(pkix.Name{CommonName: commonName}, subjectSK.Public())
+	if !response.Success {
+		t.Fatalf("issue certificate failed: %s", response.Message)
+	}
+	return parseTestCertificate(t, response.Certificate)
+}
+
+// parseTestCertificate 解析PEM证书，国密证书同样适用
+func parseTestCertificate(t *testing.T, certPEM string) *x509.Certificate {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		t.Fatalf("certificate is not PEM encoded")
+	}
+	cert, err := ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("parse certificate failed: %v", err)
+	}
+	return cert
+}
@@ -0,0 +1,575 @@
+package cer_ca_tools
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FISCO-BCOS/go-sdk/smcrypto"
+	"golang.org/x/crypto/ocsp"
+)
+
+var (
+	oidPKIXOCSPBasic    = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+	oidOCSPNonce        = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 2}
+	oidOCSPNoCheck      = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 5}
+	oidSHA1             = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256           = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384           = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512           = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+	oidECDSAWithSHA256  = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidECDSAWithSHA384  = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}
+	oidECDSAWithSHA512  = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}
+	oidSignatureEd25519 = asn1.ObjectIdentifier{1, 3, 101, 112}
+)
+
+// OCSPSigner OCSP响应签名者，可以是CA本身或CA签发的委托OCSP签名证书
+type OCSPSigner struct {
+	Certificate *x509.Certificate
+	PrivateKey  crypto.Signer
+}
+
+type cachedOCSPResponse struct {
+	der        []byte
+	status     int
+	reason     int
+	nextUpdate time.Time
+}
+
+// OCSPResponder 基于 CAManager 的 RFC 6960 OCSP 响应器
+type OCSPResponder struct {
+	manager  *CAManager
+	Validity time.Duration // nextUpdate 与 thisUpdate 的间隔
+	signers  map[string]*OCSPSigner
+	cache    map[string]*cachedOCSPResponse
+	mutex    sync.RWMutex
+}
+
+// NewOCSPResponder 创建OCSP响应器，默认使用CA密钥签名，响应有效期1小时
+func NewOCSPResponder(manager *CAManager) *OCSPResponder {
+	return &OCSPResponder{
+		manager:  manager,
+		Validity: time.Hour,
+		signers:  make(map[string]*OCSPSigner),
+		cache:    make(map[string]*cachedOCSPResponse),
+	}
+}
+
+// OCSPURL 返回该CA的OCSP服务地址
+func (ca *CA) OCSPURL() string {
+	return ca.BaseURL + APIVersionPrefix + "/certificate/ocsp"
+}
+
+// IssueOCSPSigner 按 ocsp-signer 模板由CA签发一张委托OCSP签名证书（带 id-pkix-ocsp-nocheck 扩展）
+func (ca *CA) IssueOCSPSigner(validity time.Duration) (*OCSPSigner, error) {
+	if ca.IsOffline() {
+		return nil, fmt.Errorf("CA %s is offline", ca.Name.CommonName)
+	}
+	// 国密CA的委托签名证书同样使用 SM2，其余CA使用 P-256
+	signerAlgorithm := KeyAlgorithmP256
+	if ca.KeyAlgorithm() == KeyAlgorithmSM2 {
+		signerAlgorithm = KeyAlgorithmSM2
+	}
+	signerSK, err := GenerateKey(signerAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate OCSP signer key: %w", err)
+	}
+
+	profile, err := ca.Profiles.Get(ProfileOCSPSigner)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template, err := profile.NewTemplate(&IssuanceRequest{
+		Subject: pkix.Name{
+			CommonName:   ca.Name.CommonName + " OCSP Signer",
+			Organization: ca.Name.Organization,
+		},
+		PublicKey: signerSK.Public(),
+	}, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build OCSP signer certificate: %w", err)
+	}
+	// validity 为 0 时使用模板的有效期
+	if validity > 0 {
+		template.NotAfter = now.Add(validity)
+	}
+
+	ca.Mutex.Lock()
+	signerDER, err := CreateCertificate(template, ca.Certificate, signerSK.Public(), ca.PrivateKey)
+	ca.Mutex.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OCSP signer certificate: %w", err)
+	}
+	signerCert, err := ParseCertificate(signerDER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OCSP signer certificate: %w", err)
+	}
+
+	return &OCSPSigner{
+		Certificate: signerCert,
+		PrivateKey:  signerSK,
+	}, nil
+}
+
+// SetDelegatedSigner 为CA配置委托OCSP签名证书，证书必须由该CA签发并带 OCSPSigning 扩展用途
+func (responder *OCSPResponder) SetDelegatedSigner(caName string, signer *OCSPSigner) error {
+	ca, exists := responder.manager.GetCAInfo(caName)
+	if !exists {
+		return fmt.Errorf("CA %s not found", caName)
+	}
+	if err := checkSignatureFrom(signer.Certificate, ca.Certificate); err != nil {
+		return fmt.Errorf("OCSP signer is not issued by CA %s: %w", caName, err)
+	}
+	hasOCSPSigning := false
+	for _, usage := range signer.Certificate.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageOCSPSigning {
+			hasOCSPSigning = true
+		}
+	}
+	if !hasOCSPSigning {
+		return fmt.Errorf("OCSP signer certificate lacks the OCSPSigning extended key usage")
+	}
+
+	responder.mutex.Lock()
+	defer responder.mutex.Unlock()
+
+	responder.signers[caName] = signer
+	responder.dropCacheLocked(caName)
+	return nil
+}
+
+// PreSign 为CA签发的全部证书预先签名OCSP响应并缓存
+func (responder *OCSPResponder) PreSign(caName string) error {
+	ca, exists := responder.manager.GetCAInfo(caName)
+	if !exists {
+		return fmt.Errorf("CA %s not found", caName)
+	}
+
+	ca.Mutex.Lock()
+	serials := make([]*big.Int, 0, len(ca.IssuedCerts))
+	for _, cert := range ca.IssuedCerts {
+		serials = append(serials, cert.SerialNumber)
+	}
+	ca.Mutex.Unlock()
+
+	for _, serial := range serials {
+		if _, err := responder.respond(ca, nil, serial, crypto.SHA1, nil); err != nil {
+			return err
+		}
+	}
+	log.Printf("OCSP responder pre-signed %d responses for CA %s", len(serials), caName)
+	return nil
+}
+
+// Respond 处理DER编码的OCSP请求并返回DER编码的OCSP响应
+func (responder *OCSPResponder) Respond(requestDER []byte) []byte {
+	request, err := ocsp.ParseRequest(requestDER)
+	if err != nil {
+		log.Printf("OCSP: malformed request: %v", err)
+		return ocsp.MalformedRequestErrorResponse
+	}
+	nonce, err := parseOCSPNonce(requestDER)
+	if err != nil {
+		log.Printf("OCSP: malformed nonce: %v", err)
+		return ocsp.MalformedRequestErrorResponse
+	}
+
+	ca, retired := responder.findIssuer(request)
+	if ca == nil {
+		return ocsp.UnauthorizedErrorResponse
+	}
+
+	response, err := responder.respond(ca, retired, request.SerialNumber, request.HashAlgorithm, nonce)
+	if err != nil {
+		log.Printf("OCSP: failed to sign response: %v", err)
+		return ocsp.InternalErrorErrorResponse
+	}
+	return response
+}
+
+// ServeHTTP 支持 RFC 6960 附录A 的 GET（base64路径）与 POST 请求
+func (responder *OCSPResponder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var requestDER []byte
+	var err error
+
+	switch r.Method {
+	case http.MethodPost:
+		if r.Header.Get("Content-Type") != "application/ocsp-request" {
+			http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+			return
+		}
+		requestDER, err = io.ReadAll(io.LimitReader(r.Body, 10*1024))
+	case http.MethodGet:
+		// r.URL.Path 已解码过一次，从原始路径解码，base64 中的 "+"、"/"、"=" 可以按百分号编码传输
+		encoded := strings.TrimPrefix(r.URL.EscapedPath(), "/certificate/ocsp")
+		encoded = strings.TrimPrefix(encoded, "/")
+		if encoded, err = url.PathUnescape(encoded); err == nil {
+			requestDER, err = base64.StdEncoding.DecodeString(encoded)
+		}
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	w.Write(responder.Respond(requestDER))
+}
+
+// findIssuer 按请求中的签发者名称与公钥摘要查找CA，请求指向仍在使用的旧密钥时同时返回该密钥
+func (responder *OCSPResponder) findIssuer(request *ocsp.Request) (*CA, *RetiredCAKey) {
+	responder.manager.mutex.RLock()
+	defer responder.manager.mutex.RUnlock()
+
+	matches := func(issuer *x509.Certificate) bool {
+		nameHash, keyHash, err := issuerHashes(issuer, request.HashAlgorithm)
+		return err == nil && bytes.Equal(nameHash, request.IssuerNameHash) && bytes.Equal(keyHash, request.IssuerKeyHash)
+	}
+	now := time.Now()
+	for _, ca := range responder.manager.CAs {
+		ca.Mutex.Lock()
+		issuer, retiredKeys := ca.Certificate, ca.RetiredKeys
+		ca.Mutex.Unlock()
+
+		if matches(issuer) {
+			return ca, nil
+		}
+		for _, retired := range retiredKeys {
+			if retired.Active(now) && matches(retired.Certificate) {
+				return ca, retired
+			}
+		}
+	}
+	return nil, nil
+}
+
+// respond 签名单个证书的OCSP响应，retired 不为 nil 时由该旧密钥签名
+func (responder *OCSPResponder) respond(ca *CA, retired *RetiredCAKey, serial *big.Int, hashAlgorithm crypto.Hash, nonce []byte) ([]byte, error) {
+	caName := ca.Name.CommonName
+	keyID := ""
+	if retired != nil {
+		keyID = retired.KeyID()
+	}
+	cacheKey := fmt.Sprintf("%s/%s/%d/%s", caName, serial, hashAlgorithm, keyID)
+
+	template := ocsp.Response{
+		Status:       ocsp.Unknown,
+		SerialNumber: serial,
+		IssuerHash:   hashAlgorithm,
+	}
+	ca.Mutex.Lock()
+	if _, issued := ca.IssuedCerts[serial.String()]; issued {
+		template.Status = ocsp.Good
+	}
+	// 暂停的证书同样返回 revoked，原因为 certificateHold (RFC 6960 2.2)
+	if revoked, exists := ca.revocation(serial.String()); exists {
+		entry := revocationListEntry(revoked)
+		template.Status = ocsp.Revoked
+		template.RevokedAt = entry.RevocationTime
+		template.RevocationReason = entry.ReasonCode
+	}
+	ca.Mutex.Unlock()
+
+	now := time.Now()
+	// 带 nonce 的请求必须实时签名，不能使用缓存
+	cacheable := nonce == nil
+	if cacheable {
+		responder.mutex.RLock()
+		cached, exists := responder.cache[cacheKey]
+		responder.mutex.RUnlock()
+		if exists && cached.status == template.Status && cached.reason == template.RevocationReason && now.Before(cached.nextUpdate) {
+			return cached.der, nil
+		}
+	}
+
+	template.ThisUpdate = now
+	template.NextUpdate = now.Add(responder.Validity)
+
+	ca.Mutex.Lock()
+	issuer := ca.Certificate
+	signer := &OCSPSigner{Certificate: ca.Certificate, PrivateKey: ca.PrivateKey}
+	ca.Mutex.Unlock()
+	delegated := false
+	if retired != nil {
+		// 委托签名证书只对当前密钥有效，旧密钥签发的证书由旧密钥自己签名
+		issuer = retired.Certificate
+		signer = &OCSPSigner{Certificate: retired.Certificate, PrivateKey: retired.PrivateKey}
+	} else {
+		responder.mutex.RLock()
+		delegatedSigner, exists := responder.signers[caName]
+		responder.mutex.RUnlock()
+		if exists {
+			signer, delegated = delegatedSigner, true
+		}
+	}
+	if signer.PrivateKey == nil {
+		return nil, fmt.Errorf("CA %s is offline and has no delegated OCSP signer", caName)
+	}
+
+	var extensions []pkix.Extension
+	if nonce != nil {
+		extensions = append(extensions, pkix.Extension{Id: oidOCSPNonce, Value: nonce})
+	}
+	responseDER, err := signOCSPResponse(issuer, signer, delegated, template, extensions)
+	if err != nil {
+		return nil, err
+	}
+
+	if cacheable {
+		responder.mutex.Lock()
+		responder.cache[cacheKey] = &cachedOCSPResponse{
+			der:        responseDER,
+			status:     template.Status,
+			reason:     template.RevocationReason,
+			nextUpdate: template.NextUpdate,
+		}
+		responder.mutex.Unlock()
+	}
+	return responseDER, nil
+}
+
+func (responder *OCSPResponder) dropCacheLocked(caName string) {
+	for key := range responder.cache {
+		if strings.HasPrefix(key, caName+"/") {
+			delete(responder.cache, key)
+		}
+	}
+}
+
+// 以下为 RFC 6960 4.2.1 响应结构，x/crypto/ocsp 不支持 responseExtensions，nonce 需要自行编码
+type ocspCertID struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	NameHash      []byte
+	IssuerKeyHash []byte
+	SerialNumber  *big.Int
+}
+
+type ocspRevokedInfo struct {
+	RevocationTime time.Time       `asn1:"generalized"`
+	Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspSingleResponse struct {
+	CertID     ocspCertID
+	Good       asn1.Flag       `asn1:"tag:0,optional"`
+	Revoked    ocspRevokedInfo `asn1:"tag:1,optional"`
+	Unknown    asn1.Flag       `asn1:"tag:2,optional"`
+	ThisUpdate time.Time       `asn1:"generalized"`
+	NextUpdate time.Time       `asn1:"generalized,explicit,tag:0,optional"`
+}
+
+type ocspResponseData struct {
+	RawResponderID     asn1.RawValue
+	ProducedAt         time.Time `asn1:"generalized"`
+	Responses          []ocspSingleResponse
+	ResponseExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type ocspBasicResponse struct {
+	TBSResponseData    ocspResponseData
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type ocspResponseASN1 struct {
+	Status   asn1.Enumerated
+	Response ocspResponseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspRequestASN1 struct {
+	TBSRequest struct {
+		Version           int           `asn1:"explicit,tag:0,default:0,optional"`
+		RequestorName     asn1.RawValue `asn1:"explicit,tag:1,optional"`
+		RequestList       []asn1.RawValue
+		RequestExtensions []pkix.Extension `asn1:"explicit,tag:2,optional"`
+	}
+	OptionalSignature asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+// parseOCSPNonce 取出请求扩展中的 nonce，没有时返回 nil
+func parseOCSPNonce(requestDER []byte) ([]byte, error) {
+	var request ocspRequestASN1
+	if _, err := asn1.Unmarshal(requestDER, &request); err != nil {
+		return nil, err
+	}
+	for _, ext := range request.TBSRequest.RequestExtensions {
+		if ext.Id.Equal(oidOCSPNonce) {
+			return ext.Value, nil
+		}
+	}
+	return nil, nil
+}
+
+func issuerHashes(issuer *x509.Certificate, hashAlgorithm crypto.Hash) ([]byte, []byte, error) {
+	var publicKeyInfo struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &publicKeyInfo); err != nil {
+		return nil, nil, err
+	}
+	if !hashAlgorithm.Available() {
+		return nil, nil, fmt.Errorf("hash algorithm %v is not available", hashAlgorithm)
+	}
+	h := hashAlgorithm.New()
+	h.Write(issuer.RawSubject)
+	nameHash := h.Sum(nil)
+	h.Reset()
+	h.Write(publicKeyInfo.PublicKey.RightAlign())
+	return nameHash, h.Sum(nil), nil
+}
+
+func hashAlgorithmOID(hashAlgorithm crypto.Hash) (asn1.ObjectIdentifier, error) {
+	switch hashAlgorithm {
+	case crypto.SHA1:
+		return oidSHA1, nil
+	case crypto.SHA256:
+		return oidSHA256, nil
+	case crypto.SHA384:
+		return oidSHA384, nil
+	case crypto.SHA512:
+		return oidSHA512, nil
+	}
+	return nil, fmt.Errorf("unsupported hash algorithm %v", hashAlgorithm)
+}
+
+// ocspSigningParams 根据签名者公钥选择摘要与签名算法
+// Ed25519 直接对 tbsResponseData 签名，摘要算法为 0
+func ocspSigningParams(pub crypto.PublicKey) (crypto.Hash, pkix.AlgorithmIdentifier, error) {
+	if _, ok := pub.(ed25519.PublicKey); ok {
+		return crypto.Hash(0), pkix.AlgorithmIdentifier{Algorithm: oidSignatureEd25519}, nil
+	}
+	// SM2 签名内部计算 SM3 摘要，对 tbsResponseData 直接签名
+	if smcrypto.IsSM2PublicKey(pub) {
+		return crypto.Hash(0), pkix.AlgorithmIdentifier{Algorithm: smcrypto.OIDSignatureSM2WithSM3}, nil
+	}
+	ecdsaPub, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return 0, pkix.AlgorithmIdentifier{}, fmt.Errorf("unsupported OCSP signer key type %T", pub)
+	}
+	switch ecdsaPub.Curve {
+	case elliptic.P384():
+		return crypto.SHA384, pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA384}, nil
+	case elliptic.P521():
+		return crypto.SHA512, pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA512}, nil
+	default:
+		return crypto.SHA256, pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256}, nil
+	}
+}
+
+func signOCSPResponse(issuer *x509.Certificate, signer *OCSPSigner, includeCert bool, template ocsp.Response, extensions []pkix.Extension) ([]byte, error) {
+	if template.IssuerHash == 0 {
+		template.IssuerHash = crypto.SHA1
+	}
+	hashOID, err := hashAlgorithmOID(template.IssuerHash)
+	if err != nil {
+		return nil, err
+	}
+	nameHash, keyHash, err := issuerHashes(issuer, template.IssuerHash)
+	if err != nil {
+		return nil, err
+	}
+
+	single := ocspSingleResponse{
+		CertID: ocspCertID{
+			HashAlgorithm: pkix.AlgorithmIdentifier{
+				Algorithm:  hashOID,
+				Parameters: asn1.RawValue{Tag: 5 /* ASN.1 NULL */},
+			},
+			NameHash:      nameHash,
+			IssuerKeyHash: keyHash,
+			SerialNumber:  template.SerialNumber,
+		},
+		ThisUpdate: template.ThisUpdate.UTC(),
+		NextUpdate: template.NextUpdate.UTC(),
+	}
+	switch template.Status {
+	case ocsp.Good:
+		single.Good = true
+	case ocsp.Revoked:
+		single.Revoked = ocspRevokedInfo{
+			RevocationTime: template.RevokedAt.UTC(),
+			Reason:         asn1.Enumerated(template.RevocationReason),
+		}
+	default:
+		single.Unknown = true
+	}
+
+	tbsResponseData := ocspResponseData{
+		RawResponderID: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        1, // byName
+			IsCompound: true,
+			Bytes:      signer.Certificate.RawSubject,
+		},
+		ProducedAt:         time.Now().Truncate(time.Second).UTC(),
+		Responses:          []ocspSingleResponse{single},
+		ResponseExtensions: extensions,
+	}
+	tbsDER, err := asn1.Marshal(tbsResponseData)
+	if err != nil {
+		return nil, err
+	}
+
+	hashFunc, signatureAlgorithm, err := ocspSigningParams(signer.PrivateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+	signed := tbsDER
+	if hashFunc != 0 {
+		digest := hashFunc.New()
+		digest.Write(tbsDER)
+		signed = digest.Sum(nil)
+	}
+	signature, err := signer.PrivateKey.Sign(rand.Reader, signed, hashFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	basic := ocspBasicResponse{
+		TBSResponseData:    tbsResponseData,
+		SignatureAlgorithm: signatureAlgorithm,
+		Signature:          asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)},
+	}
+	if includeCert {
+		basic.Certificates = []asn1.RawValue{{FullBytes: signer.Certificate.Raw}}
+	}
+	basicDER, err := asn1.Marshal(basic)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(ocspResponseASN1{
+		Status: asn1.Enumerated(ocsp.Success),
+		Response: ocspResponseBytes{
+			ResponseType: oidPKIXOCSPBasic,
+			Response:     basicDER,
+		},
+	})
+}
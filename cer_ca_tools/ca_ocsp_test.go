@@ -0,0 +1,108 @@
+package cer_ca_tools
+
+import (
+	"bytes"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestOCSPResponder(t *testing.T) {
+	manager := NewCAManagerWithStore(NewMemoryStore())
+	ca, err := manager.CreateCA("ca_ocsp_test")
+	if err != nil {
+		t.Fatalf("create CA failed: %v", err)
+	}
+	cert := issueTestCert(t, ca, "anonymous-test")
+	if len(cert.OCSPServer) != 1 || cert.OCSPServer[0] != ca.OCSPURL() {
+		t.Fatalf("unexpected OCSP server: %v", cert.OCSPServer)
+	}
+
+	requestDER, err := ocsp.CreateRequest(cert, ca.Certificate, nil)
+	if err != nil {
+		t.Fatalf("create OCSP request failed: %v", err)
+	}
+	response, err := ocsp.ParseResponseForCert(manager.OCSP.Respond(requestDER), cert, ca.Certificate)
+	if err != nil {
+		t.Fatalf("parse OCSP response failed: %v", err)
+	}
+	if response.Status != ocsp.Good {
+		t.Fatalf("expected good status, have %d", response.Status)
+	}
+
+	// RFC 6960 附录A 的 GET 请求，base64 中的保留字符按百分号编码或原样传输
+	encoded := base64.StdEncoding.EncodeToString(requestDER)
+	escaped := strings.NewReplacer("+", "%2B", "/", "%2F", "=", "%3D").Replace(encoded)
+	for _, path := range []string{encoded, escaped} {
+		recorder := httptest.NewRecorder()
+		manager.OCSP.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/certificate/ocsp/"+path, nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("GET %s failed: %d %s", path, recorder.Code, recorder.Body.String())
+		}
+		if response, err := ocsp.ParseResponseForCert(recorder.Body.Bytes(), cert, ca.Certificate); err != nil || response.Status != ocsp.Good {
+			t.Fatalf("GET %s should report good status, have %v", path, err)
+		}
+	}
+
+	ca.RevokeCertificate(ca.Name.CommonName, cert.SerialNumber.String(), 1)
+	response, err = ocsp.ParseResponseForCert(manager.OCSP.Respond(requestDER), cert, ca.Certificate)
+	if err != nil {
+		t.Fatalf("parse OCSP response failed: %v", err)
+	}
+	if response.Status != ocsp.Revoked || response.RevocationReason != ocsp.KeyCompromise {
+		t.Fatalf("expected revoked/keyCompromise, have %d/%d", response.Status, response.RevocationReason)
+	}
+
+	// 委托签名证书 + nonce
+	signer, err := ca.IssueOCSPSigner(24 * time.Hour)
+	if err != nil {
+		t.Fatalf("issue OCSP signer failed: %v", err)
+	}
+	if err := manager.OCSP.SetDelegatedSigner(ca.Name.CommonName, signer); err != nil {
+		t.Fatalf("set delegated signer failed: %v", err)
+	}
+
+	var request ocspRequestASN1
+	if _, err := asn1.Unmarshal(requestDER, &request); err != nil {
+		t.Fatalf("unmarshal OCSP request failed: %v", err)
+	}
+	nonce, _ := asn1.Marshal([]byte("0123456789abcdef"))
+	request.TBSRequest.RequestExtensions = []pkix.Extension{{Id: oidOCSPNonce, Value: nonce}}
+	nonceRequestDER, err := asn1.Marshal(request)
+	if err != nil {
+		t.Fatalf("marshal OCSP request failed: %v", err)
+	}
+
+	responseDER := manager.OCSP.Respond(nonceRequestDER)
+	response, err = ocsp.ParseResponseForCert(responseDER, cert, ca.Certificate)
+	if err != nil {
+		t.Fatalf("parse delegated OCSP response failed: %v", err)
+	}
+	if response.Certificate == nil || !bytes.Equal(response.Certificate.Raw, signer.Certificate.Raw) {
+		t.Fatalf("delegated response should carry the OCSP signer certificate")
+	}
+	var outer ocspResponseASN1
+	var basic ocspBasicResponse
+	if _, err := asn1.Unmarshal(responseDER, &outer); err != nil {
+		t.Fatalf("unmarshal OCSP response failed: %v", err)
+	}
+	if _, err := asn1.Unmarshal(outer.Response.Response, &basic); err != nil {
+		t.Fatalf("unmarshal basic OCSP response failed: %v", err)
+	}
+	echoed := false
+	for _, ext := range basic.TBSResponseData.ResponseExtensions {
+		if ext.Id.Equal(oidOCSPNonce) && bytes.Equal(ext.Value, nonce) {
+			echoed = true
+		}
+	}
+	if !echoed {
+		t.Fatalf("nonce not echoed in response extensions")
+	}
+}
@@ -0,0 +1,516 @@
+package cer_ca_tools
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 内置证书模板名称
+const (
+	ProfileAnonClient     = "anon-client"
+	ProfileShortLived     = "short-lived" // 短期匿名证书，由登记记录自动重签
+	ProfileVerifierServer = "verifier-server"
+	ProfileOCSPSigner     = "ocsp-signer"
+	ProfileRootCA         = "root-ca"
+	ProfileIntermediateCA = "intermediate-ca"
+)
+
+// 证书模板拒绝签发的原因
+var (
+	ErrProfileNotFound       = errors.New("certificate profile not found")
+	ErrProfileKey            = errors.New("public key not allowed by profile")
+	ErrProfileEKU            = errors.New("extended key usage not allowed by profile")
+	ErrProfileExtension      = errors.New("extension not allowed by profile")
+	ErrProfileNameConstraint = errors.New("subject alternative name violates profile name constraints")
+)
+
+var keyUsageNames = map[string]x509.KeyUsage{
+	"digitalSignature":  x509.KeyUsageDigitalSignature,
+	"contentCommitment": x509.KeyUsageContentCommitment,
+	"keyEncipherment":   x509.KeyUsageKeyEncipherment,
+	"dataEncipherment":  x509.KeyUsageDataEncipherment,
+	"keyAgreement":      x509.KeyUsageKeyAgreement,
+	"certSign":          x509.KeyUsageCertSign,
+	"crlSign":           x509.KeyUsageCRLSign,
+}
+
+var extKeyUsageNames = map[string]x509.ExtKeyUsage{
+	"any":             x509.ExtKeyUsageAny,
+	"serverAuth":      x509.ExtKeyUsageServerAuth,
+	"clientAuth":      x509.ExtKeyUsageClientAuth,
+	"codeSigning":     x509.ExtKeyUsageCodeSigning,
+	"emailProtection": x509.ExtKeyUsageEmailProtection,
+	"timeStamping":    x509.ExtKeyUsageTimeStamping,
+	"ocspSigning":     x509.ExtKeyUsageOCSPSigning,
+}
+
+// CertProfile 命名的证书模板，决定有效期、密钥用途、允许的曲线、扩展与名称约束
+type CertProfile struct {
+	Name                string                  `json:"name"`
+	Validity            string                  `json:"validity"`           // 有效期，time.ParseDuration 格式，如 "8760h"
+	Backdate            string                  `json:"backdate,omitempty"` // NotBefore 提前量，容忍时钟偏差
+	KeyUsage            []string                `json:"key_usage"`
+	ExtKeyUsage         []string                `json:"ext_key_usage,omitempty"`
+	AllowedCurves       []string                `json:"allowed_curves"` // 允许的密钥算法，如 "P-256"、"Ed25519"，取值见 KeyAlgorithm
+	IsCA                bool                    `json:"is_ca,omitempty"`
+	MaxPathLen          int                     `json:"max_path_len,omitempty"` // 仅CA模板有效，-1 表示不限制
+	MandatoryExtensions []ProfileExtension      `json:"mandatory_extensions,omitempty"`
+	OptionalExtensions  []string                `json:"optional_extensions,omitempty"` // 允许从CSR复制的扩展OID
+	NameConstraints     *ProfileNameConstraints `json:"name_constraints,omitempty"`
+
+	validity      time.Duration
+	backdate      time.Duration
+	keyUsage      x509.KeyUsage
+	extKeyUsage   []x509.ExtKeyUsage
+	mandatory     []pkix.Extension
+	keyAlgorithms []KeyAlgorithm
+	optional      []asn1.ObjectIdentifier
+	permittedIP   []*net.IPNet
+	excludedIP    []*net.IPNet
+}
+
+// ProfileExtension 模板中固定添加的扩展，Value 为DER编码（JSON中为base64）
+type ProfileExtension struct {
+	OID      string `json:"oid"`
+	Critical bool   `json:"critical,omitempty"`
+	Value    []byte `json:"value"`
+}
+
+// ProfileNameConstraints 名称约束；CA模板写入证书的 nameConstraints 扩展，
+// 终端证书模板用作SAN白名单：未被 Permitted* 列出的DNS、邮箱与IP一律拒绝，URI 始终拒绝
+type ProfileNameConstraints struct {
+	Critical                bool     `json:"critical,omitempty"`
+	PermittedDNSDomains     []string `json:"permitted_dns_domains,omitempty"`
+	ExcludedDNSDomains      []string `json:"excluded_dns_domains,omitempty"`
+	PermittedIPRanges       []string `json:"permitted_ip_ranges,omitempty"` // CIDR
+	ExcludedIPRanges        []string `json:"excluded_ip_ranges,omitempty"`
+	PermittedEmailAddresses []string `json:"permitted_email_addresses,omitempty"`
+	ExcludedEmailAddresses  []string `json:"excluded_email_addresses,omitempty"`
+}
+
+// IssuanceRequest 一次签发请求的主体、公钥以及请求的SAN与扩展
+type IssuanceRequest struct {
+	Subject        pkix.Name
+	PublicKey      crypto.PublicKey
+	DNSNames       []string
+	EmailAddresses []string
+	IPAddresses    []net.IP
+	URIs           []*url.URL
+	ExtKeyUsage    []x509.ExtKeyUsage // 为空时使用模板的 ExtKeyUsage
+	Extensions     []pkix.Extension   // CSR 中未由 x509 解析的扩展
+
+	dnsValidated bool // DNSNames 已通过 ACME 挑战验证，不受模板的DNS白名单限制
+}
+
+// CertProfiles 模板名称到模板的映射
+type CertProfiles map[string]*CertProfile
+
+// ProfileConfig 证书模板配置文件
+type ProfileConfig struct {
+	Profiles []*CertProfile             `json:"profiles"`
+	CAs      map[string]CAProfileConfig `json:"cas,omitempty"`
+}
+
+// CAProfileConfig 单个CA可使用的模板
+type CAProfileConfig struct {
+	DefaultProfile string   `json:"default_profile"`
+	Profiles       []string `json:"profiles"`
+}
+
+// DefaultCertProfiles 内置模板，与未提供配置文件时的签发行为一致
+func DefaultCertProfiles() CertProfiles {
+	profiles := []*CertProfile{
+		{
+			Name:          ProfileAnonClient,
+			Validity:      "8760h",
+			Backdate:      "5m",
+			KeyUsage:      []string{"digitalSignature", "keyEncipherment"},
+			ExtKeyUsage:   []string{"clientAuth", "serverAuth"},
+			AllowedCurves: []string{"P-256", "P-384", "Ed25519", "SM2"},
+		},
+		{
+			Name:          ProfileShortLived,
+			Validity:      "1h",
+			Backdate:      "5m",
+			KeyUsage:      []string{"digitalSignature", "keyEncipherment"},
+			ExtKeyUsage:   []string{"clientAuth", "serverAuth"},
+			AllowedCurves: []string{"P-256", "P-384", "Ed25519", "SM2"},
+		},
+		{
+			Name:          ProfileVerifierServer,
+			Validity:      "87600h",
+			Backdate:      "5m",
+			KeyUsage:      []string{"digitalSignature", "keyEncipherment"},
+			ExtKeyUsage:   []string{"serverAuth"},
+			AllowedCurves: []string{"P-256", "P-384", "Ed25519", "SM2"},
+			NameConstraints: &ProfileNameConstraints{
+				PermittedDNSDomains: []string{"localhost"},
+				PermittedIPRanges:   []string{"127.0.0.0/8", "::1/128"},
+			},
+		},
+		{
+			Name:          ProfileOCSPSigner,
+			Validity:      "720h",
+			Backdate:      "5m",
+			KeyUsage:      []string{"digitalSignature"},
+			ExtKeyUsage:   []string{"ocspSigning"},
+			AllowedCurves: []string{"P-256", "P-384", "Ed25519", "SM2"},
+			MandatoryExtensions: []ProfileExtension{
+				{OID: oidOCSPNoCheck.String(), Value: asn1.NullBytes},
+			},
+		},
+		{
+			Name:          ProfileRootCA,
+			Validity:      "87600h",
+			KeyUsage:      []string{"certSign", "crlSign", "digitalSignature"},
+			AllowedCurves: []string{"P-256", "P-384", "Ed25519", "SM2"},
+			IsCA:          true,
+			MaxPathLen:    2,
+		},
+		{
+			Name:          ProfileIntermediateCA,
+			Validity:      "43800h",
+			KeyUsage:      []string{"certSign", "crlSign", "digitalSignature"},
+			AllowedCurves: []string{"P-256", "P-384", "Ed25519", "SM2"},
+			IsCA:          true,
+			MaxPathLen:    0,
+		},
+	}
+
+	set := make(CertProfiles, len(profiles))
+	for _, profile := range profiles {
+		if err := profile.compile(); err != nil {
+			panic(fmt.Sprintf("invalid built-in profile %s: %v", profile.Name, err))
+		}
+		set[profile.Name] = profile
+	}
+	return set
+}
+
+// LoadProfileConfig 读取JSON格式的证书模板配置文件
+func LoadProfileConfig(path string) (*ProfileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profile config %s: %w", path, err)
+	}
+	return ParseProfileConfig(data)
+}
+
+// ParseProfileConfig 解析并校验证书模板配置
+func ParseProfileConfig(data []byte) (*ProfileConfig, error) {
+	var config ProfileConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse profile config: %w", err)
+	}
+
+	names := make(map[string]bool, len(config.Profiles))
+	for _, profile := range config.Profiles {
+		if profile.Name == "" {
+			return nil, fmt.Errorf("profile without name")
+		}
+		if names[profile.Name] {
+			return nil, fmt.Errorf("duplicate profile %s", profile.Name)
+		}
+		if err := profile.compile(); err != nil {
+			return nil, fmt.Errorf("profile %s: %w", profile.Name, err)
+		}
+		names[profile.Name] = true
+	}
+	for caName, caConfig := range config.CAs {
+		for _, name := range append([]string{caConfig.DefaultProfile}, caConfig.Profiles...) {
+			if name != "" && !names[name] {
+				return nil, fmt.Errorf("CA %s references unknown profile %s", caName, name)
+			}
+		}
+	}
+	return &config, nil
+}
+
+// ProfileSet 返回配置中全部模板
+func (config *ProfileConfig) ProfileSet() CertProfiles {
+	set := make(CertProfiles, len(config.Profiles))
+	for _, profile := range config.Profiles {
+		set[profile.Name] = profile
+	}
+	return set
+}
+
+// Get 按名称查找模板
+func (profiles CertProfiles) Get(name string) (*CertProfile, error) {
+	profile, exists := profiles[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+	}
+	return profile, nil
+}
+
+func (profile *CertProfile) compile() error {
+	var err error
+	if profile.validity, err = time.ParseDuration(profile.Validity); err != nil || profile.validity <= 0 {
+		return fmt.Errorf("invalid validity %q", profile.Validity)
+	}
+	if profile.Backdate != "" {
+		if profile.backdate, err = time.ParseDuration(profile.Backdate); err != nil || profile.backdate < 0 {
+			return fmt.Errorf("invalid backdate %q", profile.Backdate)
+		}
+	}
+
+	profile.keyUsage = 0
+	for _, name := range profile.KeyUsage {
+		usage, ok := keyUsageNames[name]
+		if !ok {
+			return fmt.Errorf("unknown key usage %q", name)
+		}
+		profile.keyUsage |= usage
+	}
+	profile.extKeyUsage = nil
+	for _, name := range profile.ExtKeyUsage {
+		usage, ok := extKeyUsageNames[name]
+		if !ok {
+			return fmt.Errorf("unknown extended key usage %q", name)
+		}
+		profile.extKeyUsage = append(profile.extKeyUsage, usage)
+	}
+	if profile.IsCA && profile.keyUsage&x509.KeyUsageCertSign == 0 {
+		return fmt.Errorf("CA profile must include certSign key usage")
+	}
+	if len(profile.AllowedCurves) == 0 {
+		return fmt.Errorf("no allowed curves")
+	}
+	profile.keyAlgorithms = nil
+	for _, name := range profile.AllowedCurves {
+		algorithm, err := ParseKeyAlgorithm(name)
+		if err != nil {
+			return err
+		}
+		profile.keyAlgorithms = append(profile.keyAlgorithms, algorithm)
+	}
+
+	profile.mandatory = nil
+	for _, ext := range profile.MandatoryExtensions {
+		oid, err := parseOID(ext.OID)
+		if err != nil {
+			return err
+		}
+		profile.mandatory = append(profile.mandatory, pkix.Extension{Id: oid, Critical: ext.Critical, Value: ext.Value})
+	}
+	profile.optional = nil
+	for _, text := range profile.OptionalExtensions {
+		oid, err := parseOID(text)
+		if err != nil {
+			return err
+		}
+		profile.optional = append(profile.optional, oid)
+	}
+
+	profile.permittedIP, profile.excludedIP = nil, nil
+	if constraints := profile.NameConstraints; constraints != nil {
+		if profile.permittedIP, err = parseCIDRs(constraints.PermittedIPRanges); err != nil {
+			return err
+		}
+		if profile.excludedIP, err = parseCIDRs(constraints.ExcludedIPRanges); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewTemplate 按模板与签发请求生成证书模板，检查公钥、扩展用途、扩展与名称约束
+func (profile *CertProfile) NewTemplate(request *IssuanceRequest, now time.Time) (*x509.Certificate, error) {
+	if err := profile.checkPublicKey(request.PublicKey); err != nil {
+		return nil, err
+	}
+
+	extKeyUsage := profile.extKeyUsage
+	if len(request.ExtKeyUsage) > 0 {
+		for _, usage := range request.ExtKeyUsage {
+			if !containsExtKeyUsage(profile.extKeyUsage, usage) {
+				return nil, fmt.Errorf("%w: %d requested for profile %s", ErrProfileEKU, usage, profile.Name)
+			}
+		}
+		extKeyUsage = request.ExtKeyUsage
+	}
+
+	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 160)
+	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               request.Subject,
+		NotBefore:             now.Add(-profile.backdate),
+		NotAfter:              now.Add(profile.validity),
+		KeyUsage:              profile.keyUsage,
+		ExtKeyUsage:           extKeyUsage,
+		BasicConstraintsValid: true,
+		IsCA:                  profile.IsCA,
+		DNSNames:              request.DNSNames,
+		EmailAddresses:        request.EmailAddresses,
+		IPAddresses:           request.IPAddresses,
+		URIs:                  request.URIs,
+	}
+	if profile.IsCA {
+		template.MaxPathLen = profile.MaxPathLen
+		template.MaxPathLenZero = profile.MaxPathLen == 0
+	}
+
+	if constraints := profile.NameConstraints; profile.IsCA && constraints != nil {
+		template.PermittedDNSDomainsCritical = constraints.Critical
+		template.PermittedDNSDomains = constraints.PermittedDNSDomains
+		template.ExcludedDNSDomains = constraints.ExcludedDNSDomains
+		template.PermittedIPRanges = profile.permittedIP
+		template.ExcludedIPRanges = profile.excludedIP
+		template.PermittedEmailAddresses = constraints.PermittedEmailAddresses
+		template.ExcludedEmailAddresses = constraints.ExcludedEmailAddresses
+	} else if !profile.IsCA {
+		if err := profile.checkNameConstraints(request); err != nil {
+			return nil, err
+		}
+	}
+
+	template.ExtraExtensions = append(template.ExtraExtensions, profile.mandatory...)
+	for _, ext := range request.Extensions {
+		switch {
+		case profile.allowsExtension(ext.Id):
+			template.ExtraExtensions = append(template.ExtraExtensions, ext)
+		case ext.Critical:
+			return nil, fmt.Errorf("%w: critical extension %s", ErrProfileExtension, ext.Id)
+		default:
+			log.Printf("profile %s: dropping requested extension %s", profile.Name, ext.Id)
+		}
+	}
+	return template, nil
+}
+
+func (profile *CertProfile) checkPublicKey(publicKey crypto.PublicKey) error {
+	algorithm, err := PublicKeyAlgorithm(publicKey)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrProfileKey, err)
+	}
+	for _, allowed := range profile.keyAlgorithms {
+		if allowed == algorithm {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s not allowed by profile %s", ErrProfileKey, algorithm, profile.Name)
+}
+
+func (profile *CertProfile) allowsExtension(oid asn1.ObjectIdentifier) bool {
+	for _, allowed := range profile.optional {
+		if allowed.Equal(oid) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkNameConstraints 终端证书的SAN策略：只签发名称约束明确允许的DNS、邮箱与IP，
+// 未配置名称约束的模板（如匿名证书模板）不接受任何SAN，避免匿名证书冒用主机名或暴露身份
+func (profile *CertProfile) checkNameConstraints(request *IssuanceRequest) error {
+	constraints := profile.NameConstraints
+	if constraints == nil {
+		constraints = &ProfileNameConstraints{}
+	}
+	for _, name := range request.DNSNames {
+		if matchesAnyDomain(name, constraints.ExcludedDNSDomains) ||
+			(!request.dnsValidated && !matchesAnyDomain(name, constraints.PermittedDNSDomains)) {
+			return fmt.Errorf("%w: DNS name %s", ErrProfileNameConstraint, name)
+		}
+	}
+	for _, email := range request.EmailAddresses {
+		domain := email[strings.LastIndex(email, "@")+1:]
+		if matchesAnyDomain(domain, constraints.ExcludedEmailAddresses) || !matchesAnyDomain(domain, constraints.PermittedEmailAddresses) {
+			return fmt.Errorf("%w: email address %s", ErrProfileNameConstraint, email)
+		}
+	}
+	for _, ip := range request.IPAddresses {
+		if containsIP(profile.excludedIP, ip) || !containsIP(profile.permittedIP, ip) {
+			return fmt.Errorf("%w: IP address %s", ErrProfileNameConstraint, ip)
+		}
+	}
+	// 名称约束不含URI，终端证书不签发URI SAN
+	if len(request.URIs) > 0 {
+		return fmt.Errorf("%w: URI %s", ErrProfileNameConstraint, request.URIs[0])
+	}
+	return nil
+}
+
+// matchesAnyDomain 按 RFC 5280 4.2.1.10 的规则判断名称是否位于某个域之内
+func matchesAnyDomain(name string, domains []string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+		if strings.HasPrefix(domain, ".") {
+			if strings.HasSuffix(name, domain) {
+				return true
+			}
+			continue
+		}
+		if name == domain || strings.HasSuffix(name, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsIP(ranges []*net.IPNet, ip net.IP) bool {
+	for _, ipRange := range ranges {
+		if ipRange.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsExtKeyUsage(usages []x509.ExtKeyUsage, usage x509.ExtKeyUsage) bool {
+	for _, allowed := range usages {
+		if allowed == usage || allowed == x509.ExtKeyUsageAny {
+			return true
+		}
+	}
+	return false
+}
+
+func parseOID(text string) (asn1.ObjectIdentifier, error) {
+	parts := strings.Split(text, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid OID %q", text)
+	}
+	oid := make(asn1.ObjectIdentifier, len(parts))
+	for i, part := range parts {
+		arc, err := strconv.Atoi(part)
+		if err != nil || arc < 0 {
+			return nil, fmt.Errorf("invalid OID %q", text)
+		}
+		oid[i] = arc
+	}
+	return oid, nil
+}
+
+func parseCIDRs(ranges []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(ranges))
+	for _, cidr := range ranges {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP range %q: %w", cidr, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
@@ -0,0 +1,98 @@
+package cer_ca_tools
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"testing"
+	"time"
+)
+
+const testProfileConfig = `{
+  "profiles": [
+    {
+      "name": "edge-server",
+      "validity": "72h",
+      "key_usage": ["digitalSignature"],
+      "ext_key_usage": ["serverAuth"],
+      "allowed_curves": ["P-256"],
+      "optional_extensions": ["1.2.3.4"],
+      "mandatory_extensions": [{"oid": "1.2.3.5", "value": "BQA="}],
+      "name_constraints": {"permitted_dns_domains": ["example.com"]}
+    },
+    {
+      "name": "root-ca",
+      "validity": "87600h",
+      "key_usage": ["certSign", "crlSign"],
+      "allowed_curves": ["P-256"],
+      "is_ca": true,
+      "max_path_len": 1
+    }
+  ],
+  "cas": {
+    "ca_profile_test": {"default_profile": "edge-server", "profiles": ["edge-server"]}
+  }
+}`
+
+func TestCertProfiles(t *testing.T) {
+	config, err := ParseProfileConfig([]byte(testProfileConfig))
+	if err != nil {
+		t.Fatalf("parse profile config failed: %v", err)
+	}
+	if _, err := ParseProfileConfig([]byte(`{"profiles":[{"name":"bad","validity":"1y","allowed_curves":["P-256"]}]}`)); err == nil {
+		t.Fatalf("invalid validity should be rejected")
+	}
+
+	manager := NewCAManagerWithStore(NewMemoryStore())
+	manager.SetProfileConfig(config)
+	ca, err := manager.CreateCA("ca_profile_test")
+	if err != nil {
+		t.Fatalf("create CA failed: %v", err)
+	}
+	if ca.Certificate.MaxPathLen != 1 {
+		t.Fatalf("root CA should use the configured root-ca profile, pathLen %d", ca.Certificate.MaxPathLen)
+	}
+
+	subjectSK, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	request := &IssuanceRequest{
+		Subject:   pkix.Name{CommonName: "edge"},
+		PublicKey: &subjectSK.PublicKey,
+		DNSNames:  []string{"edge.example.com"},
+		Extensions: []pkix.Extension{
+			{Id: asn1.ObjectIdentifier{1, 2, 3, 4}, Value: asn1.NullBytes},
+			{Id: asn1.ObjectIdentifier{1, 2, 3, 6}, Value: asn1.NullBytes},
+		},
+	}
+	response := ca.Issue("", request)
+	if !response.Success {
+		t.Fatalf("issue with default profile failed: %s", response.Message)
+	}
+	cert := parseTestCertificate(t, response.Certificate)
+	if validity := cert.NotAfter.Sub(cert.NotBefore); validity != 72*time.Hour {
+		t.Fatalf("unexpected validity %s", validity)
+	}
+	extensions := make(map[string]bool)
+	for _, ext := range cert.Extensions {
+		extensions[ext.Id.String()] = true
+	}
+	if !extensions["1.2.3.4"] || !extensions["1.2.3.5"] || extensions["1.2.3.6"] {
+		t.Fatalf("optional/mandatory extensions not applied: %v", extensions)
+	}
+
+	request.DNSNames = []string{"edge.example.org"}
+	if response := ca.Issue("", request); !errors.Is(response.Err, ErrProfileNameConstraint) {
+		t.Fatalf("expected name constraint violation, have %v", response.Err)
+	}
+	request.DNSNames = nil
+	request.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	if response := ca.Issue("", request); !errors.Is(response.Err, ErrProfileEKU) {
+		t.Fatalf("expected EKU rejection, have %v", response.Err)
+	}
+	if response := ca.Issue(ProfileAnonClient, request); !errors.Is(response.Err, ErrProfileNotFound) {
+		t.Fatalf("profile outside the CA's configuration should be rejected, have %v", response.Err)
+	}
+}
@@ -0,0 +1,224 @@
+package cer_ca_tools
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// 续期请求签名的上下文前缀，防止签名被挪作他用
+const renewalSignatureContext = "AnonCert certificate renewal v1"
+
+// RenewalMaxClockSkew 续期请求时间戳与CA时间的最大偏差
+const RenewalMaxClockSkew = 5 * time.Minute
+
+var (
+	ErrRenewalMalformed   = errors.New("malformed renewal request")
+	ErrRenewalSignature   = errors.New("renewal request signature verification failed")
+	ErrRenewalStale       = errors.New("renewal request timestamp outside the allowed window")
+	ErrRenewalUnknownCert = errors.New("certificate was not issued by this CA")
+	ErrRenewalInvalidCert = errors.New("certificate is expired or revoked")
+)
+
+// RenewalRequest 证书续期/换钥请求，由当前仍有效的匿名证书的私钥签名
+//
+// CSR 为空时沿用原公钥续期；携带 CSR 时换用CSR中的新公钥，CSR 自签名证明持有新私钥。
+// 后继证书沿用原证书的匿名主体与 SAN，不需要重新走 CRT/模数流程。
+type RenewalRequest struct {
+	Certificate       []byte `json:"certificate"`         // 当前证书，PEM或DER
+	CSR               []byte `json:"csr,omitempty"`       // 换钥时新密钥的PKCS#10请求，PEM或DER
+	RevokePredecessor bool   `json:"revoke_predecessor"`  // 签发成功后以 superseded 原因撤销原证书
+	Timestamp         int64  `json:"timestamp"`           // Unix 秒
+	Signature         []byte `json:"signature,omitempty"` // 原证书私钥对 SignedData 的 SHA-256 摘要的签名
+}
+
+// SignedData 返回被签名的内容：各字段按长度前缀依次编码
+func (request *RenewalRequest) SignedData() []byte {
+	var data bytes.Buffer
+	data.WriteString(renewalSignatureContext)
+	for _, field := range [][]byte{
+		request.Certificate,
+		request.CSR,
+		[]byte(strconv.FormatBool(request.RevokePredecessor)),
+		[]byte(strconv.FormatInt(request.Timestamp, 10)),
+	} {
+		binary.Write(&data, binary.BigEndian, uint32(len(field)))
+		data.Write(field)
+	}
+	return data.Bytes()
+}
+
+// Sign 使用当前证书的私钥签名续期请求，未设置时间戳时使用当前时间
+func (request *RenewalRequest) Sign(key crypto.Signer) error {
+	if request.Timestamp == 0 {
+		request.Timestamp = time.Now().Unix()
+	}
+	digest := sha256.Sum256(request.SignedData())
+	signature, err := signDigest(key, digest[:], crypto.SHA256)
+	if err != nil {
+		return fmt.Errorf("failed to sign renewal request: %w", err)
+	}
+	request.Signature = signature
+	return nil
+}
+
+// Verify 校验续期请求的时间戳与签名，返回原证书和可选的换钥CSR
+func (request *RenewalRequest) Verify(now time.Time) (*x509.Certificate, *x509.CertificateRequest, error) {
+	predecessor, err := parseRenewalCertificate(request.Certificate)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	requestTime := time.Unix(request.Timestamp, 0)
+	if requestTime.Before(now.Add(-RenewalMaxClockSkew)) || requestTime.After(now.Add(RenewalMaxClockSkew)) {
+		return nil, nil, ErrRenewalStale
+	}
+	digest := sha256.Sum256(request.SignedData())
+	if err := verifyDigestSignature(predecessor.PublicKey, digest[:], request.Signature); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrRenewalSignature, err)
+	}
+
+	var csr *x509.CertificateRequest
+	if len(request.CSR) > 0 {
+		if csr, err = ParseCSR(request.CSR); err != nil {
+			return nil, nil, err
+		}
+	}
+	return predecessor, csr, nil
+}
+
+func parseRenewalCertificate(data []byte) (*x509.Certificate, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+	cert, err := ParseCertificate(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRenewalMalformed, err)
+	}
+	return cert, nil
+}
+
+// RenewCertificate 为仍有效的证书签发后继证书：沿用匿名主体与 SAN，可选换钥，可选以 superseded 撤销原证书。
+// 检查原证书、签发与撤销原证书在同一临界区内完成，同一证书的并发续期与撤销依次进行；
+// 原证书撤销失败时撤回后继证书并返回失败
+func (ca *CA) RenewCertificate(profileName string, renewal *RenewalRequest) CertificateResponse {
+	now := time.Now()
+	predecessor, csr, err := renewal.Verify(now)
+
+	ca.renewal.Lock()
+	defer ca.renewal.Unlock()
+
+	if err == nil {
+		err = ca.checkPredecessor(predecessor, now)
+	}
+	var request *IssuanceRequest
+	if err == nil {
+		request, err = ca.renewalIssuanceRequest(predecessor, csr)
+	}
+	if err != nil {
+		log.Printf("CA %s rejected renewal: %v", ca.Name.CommonName, err)
+		return CertificateResponse{
+			Success: false,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+
+	response := ca.Issue(profileName, request)
+	if !response.Success {
+		return response
+	}
+	serial := predecessor.SerialNumber.String()
+	log.Printf("CA %s renewed certificate %s (rekey=%t)", ca.Name.CommonName, serial, csr != nil)
+
+	if renewal.RevokePredecessor {
+		if revoked := ca.revokeCertificate(ca.Name.CommonName, serial, ocsp.Superseded, RevocationOptions{}); !revoked.Success {
+			successor := certificateSerial(response.Certificate)
+			log.Printf("CA %s failed to revoke superseded certificate %s, withdrawing successor %s: %s", ca.Name.CommonName, serial, successor, revoked.Message)
+			message := fmt.Sprintf("renewal aborted, predecessor not revoked: %s", revoked.Message)
+			if withdrawn := ca.revokeCertificate(ca.Name.CommonName, successor, ocsp.CessationOfOperation, RevocationOptions{}); !withdrawn.Success {
+				message = fmt.Sprintf("%s; successor %s not withdrawn: %s", message, successor, withdrawn.Message)
+			}
+			return CertificateResponse{
+				Success: false,
+				Message: message,
+				Err:     revoked.Err,
+			}
+		}
+	}
+	return response
+}
+
+// RenewCertificate 按原证书序列号找到签发CA并续期
+func (manager *CAManager) RenewCertificate(profileName string, renewal *RenewalRequest) CertificateResponse {
+	predecessor, err := parseRenewalCertificate(renewal.Certificate)
+	if err == nil {
+		var ca *CA
+		if ca, _, err = manager.FindCertIssuer(predecessor.SerialNumber.String()); err == nil {
+			return ca.RenewCertificate(profileName, renewal)
+		}
+		err = fmt.Errorf("%w: %v", ErrRenewalUnknownCert, err)
+	}
+	return CertificateResponse{
+		Success: false,
+		Message: err.Error(),
+		Err:     err,
+	}
+}
+
+// checkPredecessor 原证书必须由本CA签发、在有效期内且未被撤销
+func (ca *CA) checkPredecessor(predecessor *x509.Certificate, now time.Time) error {
+	ca.Mutex.Lock()
+	defer ca.Mutex.Unlock()
+
+	serial := predecessor.SerialNumber.String()
+	issued, exists := ca.IssuedCerts[serial]
+	if !exists || !issued.Equal(predecessor) {
+		return ErrRenewalUnknownCert
+	}
+	if _, revoked := ca.revocation(serial); revoked {
+		return fmt.Errorf("%w: %s is revoked", ErrRenewalInvalidCert, serial)
+	}
+	if now.Before(predecessor.NotBefore) || now.After(predecessor.NotAfter) {
+		return fmt.Errorf("%w: %s is not within its validity period", ErrRenewalInvalidCert, serial)
+	}
+	return nil
+}
+
+// renewalIssuanceRequest 后继证书沿用原证书的主体与 SAN；换钥时公钥与扩展来自经过校验的CSR
+func (ca *CA) renewalIssuanceRequest(predecessor *x509.Certificate, csr *x509.CertificateRequest) (*IssuanceRequest, error) {
+	request := &IssuanceRequest{
+		Subject:   predecessor.Subject,
+		PublicKey: predecessor.PublicKey,
+	}
+	if csr != nil {
+		var err error
+		if request, err = ca.CSRPolicy.ValidateCSR(csr, predecessor.Subject); err != nil {
+			return nil, err
+		}
+	}
+	request.DNSNames = predecessor.DNSNames
+	request.EmailAddresses = predecessor.EmailAddresses
+	request.IPAddresses = predecessor.IPAddresses
+	request.URIs = predecessor.URIs
+	return request, nil
+}
+
+func isRenewalRejection(err error) bool {
+	for _, renewalErr := range []error{ErrRenewalMalformed, ErrRenewalSignature, ErrRenewalStale, ErrRenewalUnknownCert, ErrRenewalInvalidCert} {
+		if errors.Is(err, renewalErr) {
+			return true
+		}
+	}
+	return isCSRRejection(err)
+}
@@ -0,0 +1,173 @@
+package cer_ca_tools
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestRenewCertificate(t *testing.T) {
+	manager := NewCAManagerWithStore(NewMemoryStore())
+	ca, err := manager.CreateCA("ca_renew_test")
+	if err != nil {
+		t.Fatalf("create CA failed: %v", err)
+	}
+	subjectSK, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	response := ca.IssueCertificate(pkix.Name{CommonName: "anonymous-renew"}, &subjectSK.PublicKey)
+	if !response.Success {
+		t.Fatalf("issue certificate failed: %s", response.Message)
+	}
+	predecessor := parseTestCertificate(t, response.Certificate)
+
+	// 沿用原公钥续期
+	renewal := &RenewalRequest{Certificate: []byte(response.Certificate)}
+	if err := renewal.Sign(subjectSK); err != nil {
+		t.Fatalf("sign renewal failed: %v", err)
+	}
+	renewed := manager.RenewCertificate("", renewal)
+	if !renewed.Success {
+		t.Fatalf("renew failed: %s", renewed.Message)
+	}
+	successor := parseTestCertificate(t, renewed.Certificate)
+	if successor.SerialNumber.Cmp(predecessor.SerialNumber) == 0 || successor.Subject.String() != predecessor.Subject.String() {
+		t.Fatalf("successor should have a new serial and the same anonymous subject")
+	}
+	if !successor.PublicKey.(*ecdsa.PublicKey).Equal(&subjectSK.PublicKey) {
+		t.Fatalf("renewal without CSR should keep the public key")
+	}
+
+	// 篡改请求与过期时间戳被拒绝
+	tampered := *renewal
+	tampered.RevokePredecessor = true
+	if result := manager.RenewCertificate("", &tampered); !errors.Is(result.Err, ErrRenewalSignature) {
+		t.Fatalf("tampered request should be rejected, have %v", result.Err)
+	}
+	stale := &RenewalRequest{Certificate: []byte(response.Certificate), Timestamp: time.Now().Add(-time.Hour).Unix()}
+	stale.Sign(subjectSK)
+	if result := manager.RenewCertificate("", stale); !errors.Is(result.Err, ErrRenewalStale) {
+		t.Fatalf("stale request should be rejected, have %v", result.Err)
+	}
+	otherSK, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	forged := &RenewalRequest{Certificate: []byte(response.Certificate)}
+	forged.Sign(otherSK)
+	if result := manager.RenewCertificate("", forged); !errors.Is(result.Err, ErrRenewalSignature) {
+		t.Fatalf("request signed by another key should be rejected, have %v", result.Err)
+	}
+
+	// 换钥续期并以 superseded 撤销原证书
+	newSK, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	csrDER, _ := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{}, newSK)
+	rekey := &RenewalRequest{Certificate: []byte(response.Certificate), CSR: csrDER, RevokePredecessor: true}
+	rekey.Sign(subjectSK)
+	renewed = manager.RenewCertificate("", rekey)
+	if !renewed.Success {
+		t.Fatalf("rekey failed: %s", renewed.Message)
+	}
+	successor = parseTestCertificate(t, renewed.Certificate)
+	if !successor.PublicKey.(*ecdsa.PublicKey).Equal(&newSK.PublicKey) || successor.Subject.String() != predecessor.Subject.String() {
+		t.Fatalf("rekeyed certificate should carry the new key and the same subject")
+	}
+	revoked, exists := ca.RevokedCerts[predecessor.SerialNumber.String()]
+	if !exists || revocationReason(revoked) != ocsp.Superseded {
+		t.Fatalf("predecessor should be revoked as superseded")
+	}
+
+	// 已撤销的证书不能再续期
+	again := &RenewalRequest{Certificate: []byte(response.Certificate)}
+	again.Sign(subjectSK)
+	if result := manager.RenewCertificate("", again); !errors.Is(result.Err, ErrRenewalInvalidCert) {
+		t.Fatalf("revoked certificate should not be renewed, have %v", result.Err)
+	}
+}
+
+// rejectingRevocationStore 拒绝保存指定序列号的撤销记录
+type rejectingRevocationStore struct {
+	CAStore
+	serial string
+}
+
+func (store *rejectingRevocationStore) SaveRevokedCert(caName string, revoked *pkix.RevokedCertificate) error {
+	if revoked.SerialNumber.String() == store.serial {
+		return errors.New("storage unavailable")
+	}
+	return store.CAStore.SaveRevokedCert(caName, revoked)
+}
+
+func TestRenewCertificateRevokesPredecessorOnce(t *testing.T) {
+	manager := NewCAManagerWithStore(NewMemoryStore())
+	ca, err := manager.CreateCA("ca_renew_race_test")
+	if err != nil {
+		t.Fatalf("create CA failed: %v", err)
+	}
+	subjectSK, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	response := ca.IssueCertificate(pkix.Name{CommonName: "anonymous-renew"}, &subjectSK.PublicKey)
+	if !response.Success {
+		t.Fatalf("issue certificate failed: %s", response.Message)
+	}
+
+	// 同一证书的并发续期只有一个能签发后继证书
+	const workers = 8
+	var wg sync.WaitGroup
+	results := make([]CertificateResponse, workers)
+	for i := range results {
+		renewal := &RenewalRequest{Certificate: []byte(response.Certificate), RevokePredecessor: true}
+		if err := renewal.Sign(subjectSK); err != nil {
+			t.Fatalf("sign renewal failed: %v", err)
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = manager.RenewCertificate("", renewal)
+		}(i)
+	}
+	wg.Wait()
+	renewed := 0
+	for _, result := range results {
+		if result.Success {
+			renewed++
+		} else if !errors.Is(result.Err, ErrRenewalInvalidCert) {
+			t.Fatalf("concurrent renewal should see the revoked predecessor, have %v", result.Err)
+		}
+	}
+	if renewed != 1 {
+		t.Fatalf("predecessor renewed %d times, want once", renewed)
+	}
+
+	// 原证书撤销失败时续期失败，后继证书被撤回
+	response = ca.IssueCertificate(pkix.Name{CommonName: "anonymous-renew"}, &subjectSK.PublicKey)
+	predecessor := parseTestCertificate(t, response.Certificate)
+	ca.store = &rejectingRevocationStore{CAStore: ca.store, serial: predecessor.SerialNumber.String()}
+	issued := make(map[string]bool)
+	for serial := range ca.IssuedCerts {
+		issued[serial] = true
+	}
+	renewal := &RenewalRequest{Certificate: []byte(response.Certificate), RevokePredecessor: true}
+	renewal.Sign(subjectSK)
+	if result := manager.RenewCertificate("", renewal); result.Success || result.Err == nil {
+		t.Fatalf("renewal should fail when the predecessor cannot be revoked")
+	}
+	if _, exists := ca.RevokedCerts[predecessor.SerialNumber.String()]; exists {
+		t.Fatalf("predecessor revocation should not be recorded")
+	}
+	withdrawn := 0
+	for serial := range ca.IssuedCerts {
+		if issued[serial] {
+			continue
+		}
+		withdrawn++
+		if revoked, exists := ca.RevokedCerts[serial]; !exists || revocationReason(revoked) != ocsp.CessationOfOperation {
+			t.Fatalf("successor %s should be withdrawn", serial)
+		}
+	}
+	if withdrawn != 1 {
+		t.Fatalf("%d successors issued, want one withdrawn successor", withdrawn)
+	}
+}
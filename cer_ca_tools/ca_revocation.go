@@ -0,0 +1,324 @@
+package cer_ca_tools
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+// CRLReason 撤销原因 (RFC 5280 5.3.1)，取值与 golang.org/x/crypto/ocsp 中的常量一致，7 未使用
+const (
+	ReasonUnspecified          = 0
+	ReasonKeyCompromise        = 1
+	ReasonCACompromise         = 2
+	ReasonAffiliationChanged   = 3
+	ReasonSuperseded           = 4
+	ReasonCessationOfOperation = 5
+	ReasonCertificateHold      = 6 // 暂停，可由 ReleaseCertificateHold 解除
+	ReasonRemoveFromCRL        = 8 // 解除暂停，只出现在增量CRL中
+	ReasonPrivilegeWithdrawn   = 9
+	ReasonAACompromise         = 10
+)
+
+var revocationReasonNames = map[int]string{
+	ReasonUnspecified:          "unspecified",
+	ReasonKeyCompromise:        "keyCompromise",
+	ReasonCACompromise:         "cACompromise",
+	ReasonAffiliationChanged:   "affiliationChanged",
+	ReasonSuperseded:           "superseded",
+	ReasonCessationOfOperation: "cessationOfOperation",
+	ReasonCertificateHold:      "certificateHold",
+	ReasonRemoveFromCRL:        "removeFromCRL",
+	ReasonPrivilegeWithdrawn:   "privilegeWithdrawn",
+	ReasonAACompromise:         "aACompromise",
+}
+
+// invalidityDate 扩展 (RFC 5280 5.3.2)
+var oidExtensionInvalidityDate = asn1.ObjectIdentifier{2, 5, 29, 24}
+
+var (
+	ErrInvalidRevocationReason = errors.New("invalid revocation reason")
+	ErrInvalidityDate          = errors.New("invalidity date is later than the revocation time")
+	ErrCertNotOnHold           = errors.New("certificate is not on hold")
+)
+
+// RevocationReasonName 返回撤销原因在 RFC 5280 中的名称
+func RevocationReasonName(reason int) string {
+	if name, ok := revocationReasonNames[reason]; ok {
+		return name
+	}
+	return strconv.Itoa(reason)
+}
+
+// RevocationOptions 撤销时的可选信息
+type RevocationOptions struct {
+	// InvalidityDate 已知或怀疑私钥泄露、证书不再可信的时间，早于撤销时间；零值表示不携带 invalidityDate 扩展
+	InvalidityDate time.Time
+}
+
+// checkRevocationReason 撤销请求可使用的原因，removeFromCRL 只能由解除暂停产生
+func checkRevocationReason(reason int) error {
+	if _, ok := revocationReasonNames[reason]; !ok || reason == ReasonRemoveFromCRL {
+		return fmt.Errorf("%w: %d", ErrInvalidRevocationReason, reason)
+	}
+	return nil
+}
+
+// newRevocationEntry 生成撤销记录，reasonCode 按 DER ENUMERATED 编码，unspecified 时按 RFC 5280 省略
+func newRevocationEntry(serial *big.Int, reason int, revokedAt time.Time, options RevocationOptions) (pkix.RevokedCertificate, error) {
+	revoked := pkix.RevokedCertificate{
+		SerialNumber:   serial,
+		RevocationTime: revokedAt,
+	}
+	if reason != ReasonUnspecified {
+		value, err := asn1.Marshal(asn1.Enumerated(reason))
+		if err != nil {
+			return revoked, fmt.Errorf("failed to encode reason code: %w", err)
+		}
+		revoked.Extensions = append(revoked.Extensions, pkix.Extension{Id: oidExtensionReasonCode, Value: value})
+	}
+	if !options.InvalidityDate.IsZero() {
+		value, err := asn1.MarshalWithParams(options.InvalidityDate.UTC(), "generalized")
+		if err != nil {
+			return revoked, fmt.Errorf("failed to encode invalidity date: %w", err)
+		}
+		revoked.Extensions = append(revoked.Extensions, pkix.Extension{Id: oidExtensionInvalidityDate, Value: value})
+	}
+	return revoked, nil
+}
+
+// revocationReason 读取撤销记录中的原因，兼容早期以单字节保存的原因
+func revocationReason(revoked *pkix.RevokedCertificate) int {
+	for _, ext := range revoked.Extensions {
+		if !ext.Id.Equal(oidExtensionReasonCode) {
+			continue
+		}
+		var reason asn1.Enumerated
+		if rest, err := asn1.Unmarshal(ext.Value, &reason); err == nil && len(rest) == 0 {
+			return int(reason)
+		}
+		if len(ext.Value) == 1 {
+			return int(ext.Value[0])
+		}
+	}
+	return ReasonUnspecified
+}
+
+// RevocationInvalidityDate 读取撤销记录中的 invalidityDate
+func RevocationInvalidityDate(revoked *pkix.RevokedCertificate) (time.Time, bool) {
+	for _, ext := range revoked.Extensions {
+		if !ext.Id.Equal(oidExtensionInvalidityDate) {
+			continue
+		}
+		var invalidity time.Time
+		if _, err := asn1.UnmarshalWithParams(ext.Value, &invalidity, "generalized"); err == nil {
+			return invalidity, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// revocation 返回证书当前生效的撤销记录；解除暂停后保留的 removeFromCRL 记录不算撤销
+func (ca *CA) revocation(serialNumber string) (*pkix.RevokedCertificate, bool) {
+	revoked, exists := ca.RevokedCerts[serialNumber]
+	if !exists || revocationReason(revoked) == ReasonRemoveFromCRL {
+		return nil, false
+	}
+	return revoked, true
+}
+
+// RevocationStatus 返回证书是否被撤销（含暂停）以及撤销原因
+func (ca *CA) RevocationStatus(serialNumber string) (bool, int) {
+	ca.Mutex.Lock()
+	defer ca.Mutex.Unlock()
+
+	revoked, exists := ca.revocation(serialNumber)
+	if !exists {
+		return false, ReasonUnspecified
+	}
+	return true, revocationReason(revoked)
+}
+
+// RevokeCertificate 以指定原因撤销证书，见 RevokeCertificateWithOptions
+func (ca *CA) RevokeCertificate(caName string, serialNumber string, reason int) CertificateResponse {
+	return ca.RevokeCertificateWithOptions(caName, serialNumber, reason, RevocationOptions{})
+}
+
+// RevokeCertificateWithOptions 撤销或暂停证书。reason 为 certificateHold 时证书被暂停，之后可以解除暂停
+// 或改为永久撤销；永久撤销后不能再改变状态
+func (ca *CA) RevokeCertificateWithOptions(caName string, serialNumber string, reason int, options RevocationOptions) CertificateResponse {
+	ca.renewal.Lock()
+	defer ca.renewal.Unlock()
+
+	return ca.revokeCertificate(caName, serialNumber, reason, options)
+}
+
+// revokeCertificate 同 RevokeCertificateWithOptions，调用方持有 ca.renewal
+func (ca *CA) revokeCertificate(caName string, serialNumber string, reason int, options RevocationOptions) CertificateResponse {
+	if err := checkRevocationReason(reason); err != nil {
+		return CertificateResponse{
+			Success: false,
+			Message: "撤销失败，撤销原因无效",
+			Err:     err,
+		}
+	}
+
+	now := time.Now()
+	if options.InvalidityDate.After(now) {
+		return CertificateResponse{
+			Success: false,
+			Message: "撤销失败，失效时间晚于撤销时间",
+			Err:     ErrInvalidityDate,
+		}
+	}
+
+	ca.Mutex.Lock()
+	defer ca.Mutex.Unlock()
+
+	cert, exists := ca.IssuedCerts[serialNumber]
+	if !exists {
+		return CertificateResponse{
+			Success: false,
+			Message: "撤销失败，证书不存在",
+			Err:     fmt.Errorf("%w: %s", ErrCertNotFound, serialNumber),
+		}
+	}
+
+	event := AuditEventRevoke
+	if reason == ReasonCertificateHold {
+		event = AuditEventHold
+	}
+	if current, revoked := ca.revocation(serialNumber); revoked {
+		switch {
+		case revocationReason(current) != ReasonCertificateHold:
+			return CertificateResponse{
+				Success: false,
+				Message: "撤销失败，证书已经被撤销",
+				Err:     fmt.Errorf("%w: %s", ErrCertAlreadyRevoked, serialNumber),
+			}
+		case reason == ReasonCertificateHold:
+			return CertificateResponse{
+				Success: false,
+				Message: "暂停失败，证书已经处于暂停状态",
+				Err:     fmt.Errorf("%w: %s is already on hold", ErrCertAlreadyRevoked, serialNumber),
+			}
+		}
+	}
+
+	revokedCert, err := newRevocationEntry(cert.SerialNumber, reason, now, options)
+	if err != nil {
+		return CertificateResponse{
+			Success: false,
+			Message: "撤销失败，无法编码撤销记录",
+			Err:     err,
+		}
+	}
+	details := map[string]string{"reason": strconv.Itoa(reason)}
+	if !options.InvalidityDate.IsZero() {
+		details["invalidity_date"] = options.InvalidityDate.UTC().Format(time.RFC3339)
+	}
+	if err := ca.recordAudit(event, serialNumber, details); err != nil {
+		return CertificateResponse{
+			Success: false,
+			Message: "撤销失败，无法写入审计日志",
+			Err:     err,
+		}
+	}
+	if err := ca.saveRevocation(&revokedCert); err != nil {
+		log.Printf("保存撤销记录失败: %v", err)
+		return CertificateResponse{
+			Success: false,
+			Message: "撤销失败，无法保存撤销记录",
+			Err:     err,
+		}
+	}
+	log.Printf(" revoked certificate for CA: %s, Serial: %s, reason: %s", caName, serialNumber, RevocationReasonName(reason))
+
+	message := "证书撤销成功"
+	if reason == ReasonCertificateHold {
+		message = "证书暂停成功"
+	}
+	return CertificateResponse{
+		Success: true,
+		Message: message,
+	}
+}
+
+// ReleaseCertificateHold 解除证书暂停。撤销记录改为 removeFromCRL，完整CRL不再列出该证书，
+// 下一次完整CRL之前的增量CRL以 removeFromCRL 通知依赖方
+func (ca *CA) ReleaseCertificateHold(caName string, serialNumber string) CertificateResponse {
+	ca.Mutex.Lock()
+	defer ca.Mutex.Unlock()
+
+	cert, exists := ca.IssuedCerts[serialNumber]
+	if !exists {
+		return CertificateResponse{
+			Success: false,
+			Message: "解除暂停失败，证书不存在",
+			Err:     fmt.Errorf("%w: %s", ErrCertNotFound, serialNumber),
+		}
+	}
+	current, revoked := ca.revocation(serialNumber)
+	if !revoked {
+		return CertificateResponse{
+			Success: false,
+			Message: "解除暂停失败，证书未被暂停",
+			Err:     fmt.Errorf("%w: %s", ErrCertNotOnHold, serialNumber),
+		}
+	}
+	if reason := revocationReason(current); reason != ReasonCertificateHold {
+		return CertificateResponse{
+			Success: false,
+			Message: "解除暂停失败，证书已被永久撤销",
+			Err:     fmt.Errorf("%w: %s is revoked (%s)", ErrCertNotOnHold, serialNumber, RevocationReasonName(reason)),
+		}
+	}
+
+	released, err := newRevocationEntry(cert.SerialNumber, ReasonRemoveFromCRL, time.Now(), RevocationOptions{})
+	if err != nil {
+		return CertificateResponse{
+			Success: false,
+			Message: "解除暂停失败，无法编码撤销记录",
+			Err:     err,
+		}
+	}
+	if err := ca.recordAudit(AuditEventUnhold, serialNumber, nil); err != nil {
+		return CertificateResponse{
+			Success: false,
+			Message: "解除暂停失败，无法写入审计日志",
+			Err:     err,
+		}
+	}
+	if err := ca.saveRevocation(&released); err != nil {
+		log.Printf("保存撤销记录失败: %v", err)
+		return CertificateResponse{
+			Success: false,
+			Message: "解除暂停失败，无法保存撤销记录",
+			Err:     err,
+		}
+	}
+	log.Printf(" released hold of certificate for CA: %s, Serial: %s", caName, serialNumber)
+	return CertificateResponse{
+		Success: true,
+		Message: "证书解除暂停成功",
+	}
+}
+
+// saveRevocation 持久化并替换证书的撤销记录，同步更新撤销过滤器，调用方持有 ca.Mutex
+func (ca *CA) saveRevocation(revoked *pkix.RevokedCertificate) error {
+	if ca.store != nil {
+		if err := ca.store.SaveRevokedCert(ca.Name.CommonName, revoked); err != nil {
+			return err
+		}
+	}
+	serial := revoked.SerialNumber.String()
+	previous := ca.RevokedCerts[serial]
+	ca.RevokedCerts[serial] = revoked
+	ca.invalidateCRL()
+	return ca.updateFilter(previous, revoked)
+}
@@ -0,0 +1,54 @@
+package cer_ca_tools
+
+import "errors"
+
+// RevocationFilter 撤销过滤器的公共接口，元素为证书的十进制序列号（见 filterKey）。
+// 各实现在空间、速度与是否可删除之间取舍不同：
+//
+//	CountingBloomFilter 可删除，计数器占用空间较大
+//	CuckooFilter        可删除，按指纹存储，装载率高时插入可能失败
+//	BinaryFuseFilter    静态，构建后不可修改，每个周期由全部撤销证书重建，空间最小
+type RevocationFilter interface {
+	// Add 加入元素
+	Add(data []byte) error
+	// Remove 删除之前加入的元素
+	Remove(data []byte) error
+	// Contains 元素是否可能在过滤器中，返回 false 时一定不在
+	Contains(data []byte) bool
+	// Marshal 序列化过滤器，与各实现的 MarshalBinary 相同
+	Marshal() ([]byte, error)
+	// Stats 返回参数与统计信息，至少包含 elements、memory_bytes 与 estimated_fpr
+	Stats() map[string]interface{}
+}
+
+var (
+	ErrFilterFull            = errors.New("revocation filter is full")
+	ErrFilterStatic          = errors.New("revocation filter is static, rebuild it instead")
+	ErrFilterElementNotFound = errors.New("element is not in the revocation filter")
+)
+
+func (cbf *CountingBloomFilter) Add(data []byte) error {
+	cbf.AddElement(data)
+	return nil
+}
+
+func (cbf *CountingBloomFilter) Remove(data []byte) error {
+	if !cbf.RemoveElement(data) {
+		return ErrFilterElementNotFound
+	}
+	return nil
+}
+
+func (cbf *CountingBloomFilter) Contains(data []byte) bool {
+	return cbf.QueryElement(data)
+}
+
+func (cbf *CountingBloomFilter) Marshal() ([]byte, error) {
+	return cbf.MarshalBinary()
+}
+
+func (cbf *CountingBloomFilter) Stats() map[string]interface{} {
+	stats := cbf.GetStats()
+	stats["memory_bytes"] = cbf.GetMemoryUsage()
+	return stats
+}
@@ -0,0 +1,189 @@
+package cer_ca_tools
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"slices"
+	"testing"
+)
+
+func filterTestElements(prefix string, count int) [][]byte {
+	elements := make([][]byte, count)
+	for i := range elements {
+		elements[i] = []byte(fmt.Sprint(prefix, i))
+	}
+	return elements
+}
+
+func TestRevocationFilterImplementations(t *testing.T) {
+	const count = 20000
+	revoked := filterTestElements("revoked-", count)
+	fuse, err := NewBinaryFuseFilter(revoked)
+	if err != nil {
+		t.Fatalf("build binary fuse filter failed: %v", err)
+	}
+	filters := []struct {
+		name     string
+		filter   RevocationFilter
+		restored RevocationFilter
+		static   bool
+	}{
+		{"counting bloom", NewCountingBloomFilter(count, 0.001, 4), &CountingBloomFilter{}, false},
+		{"cuckoo", NewCuckooFilter(count), &CuckooFilter{}, false},
+		{"binary fuse", fuse, &BinaryFuseFilter{}, true},
+	}
+
+	absent := filterTestElements("valid-", 100000)
+	for _, test := range filters {
+		if !test.static {
+			for _, element := range revoked {
+				if err := test.filter.Add(element); err != nil {
+					t.Fatalf("%s: add failed: %v", test.name, err)
+				}
+			}
+		}
+		for _, element := range revoked {
+			if !test.filter.Contains(element) {
+				t.Fatalf("%s: false negative for %s", test.name, element)
+			}
+		}
+		falsePositives := 0
+		for _, element := range absent {
+			if test.filter.Contains(element) {
+				falsePositives++
+			}
+		}
+		if rate := float64(falsePositives) / float64(len(absent)); rate > 0.002 {
+			t.Fatalf("%s: false positive rate %.5f is too high", test.name, rate)
+		}
+		if stats := test.filter.Stats(); stats["elements"] != uint(count) || stats["memory_bytes"] == uint(0) {
+			t.Fatalf("%s: unexpected stats %v", test.name, stats)
+		}
+
+		data, err := test.filter.Marshal()
+		if err != nil {
+			t.Fatalf("%s: marshal failed: %v", test.name, err)
+		}
+		if err := test.restored.(interface{ UnmarshalBinary([]byte) error }).UnmarshalBinary(data); err != nil {
+			t.Fatalf("%s: unmarshal failed: %v", test.name, err)
+		}
+		for _, element := range slices.Concat(revoked[:1000], absent[:1000]) {
+			if test.restored.Contains(element) != test.filter.Contains(element) {
+				t.Fatalf("%s: restored filter answers differently for %s", test.name, element)
+			}
+		}
+
+		if test.static {
+			if err := test.filter.Add(absent[0]); !errors.Is(err, ErrFilterStatic) {
+				t.Fatalf("%s: add to a static filter should fail, have %v", test.name, err)
+			}
+			continue
+		}
+		for _, element := range revoked[:count/2] {
+			if err := test.filter.Remove(element); err != nil {
+				t.Fatalf("%s: remove failed: %v", test.name, err)
+			}
+		}
+		for _, element := range revoked[count/2:] {
+			if !test.filter.Contains(element) {
+				t.Fatalf("%s: removal caused a false negative for %s", test.name, element)
+			}
+		}
+		if err := test.filter.Remove([]byte("never-added")); !errors.Is(err, ErrFilterElementNotFound) {
+			t.Fatalf("%s: removing an absent element should fail, have %v", test.name, err)
+		}
+	}
+}
+
+func TestCuckooFilterFull(t *testing.T) {
+	filter := NewCuckooFilter(64)
+	var added [][]byte
+	for i := 0; ; i++ {
+		element := []byte(fmt.Sprint(i))
+		if err := filter.Add(element); errors.Is(err, ErrFilterFull) {
+			break
+		} else if err != nil || i > 1000 {
+			t.Fatalf("filter should eventually be full: %v", err)
+		}
+		added = append(added, element)
+	}
+	// 踢出失败的指纹保存在 victim 中，已加入的元素都不丢失
+	for _, element := range added {
+		if !filter.Contains(element) {
+			t.Fatalf("full filter lost %s", element)
+		}
+	}
+	for _, element := range added[:len(added)/2] {
+		if err := filter.Remove(element); err != nil {
+			t.Fatalf("remove failed: %v", err)
+		}
+	}
+	if err := filter.Add([]byte("after-remove")); err != nil {
+		t.Fatalf("removal should make room, have %v", err)
+	}
+}
+
+func TestBinaryFuseFilterSmallSets(t *testing.T) {
+	for _, count := range []int{0, 1, 2, 3, 10} {
+		elements := filterTestElements("small-", count)
+		filter, err := NewBinaryFuseFilter(slices.Concat(elements, elements))
+		if err != nil {
+			t.Fatalf("build filter of %d elements failed: %v", count, err)
+		}
+		for _, element := range elements {
+			if !filter.Contains(element) {
+				t.Fatalf("false negative in filter of %d elements", count)
+			}
+		}
+		if count == 0 && filter.Contains([]byte("small-0")) {
+			t.Fatalf("empty filter should contain nothing")
+		}
+	}
+}
+
+func TestBinaryFuseFilterMalformedInput(t *testing.T) {
+	filter, err := NewBinaryFuseFilter(filterTestElements("malformed-", 100))
+	if err != nil {
+		t.Fatalf("build filter failed: %v", err)
+	}
+	valid, _ := filter.MarshalBinary()
+	// encode 以给定的段长、段数与指纹数据重新编码，并重新计算校验和
+	encode := func(segmentLength, segmentCount uint32, fingerprints []byte) []byte {
+		data := append([]byte(nil), valid[:28]...)
+		binary.BigEndian.PutUint32(data[12:16], segmentLength)
+		binary.BigEndian.PutUint32(data[16:20], segmentCount)
+		data = append(data, fingerprints...)
+		return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(data))
+	}
+
+	tests := map[string][]byte{
+		"truncated":               valid[:20],
+		"bad checksum":            append(valid[:len(valid)-1:len(valid)-1], valid[len(valid)-1]^1),
+		"segment count overflow":  encode(4, 0xFFFFFFFE, nil),
+		"length overflow":         encode(1<<18, 0xFFFFFFFF, nil),
+		"segment count too large": encode(4, fuseMaxSegmentCount+1, nil),
+		"zero segment count":      encode(4, 0, make([]byte, 16)),
+		"segment length not pow2": encode(6, 1, make([]byte, 36)),
+		"odd fingerprint bytes":   encode(4, 1, make([]byte, 25)),
+		"short fingerprints":      encode(4, 1, make([]byte, 22)),
+	}
+	for name, data := range tests {
+		restored := &BinaryFuseFilter{}
+		if err := restored.UnmarshalBinary(data); !errors.Is(err, ErrInvalidFuseData) {
+			t.Fatalf("%s: should be rejected, have %v", name, err)
+		}
+	}
+
+	// 随机改写头部字段后，解码要么失败，要么得到可以安全查询的过滤器
+	for i := 0; i < 1000; i++ {
+		segmentLength := uint32(1) << (i % 32)
+		segmentCount := uint32(i) * 0x9E3779B1
+		data := encode(segmentLength, segmentCount, valid[28:len(valid)-4])
+		restored := &BinaryFuseFilter{}
+		if restored.UnmarshalBinary(data) == nil {
+			restored.Contains([]byte("malformed-0"))
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package cer_ca_tools
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestRevocationReasonEncoding(t *testing.T) {
+	manager := NewCAManagerWithStore(NewMemoryStore())
+	ca, err := manager.CreateCA("ca_reason_test")
+	if err != nil {
+		t.Fatalf("create CA failed: %v", err)
+	}
+	cert := issueTestCert(t, ca, "anonymous-test")
+	serial := cert.SerialNumber.String()
+
+	for _, reason := range []int{-1, 7, ReasonRemoveFromCRL, 11} {
+		if response := ca.RevokeCertificate(ca.Name.CommonName, serial, reason); !errors.Is(response.Err, ErrInvalidRevocationReason) {
+			t.Fatalf("reason %d should be rejected, have %v", reason, response.Err)
+		}
+	}
+	future := RevocationOptions{InvalidityDate: time.Now().Add(time.Hour)}
+	if response := ca.RevokeCertificateWithOptions(ca.Name.CommonName, serial, ReasonKeyCompromise, future); !errors.Is(response.Err, ErrInvalidityDate) {
+		t.Fatalf("future invalidity date should be rejected, have %v", response.Err)
+	}
+
+	compromised := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	options := RevocationOptions{InvalidityDate: compromised}
+	if response := ca.RevokeCertificateWithOptions(ca.Name.CommonName, serial, ReasonKeyCompromise, options); !response.Success {
+		t.Fatalf("revoke failed: %s", response.Message)
+	}
+	revoked := ca.RevokedCerts[serial]
+	if !bytes.Equal(revoked.Extensions[0].Value, []byte{0x0a, 0x01, ReasonKeyCompromise}) {
+		t.Fatalf("reason code should be a DER ENUMERATED, have %x", revoked.Extensions[0].Value)
+	}
+	if invalidity, ok := RevocationInvalidityDate(revoked); !ok || !invalidity.Equal(compromised) {
+		t.Fatalf("invalidity date should be recorded, have %v", invalidity)
+	}
+
+	crlDER, err := ca.GenerateCRL(time.Now())
+	if err != nil {
+		t.Fatalf("generate CRL failed: %v", err)
+	}
+	crl, _ := x509.ParseRevocationList(crlDER)
+	entry := crl.RevokedCertificateEntries[0]
+	if entry.ReasonCode != ReasonKeyCompromise {
+		t.Fatalf("CRL entry should carry keyCompromise, have %d", entry.ReasonCode)
+	}
+	hasInvalidityDate := false
+	for _, ext := range entry.Extensions {
+		hasInvalidityDate = hasInvalidityDate || ext.Id.Equal(oidExtensionInvalidityDate)
+	}
+	if !hasInvalidityDate {
+		t.Fatalf("CRL entry should carry the invalidity date")
+	}
+}
+
+func TestCertificateHold(t *testing.T) {
+	manager := NewCAManagerWithStore(NewMemoryStore())
+	ca, err := manager.CreateCA("ca_hold_test")
+	if err != nil {
+		t.Fatalf("create CA failed: %v", err)
+	}
+	ca.CRLConfig.EnableDelta = true
+	cert := issueTestCert(t, ca, "anonymous-test")
+	serial := cert.SerialNumber.String()
+	ocspStatus := func() *ocsp.Response {
+		requestDER, _ := ocsp.CreateRequest(cert, ca.Certificate, nil)
+		response, err := ocsp.ParseResponseForCert(manager.OCSP.Respond(requestDER), cert, ca.Certificate)
+		if err != nil {
+			t.Fatalf("parse OCSP response failed: %v", err)
+		}
+		return response
+	}
+
+	if response := ca.ReleaseCertificateHold(ca.Name.CommonName, serial); !errors.Is(response.Err, ErrCertNotOnHold) {
+		t.Fatalf("releasing a valid certificate should fail, have %v", response.Err)
+	}
+	if response := ca.RevokeCertificate(ca.Name.CommonName, serial, ReasonCertificateHold); !response.Success {
+		t.Fatalf("hold failed: %s", response.Message)
+	}
+	if status := ocspStatus(); status.Status != ocsp.Revoked || status.RevocationReason != ocsp.CertificateHold {
+		t.Fatalf("held certificate should be revoked with certificateHold, have %d %d", status.Status, status.RevocationReason)
+	}
+	if response := ca.RevokeCertificate(ca.Name.CommonName, serial, ReasonCertificateHold); !errors.Is(response.Err, ErrCertAlreadyRevoked) {
+		t.Fatalf("holding twice should fail, have %v", response.Err)
+	}
+	if _, err := ca.CurrentCRL(false); err != nil {
+		t.Fatalf("generate base CRL failed: %v", err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if response := ca.ReleaseCertificateHold(ca.Name.CommonName, serial); !response.Success {
+		t.Fatalf("release hold failed: %s", response.Message)
+	}
+	if revoked, _ := ca.RevocationStatus(serial); revoked {
+		t.Fatalf("released certificate should not be revoked")
+	}
+	if status := ocspStatus(); status.Status != ocsp.Good {
+		t.Fatalf("released certificate should be good, have %d", status.Status)
+	}
+	deltaDER, err := ca.CurrentCRL(true)
+	if err != nil {
+		t.Fatalf("generate delta CRL failed: %v", err)
+	}
+	delta, _ := x509.ParseRevocationList(deltaDER)
+	if len(delta.RevokedCertificateEntries) != 1 || delta.RevokedCertificateEntries[0].ReasonCode != ReasonRemoveFromCRL {
+		t.Fatalf("delta CRL should list the released certificate with removeFromCRL: %+v", delta.RevokedCertificateEntries)
+	}
+	fullDER, _ := ca.GenerateCRL(time.Now())
+	if full, _ := x509.ParseRevocationList(fullDER); len(full.RevokedCertificateEntries) != 0 {
+		t.Fatalf("full CRL should not list the released certificate")
+	}
+
+	// 暂停后可改为永久撤销，永久撤销后不能解除暂停
+	ca.RevokeCertificate(ca.Name.CommonName, serial, ReasonCertificateHold)
+	if response := ca.RevokeCertificate(ca.Name.CommonName, serial, ReasonKeyCompromise); !response.Success {
+		t.Fatalf("revoking a held certificate failed: %s", response.Message)
+	}
+	if status := ocspStatus(); status.Status != ocsp.Revoked || status.RevocationReason != ocsp.KeyCompromise {
+		t.Fatalf("OCSP should report keyCompromise after the hold became permanent, have %d", status.RevocationReason)
+	}
+	if response := ca.ReleaseCertificateHold(ca.Name.CommonName, serial); !errors.Is(response.Err, ErrCertNotOnHold) {
+		t.Fatalf("releasing a revoked certificate should fail, have %v", response.Err)
+	}
+	if response := ca.RevokeCertificate(ca.Name.CommonName, serial, ReasonCertificateHold); !errors.Is(response.Err, ErrCertAlreadyRevoked) {
+		t.Fatalf("holding a revoked certificate should fail, have %v", response.Err)
+	}
+}
+
+func TestHoldHTTPTransitions(t *testing.T) {
+	manager := NewCAManagerWithStore(NewMemoryStore())
+	ca, err := manager.CreateCA("ca_hold_http_test")
+	if err != nil {
+		t.Fatalf("create CA failed: %v", err)
+	}
+	server := httptest.NewServer(manager.Handler())
+	defer server.Close()
+	serial := issueTestCert(t, ca, "anonymous-test").SerialNumber.String()
+
+	post := func(action string, reason int) (int, string) {
+		body, _ := json.Marshal(HTTPCertRevokeRequest{SerialNumber: serial, Reason: reason})
+		response, err := http.Post(server.URL+APIVersionPrefix+"/certificate/"+action+"?caName=ca_hold_http_test", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST %s failed: %v", action, err)
+		}
+		defer response.Body.Close()
+		var envelope APIErrorResponse
+		json.NewDecoder(response.Body).Decode(&envelope)
+		if envelope.Error != nil {
+			return response.StatusCode, envelope.Error.Code
+		}
+		return response.StatusCode, ""
+	}
+
+	for _, step := range []struct {
+		action string
+		reason int
+		status int
+		code   string
+	}{
+		{"unhold", 0, http.StatusConflict, APIErrorConflict},
+		{"revoke", 7, http.StatusBadRequest, APIErrorBadRequest},
+		{"hold", 0, http.StatusOK, ""},
+		{"hold", 0, http.StatusConflict, APIErrorConflict},
+		{"unhold", 0, http.StatusOK, ""},
+		{"revoke", ReasonCertificateHold, http.StatusOK, ""},
+		{"revoke", ReasonCessationOfOperation, http.StatusOK, ""},
+		{"unhold", 0, http.StatusConflict, APIErrorConflict},
+		{"revoke", ReasonKeyCompromise, http.StatusConflict, APIErrorConflict},
+	} {
+		if status, code := post(step.action, step.reason); status != step.status || code != step.code {
+			t.Fatalf("%s (reason %d): expected %d %q, have %d %q", step.action, step.reason, step.status, step.code, status, code)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package cer_ca_tools
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// DefaultRolloverOverlap 密钥轮换后新旧CA证书同时有效的默认过渡期
+const DefaultRolloverOverlap = 30 * 24 * time.Hour
+
+// ErrUnknownCAKey 请求的CA密钥标识不属于该CA或已停止使用
+var ErrUnknownCAKey = errors.New("unknown or retired CA key")
+
+// RetiredCAKey 密钥轮换后保留的旧CA证书与私钥
+//
+// 过渡期（OverlapUntil）内新旧CA证书及两张交叉证书都会出现在信任包中，任一CA证书都能验证证书链；
+// 旧私钥不再签发证书，但会继续签名旧证书的CRL与OCSP响应，直到它签发的最后一张证书过期（RetireAt）。
+type RetiredCAKey struct {
+	Certificate    *x509.Certificate
+	PrivateKey     crypto.Signer
+	CrossSignedOld *x509.Certificate // 新密钥签发的旧CA证书，只信任新根的验证者可验证旧证书；中间CA轮换时为 nil
+	CrossSignedNew *x509.Certificate // 旧密钥签发的新CA证书，只信任旧根的验证者可验证新证书；中间CA轮换时为 nil
+	OverlapUntil   time.Time
+	RetireAt       time.Time
+	crl            *cachedCRL
+}
+
+// KeyID 返回旧CA证书的主体密钥标识（十六进制），用于CRL分发点
+func (retired *RetiredCAKey) KeyID() string {
+	return hex.EncodeToString(retired.Certificate.SubjectKeyId)
+}
+
+// InOverlap 新旧CA证书是否仍同时有效
+func (retired *RetiredCAKey) InOverlap(now time.Time) bool {
+	return now.Before(retired.OverlapUntil)
+}
+
+// Active 旧私钥是否仍需签名CRL与OCSP响应
+func (retired *RetiredCAKey) Active(now time.Time) bool {
+	return now.Before(retired.RetireAt)
+}
+
+// RolloverCA 为CA生成新密钥与新CA证书，此后签发改用新密钥
+//
+// 根CA轮换时新旧证书互相交叉签名；中间CA轮换时新证书由父CA签发，新旧证书都能链到同一根CA。
+// overlap 为 0 时使用 DefaultRolloverOverlap。
+func (manager *CAManager) RolloverCA(caName string, overlap time.Duration) (*CA, error) {
+	ca, exists := manager.GetCAInfo(caName)
+	if !exists {
+		return nil, fmt.Errorf("CA %s not found", caName)
+	}
+	if ca.IsOffline() {
+		return nil, fmt.Errorf("CA %s is offline", caName)
+	}
+	if overlap <= 0 {
+		overlap = DefaultRolloverOverlap
+	}
+
+	profileName := ProfileRootCA
+	if ca.Parent != nil {
+		profileName = ProfileIntermediateCA
+	}
+	manager.mutex.RLock()
+	profile, err := manager.Profiles.Get(profileName)
+	manager.mutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	newSK, err := manager.KeyGenerator(fmt.Sprintf("%s-%d", caName, now.Unix()), ca.KeyAlgorithm())
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key for CA %s: %w", caName, err)
+	}
+
+	// 等待进行中的签发完成，轮换期间不再签发
+	ca.issuing.Lock()
+	defer ca.issuing.Unlock()
+	ca.Mutex.Lock()
+	defer ca.Mutex.Unlock()
+
+	oldCert, oldSK := ca.Certificate, ca.PrivateKey
+	request := &IssuanceRequest{Subject: oldCert.Subject, PublicKey: newSK.Public()}
+	template, err := profile.NewTemplate(request, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build CA certificate: %w", err)
+	}
+
+	// 根CA自签名，中间CA由父CA签发且不超过父CA证书有效期。重新签发期间持有父CA的签发读锁，
+	// 父CA证书与私钥在同一临界区内读取，父CA不会同时轮换
+	issuerCert, issuerSK := template, newSK
+	if parent := ca.Parent; parent != nil {
+		parent.issuing.RLock()
+		defer parent.issuing.RUnlock()
+		parent.Mutex.Lock()
+		issuerCert, issuerSK = parent.Certificate, parent.PrivateKey
+		parent.Mutex.Unlock()
+		if issuerSK == nil {
+			return nil, fmt.Errorf("parent CA %s is offline", parent.Name.CommonName)
+		}
+		if template.NotAfter.After(issuerCert.NotAfter) {
+			template.NotAfter = issuerCert.NotAfter
+		}
+	}
+	newCert, err := createCACertificate(template, issuerCert, newSK, issuerSK)
+	if err != nil {
+		return nil, err
+	}
+
+	retired := &RetiredCAKey{
+		Certificate:  oldCert,
+		PrivateKey:   oldSK,
+		OverlapUntil: now.Add(overlap),
+		RetireAt:     now.Add(overlap),
+	}
+	if retired.OverlapUntil.After(oldCert.NotAfter) {
+		retired.OverlapUntil = oldCert.NotAfter
+	}
+	// 旧私钥签发的最后一张终端证书过期前，继续用旧私钥签名CRL/OCSP
+	for _, issued := range ca.IssuedCerts {
+		if issuedByKey(issued, oldCert) && issued.NotAfter.After(retired.RetireAt) {
+			retired.RetireAt = issued.NotAfter
+		}
+	}
+
+	if ca.Parent == nil {
+		if retired.CrossSignedNew, retired.CrossSignedOld, err = crossSign(profile, oldCert, oldSK, newCert, newSK, retired.OverlapUntil, now); err != nil {
+			return nil, err
+		}
+	}
+
+	// 轮换记录由新密钥签名
+	if err := ca.recordAuditWithKey(newCert, newSK, AuditEventRollover, newCert.SerialNumber.String(), map[string]string{
+		"previous_key":  retired.KeyID(),
+		"overlap_until": retired.OverlapUntil.UTC().Format(time.RFC3339),
+	}); err != nil {
+		return nil, fmt.Errorf("failed to record rollover of CA %s in audit log: %w", caName, err)
+	}
+
+	// 先保存旧密钥再覆盖当前CA，进程中断时不会丢失旧私钥
+	if ca.store != nil {
+		if err := ca.store.SaveRetiredKey(caName, retired); err != nil {
+			return nil, fmt.Errorf("failed to save retired key of CA %s: %w", caName, err)
+		}
+	}
+	ca.RetiredKeys = append(ca.RetiredKeys, retired)
+	ca.Certificate = newCert
+	ca.CertificatePEM = newCert.Raw
+	ca.PrivateKey = newSK
+	ca.PublicKey = newCert.PublicKey
+	ca.fullCRL, ca.deltaCRL = nil, nil
+	if ca.store != nil {
+		if err := ca.store.SaveCA(ca); err != nil {
+			return nil, fmt.Errorf("failed to save CA %s: %w", caName, err)
+		}
+	}
+
+	manager.OCSP.mutex.Lock()
+	manager.OCSP.dropCacheLocked(caName)
+	// 委托OCSP签名证书由旧密钥签发，轮换后需重新配置
+	delete(manager.OCSP.signers, caName)
+	manager.OCSP.mutex.Unlock()
+
+	log.Printf("CA %s rolled over to key %s, overlap until %s, old key retires at %s",
+		caName, hex.EncodeToString(newCert.SubjectKeyId), retired.OverlapUntil.Format(time.RFC3339), retired.RetireAt.Format(time.RFC3339))
+	return ca, nil
+}
+
+// crossSign 生成两张交叉证书：旧密钥签发的新CA证书与新密钥签发的旧CA证书，有效期截止到过渡期结束
+func crossSign(profile *CertProfile, oldCert *x509.Certificate, oldSK crypto.Signer, newCert *x509.Certificate, newSK crypto.Signer, notAfter time.Time, now time.Time) (*x509.Certificate, *x509.Certificate, error) {
+	newTemplate, err := profile.NewTemplate(&IssuanceRequest{Subject: newCert.Subject, PublicKey: newSK.Public()}, now)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build cross certificate: %w", err)
+	}
+	newTemplate.SubjectKeyId = newCert.SubjectKeyId
+	newTemplate.AuthorityKeyId = oldCert.SubjectKeyId
+	newTemplate.NotAfter = notAfter
+	crossSignedNew, err := createCACertificate(newTemplate, oldCert, newSK, oldSK)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	oldTemplate, err := profile.NewTemplate(&IssuanceRequest{Subject: oldCert.Subject, PublicKey: oldSK.Public()}, now)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build cross certificate: %w", err)
+	}
+	oldTemplate.SubjectKeyId = oldCert.SubjectKeyId
+	oldTemplate.AuthorityKeyId = newCert.SubjectKeyId
+	oldTemplate.NotAfter = notAfter
+	crossSignedOld, err := createCACertificate(oldTemplate, newCert, oldSK, newSK)
+	if err != nil {
+		return nil, nil, err
+	}
+	return crossSignedNew, crossSignedOld, nil
+}
+
+func createCACertificate(template, issuer *x509.Certificate, subjectSK, issuerSK crypto.Signer) (*x509.Certificate, error) {
+	certDER, err := CreateCertificate(template, issuer, subjectSK.Public(), issuerSK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CA certificate: %w", err)
+	}
+	cert, err := ParseCertificate(certDER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+	return cert, nil
+}
+
+// issuedByKey 证书是否由 issuer 的密钥签发，优先比较密钥标识
+func issuedByKey(cert *x509.Certificate, issuer *x509.Certificate) bool {
+	if len(cert.AuthorityKeyId) > 0 && len(issuer.SubjectKeyId) > 0 {
+		return bytes.Equal(cert.AuthorityKeyId, issuer.SubjectKeyId)
+	}
+	return checkSignatureFrom(cert, issuer) == nil
+}
+
+// issuerCertificate 返回签发 cert 的本CA证书（当前或已轮换的），找不到时返回当前证书
+func (ca *CA) issuerCertificate(cert *x509.Certificate) *x509.Certificate {
+	if issuedByKey(cert, ca.Certificate) {
+		return ca.Certificate
+	}
+	for _, retired := range ca.RetiredKeys {
+		if issuedByKey(cert, retired.Certificate) {
+			return retired.Certificate
+		}
+	}
+	return ca.Certificate
+}
+
+// retiredKey 按主体密钥标识（十六进制）查找仍在使用的旧密钥
+func (ca *CA) retiredKey(keyID string, now time.Time) *RetiredCAKey {
+	for _, retired := range ca.RetiredKeys {
+		if retired.KeyID() == keyID && retired.Active(now) {
+			return retired
+		}
+	}
+	return nil
+}
+
+// TrustBundle 返回验证者应信任的CA证书：当前CA证书，以及过渡期内的旧CA证书与交叉证书
+func (ca *CA) TrustBundle(now time.Time) []*x509.Certificate {
+	ca.Mutex.Lock()
+	defer ca.Mutex.Unlock()
+
+	bundle := []*x509.Certificate{ca.Certificate}
+	for _, retired := range ca.RetiredKeys {
+		if !retired.InOverlap(now) {
+			continue
+		}
+		bundle = append(bundle, retired.Certificate)
+		if retired.CrossSignedNew != nil {
+			bundle = append(bundle, retired.CrossSignedNew, retired.CrossSignedOld)
+		}
+	}
+	return bundle
+}
+
+// TrustBundlePEM 将 TrustBundle 编码为PEM证书包
+func (ca *CA) TrustBundlePEM(now time.Time) []byte {
+	var bundle []byte
+	for _, cert := range ca.TrustBundle(now) {
+		bundle = append(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	return bundle
+}
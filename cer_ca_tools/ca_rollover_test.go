@@ -0,0 +1,100 @@
+package cer_ca_tools
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestRolloverCA(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+	manager := NewCAManagerWithStore(store)
+	ca, err := manager.CreateCA("ca_rollover_test")
+	if err != nil {
+		t.Fatalf("create CA failed: %v", err)
+	}
+	oldCert := ca.Certificate
+	oldLeaf := issueTestCert(t, ca, "old-leaf")
+
+	if _, err := manager.RolloverCA("ca_rollover_test", time.Hour); err != nil {
+		t.Fatalf("rollover failed: %v", err)
+	}
+	newCert := ca.Certificate
+	if bytes.Equal(newCert.SubjectKeyId, oldCert.SubjectKeyId) {
+		t.Fatalf("rollover should replace the CA key")
+	}
+	newLeaf := issueTestCert(t, ca, "new-leaf")
+	if err := newLeaf.CheckSignatureFrom(newCert); err != nil {
+		t.Fatalf("new issuance should use the new key: %v", err)
+	}
+	if !strings.Contains(newLeaf.CRLDistributionPoints[0], "key="+hex.EncodeToString(newCert.SubjectKeyId)) {
+		t.Fatalf("new leaf CRL distribution point should name the new key: %s", newLeaf.CRLDistributionPoints[0])
+	}
+
+	retired := ca.RetiredKeys[0]
+	if !retired.RetireAt.Equal(oldLeaf.NotAfter) {
+		t.Fatalf("old key should stay active until its last leaf expires, retires at %s", retired.RetireAt)
+	}
+	if len(ca.TrustBundle(time.Now())) != 4 || len(ca.TrustBundle(retired.OverlapUntil)) != 1 {
+		t.Fatalf("trust bundle should carry both CA certificates and cross certificates only during the overlap")
+	}
+
+	// 只信任任一CA证书的验证者都能通过交叉证书验证新旧证书
+	usages := []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	cross := []*x509.Certificate{retired.CrossSignedOld, retired.CrossSignedNew}
+	for _, root := range []*x509.Certificate{oldCert, newCert} {
+		for _, leaf := range []*x509.Certificate{oldLeaf, newLeaf} {
+			if _, err := VerifyCertChain(leaf, cross, []*x509.Certificate{root}, usages); err != nil {
+				t.Fatalf("%s should verify against root %x: %v", leaf.Subject.CommonName, root.SubjectKeyId, err)
+			}
+		}
+	}
+
+	// 旧证书的CRL与OCSP响应仍由旧密钥签名
+	if response := ca.RevokeCertificate(ca.Name.CommonName, oldLeaf.SerialNumber.String(), ocsp.KeyCompromise); !response.Success {
+		t.Fatalf("revoke failed: %s", response.Message)
+	}
+	crlDER, err := ca.CRLForKey("", false)
+	if err != nil {
+		t.Fatalf("get CRL for original key failed: %v", err)
+	}
+	crl, _ := x509.ParseRevocationList(crlDER)
+	if err := crl.CheckSignatureFrom(oldCert); err != nil || len(crl.RevokedCertificateEntries) != 1 {
+		t.Fatalf("original distribution point should serve a CRL signed by the old key: %v", err)
+	}
+	crlDER, err = ca.CRLForKey(hex.EncodeToString(newCert.SubjectKeyId), false)
+	if err != nil {
+		t.Fatalf("get CRL for new key failed: %v", err)
+	}
+	crl, _ = x509.ParseRevocationList(crlDER)
+	if err := crl.CheckSignatureFrom(newCert); err != nil {
+		t.Fatalf("CRL for the new key should be signed by the new key: %v", err)
+	}
+	if _, err := ca.CRLForKey("00", false); !errors.Is(err, ErrUnknownCAKey) {
+		t.Fatalf("unknown key should be rejected, have %v", err)
+	}
+
+	// 重启后恢复旧密钥，继续响应旧证书的OCSP请求
+	restarted := NewCAManagerWithStore(store)
+	if err := restarted.LoadState(); err != nil {
+		t.Fatalf("load state failed: %v", err)
+	}
+	requestDER, _ := ocsp.CreateRequest(oldLeaf, oldCert, nil)
+	response, err := ocsp.ParseResponseForCert(restarted.OCSP.Respond(requestDER), oldLeaf, oldCert)
+	if err != nil {
+		t.Fatalf("parse OCSP response for old leaf failed: %v", err)
+	}
+	if response.Status != ocsp.Revoked {
+		t.Fatalf("old leaf should be reported revoked, have %d", response.Status)
+	}
+	requestDER, _ = ocsp.CreateRequest(newLeaf, newCert, nil)
+	if response, err = ocsp.ParseResponseForCert(restarted.OCSP.Respond(requestDER), newLeaf, newCert); err != nil || response.Status != ocsp.Good {
+		t.Fatalf("new leaf should be reported good: %v", err)
+	}
+}
@@ -0,0 +1,338 @@
+package cer_ca_tools
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIVersionPrefix 版本化HTTP接口的路径前缀，未带前缀的旧路径作为别名保留
+const APIVersionPrefix = "/v1"
+
+// maxRequestBodyBytes JSON请求体大小上限，足以容纳 MaxBatchIssueSize 项的批量签发请求
+const maxRequestBodyBytes = 8 << 20
+
+// API错误码
+const (
+	APIErrorBadRequest       = "bad_request"
+	APIErrorNotFound         = "not_found"
+	APIErrorMethodNotAllowed = "method_not_allowed"
+	APIErrorCSRRejected      = "csr_rejected"
+	APIErrorRenewalRejected  = "renewal_rejected"
+	APIErrorXORVerification  = "xor_verification_failed"
+	APIErrorEnrollment       = "enrollment_inactive"
+	APIErrorConflict         = "conflict"
+	APIErrorTooLarge         = "request_too_large"
+	APIErrorTimeout          = "timeout"
+	APIErrorInternal         = "internal_error"
+)
+
+// APIError HTTP接口统一的错误结构，以 {"error": {...}} 形式返回
+type APIError struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+func (apiError *APIError) Error() string {
+	return fmt.Sprintf("%s: %s", apiError.Code, apiError.Message)
+}
+
+// APIErrorResponse 错误响应体
+type APIErrorResponse struct {
+	Error *APIError `json:"error"`
+}
+
+func writeAPIJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("写入响应失败: %v", err)
+	}
+}
+
+// writeFilterResponse 写入撤销过滤器快照或增量，If-None-Match 与 ETag 匹配时返回 304
+func writeFilterResponse(w http.ResponseWriter, r *http.Request, tag string, body []byte) {
+	etag := strconv.Quote(tag)
+	w.Header().Set("ETag", etag)
+	for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, code string, message string, details map[string]interface{}) {
+	writeAPIJSON(w, status, APIErrorResponse{Error: &APIError{Code: code, Message: message, Details: details}})
+}
+
+// writeErrorResponse 按错误类型选择状态码与错误码
+func writeErrorResponse(w http.ResponseWriter, err error) {
+	status, code := apiErrorStatus(err)
+	if status == http.StatusInternalServerError {
+		log.Printf("HTTP请求处理失败: %v", err)
+	}
+	writeAPIError(w, status, code, err.Error(), nil)
+}
+
+func apiErrorStatus(err error) (int, string) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, ErrCANotFound), errors.Is(err, ErrCertNotFound), errors.Is(err, ErrUnknownCAKey),
+		errors.Is(err, ErrEnrollmentNotFound), errors.Is(err, ErrFilterDeltaUnavailable):
+		return http.StatusNotFound, APIErrorNotFound
+	case errors.Is(err, ErrEnrollmentRevoked), errors.Is(err, ErrEnrollmentExpired):
+		return http.StatusForbidden, APIErrorEnrollment
+	case errors.Is(err, ErrCertAlreadyRevoked), errors.Is(err, ErrCertNotOnHold), errors.Is(err, ErrCAOffline),
+		errors.Is(err, ErrFilterVersionAhead):
+		return http.StatusConflict, APIErrorConflict
+	case errors.Is(err, ErrBatchTooLarge), errors.As(err, &maxBytesErr):
+		return http.StatusRequestEntityTooLarge, APIErrorTooLarge
+	case errors.Is(err, ErrInvalidRevocationReason), errors.Is(err, ErrInvalidityDate):
+		return http.StatusBadRequest, APIErrorBadRequest
+	case errors.Is(err, ErrXORVerification):
+		return http.StatusBadRequest, APIErrorXORVerification
+	case isCSRRejection(err):
+		return http.StatusBadRequest, APIErrorCSRRejected
+	case isRenewalRejection(err):
+		return http.StatusBadRequest, APIErrorRenewalRejected
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable, APIErrorTimeout
+	}
+	return http.StatusInternalServerError, APIErrorInternal
+}
+
+// writeCertificateResponse 成功时返回签发结果，失败时返回错误结构
+func writeCertificateResponse(w http.ResponseWriter, response CertificateResponse) {
+	if response.Success {
+		writeAPIJSON(w, http.StatusOK, response)
+		return
+	}
+	err := response.Err
+	if err == nil {
+		err = errors.New(response.Message)
+	}
+	writeErrorResponse(w, err)
+}
+
+// requireMethod 请求方法不匹配时返回 405
+func requireMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeAPIError(w, http.StatusMethodNotAllowed, APIErrorMethodNotAllowed,
+		fmt.Sprintf("method %s not allowed", r.Method), map[string]interface{}{"allowed": []string{method}})
+	return false
+}
+
+// decodeJSONBody 限制请求体大小并解析JSON，失败时已写入错误响应
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, body interface{}) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeErrorResponse(w, err)
+			return false
+		}
+		writeAPIError(w, http.StatusBadRequest, APIErrorBadRequest, fmt.Sprintf("invalid JSON body: %v", err), nil)
+		return false
+	}
+	return true
+}
+
+// requireCA 按 caName 查询参数查找CA，失败时已写入错误响应
+func (manager *CAManager) requireCA(w http.ResponseWriter, r *http.Request) (*CA, bool) {
+	caName := r.URL.Query().Get("caName")
+	if caName == "" {
+		writeAPIError(w, http.StatusBadRequest, APIErrorBadRequest, "caName is required",
+			map[string]interface{}{"param": "caName"})
+		return nil, false
+	}
+	ca, exists := manager.GetCAInfo(caName)
+	if !exists {
+		writeErrorResponse(w, fmt.Errorf("%w: %s", ErrCANotFound, caName))
+		return nil, false
+	}
+	return ca, true
+}
+
+// revocationTarget 解析撤销类请求并确认证书由 caName 指定的CA签发，失败时已写入错误响应
+func (manager *CAManager) revocationTarget(w http.ResponseWriter, r *http.Request) (*HTTPCertRevokeRequest, *CA, *CA, bool) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return nil, nil, nil, false
+	}
+	var request HTTPCertRevokeRequest
+	if !decodeJSONBody(w, r, &request) {
+		return nil, nil, nil, false
+	}
+	ca, ok := manager.requireCA(w, r)
+	if !ok {
+		return nil, nil, nil, false
+	}
+
+	issuerCA, _, err := manager.FindCertIssuer(request.SerialNumber)
+	if err == nil && issuerCA != ca {
+		err = fmt.Errorf("%w: %s was not issued by CA %s", ErrCertNotFound, request.SerialNumber, ca.Name.CommonName)
+	}
+	if err != nil {
+		writeErrorResponse(w, err)
+		return nil, nil, nil, false
+	}
+	return &request, ca, issuerCA, true
+}
+
+// withRequestTimeout 为每个请求设置处理时限，处理函数通过 r.Context() 感知超时
+func withRequestTimeout(handler http.Handler, timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ServerConfig CA HTTP服务配置，零值字段使用默认值
+type ServerConfig struct {
+	Addr              string        // 监听地址，默认 ":8080"
+	TLSConfig         *tls.Config   // 非 nil 时以 HTTPS 提供服务
+	CertFile          string        // TLS证书文件，TLSConfig 中已包含证书时可为空
+	KeyFile           string        // TLS私钥文件
+	RequestTimeout    time.Duration // 单个请求的处理时限，默认 30s
+	ReadHeaderTimeout time.Duration // 默认 5s
+	ReadTimeout       time.Duration // 默认 30s
+	WriteTimeout      time.Duration // 默认 RequestTimeout + 10s
+	IdleTimeout       time.Duration // 默认 120s
+	ShutdownTimeout   time.Duration // 优雅关闭时等待进行中请求的时限，默认 10s
+}
+
+func (config *ServerConfig) applyDefaults() {
+	if config.Addr == "" {
+		config.Addr = ":8080"
+	}
+	if config.RequestTimeout <= 0 {
+		config.RequestTimeout = 30 * time.Second
+	}
+	if config.ReadHeaderTimeout <= 0 {
+		config.ReadHeaderTimeout = 5 * time.Second
+	}
+	if config.ReadTimeout <= 0 {
+		config.ReadTimeout = 30 * time.Second
+	}
+	if config.WriteTimeout <= 0 {
+		config.WriteTimeout = config.RequestTimeout + 10*time.Second
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = 120 * time.Second
+	}
+	if config.ShutdownTimeout <= 0 {
+		config.ShutdownTimeout = 10 * time.Second
+	}
+}
+
+// Server 独立的CA HTTP服务，不使用 http.DefaultServeMux，可与SDK及其他服务在同一进程中运行
+type Server struct {
+	Manager *CAManager
+	config  ServerConfig
+	mux     *http.ServeMux
+	server  *http.Server
+}
+
+// NewServer 创建CA HTTP服务，CA接口挂载在 / 下（/v1 及旧路径别名）
+func NewServer(manager *CAManager, config ServerConfig) *Server {
+	config.applyDefaults()
+	mux := http.NewServeMux()
+	mux.Handle("/", manager.Handler())
+
+	server := &Server{
+		Manager: manager,
+		config:  config,
+		mux:     mux,
+	}
+	server.server = &http.Server{
+		Addr:              config.Addr,
+		Handler:           withRequestTimeout(mux, config.RequestTimeout),
+		TLSConfig:         config.TLSConfig,
+		ReadHeaderTimeout: config.ReadHeaderTimeout,
+		ReadTimeout:       config.ReadTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+	}
+	return server
+}
+
+// Handle 挂载其他处理器，例如 ACME 服务
+func (server *Server) Handle(pattern string, handler http.Handler) {
+	server.mux.Handle(pattern, handler)
+}
+
+// Handler 返回带请求时限的完整处理器，用于嵌入其他服务
+func (server *Server) Handler() http.Handler {
+	return server.server.Handler
+}
+
+// ListenAndServe 监听配置的地址并提供服务，ctx 取消后优雅关闭
+func (server *Server) ListenAndServe(ctx context.Context) error {
+	listener, err := net.Listen("tcp", server.config.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", server.config.Addr, err)
+	}
+	return server.Serve(ctx, listener)
+}
+
+// Serve 在给定监听器上提供服务，直到 ctx 取消或服务出错；ctx 取消时等待进行中的请求完成后返回 nil
+func (server *Server) Serve(ctx context.Context, listener net.Listener) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		if server.useTLS() {
+			serveErr <- server.server.ServeTLS(listener, server.config.CertFile, server.config.KeyFile)
+		} else {
+			serveErr <- server.server.Serve(listener)
+		}
+	}()
+
+	select {
+	case err := <-serveErr:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), server.config.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Shutdown 停止接受新连接并等待进行中的请求完成
+func (server *Server) Shutdown(ctx context.Context) error {
+	if err := server.server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to shut down CA server: %w", err)
+	}
+	return nil
+}
+
+func (server *Server) useTLS() bool {
+	return server.config.TLSConfig != nil || server.config.CertFile != ""
+}
+
+// 旧路径前缀，SetupHTTPHandlers 将其注册到 http.DefaultServeMux
+var legacyAPIPrefixes = []string{APIVersionPrefix + "/", "/certificate/", "/ct/"}
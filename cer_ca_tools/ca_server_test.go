@@ -0,0 +1,148 @@
+package cer_ca_tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPHandler(t *testing.T) {
+	manager := NewCAManagerWithStore(NewMemoryStore())
+	if _, err := manager.CreateCA("ca_server_test"); err != nil {
+		t.Fatalf("create CA failed: %v", err)
+	}
+	server := httptest.NewServer(manager.Handler())
+	defer server.Close()
+
+	call := func(method, path string, body interface{}, out interface{}) int {
+		var reader bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&reader).Encode(body)
+		}
+		request, _ := http.NewRequest(method, server.URL+path, &reader)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		defer response.Body.Close()
+		if out != nil {
+			json.NewDecoder(response.Body).Decode(out)
+		}
+		return response.StatusCode
+	}
+	expectError := func(method, path string, body interface{}, status int, code string) {
+		var envelope APIErrorResponse
+		if have := call(method, path, body, &envelope); have != status || envelope.Error == nil || envelope.Error.Code != code {
+			t.Fatalf("%s %s: expected %d %s, have %d %+v", method, path, status, code, have, envelope.Error)
+		}
+	}
+
+	subject := pkix.Name{CommonName: "http subject"}
+	subjectJSON, _ := json.Marshal(subject)
+	remainder := big.NewInt(12345)
+	xorResult := append([]byte{}, subjectJSON...)
+	for i := range xorResult {
+		xorResult[i] ^= remainder.Bytes()[i%len(remainder.Bytes())]
+	}
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	csrDER, _ := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{}, key)
+	issueRequest := func() BatchIssueItem {
+		return BatchIssueItem{SubjectInfo: subject, CSR: csrDER, XORResult: append([]byte{}, xorResult...), Remainders: []*big.Int{remainder}}
+	}
+
+	// /v1 与旧路径共用同一处理器
+	var issued CertificateResponse
+	for _, path := range []string{"/v1/certificate/issue?caName=ca_server_test", "/certificate/issue?caName=ca_server_test"} {
+		if status := call(http.MethodPost, path, issueRequest(), &issued); status != http.StatusOK || !issued.Success {
+			t.Fatalf("POST %s failed: %d %s", path, status, issued.Message)
+		}
+	}
+	cert := parseTestCertificate(t, issued.Certificate)
+	if cert.CRLDistributionPoints[0] != manager.BaseURL+"/v1/certificate/crl?caName=ca_server_test" {
+		t.Fatalf("unexpected CRL distribution point %s", cert.CRLDistributionPoints[0])
+	}
+
+	expectError(http.MethodGet, "/v1/certificate/issue?caName=ca_server_test", nil, http.StatusMethodNotAllowed, APIErrorMethodNotAllowed)
+	expectError(http.MethodPost, "/v1/certificate/issue", issueRequest(), http.StatusBadRequest, APIErrorBadRequest)
+	expectError(http.MethodPost, "/v1/certificate/issue?caName=missing", issueRequest(), http.StatusNotFound, APIErrorNotFound)
+	tampered := issueRequest()
+	tampered.XORResult[0] ^= 0xff
+	expectError(http.MethodPost, "/v1/certificate/issue?caName=ca_server_test", tampered, http.StatusBadRequest, APIErrorXORVerification)
+	expectError(http.MethodGet, "/v1/no/such/endpoint", nil, http.StatusNotFound, APIErrorNotFound)
+
+	// 撤销未知序列号返回 404 而不是空指针，重复撤销返回 409
+	revoke := HTTPCertRevokeRequest{SerialNumber: "1", Reason: 1}
+	expectError(http.MethodPost, "/v1/certificate/revoke?caName=ca_server_test", revoke, http.StatusNotFound, APIErrorNotFound)
+	revoke.SerialNumber = cert.SerialNumber.String()
+	if status := call(http.MethodPost, "/v1/certificate/revoke?caName=ca_server_test", revoke, nil); status != http.StatusOK {
+		t.Fatalf("revoke failed with status %d", status)
+	}
+	expectError(http.MethodPost, "/v1/certificate/revoke?caName=ca_server_test", revoke, http.StatusConflict, APIErrorConflict)
+
+	// 撤销登记后重签返回 403
+	enrollment := manager.Enroll("ca_server_test", subject, mustParseCSR(t, csrDER))
+	if err := manager.RevokeEnrollment(enrollment.EnrollmentID); err != nil {
+		t.Fatalf("revoke enrollment failed: %v", err)
+	}
+	reissue := &ReissueRequest{EnrollmentID: enrollment.EnrollmentID}
+	reissue.Sign(key)
+	expectError(http.MethodPost, "/v1/certificate/reissue", reissue, http.StatusForbidden, APIErrorEnrollment)
+}
+
+func TestServerGracefulShutdown(t *testing.T) {
+	manager := NewCAManagerWithStore(NewMemoryStore())
+	server := NewServer(manager, ServerConfig{ShutdownTimeout: time.Second})
+	slow := make(chan struct{})
+	server.Handle("/slow", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(slow)
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("done"))
+	}))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(ctx, listener) }()
+
+	// 关闭时等待进行中的请求完成
+	responded := make(chan int, 1)
+	go func() {
+		response, err := http.Get("http://" + listener.Addr().String() + "/slow")
+		if err != nil {
+			responded <- 0
+			return
+		}
+		response.Body.Close()
+		responded <- response.StatusCode
+	}()
+	<-slow
+	cancel()
+	if status := <-responded; status != http.StatusOK {
+		t.Fatalf("in-flight request should complete during shutdown, have status %d", status)
+	}
+	if err := <-served; err != nil {
+		t.Fatalf("serve should return nil after graceful shutdown, have %v", err)
+	}
+}
+
+func mustParseCSR(t *testing.T, der []byte) *x509.CertificateRequest {
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		t.Fatalf("parse CSR failed: %v", err)
+	}
+	return csr
+}
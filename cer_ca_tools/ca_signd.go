@@ -0,0 +1,365 @@
+package cer_ca_tools
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FISCO-BCOS/go-sdk/smcrypto"
+)
+
+// SigningDaemonSocketEnv 签名守护进程 Unix socket 路径的环境变量
+const SigningDaemonSocketEnv = "CA_SIGND_SOCKET"
+
+const signdTimeout = 10 * time.Second
+
+// 签名守护进程协议：每个连接上按行交换 JSON 请求与响应
+type signdRequest struct {
+	Op        string       `json:"op"` // public_key | sign | generate | find
+	Key       string       `json:"key"`
+	Digest    []byte       `json:"digest,omitempty"`
+	Hash      crypto.Hash  `json:"hash,omitempty"`
+	PublicKey []byte       `json:"public_key,omitempty"` // find 按公钥（PKIX DER）查找私钥
+	Algorithm KeyAlgorithm `json:"algorithm,omitempty"`  // generate 使用的密钥算法，为空时使用 DefaultKeyAlgorithm
+}
+
+type signdResponse struct {
+	Key       string `json:"key,omitempty"`
+	PublicKey []byte `json:"public_key,omitempty"` // PKIX DER
+	Signature []byte `json:"signature,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// SigningDaemon 在独立进程中保管CA私钥，通过 Unix socket 提供签名服务
+// 私钥以 <Dir>/<caName>.key 保存，与 FileStore 的 ca 目录布局一致
+type SigningDaemon struct {
+	Dir        string
+	Passphrase []byte
+	keys       map[string]crypto.Signer
+	listener   net.Listener
+	mutex      sync.RWMutex
+}
+
+// NewSigningDaemon 创建签名守护进程并加载目录中的全部私钥
+func NewSigningDaemon(dir string, passphrase []byte) (*SigningDaemon, error) {
+	daemon := &SigningDaemon{
+		Dir:        dir,
+		Passphrase: passphrase,
+		keys:       make(map[string]crypto.Signer),
+	}
+
+	keyPaths, err := filepath.Glob(filepath.Join(dir, "*.key"))
+	if err != nil {
+		return nil, err
+	}
+	for _, keyPath := range keyPaths {
+		key, err := readKeyFile(keyPath, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		daemon.keys[strings.TrimSuffix(filepath.Base(keyPath), ".key")] = key
+	}
+	log.Printf("signing daemon loaded %d keys from %s", len(daemon.keys), dir)
+	return daemon, nil
+}
+
+// ListenAndServe 在 Unix socket 上提供签名服务，socket 权限为 0600
+func (daemon *SigningDaemon) ListenAndServe(socketPath string) error {
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", socketPath, err)
+	}
+	if err := os.Chmod(socketPath, 0o600); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to restrict socket permissions: %w", err)
+	}
+
+	daemon.mutex.Lock()
+	daemon.listener = listener
+	daemon.mutex.Unlock()
+
+	log.Printf("signing daemon listening on %s", socketPath)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go daemon.serveConn(conn)
+	}
+}
+
+// Close 停止监听
+func (daemon *SigningDaemon) Close() error {
+	daemon.mutex.Lock()
+	defer daemon.mutex.Unlock()
+
+	if daemon.listener == nil {
+		return nil
+	}
+	return daemon.listener.Close()
+}
+
+func (daemon *SigningDaemon) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	decoder := json.NewDecoder(conn)
+	encoder := json.NewEncoder(conn)
+	for {
+		var request signdRequest
+		if err := decoder.Decode(&request); err != nil {
+			if err != io.EOF {
+				log.Printf("signing daemon: bad request: %v", err)
+			}
+			return
+		}
+		if err := encoder.Encode(daemon.handle(&request)); err != nil {
+			return
+		}
+	}
+}
+
+func (daemon *SigningDaemon) handle(request *signdRequest) *signdResponse {
+	if request.Op == "find" {
+		return daemon.find(request.PublicKey)
+	}
+	if !validKeyName(request.Key) {
+		return &signdResponse{Error: fmt.Sprintf("invalid key name %q", request.Key)}
+	}
+
+	var key crypto.Signer
+	var err error
+	switch request.Op {
+	case "generate":
+		key, err = daemon.generate(request.Key, request.Algorithm)
+	case "public_key", "sign":
+		daemon.mutex.RLock()
+		key = daemon.keys[request.Key]
+		daemon.mutex.RUnlock()
+		if key == nil {
+			err = fmt.Errorf("key %s not found", request.Key)
+		}
+	default:
+		err = fmt.Errorf("unknown op %q", request.Op)
+	}
+	if err != nil {
+		return &signdResponse{Error: err.Error()}
+	}
+
+	if request.Op == "sign" {
+		signature, err := key.Sign(rand.Reader, request.Digest, request.Hash)
+		if err != nil {
+			return &signdResponse{Error: err.Error()}
+		}
+		log.Printf("signing daemon: signed %d-byte digest with key %s", len(request.Digest), request.Key)
+		return &signdResponse{Signature: signature}
+	}
+
+	publicKeyDER, err := smcrypto.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return &signdResponse{Error: err.Error()}
+	}
+	return &signdResponse{Key: request.Key, PublicKey: publicKeyDER}
+}
+
+// find 按公钥查找私钥，CA密钥轮换后新旧私钥的名称与CA名称不一致
+func (daemon *SigningDaemon) find(publicKeyDER []byte) *signdResponse {
+	daemon.mutex.RLock()
+	defer daemon.mutex.RUnlock()
+
+	for keyName, key := range daemon.keys {
+		keyDER, err := smcrypto.MarshalPKIXPublicKey(key.Public())
+		if err == nil && bytes.Equal(keyDER, publicKeyDER) {
+			return &signdResponse{Key: keyName, PublicKey: keyDER}
+		}
+	}
+	return &signdResponse{Error: "no key matches the public key"}
+}
+
+// generate 按算法生成新的CA私钥并加密保存到守护进程目录
+func (daemon *SigningDaemon) generate(keyName string, algorithm KeyAlgorithm) (crypto.Signer, error) {
+	daemon.mutex.Lock()
+	defer daemon.mutex.Unlock()
+
+	if _, exists := daemon.keys[keyName]; exists {
+		return nil, fmt.Errorf("key %s already exists", keyName)
+	}
+	if algorithm == "" {
+		algorithm = DefaultKeyAlgorithm
+	}
+	if err := checkKeyAlgorithm(algorithm); err != nil {
+		return nil, err
+	}
+	key, err := GenerateKey(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := MarshalPrivateKeyPEM(key, daemon.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(daemon.Dir, 0o700); err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(filepath.Join(daemon.Dir, keyName+".key"), keyPEM, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write key %s: %w", keyName, err)
+	}
+	daemon.keys[keyName] = key
+	log.Printf("signing daemon: generated %s key %s", algorithm, keyName)
+	return key, nil
+}
+
+func validKeyName(name string) bool {
+	return name != "" && name == filepath.Base(name) && !strings.HasPrefix(name, ".")
+}
+
+// RemoteSigner 通过签名守护进程签名的 crypto.Signer，本进程不持有私钥
+type RemoteSigner struct {
+	SocketPath string
+	KeyName    string
+	publicKey  crypto.PublicKey
+}
+
+// NewRemoteSigner 连接签名守护进程中已有的私钥
+func NewRemoteSigner(socketPath string, keyName string) (*RemoteSigner, error) {
+	return newRemoteSigner(socketPath, keyName, "public_key")
+}
+
+// GenerateRemoteKey 由签名守护进程生成新的 DefaultKeyAlgorithm 私钥并返回对应的签名器
+func GenerateRemoteKey(socketPath string, keyName string) (*RemoteSigner, error) {
+	return GenerateRemoteKeyWithAlgorithm(socketPath, keyName, DefaultKeyAlgorithm)
+}
+
+// GenerateRemoteKeyWithAlgorithm 由签名守护进程按算法生成新私钥并返回对应的签名器
+func GenerateRemoteKeyWithAlgorithm(socketPath string, keyName string, algorithm KeyAlgorithm) (*RemoteSigner, error) {
+	return signdPublicKey(socketPath, &signdRequest{Op: "generate", Key: keyName, Algorithm: algorithm})
+}
+
+// FindRemoteSigner 按公钥查找签名守护进程中的私钥
+func FindRemoteSigner(socketPath string, publicKey crypto.PublicKey) (*RemoteSigner, error) {
+	publicKeyDER, err := smcrypto.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return signdPublicKey(socketPath, &signdRequest{Op: "find", PublicKey: publicKeyDER})
+}
+
+func newRemoteSigner(socketPath string, keyName string, op string) (*RemoteSigner, error) {
+	return signdPublicKey(socketPath, &signdRequest{Op: op, Key: keyName})
+}
+
+func signdPublicKey(socketPath string, request *signdRequest) (*RemoteSigner, error) {
+	response, err := signdCall(socketPath, request)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := smcrypto.ParsePKIXPublicKey(response.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key from signing daemon: %w", err)
+	}
+	return &RemoteSigner{
+		SocketPath: socketPath,
+		KeyName:    response.Key,
+		publicKey:  publicKey,
+	}, nil
+}
+
+func (signer *RemoteSigner) Public() crypto.PublicKey {
+	return signer.publicKey
+}
+
+func (signer *RemoteSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	response, err := signdCall(signer.SocketPath, &signdRequest{
+		Op:     "sign",
+		Key:    signer.KeyName,
+		Digest: digest,
+		Hash:   opts.HashFunc(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response.Signature, nil
+}
+
+func isRemoteSigner(key crypto.Signer) bool {
+	_, remote := key.(*RemoteSigner)
+	return remote
+}
+
+func signdCall(socketPath string, request *signdRequest) (*signdResponse, error) {
+	conn, err := net.DialTimeout("unix", socketPath, signdTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to signing daemon: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(signdTimeout))
+
+	if err := json.NewEncoder(conn).Encode(request); err != nil {
+		return nil, fmt.Errorf("failed to send request to signing daemon: %w", err)
+	}
+	var response signdResponse
+	if err := json.NewDecoder(conn).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to read signing daemon response: %w", err)
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("signing daemon: %s", response.Error)
+	}
+	return &response, nil
+}
+
+// UseSigningDaemon 让CA通过签名守护进程签名：新CA的私钥由守护进程生成，已加载的CA连接守护进程中同名私钥
+// 需在 LoadState 之前调用，这样文件存储不会读取私钥文件，加载的CA会连接守护进程
+func (manager *CAManager) UseSigningDaemon(socketPath string) {
+	if fileStore, ok := manager.Store.(*FileStore); ok {
+		fileStore.ExternalKeys = true
+	}
+
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	manager.KeyGenerator = func(caName string, algorithm KeyAlgorithm) (crypto.Signer, error) {
+		return GenerateRemoteKeyWithAlgorithm(socketPath, caName, algorithm)
+	}
+	for _, ca := range manager.CAs {
+		attachRemoteSigner(ca, socketPath)
+	}
+	manager.signdSocket = socketPath
+}
+
+// attachRemoteSigner 为没有私钥的CA及其旧密钥按公钥连接签名守护进程，守护进程中没有对应私钥时保持离线
+func attachRemoteSigner(ca *CA, socketPath string) {
+	if ca.IsOffline() {
+		ca.PrivateKey = findRemoteKey(socketPath, ca.Certificate)
+	}
+	for _, retired := range ca.RetiredKeys {
+		if retired.PrivateKey == nil {
+			retired.PrivateKey = findRemoteKey(socketPath, retired.Certificate)
+		}
+	}
+}
+
+func findRemoteKey(socketPath string, cert *x509.Certificate) crypto.Signer {
+	signer, err := FindRemoteSigner(socketPath, cert.PublicKey)
+	if err != nil {
+		log.Printf("CA key %s stays offline: %v", cert.Subject.CommonName, err)
+		return nil
+	}
+	return signer
+}
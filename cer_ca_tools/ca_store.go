@@ -0,0 +1,640 @@
+package cer_ca_tools
+
+import (
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CAStore CA状态的持久化接口，CAManager 通过它保存和恢复 CA、已签发证书、撤销记录与质数池
+type CAStore interface {
+	// SaveCA 保存CA证书与私钥
+	SaveCA(ca *CA) error
+	// LoadCAs 加载所有CA，返回的CA已填充 IssuedCerts 与 RevokedCerts
+	LoadCAs() ([]*CA, error)
+	// SaveIssuedCert 保存CA签发的证书
+	SaveIssuedCert(caName string, cert *x509.Certificate) error
+	// SaveRevokedCert 保存撤销记录
+	SaveRevokedCert(caName string, revoked *pkix.RevokedCertificate) error
+	// SaveCRLState 保存CRL编号等状态
+	SaveCRLState(caName string, state CRLState) error
+	// SaveRetiredKey 保存密钥轮换后的旧CA证书、私钥与交叉证书，LoadCAs 将其恢复到 RetiredKeys
+	SaveRetiredKey(caName string, retired *RetiredCAKey) error
+	// SaveEnrollment 保存短期证书的登记记录，同一 ID 覆盖保存
+	SaveEnrollment(enrollment *Enrollment) error
+	// LoadEnrollments 加载全部登记记录
+	LoadEnrollments() ([]*Enrollment, error)
+	// SavePrimePool 保存质数池
+	SavePrimePool(pool *PrimePool) error
+	// LoadPrimePool 加载质数池，未保存过时返回空池
+	LoadPrimePool() (*PrimePool, error)
+}
+
+// ErrInvalidStoreName CA名或登记ID不能安全地用作文件名
+var ErrInvalidStoreName = errors.New("invalid name for file store")
+
+// FileStore 基于文件系统的 CAStore 实现，目录结构：
+//
+//	<Dir>/ca/<caName>.crt|.key         CA证书与私钥
+//	<Dir>/issued/<caName>/<serial>.crt 已签发证书
+//	<Dir>/revoked/<caName>/<serial>.json 撤销记录
+//	<Dir>/crl/<caName>.json            CRL状态
+//	<Dir>/retired/<caName>/<keyID>.json|.key 密钥轮换后的旧CA证书、交叉证书与旧私钥
+//	<Dir>/enrollments/<id>.json        短期证书登记记录
+//	<Dir>/primes.json                  质数池
+//
+// CA名与登记ID直接用作文件名，含路径分隔符或为 "."、".." 时保存失败并返回 ErrInvalidStoreName。
+// 设置 Passphrase 时CA私钥以 scrypt + AES-GCM 加密保存；ExternalKeys 为 true 时
+// 私钥由签名守护进程保管，本进程不读写私钥文件
+type FileStore struct {
+	Dir          string
+	Passphrase   []byte
+	ExternalKeys bool
+	mutex        sync.Mutex
+}
+
+// NewFileStore 创建文件存储
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{
+		Dir: dir,
+	}
+}
+
+// checkStoreName 拒绝会逃出存储目录或指向其他记录的文件名
+func checkStoreName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\\x00") {
+		return fmt.Errorf("%w: %q", ErrInvalidStoreName, name)
+	}
+	return nil
+}
+
+func (fs *FileStore) caDir() string {
+	return filepath.Join(fs.Dir, "ca")
+}
+
+func (fs *FileStore) issuedDir(caName string) string {
+	return filepath.Join(fs.Dir, "issued", caName)
+}
+
+func (fs *FileStore) revokedDir(caName string) string {
+	return filepath.Join(fs.Dir, "revoked", caName)
+}
+
+func (fs *FileStore) retiredDir(caName string) string {
+	return filepath.Join(fs.Dir, "retired", caName)
+}
+
+func (fs *FileStore) enrollmentDir() string {
+	return filepath.Join(fs.Dir, "enrollments")
+}
+
+func (fs *FileStore) crlStatePath(caName string) string {
+	return filepath.Join(fs.Dir, "crl", caName+".json")
+}
+
+func (fs *FileStore) SaveCA(ca *CA) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	caName := ca.Name.CommonName
+	if err := checkStoreName(caName); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(fs.caDir(), 0o700); err != nil {
+		return fmt.Errorf("failed to create CA directory: %w", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Certificate.Raw})
+	if err := writeFileAtomic(filepath.Join(fs.caDir(), caName+".crt"), certPEM, 0o644); err != nil {
+		return fmt.Errorf("failed to write CA certificate: %w", err)
+	}
+
+	// 离线CA只保存证书，私钥由运维离线保管；远程签名的私钥保存在签名守护进程中
+	if ca.IsOffline() || fs.ExternalKeys || isRemoteSigner(ca.PrivateKey) {
+		return nil
+	}
+	keyPEM, err := MarshalPrivateKeyPEM(ca.PrivateKey, fs.Passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to marshal CA private key: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(fs.caDir(), caName+".key"), keyPEM, 0o600); err != nil {
+		return fmt.Errorf("failed to write CA private key: %w", err)
+	}
+	return nil
+}
+
+func (fs *FileStore) LoadCAs() ([]*CA, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	certPaths, err := filepath.Glob(filepath.Join(fs.caDir(), "*.crt"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(certPaths)
+
+	cas := make([]*CA, 0, len(certPaths))
+	for _, certPath := range certPaths {
+		keyPath := strings.TrimSuffix(certPath, ".crt") + ".key"
+		cert, err := readCertFile(certPath)
+		if err != nil {
+			return nil, err
+		}
+		// 私钥文件不存在时按离线CA加载（例如根CA私钥已移出线上环境）
+		var key crypto.Signer
+		if fs.ExternalKeys {
+			// 私钥由签名守护进程提供
+		} else if _, err := os.Stat(keyPath); err == nil {
+			key, err = readKeyFile(keyPath, fs.Passphrase)
+			if err != nil {
+				return nil, err
+			}
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to stat private key %s: %w", keyPath, err)
+		}
+
+		ca := newCA(cert, key)
+		caName := ca.Name.CommonName
+
+		issuedPaths, _ := filepath.Glob(filepath.Join(fs.issuedDir(caName), "*.crt"))
+		for _, issuedPath := range issuedPaths {
+			issued, err := readCertFile(issuedPath)
+			if err != nil {
+				return nil, err
+			}
+			ca.IssuedCerts[issued.SerialNumber.String()] = issued
+		}
+
+		revokedPaths, _ := filepath.Glob(filepath.Join(fs.revokedDir(caName), "*.json"))
+		for _, revokedPath := range revokedPaths {
+			data, err := os.ReadFile(revokedPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read revocation %s: %w", revokedPath, err)
+			}
+			var revoked pkix.RevokedCertificate
+			if err := json.Unmarshal(data, &revoked); err != nil {
+				return nil, fmt.Errorf("failed to parse revocation %s: %w", revokedPath, err)
+			}
+			ca.RevokedCerts[revoked.SerialNumber.String()] = &revoked
+		}
+
+		data, err := os.ReadFile(fs.crlStatePath(caName))
+		if err == nil {
+			if err := json.Unmarshal(data, &ca.crlState); err != nil {
+				return nil, fmt.Errorf("failed to parse CRL state of %s: %w", caName, err)
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read CRL state of %s: %w", caName, err)
+		}
+
+		if ca.RetiredKeys, err = fs.loadRetiredKeys(caName); err != nil {
+			return nil, err
+		}
+
+		cas = append(cas, ca)
+	}
+	return cas, nil
+}
+
+func (fs *FileStore) SaveIssuedCert(caName string, cert *x509.Certificate) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if err := checkStoreName(caName); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(fs.issuedDir(caName), 0o755); err != nil {
+		return fmt.Errorf("failed to create issued directory: %w", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	path := filepath.Join(fs.issuedDir(caName), cert.SerialNumber.String()+".crt")
+	return writeFileAtomic(path, certPEM, 0o644)
+}
+
+func (fs *FileStore) SaveRevokedCert(caName string, revoked *pkix.RevokedCertificate) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if err := checkStoreName(caName); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(fs.revokedDir(caName), 0o755); err != nil {
+		return fmt.Errorf("failed to create revoked directory: %w", err)
+	}
+	data, err := json.Marshal(revoked)
+	if err != nil {
+		return fmt.Errorf("failed to marshal revocation: %w", err)
+	}
+	path := filepath.Join(fs.revokedDir(caName), revoked.SerialNumber.String()+".json")
+	return writeFileAtomic(path, data, 0o644)
+}
+
+func (fs *FileStore) SaveCRLState(caName string, state CRLState) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if err := checkStoreName(caName); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fs.crlStatePath(caName)), 0o755); err != nil {
+		return fmt.Errorf("failed to create CRL directory: %w", err)
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal CRL state: %w", err)
+	}
+	return writeFileAtomic(fs.crlStatePath(caName), data, 0o644)
+}
+
+// retiredKeyRecord 旧CA密钥的持久化格式，证书均为DER
+type retiredKeyRecord struct {
+	Certificate    []byte    `json:"certificate"`
+	CrossSignedOld []byte    `json:"cross_signed_old,omitempty"`
+	CrossSignedNew []byte    `json:"cross_signed_new,omitempty"`
+	OverlapUntil   time.Time `json:"overlap_until"`
+	RetireAt       time.Time `json:"retire_at"`
+}
+
+func newRetiredKeyRecord(retired *RetiredCAKey) retiredKeyRecord {
+	record := retiredKeyRecord{
+		Certificate:  retired.Certificate.Raw,
+		OverlapUntil: retired.OverlapUntil,
+		RetireAt:     retired.RetireAt,
+	}
+	if retired.CrossSignedOld != nil {
+		record.CrossSignedOld = retired.CrossSignedOld.Raw
+		record.CrossSignedNew = retired.CrossSignedNew.Raw
+	}
+	return record
+}
+
+func (record retiredKeyRecord) parse(key crypto.Signer) (*RetiredCAKey, error) {
+	retired := &RetiredCAKey{
+		PrivateKey:   key,
+		OverlapUntil: record.OverlapUntil,
+		RetireAt:     record.RetireAt,
+	}
+	var err error
+	if retired.Certificate, err = ParseCertificate(record.Certificate); err != nil {
+		return nil, err
+	}
+	if record.CrossSignedOld != nil {
+		if retired.CrossSignedOld, err = ParseCertificate(record.CrossSignedOld); err != nil {
+			return nil, err
+		}
+		if retired.CrossSignedNew, err = ParseCertificate(record.CrossSignedNew); err != nil {
+			return nil, err
+		}
+	}
+	return retired, nil
+}
+
+func (fs *FileStore) SaveRetiredKey(caName string, retired *RetiredCAKey) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if err := checkStoreName(caName); err != nil {
+		return err
+	}
+	dir := fs.retiredDir(caName)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	if retired.PrivateKey != nil && !fs.ExternalKeys && !isRemoteSigner(retired.PrivateKey) {
+		keyPEM, err := MarshalPrivateKeyPEM(retired.PrivateKey, fs.Passphrase)
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(filepath.Join(dir, retired.KeyID()+".key"), keyPEM, 0o600); err != nil {
+			return fmt.Errorf("failed to write retired CA private key: %w", err)
+		}
+	}
+	data, err := json.MarshalIndent(newRetiredKeyRecord(retired), "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, retired.KeyID()+".json"), data, 0o644)
+}
+
+// loadRetiredKeys 按轮换时间顺序加载CA的旧密钥，调用方需持有 fs.mutex
+func (fs *FileStore) loadRetiredKeys(caName string) ([]*RetiredCAKey, error) {
+	recordPaths, _ := filepath.Glob(filepath.Join(fs.retiredDir(caName), "*.json"))
+	retiredKeys := make([]*RetiredCAKey, 0, len(recordPaths))
+	for _, recordPath := range recordPaths {
+		data, err := os.ReadFile(recordPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read retired key %s: %w", recordPath, err)
+		}
+		var record retiredKeyRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return nil, fmt.Errorf("failed to parse retired key %s: %w", recordPath, err)
+		}
+		var key crypto.Signer
+		keyPath := strings.TrimSuffix(recordPath, ".json") + ".key"
+		if _, err := os.Stat(keyPath); err == nil && !fs.ExternalKeys {
+			if key, err = readKeyFile(keyPath, fs.Passphrase); err != nil {
+				return nil, err
+			}
+		}
+		retired, err := record.parse(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse retired key %s: %w", recordPath, err)
+		}
+		retiredKeys = append(retiredKeys, retired)
+	}
+	sortRetiredKeys(retiredKeys)
+	return retiredKeys, nil
+}
+
+// sortRetiredKeys 旧密钥按轮换先后排序，最早的原始密钥在前
+func sortRetiredKeys(retiredKeys []*RetiredCAKey) {
+	sort.Slice(retiredKeys, func(i, j int) bool {
+		return retiredKeys[i].Certificate.NotBefore.Before(retiredKeys[j].Certificate.NotBefore)
+	})
+}
+
+func (fs *FileStore) SaveEnrollment(enrollment *Enrollment) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if err := checkStoreName(enrollment.ID); err != nil {
+		return err
+	}
+	dir := fs.enrollmentDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create enrollment directory: %w", err)
+	}
+	data, err := json.Marshal(enrollment)
+	if err != nil {
+		return fmt.Errorf("failed to marshal enrollment: %w", err)
+	}
+	return writeFileAtomic(filepath.Join(dir, enrollment.ID+".json"), data, 0o644)
+}
+
+func (fs *FileStore) LoadEnrollments() ([]*Enrollment, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	files, err := filepath.Glob(filepath.Join(fs.enrollmentDir(), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var enrollments []*Enrollment
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read enrollment %s: %w", file, err)
+		}
+		enrollment := &Enrollment{}
+		if err := json.Unmarshal(data, enrollment); err != nil {
+			return nil, fmt.Errorf("failed to parse enrollment %s: %w", file, err)
+		}
+		enrollments = append(enrollments, enrollment)
+	}
+	return enrollments, nil
+}
+
+func (fs *FileStore) SavePrimePool(pool *PrimePool) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if err := os.MkdirAll(fs.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create store directory: %w", err)
+	}
+	data, err := json.Marshal(pool.Primes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal prime pool: %w", err)
+	}
+	return writeFileAtomic(filepath.Join(fs.Dir, "primes.json"), data, 0o644)
+}
+
+func (fs *FileStore) LoadPrimePool() (*PrimePool, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	pool := NewPrimePool()
+	data, err := os.ReadFile(filepath.Join(fs.Dir, "primes.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return pool, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prime pool: %w", err)
+	}
+	if err := json.Unmarshal(data, &pool.Primes); err != nil {
+		return nil, fmt.Errorf("failed to parse prime pool: %w", err)
+	}
+	return pool, nil
+}
+
+// MemoryStore 基于内存的 CAStore 实现，用于测试
+type MemoryStore struct {
+	mutex   sync.RWMutex
+	certs   map[string][]byte
+	keys    map[string]crypto.Signer
+	issued  map[string]map[string][]byte
+	revoked map[string]map[string]pkix.RevokedCertificate
+	crl     map[string]CRLState
+	retired map[string][]*RetiredCAKey
+	enrolls map[string]Enrollment
+	primes  []*big.Int
+}
+
+// NewMemoryStore 创建内存存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		certs:   make(map[string][]byte),
+		keys:    make(map[string]crypto.Signer),
+		issued:  make(map[string]map[string][]byte),
+		revoked: make(map[string]map[string]pkix.RevokedCertificate),
+		crl:     make(map[string]CRLState),
+		retired: make(map[string][]*RetiredCAKey),
+		enrolls: make(map[string]Enrollment),
+	}
+}
+
+func (ms *MemoryStore) SaveCA(ca *CA) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	caName := ca.Name.CommonName
+	ms.certs[caName] = ca.Certificate.Raw
+	ms.keys[caName] = ca.PrivateKey
+	return nil
+}
+
+func (ms *MemoryStore) LoadCAs() ([]*CA, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	names := make([]string, 0, len(ms.certs))
+	for caName := range ms.certs {
+		names = append(names, caName)
+	}
+	sort.Strings(names)
+
+	cas := make([]*CA, 0, len(names))
+	for _, caName := range names {
+		cert, err := ParseCertificate(ms.certs[caName])
+		if err != nil {
+			return nil, err
+		}
+		ca := newCA(cert, ms.keys[caName])
+		for serial, der := range ms.issued[caName] {
+			issued, err := ParseCertificate(der)
+			if err != nil {
+				return nil, err
+			}
+			ca.IssuedCerts[serial] = issued
+		}
+		for serial, revoked := range ms.revoked[caName] {
+			entry := revoked
+			ca.RevokedCerts[serial] = &entry
+		}
+		ca.crlState = ms.crl[caName]
+		for _, retired := range ms.retired[caName] {
+			record := *retired
+			record.crl = nil
+			ca.RetiredKeys = append(ca.RetiredKeys, &record)
+		}
+		cas = append(cas, ca)
+	}
+	return cas, nil
+}
+
+func (ms *MemoryStore) SaveIssuedCert(caName string, cert *x509.Certificate) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	if ms.issued[caName] == nil {
+		ms.issued[caName] = make(map[string][]byte)
+	}
+	ms.issued[caName][cert.SerialNumber.String()] = cert.Raw
+	return nil
+}
+
+func (ms *MemoryStore) SaveRevokedCert(caName string, revoked *pkix.RevokedCertificate) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	if ms.revoked[caName] == nil {
+		ms.revoked[caName] = make(map[string]pkix.RevokedCertificate)
+	}
+	ms.revoked[caName][revoked.SerialNumber.String()] = *revoked
+	return nil
+}
+
+func (ms *MemoryStore) SaveCRLState(caName string, state CRLState) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	ms.crl[caName] = state
+	return nil
+}
+
+func (ms *MemoryStore) SaveRetiredKey(caName string, retired *RetiredCAKey) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	record := *retired
+	ms.retired[caName] = append(ms.retired[caName], &record)
+	return nil
+}
+
+func (ms *MemoryStore) SaveEnrollment(enrollment *Enrollment) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	ms.enrolls[enrollment.ID] = *enrollment
+	return nil
+}
+
+func (ms *MemoryStore) LoadEnrollments() ([]*Enrollment, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	enrollments := make([]*Enrollment, 0, len(ms.enrolls))
+	for _, enrollment := range ms.enrolls {
+		record := enrollment
+		enrollments = append(enrollments, &record)
+	}
+	return enrollments, nil
+}
+
+func (ms *MemoryStore) SavePrimePool(pool *PrimePool) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	ms.primes = append([]*big.Int(nil), pool.Primes...)
+	return nil
+}
+
+func (ms *MemoryStore) LoadPrimePool() (*PrimePool, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	pool := NewPrimePool()
+	pool.Primes = append(pool.Primes, ms.primes...)
+	return pool, nil
+}
+
+// writeFileAtomic 先写临时文件再重命名，避免进程中断时留下半截文件
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func readCertFile(path string) (*x509.Certificate, error) {
+	certPEM, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate %s: %w", path, err)
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("failed to decode certificate %s", path)
+	}
+	cert, err := ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate %s: %w", path, err)
+	}
+	return cert, nil
+}
+
+func readKeyFile(path string, passphrase []byte) (crypto.Signer, error) {
+	keyPEM, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key %s: %w", path, err)
+	}
+	key, err := ParsePrivateKeyPEM(keyPEM, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load private key %s: %w", path, err)
+	}
+	return key, nil
+}
@@ -0,0 +1,89 @@
+package cer_ca_tools
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func testStoreRoundTrip(t *testing.T, store CAStore) {
+	manager := NewCAManagerWithStore(store)
+	ca, err := manager.CreateCA("ca_store_test")
+	if err != nil {
+		t.Fatalf("create CA failed: %v", err)
+	}
+	if err := manager.PrimePool.GeneratePrimes(3, 32); err != nil {
+		t.Fatalf("generate primes failed: %v", err)
+	}
+	if err := manager.SavePrimePool(); err != nil {
+		t.Fatalf("save prime pool failed: %v", err)
+	}
+
+	subjectSK, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	response := ca.IssueCertificate(pkix.Name{CommonName: "anonymous-test"}, &subjectSK.PublicKey)
+	if !response.Success {
+		t.Fatalf("issue certificate failed: %s", response.Message)
+	}
+	var serial string
+	for serial = range ca.IssuedCerts {
+	}
+	if response := ca.RevokeCertificate(ca.Name.CommonName, serial, 1); !response.Success {
+		t.Fatalf("revoke certificate failed: %s", response.Message)
+	}
+
+	restarted := NewCAManagerWithStore(store)
+	if err := restarted.LoadState(); err != nil {
+		t.Fatalf("load state failed: %v", err)
+	}
+	restored, exists := restarted.GetCAInfo("ca_store_test")
+	if !exists {
+		t.Fatalf("CA not restored")
+	}
+	if key, ok := restored.PrivateKey.(*ecdsa.PrivateKey); !ok || !key.Equal(ca.PrivateKey) {
+		t.Fatalf("CA key changed after restart")
+	}
+	if _, ok := restored.IssuedCerts[serial]; !ok {
+		t.Fatalf("issued certificate %s not restored", serial)
+	}
+	if _, ok := restored.RevokedCerts[serial]; !ok {
+		t.Fatalf("revocation of %s not restored", serial)
+	}
+	if len(restarted.PrimePool.Primes) != 3 {
+		t.Fatalf("prime pool not restored, have %d primes", len(restarted.PrimePool.Primes))
+	}
+	if _, err := restarted.CreateCA("ca_store_test"); err == nil {
+		t.Fatalf("creating an existing CA should fail")
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStoreRoundTrip(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	testStoreRoundTrip(t, NewFileStore(t.TempDir()))
+}
+
+func TestFileStoreRejectsUnsafeNames(t *testing.T) {
+	root := t.TempDir()
+	store := NewFileStore(filepath.Join(root, "store"))
+	manager := NewCAManagerWithStore(store)
+	for _, name := range []string{"../escape", "a/b", `a\b`, "..", "."} {
+		if _, err := manager.CreateCA(name); !errors.Is(err, ErrInvalidStoreName) {
+			t.Fatalf("CA name %q should be rejected, have %v", name, err)
+		}
+	}
+	if err := store.SaveEnrollment(&Enrollment{ID: "../../escape"}); !errors.Is(err, ErrInvalidStoreName) {
+		t.Fatalf("enrollment ID should be rejected, have %v", err)
+	}
+	if err := store.SaveCRLState("../escape", CRLState{}); !errors.Is(err, ErrInvalidStoreName) {
+		t.Fatalf("CRL state of an unsafe CA name should be rejected, have %v", err)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(root, "escape*")); len(matches) != 0 {
+		t.Fatalf("files written outside the store: %v", matches)
+	}
+}
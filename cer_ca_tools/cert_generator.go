@@ -1,49 +1,52 @@
 package cer_ca_tools
 
 import (
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
+	"crypto"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
 	"fmt"
 	"log"
-	"math/big"
 	"net"
 	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/FISCO-BCOS/go-sdk/smcrypto"
 )
 
 // CertGenerator 证书生成器
 type CertGenerator struct {
-	CertsDir string
+	CertsDir     string
+	Profiles     CertProfiles // 生成证书使用的模板，默认为内置模板
+	Passphrase   []byte       // CA私钥口令，为空时私钥不加密
+	KeyAlgorithm KeyAlgorithm // CA、验证者与证书主体的密钥算法，默认 P-384
 }
 
 // NewCertGenerator 创建新的证书生成器
 func NewCertGenerator(CertsDir string) *CertGenerator {
 	return &CertGenerator{
-		CertsDir: CertsDir,
+		CertsDir:     CertsDir,
+		Profiles:     DefaultCertProfiles(),
+		Passphrase:   PassphraseFromEnv(),
+		KeyAlgorithm: KeyAlgorithmP384,
 	}
 }
 
 // GenerateCA 生成CA证书和私钥
 func (cg *CertGenerator) GenerateCA() error {
 
-	// 生成椭圆曲线私钥
-	caPrivKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	caPrivKey, err := cg.generateKey()
 	if err != nil {
-		return fmt.Errorf("生成CA椭圆曲线私钥失败: %v", err)
+		return fmt.Errorf("生成CA私钥失败: %v", err)
 	}
 
-	//生成随机序列号
-	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 160)
-	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
-
-	// 创建CA证书模板
-	caTemplate := x509.Certificate{
-		SerialNumber: serialNumber,
+	// 按 root-ca 模板创建CA证书模板
+	profile, err := cg.Profiles.Get(ProfileRootCA)
+	if err != nil {
+		return err
+	}
+	caTemplate, err := profile.NewTemplate(&IssuanceRequest{
 		Subject: pkix.Name{
 			Country:            []string{"CN"},
 			Province:           []string{"Beijing"},
@@ -52,17 +55,14 @@ func (cg *CertGenerator) GenerateCA() error {
 			OrganizationalUnit: []string{"IT"},
 			CommonName:         "Test Root CA (ECDSA)", //Test Root CA (ECDSA), ca_test_one, ca_test_two, ca_test_three
 		},
-		NotBefore:             time.Now(),
-		NotAfter:              time.Now().Add(10 * 365 * 24 * time.Hour), // 10年
-		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
-		BasicConstraintsValid: true,
-		IsCA:                  true,
-		MaxPathLen:            2, // 允许2级证书链
-		MaxPathLenZero:        false,
+		PublicKey: caPrivKey.Public(),
+	}, time.Now())
+	if err != nil {
+		return fmt.Errorf("生成CA证书模板失败: %v", err)
 	}
 
 	// 生成CA证书
-	caCertDER, err := x509.CreateCertificate(rand.Reader, &caTemplate, &caTemplate, &caPrivKey.PublicKey, caPrivKey)
+	caCertDER, err := CreateCertificate(caTemplate, caTemplate, caPrivKey.Public(), caPrivKey)
 	if err != nil {
 		return fmt.Errorf("生成CA证书失败: %v", err)
 	}
@@ -85,26 +85,15 @@ func (cg *CertGenerator) GenerateCA() error {
 
 	// 保存CA私钥
 	caKeyPath := filepath.Join(cg.CertsDir, "ca.key")
-	caKeyFile, err := os.Create(caKeyPath)
-	if err != nil {
-		return fmt.Errorf("创建CA私钥文件失败: %v", err)
-	}
-	defer caKeyFile.Close()
-
-	caPrivKeyDER, err := x509.MarshalPKCS8PrivateKey(caPrivKey)
+	caKeyPEM, err := MarshalPrivateKeyPEM(caPrivKey, cg.Passphrase)
 	if err != nil {
 		return fmt.Errorf("序列化CA私钥失败: %v", err)
 	}
-
-	err = pem.Encode(caKeyFile, &pem.Block{
-		Type:  "PRIVATE KEY",
-		Bytes: caPrivKeyDER,
-	})
-	if err != nil {
-		return fmt.Errorf("编码CA私钥失败: %v", err)
+	if err := os.WriteFile(caKeyPath, caKeyPEM, 0600); err != nil {
+		return fmt.Errorf("写入CA私钥文件失败: %v", err)
 	}
 
-	log.Printf("CA证书已生成（椭圆曲线 P384）: %s", caCertPath)
+	log.Printf("CA证书已生成（%s）: %s", cg.KeyAlgorithm, caCertPath)
 	log.Printf("CA私钥已生成: %s", caKeyPath)
 	return nil
 }
@@ -118,13 +107,17 @@ func (cg *CertGenerator) GenerateVerifierCert() error {
 		return fmt.Errorf("Error loading CA: %s", err)
 	}
 
-	serverSK, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	serverSK, err := cg.generateKey()
 	if err != nil {
 		return fmt.Errorf("Error generating server private key: %s", err)
 	}
 
-	serverTemplate := x509.Certificate{
-		SerialNumber: big.NewInt(2),
+	// 按 verifier-server 模板创建验证者证书模板
+	profile, err := cg.Profiles.Get(ProfileVerifierServer)
+	if err != nil {
+		return err
+	}
+	serverTemplate, err := profile.NewTemplate(&IssuanceRequest{
 		Subject: pkix.Name{
 			Country:            []string{"CN"},
 			Locality:           []string{"xi'an"},
@@ -133,17 +126,15 @@ func (cg *CertGenerator) GenerateVerifierCert() error {
 			OrganizationalUnit: []string{"IT"},
 			CommonName:         "localhost",
 		},
-		NotBefore:             time.Now(),
-		NotAfter:              time.Now().Add(10 * 365 * 24 * time.Hour),
-		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
-		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
-		DNSNames:              []string{"localhost", "*.localhost", "127.0.0.1"},
-		BasicConstraintsValid: true,
-		IsCA:                  false,
+		PublicKey:   serverSK.Public(),
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		DNSNames:    []string{"localhost", "*.localhost"},
+	}, time.Now())
+	if err != nil {
+		return fmt.Errorf("生成验证者证书模板失败: %s", err)
 	}
 
-	serverCertDER, err := x509.CreateCertificate(rand.Reader, &serverTemplate, caCert, &serverSK.PublicKey, caSK)
+	serverCertDER, err := CreateCertificate(serverTemplate, caCert, serverSK.Public(), caSK)
 	if err != nil {
 		return fmt.Errorf("生成验证者证书失败: %s", err)
 	}
@@ -170,7 +161,7 @@ func (cg *CertGenerator) GenerateVerifierCert() error {
 	}
 	defer ServerKeyFile.Close()
 
-	serverSKDER, err := x509.MarshalPKCS8PrivateKey(serverSK)
+	serverSKDER, err := smcrypto.MarshalPKCS8PrivateKey(serverSK)
 	if err != nil {
 		return fmt.Errorf("序列化验证者密钥失败: %s", err)
 	}
@@ -191,9 +182,9 @@ func (cg *CertGenerator) GenerateVerifierCert() error {
 // GenerateClientCert 生成客户端证书
 func (cg *CertGenerator) GenerateSubjectCert() error {
 
-	subPrivKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	subPrivKey, err := cg.generateKey()
 	if err != nil {
-		return fmt.Errorf("生成证书主体椭圆曲线私钥失败: %v", err)
+		return fmt.Errorf("生成证书主体私钥失败: %v", err)
 	}
 
 	clientKeyPath := filepath.Join(cg.CertsDir, "subject.key")
@@ -203,7 +194,7 @@ func (cg *CertGenerator) GenerateSubjectCert() error {
 	}
 	defer clientKeyFile.Close()
 
-	clientPrivKeyDER, err := x509.MarshalPKCS8PrivateKey(subPrivKey)
+	clientPrivKeyDER, err := smcrypto.MarshalPKCS8PrivateKey(subPrivKey)
 	if err != nil {
 		return fmt.Errorf("序列化证书主体私钥失败: %v", err)
 	}
@@ -302,7 +293,15 @@ func (cg *CertGenerator) GenerateSubjectCert() error {
 	//return nil
 }
 
-func (cg *CertGenerator) LoadCA(caName string) (*x509.Certificate, interface{}, error) {
+// generateKey 按生成器的密钥算法生成私钥，SM2 时生成国密证书
+func (cg *CertGenerator) generateKey() (crypto.Signer, error) {
+	if err := checkKeyAlgorithm(cg.KeyAlgorithm); err != nil {
+		return nil, err
+	}
+	return GenerateKey(cg.KeyAlgorithm)
+}
+
+func (cg *CertGenerator) LoadCA(caName string) (*x509.Certificate, crypto.Signer, error) {
 	// 读取CA证书
 	caCertPath := filepath.Join(cg.CertsDir, caName+".crt")
 	caCertPEM, err := os.ReadFile(caCertPath)
@@ -315,7 +314,7 @@ func (cg *CertGenerator) LoadCA(caName string) (*x509.Certificate, interface{},
 		return nil, nil, fmt.Errorf("解码CA证书失败")
 	}
 
-	caCert, err := x509.ParseCertificate(caCertBlock.Bytes)
+	caCert, err := ParseCertificate(caCertBlock.Bytes)
 	if err != nil {
 		return nil, nil, fmt.Errorf("解析CA证书失败: %v", err)
 	}
@@ -327,12 +326,7 @@ func (cg *CertGenerator) LoadCA(caName string) (*x509.Certificate, interface{},
 		return nil, nil, fmt.Errorf("读取CA私钥失败: %v", err)
 	}
 
-	caKeyBlock, _ := pem.Decode(caKeyPEM)
-	if caKeyBlock == nil {
-		return nil, nil, fmt.Errorf("解码CA私钥失败")
-	}
-
-	caPrivKey, err := x509.ParsePKCS8PrivateKey(caKeyBlock.Bytes)
+	caPrivKey, err := ParsePrivateKeyPEM(caKeyPEM, cg.Passphrase)
 	if err != nil {
 		return nil, nil, fmt.Errorf("解析CA私钥失败: %v", err)
 	}
@@ -371,20 +365,18 @@ func (cg *CertGenerator) GenerateAllCerts() error {
 }
 
 // ValidateCertChain 验证证书链，模拟验证者行为
+// certPath 可以是单张证书，也可以是“叶子证书+中间CA证书”的PEM证书包
 func (cg *CertGenerator) ValidateCertChain(certPath string, caCertPath string) error {
-	// 读取待验证的证书
+	// 读取待验证的证书（包）
 	certPEM, err := os.ReadFile(certPath)
 	if err != nil {
 		return fmt.Errorf("读取证书 '%s' 失败: %v", certPath, err)
 	}
-	certBlock, _ := pem.Decode(certPEM)
-	if certBlock == nil {
-		return fmt.Errorf("解码证书 '%s' 失败", certPath)
-	}
-	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	bundle, err := parseCertBundle(certPEM)
 	if err != nil {
 		return fmt.Errorf("解析证书 '%s' 失败: %v", certPath, err)
 	}
+	cert := bundle[0]
 
 	// 读取CA证书
 	caCertPEM, err := os.ReadFile(caCertPath)
@@ -395,26 +387,19 @@ func (cg *CertGenerator) ValidateCertChain(certPath string, caCertPath string) e
 	if caCertBlock == nil {
 		return fmt.Errorf("解码CA证书 '%s' 失败", caCertPath)
 	}
-	caCert, err := x509.ParseCertificate(caCertBlock.Bytes)
+	caCert, err := ParseCertificate(caCertBlock.Bytes)
 	if err != nil {
 		return fmt.Errorf("解析CA证书 '%s' 失败: %v", caCertPath, err)
 	}
 
-	// 创建一个证书池，并添加CA证书
-	roots := x509.NewCertPool()
-	roots.AddCert(caCert)
-
-	// 设置验证选项
-	opts := x509.VerifyOptions{
-		Roots:         roots,
-		Intermediates: x509.NewCertPool(), // 如果有中间CA，也需要添加
-		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
-	}
-
-	// 验证证书链
-	if _, err := cert.Verify(opts); err != nil {
+	// 验证证书链，包括每一级CA的路径长度与密钥用途
+	chain, err := VerifyCertChain(cert, bundle[1:], []*x509.Certificate{caCert}, nil)
+	if err != nil {
 		return fmt.Errorf("证书链验证失败 for '%s': %v", certPath, err)
 	}
+	for i, chainCert := range chain {
+		log.Printf("  链[%d]: %s", i, chainCert.Subject.CommonName)
+	}
 
 	// 额外检查有效期
 	now := time.Now()
@@ -431,6 +416,30 @@ func (cg *CertGenerator) ValidateCertChain(certPath string, caCertPath string) e
 	return nil
 }
 
+// parseCertBundle 解析PEM证书包中的全部证书，第一张为叶子证书
+func parseCertBundle(bundlePEM []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, bundlePEM = pem.Decode(bundlePEM)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate found")
+	}
+	return certs, nil
+}
+
 // ValidateCertificate 验证单个证书的基本信息
 func (cg *CertGenerator) ValidateCertificate(certPath string) error {
 	certPEM, err := os.ReadFile(certPath)
@@ -443,7 +452,7 @@ func (cg *CertGenerator) ValidateCertificate(certPath string) error {
 		return fmt.Errorf("解码证书失败")
 	}
 
-	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	cert, err := ParseCertificate(certBlock.Bytes)
 	if err != nil {
 		return fmt.Errorf("解析证书失败: %v", err)
 	}
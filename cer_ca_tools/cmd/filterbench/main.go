@@ -0,0 +1,131 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/FISCO-BCOS/go-sdk/cer_ca_tools"
+	"log"
+	"math/big"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// 撤销过滤器对比：加载 N 个被撤销证书的序列号，报告各实现的内存、序列化大小、实测误判率以及插入、删除、查询延迟。
+// binary fuse 过滤器是静态的，插入延迟为整体构建时间按元素平均，不支持删除
+func main() {
+	revokedCount := flag.Int("n", 100000, "被撤销证书数")
+	queryCount := flag.Int("queries", 1000000, "用于测量误判率的未撤销序列号数")
+	fpr := flag.Float64("fpr", 0.001, "计数布隆过滤器的目标误判率")
+	bitsPerCount := flag.Uint("bits", 4, "计数布隆过滤器每个计数器的位数（4 或 8）")
+	flag.Parse()
+
+	revoked := randomSerials(*revokedCount)
+	valid := randomSerials(*queryCount)
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(writer, "filter\tmemory(B)\tserialized(B)\tbits/elem\tfpr\testimated fpr\tinsert(ns)\tdelete(ns)\tquery(ns)\t")
+
+	benchmarks := []struct {
+		name  string
+		build func() (cer_ca_tools.RevocationFilter, time.Duration, error)
+	}{
+		{"counting bloom", func() (cer_ca_tools.RevocationFilter, time.Duration, error) {
+			return insertAll(cer_ca_tools.NewCountingBloomFilter(uint(*revokedCount), *fpr, *bitsPerCount), revoked)
+		}},
+		{"cuckoo", func() (cer_ca_tools.RevocationFilter, time.Duration, error) {
+			// NewCuckooFilter 按 95% 装载率分配，接近满载时插入可能失败：预留 10% 余量，仍然失败时加倍容量重试
+			capacity := uint(*revokedCount) + uint(*revokedCount)/10
+			for {
+				filter, insertTime, err := insertAll(cer_ca_tools.NewCuckooFilter(capacity), revoked)
+				if !errors.Is(err, cer_ca_tools.ErrFilterFull) {
+					return filter, insertTime, err
+				}
+				log.Printf("cuckoo: 容量 %d 插入失败，加倍后重试", capacity)
+				capacity *= 2
+			}
+		}},
+		{"binary fuse", func() (cer_ca_tools.RevocationFilter, time.Duration, error) {
+			start := time.Now()
+			filter, err := cer_ca_tools.NewBinaryFuseFilter(revoked)
+			return filter, time.Since(start), err
+		}},
+	}
+
+	for _, benchmark := range benchmarks {
+		filter, insertTime, err := benchmark.build()
+		if err != nil {
+			log.Fatalf("%s: 构建过滤器失败: %v", benchmark.name, err)
+		}
+		data, err := filter.Marshal()
+		if err != nil {
+			log.Fatalf("%s: 序列化失败: %v", benchmark.name, err)
+		}
+		for _, serial := range revoked {
+			if !filter.Contains(serial) {
+				log.Fatalf("%s: 被撤销证书 %s 未命中", benchmark.name, serial)
+			}
+		}
+
+		falsePositives := 0
+		start := time.Now()
+		for _, serial := range valid {
+			if filter.Contains(serial) {
+				falsePositives++
+			}
+		}
+		queryTime := time.Since(start)
+
+		// 统计取自删除之前
+		stats := filter.Stats()
+		deleteColumn := "-"
+		start = time.Now()
+		if len(revoked) > 0 && filter.Remove(revoked[0]) == nil {
+			for _, serial := range revoked[1:] {
+				if err := filter.Remove(serial); err != nil {
+					log.Fatalf("%s: 删除失败: %v", benchmark.name, err)
+				}
+			}
+			deleteColumn = perElement(time.Since(start), len(revoked))
+		}
+
+		memory := stats["memory_bytes"].(uint)
+		fmt.Fprintf(writer, "%s\t%d\t%d\t%.2f\t%.6f\t%.6f\t%s\t%s\t%s\t\n",
+			benchmark.name, memory, len(data),
+			float64(memory*8)/float64(max(len(revoked), 1)),
+			float64(falsePositives)/float64(max(len(valid), 1)),
+			stats["estimated_fpr"],
+			perElement(insertTime, len(revoked)), deleteColumn, perElement(queryTime, len(valid)))
+	}
+	writer.Flush()
+}
+
+// randomSerials 生成与CA签发证书相同形式的序列号（160 位随机数的十进制字符串）
+func randomSerials(count int) [][]byte {
+	limit := new(big.Int).Lsh(big.NewInt(1), 160)
+	serials := make([][]byte, count)
+	for i := range serials {
+		serial, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			log.Fatal(err)
+		}
+		serials[i] = []byte(serial.String())
+	}
+	return serials
+}
+
+func insertAll(filter cer_ca_tools.RevocationFilter, elements [][]byte) (cer_ca_tools.RevocationFilter, time.Duration, error) {
+	start := time.Now()
+	for _, element := range elements {
+		if err := filter.Add(element); err != nil {
+			return nil, 0, err
+		}
+	}
+	return filter, time.Since(start), nil
+}
+
+func perElement(total time.Duration, count int) string {
+	return fmt.Sprintf("%.1f", float64(total.Nanoseconds())/float64(max(count, 1)))
+}
@@ -1,10 +1,21 @@
 package main
 
 import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
 	"fmt"
 	"github.com/FISCO-BCOS/go-sdk/cer_ca_tools"
+	"github.com/FISCO-BCOS/go-sdk/cer_chain_tools"
+	"github.com/FISCO-BCOS/go-sdk/client"
+	"github.com/FISCO-BCOS/go-sdk/conf"
+	"github.com/ethereum/go-ethereum/common"
 	"log"
-	"net/http"
+	"os/signal"
+	"syscall"
 	"time"
 
 	// "net/http"
@@ -59,41 +70,209 @@ func keyAndCertGenerate(cg *cer_ca_tools.CertGenerator) {
 func setupCAHTTP() {
 
 	caManager := cer_ca_tools.NewCAManager()
+	if os.Getenv("CA_TLS_CERT") != "" {
+		// CRL与OCSP地址随服务改用 HTTPS
+		caManager.BaseURL = "https://localhost:8080"
+	}
 
-	primePool := cer_ca_tools.NewPrimePool()
+	// 加载证书模板配置，未提供配置文件时使用内置模板
+	currentDir, _ := os.Getwd()
+	profilePath := filepath.Join(currentDir, "certs", "profiles.json")
+	if _, err := os.Stat(profilePath); err == nil {
+		if err := caManager.LoadProfiles(profilePath); err != nil {
+			log.Fatalf("加载证书模板失败: %v", err)
+		}
+	}
 
-	// 生成几个大质数用于演示
-	err := primePool.GeneratePrimes(1000, 64) // 生成5个64位的质数
-	if err != nil {
-		log.Fatalf("生成质数时出错: %v", err)
+	// 设置 CA_SIGND_SOCKET 时CA私钥由签名守护进程保管，本进程不读取私钥文件
+	if socketPath := os.Getenv(cer_ca_tools.SigningDaemonSocketEnv); socketPath != "" {
+		caManager.UseSigningDaemon(socketPath)
+	}
+
+	// 审计日志：记录CA创建、签发、撤销、密钥轮换与模数分发
+	if err := caManager.EnableAuditLog(filepath.Join(currentDir, "certs", "audit.log")); err != nil {
+		log.Fatalf("打开审计日志失败: %v", err)
+	}
+	// 设置 AUDIT_ANCHOR_CONTRACT 时定期将日志头摘要写入链上 CertOperKV 合约
+	if contract := os.Getenv("AUDIT_ANCHOR_CONTRACT"); contract != "" {
+		startAuditAnchoring(caManager.Audit, common.HexToAddress(contract))
+	}
+
+	// 证书透明日志：签发的证书先以预证书记入日志，SCT嵌入最终证书；日志公钥写入 ct_log.pub 分发给验证者
+	if err := enableCTLog(caManager, filepath.Join(currentDir, "certs")); err != nil {
+		log.Fatalf("启用证书透明日志失败: %v", err)
+	}
+
+	// 恢复已有的CA、签发记录、撤销记录和质数池
+	if err := caManager.LoadState(); err != nil {
+		log.Fatalf("加载CA状态失败: %v", err)
 	}
 
-	caManager.PrimePool = primePool
+	if len(caManager.PrimePool.Primes) == 0 {
+		// 生成几个大质数用于演示
+		err := caManager.PrimePool.GeneratePrimes(1000, 64) // 生成5个64位的质数
+		if err != nil {
+			log.Fatalf("生成质数时出错: %v", err)
+		}
+		if err := caManager.SavePrimePool(); err != nil {
+			log.Fatalf("保存质数池失败: %v", err)
+		}
+	}
 
 	caConfigs := []struct{ caName string }{{"ca_test_one"}, {"ca_test_two"}, {"ca_test_three"}}
 
 	for _, caConfig := range caConfigs {
-		ca, err := cer_ca_tools.CreateNewCA(caConfig.caName)
+		if _, exists := caManager.GetCAInfo(caConfig.caName); exists {
+			continue
+		}
+		// 仅在CA不存在时生成新密钥
+		_, err := caManager.CreateCA(caConfig.caName)
 		if err != nil {
 			log.Fatal("creat CA failed", caConfig.caName, err)
 		}
-		caManager.AddCAToManager(ca)
 	}
 
-	caManager.SetupHTTPHandlers()
+	// 设置 CA_TLS_CERT 与 CA_TLS_KEY 时以 HTTPS 提供服务
+	config := cer_ca_tools.ServerConfig{
+		Addr:     ":8080",
+		CertFile: os.Getenv("CA_TLS_CERT"),
+		KeyFile:  os.Getenv("CA_TLS_KEY"),
+	}
+	server := cer_ca_tools.NewServer(caManager, config)
+
+	// 设置 REVOCATION_FILTER_CONTRACT 时定期把 ca_test_one 的撤销过滤器写入链上 RevocationFilter 合约，
+	// 链账户需已在 ca_register 中注册
+	if contract := os.Getenv("REVOCATION_FILTER_CONTRACT"); contract != "" {
+		startFilterPublishing(caManager, "ca_test_one", common.HexToAddress(contract))
+	}
+
+	// ACME 服务：标准ACME客户端从 ca_test_one 申请匿名证书
+	acmeServer := cer_ca_tools.NewACMEServer(caManager, "ca_test_one")
+	acmeServer.HTTP01Address = os.Getenv("ACME_HTTP01_ADDRESS")
+	server.Handle("/acme/", acmeServer)
+	log.Println(" ACME directory:", acmeServer.DirectoryURL())
+
+	// 收到 SIGINT/SIGTERM 后停止接受新请求，等待进行中的请求完成再退出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	log.Println(" Starting HTTP server on :8080")
-	if err := http.ListenAndServe(":8080", nil); err != nil {
+	if err := server.ListenAndServe(ctx); err != nil {
 		log.Fatal(err)
 	}
+	log.Println(" HTTP server stopped")
 
 	// 阻塞主程序，防止退出
 	select {}
 
 }
 
+func enableCTLog(caManager *cer_ca_tools.CAManager, certsDir string) error {
+	keyPath := filepath.Join(certsDir, "ct_log.key")
+	var logKey crypto.Signer
+	if keyPEM, err := os.ReadFile(keyPath); err == nil {
+		if logKey, err = cer_ca_tools.ParsePrivateKeyPEM(keyPEM, cer_ca_tools.PassphraseFromEnv()); err != nil {
+			return err
+		}
+	} else {
+		if logKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			return err
+		}
+		keyPEM, err := cer_ca_tools.MarshalPrivateKeyPEM(logKey, cer_ca_tools.PassphraseFromEnv())
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(keyPath, keyPEM, 0o600); err != nil {
+			return err
+		}
+	}
+
+	if err := caManager.EnableCTLog(logKey, filepath.Join(certsDir, "ct.log")); err != nil {
+		return err
+	}
+	publicKeyPEM, err := caManager.CT.PublicKeyPEM()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(certsDir, "ct_log.pub"), publicKeyPEM, 0o644)
+}
+
+func dialChain() *client.Client {
+	configs, err := conf.ParseConfigFile("config.toml")
+	if err != nil {
+		log.Fatalf("读取链配置失败: %v", err)
+	}
+	c, err := client.Dial(&configs[0])
+	if err != nil {
+		log.Fatalf("连接区块链节点失败: %v", err)
+	}
+	return c
+}
+
+func startAuditAnchoring(auditLog *cer_ca_tools.AuditLog, address common.Address) {
+	anchor, err := cer_ca_tools.NewChainAuditAnchor(dialChain(), address)
+	if err != nil {
+		log.Fatal(err)
+	}
+	auditLog.StartAnchoring(anchor, time.Hour)
+	log.Println(" audit log anchored to contract", address.Hex())
+}
+
+// startFilterPublishing 每隔 10 分钟检查CA的过滤器版本，有新版本时写入链上
+func startFilterPublishing(caManager *cer_ca_tools.CAManager, caName string, address common.Address) {
+	service, err := cer_chain_tools.NewRevocationFilterService(dialChain(), address)
+	if err != nil {
+		log.Fatal(err)
+	}
+	publish := func() error {
+		ca, exists := caManager.GetCAInfo(caName)
+		if !exists {
+			return fmt.Errorf("CA %s not found", caName)
+		}
+		data, version, err := ca.FilterSnapshot()
+		if err != nil {
+			return err
+		}
+		state, err := service.GetFilterState(service.CAAddress())
+		if err != nil {
+			return err
+		}
+		if state.Version == version {
+			return nil
+		}
+		if state.Version > version {
+			// 合约要求版本严格递增，链上版本领先时无法继续发布，需人工处理
+			return fmt.Errorf("CA %s filter version %d is behind version %d on chain", caName, version, state.Version)
+		}
+		var snapshot cer_ca_tools.FilterSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return err
+		}
+		filter := &cer_ca_tools.CountingBloomFilter{}
+		if err := filter.UnmarshalBinary(snapshot.Filter); err != nil {
+			return err
+		}
+		if _, err := service.PushFilter(snapshot.Version, filter); err != nil {
+			return err
+		}
+		log.Printf(" revocation filter of CA %s version %d published to contract %s", caName, snapshot.Version, address.Hex())
+		return nil
+	}
+
+	go func() {
+		ticker := time.NewTicker(10 * time.Minute)
+		defer ticker.Stop()
+		for {
+			if err := publish(); err != nil {
+				log.Printf("发布撤销过滤器失败: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
 func BCCBFSet() {
-	bloomFilter := cer_ca_tools.NewCountingBloomFilter(100, 0.01, 1)
+	bloomFilter := cer_ca_tools.NewCountingBloomFilter(100, 0.01, 4)
 
 	//revokedSerials := []string{
 	//	"00000000000000000",
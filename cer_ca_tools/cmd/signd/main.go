@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"github.com/FISCO-BCOS/go-sdk/cer_ca_tools"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// 签名守护进程：保管CA私钥，CA服务通过 Unix socket 请求签名
+// 私钥口令取自 CA_KEY_PASSPHRASE，CA服务设置 CA_SIGND_SOCKET 指向同一 socket
+func main() {
+	keyDir := flag.String("keys", "certs/ca", "CA私钥目录")
+	socketPath := flag.String("socket", "certs/signd.sock", "Unix socket 路径")
+	flag.Parse()
+
+	passphrase := cer_ca_tools.PassphraseFromEnv()
+	if passphrase == nil {
+		log.Printf("warning: %s is not set, keys generated by the daemon are stored unencrypted", cer_ca_tools.KeyPassphraseEnv)
+	}
+
+	daemon, err := cer_ca_tools.NewSigningDaemon(*keyDir, passphrase)
+	if err != nil {
+		log.Fatalf("加载CA私钥失败: %v", err)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		daemon.Close()
+	}()
+
+	if err := daemon.ListenAndServe(*socketPath); err != nil {
+		log.Fatalf("签名守护进程退出: %v", err)
+	}
+	os.Remove(*socketPath)
+}
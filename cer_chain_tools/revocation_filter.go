@@ -0,0 +1,229 @@
+package cer_chain_tools
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/FISCO-BCOS/go-sdk/abi"
+	"github.com/FISCO-BCOS/go-sdk/abi/bind"
+	"github.com/FISCO-BCOS/go-sdk/cer_ca_tools"
+	"github.com/FISCO-BCOS/go-sdk/client"
+	"github.com/FISCO-BCOS/go-sdk/core/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// RevocationFilterABI kvtabletest/RevocationFilter.sol 的接口
+const RevocationFilterABI = `[{"inputs":[{"internalType":"address","name":"registryAddress","type":"address"}],"stateMutability":"nonpayable","type":"constructor"},{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"caAddress","type":"address"},{"indexed":false,"internalType":"uint256","name":"chunks","type":"uint256"},{"indexed":false,"internalType":"uint256","name":"timeStamp","type":"uint256"}],"name":"FilterChunksEvent","type":"event"},{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"caAddress","type":"address"},{"indexed":false,"internalType":"uint64","name":"version","type":"uint64"},{"indexed":false,"internalType":"bytes32","name":"digest","type":"bytes32"},{"indexed":false,"internalType":"bool","name":"withCells","type":"bool"},{"indexed":false,"internalType":"uint256","name":"timeStamp","type":"uint256"}],"name":"FilterUpdateEvent","type":"event"},{"inputs":[{"internalType":"uint64","name":"version","type":"uint64"},{"internalType":"bytes32","name":"digest","type":"bytes32"},{"internalType":"bytes","name":"header","type":"bytes"},{"internalType":"uint256","name":"chunkCount","type":"uint256"}],"name":"commitFilter","outputs":[{"internalType":"bool","name":"","type":"bool"}],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address","name":"caAddress","type":"address"},{"internalType":"uint256","name":"start","type":"uint256"},{"internalType":"uint256","name":"count","type":"uint256"}],"name":"getChunks","outputs":[{"internalType":"bytes32[]","name":"","type":"bytes32[]"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"address","name":"caAddress","type":"address"}],"name":"getFilter","outputs":[{"internalType":"uint64","name":"version","type":"uint64"},{"internalType":"bytes32","name":"digest","type":"bytes32"},{"internalType":"uint64","name":"cellsVersion","type":"uint64"},{"internalType":"bytes","name":"header","type":"bytes"},{"internalType":"uint256","name":"chunkCount","type":"uint256"},{"internalType":"uint256","name":"updatedAt","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"uint64","name":"version","type":"uint64"},{"internalType":"bytes32","name":"digest","type":"bytes32"}],"name":"publishDigest","outputs":[{"internalType":"bool","name":"","type":"bool"}],"stateMutability":"nonpayable","type":"function"},{"inputs":[],"name":"registry","outputs":[{"internalType":"contract ICARegister","name":"","type":"address"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"uint256[]","name":"indexes","type":"uint256[]"},{"internalType":"bytes32[]","name":"chunks","type":"bytes32[]"}],"name":"writeChunks","outputs":[{"internalType":"bool","name":"","type":"bool"}],"stateMutability":"nonpayable","type":"function"}]`
+
+const (
+	// ChunkSize 链上每个计数器块的字节数
+	ChunkSize = 32
+	// MaxChunksPerTx 每笔交易最多写入的块数
+	MaxChunksPerTx = 256
+)
+
+var (
+	ErrFilterNotPublished = errors.New("revocation filter is not published on chain")
+	ErrCellsOutdated      = errors.New("on-chain revocation filter cells are not at the published version")
+	ErrDigestMismatch     = errors.New("revocation filter digest does not match the chain")
+)
+
+// FilterState 链上某个CA的过滤器状态，字段与 getFilter 的返回值对应
+type FilterState struct {
+	Version      uint64
+	Digest       [32]byte
+	CellsVersion uint64
+	Header       []byte
+	ChunkCount   *big.Int
+	UpdatedAt    *big.Int
+}
+
+// RevocationFilterService 通过 SDK client 读写链上 RevocationFilter 合约。
+// 发送交易的账户即CA的链上地址，需在 ca_register 中注册且状态为 Normal
+type RevocationFilterService struct {
+	contract *bind.BoundContract
+	auth     *bind.TransactOpts
+	client   *client.Client
+}
+
+// NewRevocationFilterService 绑定已部署在 address 的 RevocationFilter 合约
+func NewRevocationFilterService(c *client.Client, address common.Address) (*RevocationFilterService, error) {
+	parsed, err := abi.JSON(strings.NewReader(RevocationFilterABI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse RevocationFilter ABI: %w", err)
+	}
+	return &RevocationFilterService{
+		contract: bind.NewBoundContract(address, parsed, c, c, c),
+		auth:     c.GetTransactOpts(),
+		client:   c,
+	}, nil
+}
+
+// CAAddress 返回本服务发送交易的CA地址
+func (service *RevocationFilterService) CAAddress() common.Address {
+	return service.auth.From
+}
+
+// PushFilter 把过滤器的第 version 版写入链上：只写入与链上不同的计数器块，最后提交版本与摘要
+func (service *RevocationFilterService) PushFilter(version uint64, filter *cer_ca_tools.CountingBloomFilter) (*types.Receipt, error) {
+	data, err := filter.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	header, cells := filter.MarshalCells()
+	chunks := splitChunks(cells)
+
+	state, err := service.GetFilterState(service.CAAddress())
+	if err != nil {
+		return nil, err
+	}
+	if version <= state.Version {
+		return nil, fmt.Errorf("filter version %d is not newer than %d on chain", version, state.Version)
+	}
+	existing, err := service.GetChunks(service.CAAddress(), 0, state.ChunkCount.Uint64())
+	if err != nil {
+		return nil, err
+	}
+
+	indexes, values := diffChunks(existing, chunks)
+	for start := 0; start < len(indexes); start += MaxChunksPerTx {
+		end := min(start+MaxChunksPerTx, len(indexes))
+		if _, err := service.transact("writeChunks", indexes[start:end], values[start:end]); err != nil {
+			return nil, err
+		}
+	}
+	return service.transact("commitFilter", version, sha256.Sum256(data), header, big.NewInt(int64(len(chunks))))
+}
+
+// PublishDigest 只发布第 version 版过滤器编码（CountingBloomFilter.MarshalBinary）的 SHA-256
+func (service *RevocationFilterService) PublishDigest(version uint64, digest [32]byte) (*types.Receipt, error) {
+	return service.transact("publishDigest", version, digest)
+}
+
+func (service *RevocationFilterService) transact(method string, params ...interface{}) (*types.Receipt, error) {
+	_, receipt, err := service.contract.Transact(service.auth, method, params...)
+	if err != nil {
+		return nil, fmt.Errorf("RevocationFilterService %s failed: %w", method, err)
+	}
+	if receipt.Status != types.Success {
+		return receipt, fmt.Errorf("RevocationFilterService %s failed: %s", method, receipt.GetErrorMessage())
+	}
+	return receipt, nil
+}
+
+// GetFilterState 读取CA在链上的过滤器状态
+func (service *RevocationFilterService) GetFilterState(caAddress common.Address) (*FilterState, error) {
+	state := new(FilterState)
+	if err := service.contract.Call(service.client.GetCallOpts(), state, "getFilter", caAddress); err != nil {
+		return nil, fmt.Errorf("RevocationFilterService getFilter failed: %w", err)
+	}
+	return state, nil
+}
+
+// GetChunks 读取 [start, start+count) 的计数器块，按 MaxChunksPerTx 分批调用
+func (service *RevocationFilterService) GetChunks(caAddress common.Address, start, count uint64) ([][32]byte, error) {
+	chunks := make([][32]byte, 0, count)
+	for offset := uint64(0); offset < count; offset += MaxChunksPerTx {
+		batch := min(count-offset, MaxChunksPerTx)
+		var result [][32]byte
+		err := service.contract.Call(service.client.GetCallOpts(), &result, "getChunks",
+			caAddress, new(big.Int).SetUint64(start+offset), new(big.Int).SetUint64(batch))
+		if err != nil {
+			return nil, fmt.Errorf("RevocationFilterService getChunks failed: %w", err)
+		}
+		chunks = append(chunks, result...)
+	}
+	return chunks, nil
+}
+
+// LoadFilter 由链上计数器重建CA的过滤器，并以链上摘要校验
+func (service *RevocationFilterService) LoadFilter(caAddress common.Address) (*cer_ca_tools.CountingBloomFilter, *FilterState, error) {
+	state, err := service.GetFilterState(caAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+	if state.Version == 0 {
+		return nil, nil, ErrFilterNotPublished
+	}
+	if state.CellsVersion != state.Version {
+		return nil, state, fmt.Errorf("%w: cells at %d, published %d", ErrCellsOutdated, state.CellsVersion, state.Version)
+	}
+	chunks, err := service.GetChunks(caAddress, 0, state.ChunkCount.Uint64())
+	if err != nil {
+		return nil, state, err
+	}
+	filter, err := rebuildFilter(state, chunks)
+	if err != nil {
+		return nil, state, err
+	}
+	return filter, state, nil
+}
+
+// CheckSnapshot 校验从CA获取的过滤器编码与链上发布的版本和摘要一致
+func (service *RevocationFilterService) CheckSnapshot(caAddress common.Address, version uint64, data []byte) error {
+	state, err := service.GetFilterState(caAddress)
+	if err != nil {
+		return err
+	}
+	return checkDigest(state, version, data)
+}
+
+func checkDigest(state *FilterState, version uint64, data []byte) error {
+	if state.Version == 0 {
+		return ErrFilterNotPublished
+	}
+	if state.Version != version {
+		return fmt.Errorf("%w: version %d, chain has %d", ErrDigestMismatch, version, state.Version)
+	}
+	if digest := sha256.Sum256(data); digest != state.Digest {
+		return fmt.Errorf("%w: version %d", ErrDigestMismatch, version)
+	}
+	return nil
+}
+
+// rebuildFilter 拼接计数器块并重建过滤器，重新编码后的摘要必须与链上一致
+func rebuildFilter(state *FilterState, chunks [][32]byte) (*cer_ca_tools.CountingBloomFilter, error) {
+	cells := make([]byte, 0, len(chunks)*ChunkSize)
+	for _, chunk := range chunks {
+		cells = append(cells, chunk[:]...)
+	}
+	filter, err := cer_ca_tools.UnmarshalCells(state.Header, cells)
+	if err != nil {
+		return nil, err
+	}
+	data, err := filter.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDigest(state, state.CellsVersion, data); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// splitChunks 把计数器字节按 ChunkSize 分块，末块补零
+func splitChunks(cells []byte) [][32]byte {
+	chunks := make([][32]byte, (len(cells)+ChunkSize-1)/ChunkSize)
+	for i := range chunks {
+		copy(chunks[i][:], cells[i*ChunkSize:])
+	}
+	return chunks
+}
+
+// diffChunks 返回需要写入的块。existing 之外的块在链上的内容未知（可能是未提交的写入），一律写入
+func diffChunks(existing, chunks [][32]byte) ([]*big.Int, [][32]byte) {
+	var indexes []*big.Int
+	var values [][32]byte
+	for i, chunk := range chunks {
+		if i < len(existing) && bytes.Equal(existing[i][:], chunk[:]) {
+			continue
+		}
+		indexes = append(indexes, big.NewInt(int64(i)))
+		values = append(values, chunk)
+	}
+	return indexes, values
+}
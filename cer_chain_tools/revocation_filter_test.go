@@ -0,0 +1,104 @@
+package cer_chain_tools
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/FISCO-BCOS/go-sdk/abi"
+	"github.com/FISCO-BCOS/go-sdk/cer_ca_tools"
+)
+
+func TestRevocationFilterABI(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(RevocationFilterABI))
+	if err != nil {
+		t.Fatalf("parse ABI failed: %v", err)
+	}
+	for name, signature := range map[string]string{
+		"writeChunks":   "writeChunks(uint256[],bytes32[])",
+		"commitFilter":  "commitFilter(uint64,bytes32,bytes,uint256)",
+		"publishDigest": "publishDigest(uint64,bytes32)",
+		"getFilter":     "getFilter(address)",
+		"getChunks":     "getChunks(address,uint256,uint256)",
+	} {
+		if method, ok := parsed.Methods[name]; !ok || method.Sig() != signature {
+			t.Fatalf("method %s should have signature %s", name, signature)
+		}
+	}
+
+	digest := sha256.Sum256([]byte("filter"))
+	output, err := parsed.Methods["getFilter"].Outputs.Pack(uint64(7), digest, uint64(7), []byte("header"), big.NewInt(3), big.NewInt(1700000000))
+	if err != nil {
+		t.Fatalf("pack getFilter output failed: %v", err)
+	}
+	state := new(FilterState)
+	if err := parsed.Unpack(state, "getFilter", output); err != nil {
+		t.Fatalf("unpack getFilter output failed: %v", err)
+	}
+	if state.Version != 7 || state.CellsVersion != 7 || state.Digest != digest || string(state.Header) != "header" || state.ChunkCount.Int64() != 3 {
+		t.Fatalf("unexpected filter state %+v", state)
+	}
+
+	chunks := [][32]byte{{1}, {2}}
+	if output, err = parsed.Methods["getChunks"].Outputs.Pack(chunks); err != nil {
+		t.Fatalf("pack getChunks output failed: %v", err)
+	}
+	var result [][32]byte
+	if err := parsed.Unpack(&result, "getChunks", output); err != nil || len(result) != 2 || result[1] != chunks[1] {
+		t.Fatalf("unpack getChunks output failed: %v", err)
+	}
+}
+
+func TestRebuildFilterFromChunks(t *testing.T) {
+	filter := cer_ca_tools.NewCountingBloomFilter(1000, 0.01, 4)
+	for i := 0; i < 100; i++ {
+		filter.AddElement([]byte(fmt.Sprint(i)))
+	}
+	header, cells := filter.MarshalCells()
+	chunks := splitChunks(cells)
+	data, _ := filter.MarshalBinary()
+	state := &FilterState{Version: 2, CellsVersion: 2, Digest: sha256.Sum256(data), Header: header, ChunkCount: big.NewInt(int64(len(chunks)))}
+
+	rebuilt, err := rebuildFilter(state, chunks)
+	if err != nil {
+		t.Fatalf("rebuild filter failed: %v", err)
+	}
+	for i := 0; i < 200; i++ {
+		element := []byte(fmt.Sprint(i))
+		if rebuilt.QueryElement(element) != filter.QueryElement(element) {
+			t.Fatalf("rebuilt filter answers differently for %d", i)
+		}
+	}
+	if err := checkDigest(state, 2, data); err != nil {
+		t.Fatalf("snapshot should match the chain: %v", err)
+	}
+	if err := checkDigest(state, 1, data); !errors.Is(err, ErrDigestMismatch) {
+		t.Fatalf("snapshot of another version should not match, have %v", err)
+	}
+
+	tampered := append([][32]byte(nil), chunks...)
+	tampered[0][0] ^= 0x01
+	if _, err := rebuildFilter(state, tampered); !errors.Is(err, ErrDigestMismatch) {
+		t.Fatalf("tampered chunks should not match the digest, have %v", err)
+	}
+
+	// 只写入变化的块，链上尚不存在的块全部写入
+	filter.AddElement([]byte("revoked"))
+	_, cells = filter.MarshalCells()
+	updated := splitChunks(cells)
+	indexes, values := diffChunks(chunks, updated)
+	if len(indexes) == 0 || len(indexes) > int(filter.GetStats()["hash_count"].(uint)) {
+		t.Fatalf("expected at most k changed chunks, have %d", len(indexes))
+	}
+	for i, index := range indexes {
+		if values[i] != updated[index.Int64()] || values[i] == chunks[index.Int64()] {
+			t.Fatalf("chunk %d should be written with its new value", index)
+		}
+	}
+	if indexes, _ = diffChunks(chunks[:len(chunks)-1], chunks); len(indexes) != 1 || indexes[0].Int64() != int64(len(chunks)-1) {
+		t.Fatalf("chunks beyond the on-chain count should always be written")
+	}
+}
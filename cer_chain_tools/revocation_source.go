@@ -0,0 +1,82 @@
+package cer_chain_tools
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/FISCO-BCOS/go-sdk/cer_ca_tools"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var ErrChainFilterHit = errors.New("serial is in the on-chain revocation filter")
+
+// FilterReader 读取CA在链上发布的撤销过滤器，RevocationFilterService 实现了该接口
+type FilterReader interface {
+	GetFilterState(caAddress common.Address) (*FilterState, error)
+	LoadFilter(caAddress common.Address) (*cer_ca_tools.CountingBloomFilter, *FilterState, error)
+}
+
+// ChainRevocationSource 以链上发布的撤销过滤器作为撤销状态来源，可用作验证方的 RevocationSource。
+// 链上过滤器经过摘要校验，不在其中的证书确定未被撤销；命中时仍可能是误判，返回错误交由其他来源确认
+type ChainRevocationSource struct {
+	service FilterReader
+
+	mutex     sync.Mutex
+	addresses map[string]common.Address // 按签发者 RawSubject 索引的CA链上地址
+	filters   map[common.Address]*cer_ca_tools.CountingBloomFilter
+	versions  map[common.Address]uint64
+}
+
+// NewChainRevocationSource 创建链上撤销状态来源，需通过 AddCA 登记各CA的链上地址
+func NewChainRevocationSource(service FilterReader) *ChainRevocationSource {
+	return &ChainRevocationSource{
+		service:   service,
+		addresses: make(map[string]common.Address),
+		filters:   make(map[common.Address]*cer_ca_tools.CountingBloomFilter),
+		versions:  make(map[common.Address]uint64),
+	}
+}
+
+// AddCA 登记 issuer 在 ca_register 中的链上地址
+func (source *ChainRevocationSource) AddCA(issuer *x509.Certificate, address common.Address) {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+
+	source.addresses[string(issuer.RawSubject)] = address
+}
+
+func (source *ChainRevocationSource) Name() string {
+	return "chain"
+}
+
+// CheckRevocation 读取链上过滤器版本，版本变化时重新加载计数器。链上调用不支持 ctx 取消
+func (source *ChainRevocationSource) CheckRevocation(_ context.Context, cert, issuer *x509.Certificate) (bool, error) {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+
+	address, exists := source.addresses[string(issuer.RawSubject)]
+	if !exists {
+		return false, fmt.Errorf("no chain address for CA %s", issuer.Subject)
+	}
+	state, err := source.service.GetFilterState(address)
+	if err != nil {
+		return false, err
+	}
+	filter := source.filters[address]
+	if filter == nil || source.versions[address] != state.Version {
+		if filter, state, err = source.service.LoadFilter(address); err != nil {
+			return false, err
+		}
+		source.filters[address] = filter
+		source.versions[address] = state.Version
+	}
+
+	// 过滤器元素与CA一致，为十进制序列号
+	if filter.QueryElement([]byte(cert.SerialNumber.String())) {
+		return false, fmt.Errorf("%w: version %d", ErrChainFilterHit, source.versions[address])
+	}
+	return false, nil
+}
@@ -0,0 +1,86 @@
+package cer_chain_tools
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/FISCO-BCOS/go-sdk/cer_ca_tools"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// fakeFilterReader 以内存中的过滤器模拟链上 RevocationFilter 合约
+type fakeFilterReader struct {
+	state  FilterState
+	filter *cer_ca_tools.CountingBloomFilter
+	loads  int
+	err    error
+}
+
+func (reader *fakeFilterReader) GetFilterState(common.Address) (*FilterState, error) {
+	if reader.err != nil {
+		return nil, reader.err
+	}
+	state := reader.state
+	return &state, nil
+}
+
+func (reader *fakeFilterReader) LoadFilter(common.Address) (*cer_ca_tools.CountingBloomFilter, *FilterState, error) {
+	if reader.state.Version == 0 {
+		return nil, nil, ErrFilterNotPublished
+	}
+	reader.loads++
+	data, err := reader.filter.MarshalBinary()
+	if err != nil {
+		return nil, nil, err
+	}
+	filter := &cer_ca_tools.CountingBloomFilter{}
+	if err := filter.UnmarshalBinary(data); err != nil {
+		return nil, nil, err
+	}
+	state := reader.state
+	return filter, &state, nil
+}
+
+func TestChainRevocationSource(t *testing.T) {
+	issuer := &x509.Certificate{RawSubject: []byte("chain source test CA")}
+	revoked := &x509.Certificate{SerialNumber: big.NewInt(1001)}
+	good := &x509.Certificate{SerialNumber: big.NewInt(2002)}
+	reader := &fakeFilterReader{filter: cer_ca_tools.NewCountingBloomFilter(100, 0.001, 4)}
+	source := NewChainRevocationSource(reader)
+	ctx := context.Background()
+
+	if _, err := source.CheckRevocation(ctx, good, issuer); err == nil {
+		t.Fatalf("unregistered CA should not be answered")
+	}
+	source.AddCA(issuer, common.HexToAddress("0x1"))
+	if _, err := source.CheckRevocation(ctx, good, issuer); !errors.Is(err, ErrFilterNotPublished) {
+		t.Fatalf("unpublished filter should not be answered, have %v", err)
+	}
+
+	reader.filter.AddElement([]byte(revoked.SerialNumber.String()))
+	reader.state.Version = 1
+	if revoked, err := source.CheckRevocation(ctx, good, issuer); err != nil || revoked {
+		t.Fatalf("filter miss should be a definite good, have %v %v", revoked, err)
+	}
+	// 命中可能是误判，只能交给其他来源确认
+	if _, err := source.CheckRevocation(ctx, revoked, issuer); !errors.Is(err, ErrChainFilterHit) {
+		t.Fatalf("filter hit should not be definite, have %v", err)
+	}
+	if reader.loads != 1 {
+		t.Fatalf("unchanged version should reuse the loaded filter, loaded %d times", reader.loads)
+	}
+
+	reader.filter.AddElement([]byte(good.SerialNumber.String()))
+	reader.state.Version = 2
+	if _, err := source.CheckRevocation(ctx, good, issuer); !errors.Is(err, ErrChainFilterHit) || reader.loads != 2 {
+		t.Fatalf("new version should be reloaded, have %v after %d loads", err, reader.loads)
+	}
+
+	reader.err = errors.New("node unreachable")
+	if _, err := source.CheckRevocation(ctx, good, issuer); !errors.Is(err, reader.err) {
+		t.Fatalf("chain errors should be returned, have %v", err)
+	}
+}
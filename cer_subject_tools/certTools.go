@@ -1,7 +1,7 @@
 package cer_subject_tools
 
 import (
-	"crypto/ecdsa"
+	"crypto"
 	"crypto/rand"
 	"crypto/x509"
 	"crypto/x509/pkix"
@@ -25,7 +25,7 @@ func PrintCertInfo(cert *x509.Certificate) {
 	fmt.Println("-----END CERTIFICATE-----")
 }
 
-func GenerateCert(isCA bool, caPrivateKey *ecdsa.PrivateKey, caCert *x509.Certificate, subjectPublicKey *ecdsa.PublicKey, subject pkix.Name, issuer pkix.Name, days int) (*x509.Certificate, []byte, error) {
+func GenerateCert(isCA bool, caPrivateKey crypto.Signer, caCert *x509.Certificate, subjectPublicKey crypto.PublicKey, subject pkix.Name, issuer pkix.Name, days int) (*x509.Certificate, []byte, error) {
 	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
 	SerialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
 	if err != nil {
@@ -35,24 +35,22 @@ func GenerateCert(isCA bool, caPrivateKey *ecdsa.PrivateKey, caCert *x509.Certif
 	notBefore := time.Now()
 	notAfter := notBefore.Add(time.Duration(days) * 24 * time.Hour)
 	certTemplate := x509.Certificate{
-		Version:            3,
-		SerialNumber:       SerialNumber,
-		Issuer:             issuer,
-		Subject:            subject,
-		PublicKeyAlgorithm: 3,
-		PublicKey:          subjectPublicKey,
-		NotBefore:          notBefore,
-		NotAfter:           notAfter,
-		Extensions:         nil,
-		SignatureAlgorithm: 10,
-		Signature:          nil,
-		IsCA:               isCA,
+		Version:      3,
+		SerialNumber: SerialNumber,
+		Issuer:       issuer,
+		Subject:      subject,
+		PublicKey:    subjectPublicKey,
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		Extensions:   nil,
+		Signature:    nil,
+		IsCA:         isCA,
 	}
 
 	if isCA {
 		certTemplate.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
 		certTemplate.BasicConstraintsValid = true
-		certDER, err := x509.CreateCertificate(rand.Reader, &certTemplate, &certTemplate, &caPrivateKey.PublicKey, caPrivateKey)
+		certDER, err := x509.CreateCertificate(rand.Reader, &certTemplate, &certTemplate, caPrivateKey.Public(), caPrivateKey)
 		cert, err := x509.ParseCertificate(certDER)
 		return cert, certDER, err
 	} else {
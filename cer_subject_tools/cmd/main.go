@@ -1,13 +1,13 @@
 package main
 
 import (
-	"crypto/ecdsa"
-	"crypto/x509"
+	"crypto"
 	"crypto/x509/pkix"
 	"encoding/pem"
 	"fmt"
 	"github.com/FISCO-BCOS/go-sdk/cer_ca_tools"
 	"github.com/FISCO-BCOS/go-sdk/cer_subject_tools"
+	"github.com/FISCO-BCOS/go-sdk/smcrypto"
 	"log"
 	"os"
 	"path/filepath"
@@ -43,20 +43,20 @@ func setupSubjectHTTP(cg *cer_ca_tools.CertGenerator) {
 
 	subKeyBlock, _ := pem.Decode(subKeyPEM)
 
-	subPrivKey, err := x509.ParsePKCS8PrivateKey(subKeyBlock.Bytes)
+	subPrivKey, err := smcrypto.ParsePKCS8PrivateKey(subKeyBlock.Bytes)
 	if err != nil {
 		log.Fatalf("<UNK> '%s' <UNK>: %v", subPrivKey, err)
 	}
 
-	// 类型断言为 *ecdsa.PrivateKey
-	ecdsaPrivKey, ok := subPrivKey.(*ecdsa.PrivateKey)
+	// ECDSA、Ed25519 与 SM2 私钥均实现 crypto.Signer
+	signer, ok := subPrivKey.(crypto.Signer)
 	if !ok {
-		log.Fatalf("CA私钥类型错误，期望*ecdsa.PrivateKey，得到%T", subPrivKey)
+		log.Fatalf("证书主体私钥类型错误，期望crypto.Signer，得到%T", subPrivKey)
 	}
 
-	subject.PrivateKey = ecdsaPrivKey
+	subject.PrivateKey = signer
 
-	subject.PublicKey = &ecdsaPrivKey.PublicKey
+	subject.PublicKey = signer.Public()
 
 	subjectInfo := pkix.Name{
 		Country:            []string{"CN"},
@@ -58,7 +58,7 @@ func (crt *CRTOperations) RequestModulus(caURL, caName string, subjectID string)
 		return nil, fmt.Errorf("failed to marshal modulus request: %w", err)
 	}
 
-	url := fmt.Sprintf("%s/certificate/modulus/request?caName=%s", caURL, caName)
+	url := fmt.Sprintf("%s/v1/certificate/modulus/request?caName=%s", caURL, caName)
 
 	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
 	if err != nil {
@@ -210,7 +210,7 @@ func (crt *CRTOperations) SendXORResultToCA(caURL string, caName string, subject
 		return fmt.Errorf("failed to marshal XOR request: %w", err)
 	}
 
-	url := fmt.Sprintf("%s/certificate/xorResult?caName=%s", caURL, caName)
+	url := fmt.Sprintf("%s/v1/certificate/modulus/xor?caName=%s", caURL, caName)
 
 	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
 	if err != nil {
@@ -0,0 +1,77 @@
+package cer_subject_tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"github.com/FISCO-BCOS/go-sdk/cer_ca_tools"
+	"io"
+	"math/big"
+	"net/http"
+
+	"golang.org/x/crypto/acme"
+)
+
+// CompleteACMEAnonChallenge 用 CRT/XOR 匿名化材料完成 anoncert-crt-01 挑战
+// 标准ACME客户端只能以空载荷响应挑战，这里用账户密钥自行签名挑战响应
+func (s *Subject) CompleteACMEAnonChallenge(ctx context.Context, client *acme.Client, challenge *acme.Challenge, subjectInfo pkix.Name, xorResult []byte, remainders []*big.Int) error {
+	if challenge.Type != cer_ca_tools.ACMEChallengeAnonCRT {
+		return fmt.Errorf("unexpected challenge type %s", challenge.Type)
+	}
+	directory, err := client.Discover(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch ACME directory: %w", err)
+	}
+	thumbprint, err := cer_ca_tools.ACMEKeyThumbprint(client.Key.Public())
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(cer_ca_tools.ACMEAnonCRTResponse{
+		KeyAuthorization: challenge.Token + "." + thumbprint,
+		SubjectInfo:      subjectInfo,
+		XORResult:        xorResult,
+		Remainders:       remainders,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal challenge response: %w", err)
+	}
+
+	nonceResponse, err := http.Head(directory.NonceURL)
+	if err != nil {
+		return fmt.Errorf("failed to fetch ACME nonce: %w", err)
+	}
+	nonceResponse.Body.Close()
+	body, err := cer_ca_tools.SignACMERequest(client.Key, string(client.KID), nonceResponse.Header.Get("Replay-Nonce"), challenge.URI, payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.Post(challenge.URI, "application/jose+json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to send challenge response: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to send challenge response: %s", string(respBody))
+	}
+	var result struct {
+		Status string `json:"status"`
+		Error  *struct {
+			Detail string `json:"detail"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+	if result.Error != nil {
+		return fmt.Errorf("challenge %s: %s", result.Status, result.Error.Detail)
+	}
+	return nil
+}
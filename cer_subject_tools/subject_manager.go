@@ -2,12 +2,12 @@ package cer_subject_tools
 
 import (
 	"bytes"
-	"crypto/ecdsa"
-	"crypto/rand"
+	"crypto"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/json"
 	"fmt"
+	"github.com/FISCO-BCOS/go-sdk/cer_ca_tools"
 	"io"
 	"log"
 	"math/big"
@@ -19,8 +19,8 @@ const defaultServerURL = "http://localhost:8080"
 
 type Subject struct {
 	SubjectURL string
-	PublicKey  *ecdsa.PublicKey  `json:"public_key"`
-	PrivateKey *ecdsa.PrivateKey `json:"private_key"`
+	PublicKey  crypto.PublicKey `json:"public_key"`
+	PrivateKey crypto.Signer    `json:"private_key"` // P-256、P-384、Ed25519 或 SM2 私钥
 }
 
 type CertificateRequest struct {
@@ -34,6 +34,7 @@ type CertificateResponse struct {
 	Success     bool   `json:"success"`
 	Message     string `json:"message"`
 	Certificate string `json:"certificate"`
+	Chain       string `json:"chain,omitempty"` // 叶子证书、中间CA直至根CA的PEM证书包
 	Err         error  `json:"error,omitempty"`
 }
 
@@ -51,27 +52,34 @@ func NewSubject(SubjectURL string) *Subject {
 	}
 }
 
+// GenerateKey 按算法生成证书主体密钥，空算法使用 cer_ca_tools.DefaultKeyAlgorithm，
+// SM2 密钥需向国密CA申请证书
+func (s *Subject) GenerateKey(algorithm cer_ca_tools.KeyAlgorithm) error {
+	key, err := cer_ca_tools.GenerateKey(algorithm)
+	if err != nil {
+		return err
+	}
+	s.PrivateKey, s.PublicKey = key, key.Public()
+	return nil
+}
+
 func (s *Subject) CreateCertIssueRequest(subjectInfo pkix.Name) (*x509.CertificateRequest, error) {
 	if s.PrivateKey == nil {
 		return nil, fmt.Errorf("private key not provided")
 	}
 
+	// 公钥与签名算法由私钥类型决定
 	template := x509.CertificateRequest{
-		Version:            3,
-		Subject:            subjectInfo,
-		PublicKeyAlgorithm: 3,
-		PublicKey:          s.PublicKey,
-		Signature:          nil,
-		SignatureAlgorithm: 10,
-		Extensions:         nil,
+		Version: 3,
+		Subject: subjectInfo,
 	}
 
-	cirDER, err := x509.CreateCertificateRequest(rand.Reader, &template, s.PrivateKey)
+	cirDER, err := cer_ca_tools.CreateCSR(&template, s.PrivateKey)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to create CSR: %s", err)
 	}
 
-	cir, err := x509.ParseCertificateRequest(cirDER)
+	cir, err := cer_ca_tools.ParseCSR(cirDER)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to parse CSR: %s", err)
 	}
@@ -80,25 +88,17 @@ func (s *Subject) CreateCertIssueRequest(subjectInfo pkix.Name) (*x509.Certifica
 }
 
 func (s *Subject) SendCertificateIssueRequest(caName string, cir *x509.CertificateRequest, xorResult []byte, remainders []*big.Int) (*CertificateResponse, error) {
-	publicKeyBytes, err := x509.MarshalPKIXPublicKey(cir.PublicKey)
-	if err != nil {
-		return nil, fmt.Errorf("Failed to serialize public key: %s", err)
-	}
-
+	// 发送完整CSR，CA通过CSR签名验证请求者持有私钥
 	anonCertRequest := struct {
-		SubjectInfo        pkix.Name  `json:"subject"`
-		PublicKeyAlgorithm int        `json:"public_key_algorithm"`
-		PublicKeyBytes     []byte     `json:"public_key_bytes"`
-		SignatureAlgorithm int        `json:"signature_algorithm"`
-		XORResult          []byte     `json:"xor_result"`
-		Remainders         []*big.Int `json:"remainders"`
+		SubjectInfo pkix.Name  `json:"subject"`
+		CSR         []byte     `json:"csr"`
+		XORResult   []byte     `json:"xor_result"`
+		Remainders  []*big.Int `json:"remainders"`
 	}{
-		SubjectInfo:        cir.Subject,
-		PublicKeyAlgorithm: int(cir.PublicKeyAlgorithm),
-		PublicKeyBytes:     publicKeyBytes,
-		SignatureAlgorithm: int(cir.SignatureAlgorithm),
-		XORResult:          xorResult,
-		Remainders:         remainders,
+		SubjectInfo: cir.Subject,
+		CSR:         cir.Raw,
+		XORResult:   xorResult,
+		Remainders:  remainders,
 	}
 
 	jsonData, err := json.Marshal(anonCertRequest)
@@ -106,7 +106,7 @@ func (s *Subject) SendCertificateIssueRequest(caName string, cir *x509.Certifica
 		return nil, fmt.Errorf("failed to marshal certificate issue request: %w", err)
 	}
 
-	url := fmt.Sprintf("%s/certificate/issue?caName=%s", defaultServerURL, caName)
+	url := fmt.Sprintf("%s/v1/certificate/issue?caName=%s", defaultServerURL, caName)
 
 	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
 	if err != nil {
@@ -158,7 +158,7 @@ func (s *Subject) RequestRevokeCertificate(caName string, serialNumber string, r
 		return nil, fmt.Errorf("failed to marshal revoke request: %w", err)
 	}
 
-	url := fmt.Sprintf("%s/certificate/revoke?caName=%s", defaultServerURL, caName)
+	url := fmt.Sprintf("%s/v1/certificate/revoke?caName=%s", defaultServerURL, caName)
 
 	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
 	if err != nil {
@@ -182,6 +182,56 @@ func (s *Subject) RequestRevokeCertificate(caName string, serialNumber string, r
 	return &revokeResponse, nil
 }
 
+// RequestRenewCertificate 用当前证书的私钥签名续期请求；newKey 不为空时换用新密钥，
+// revokePredecessor 为 true 时CA以 superseded 原因撤销原证书
+func (s *Subject) RequestRenewCertificate(certPEM []byte, newKey crypto.Signer, revokePredecessor bool) (*CertificateResponse, error) {
+	renewal := cer_ca_tools.RenewalRequest{
+		Certificate:       certPEM,
+		RevokePredecessor: revokePredecessor,
+	}
+	if newKey != nil {
+		csrDER, err := cer_ca_tools.CreateCSR(&x509.CertificateRequest{}, newKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create renewal CSR: %w", err)
+		}
+		renewal.CSR = csrDER
+	}
+	if err := renewal.Sign(s.PrivateKey); err != nil {
+		return nil, err
+	}
+
+	jsonData, err := json.Marshal(renewal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal renewal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/v1/certificate/renew", defaultServerURL)
+
+	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to send renewal request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to send renewal request: %s", string(body))
+	}
+
+	var renewResponse CertificateResponse
+	if err = json.Unmarshal(body, &renewResponse); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+	if renewResponse.Success && newKey != nil {
+		s.PrivateKey, s.PublicKey = newKey, newKey.Public()
+	}
+	return &renewResponse, nil
+}
+
 func CRTGeneration(caURLs []string, caNames []string, subjectInfo pkix.Name, crtOps *CRTOperations) []byte {
 	err := crtOps.RequestAllModuli(caURLs, caNames, subjectInfo.CommonName)
 	if err != nil {
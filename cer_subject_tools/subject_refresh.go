@@ -0,0 +1,206 @@
+package cer_subject_tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/FISCO-BCOS/go-sdk/cer_ca_tools"
+	"github.com/FISCO-BCOS/go-sdk/smcrypto"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+// ErrEnrollmentStopped CA拒绝继续重签（登记已撤销、过期或不存在），刷新应当停止
+var ErrEnrollmentStopped = errors.New("enrollment no longer accepted by CA")
+
+const (
+	defaultRefreshRetry = 10 * time.Second
+	maxRefreshRetry     = 5 * time.Minute
+	refreshFraction     = 3 // 剩余有效期不足 1/3 时重签
+	enrollmentTimeout   = 10 * time.Second
+)
+
+// enrollmentClient 登记与重签请求默认使用的客户端，避免CA无响应时刷新永久阻塞
+var enrollmentClient = &http.Client{Timeout: enrollmentTimeout}
+
+type EnrollmentResponse struct {
+	CertificateResponse
+	EnrollmentID       string    `json:"enrollment_id,omitempty"`
+	EnrollmentNotAfter time.Time `json:"enrollment_not_after,omitempty"`
+}
+
+// SendEnrollRequest 以匿名签发流程登记短期证书，CSR 的密钥即登记密钥，之后用于签名重签请求
+func (s *Subject) SendEnrollRequest(caName string, cir *x509.CertificateRequest, xorResult []byte, remainders []*big.Int) (*EnrollmentResponse, error) {
+	enrollRequest := struct {
+		SubjectInfo pkix.Name  `json:"subject"`
+		CSR         []byte     `json:"csr"`
+		XORResult   []byte     `json:"xor_result"`
+		Remainders  []*big.Int `json:"remainders"`
+	}{
+		SubjectInfo: cir.Subject,
+		CSR:         cir.Raw,
+		XORResult:   xorResult,
+		Remainders:  remainders,
+	}
+
+	jsonData, err := json.Marshal(enrollRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal enroll request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/v1/certificate/enroll?caName=%s", s.SubjectURL, caName)
+	enrollResponse, _, err := postEnrollment(context.Background(), enrollmentClient, url, jsonData)
+	return enrollResponse, err
+}
+
+// Refresher 持续为登记重签短期证书，直到登记被撤销或过期
+type Refresher struct {
+	Subject      *Subject
+	EnrollmentID string
+	// RetryInterval 重签失败后的首次重试间隔，之后按倍数退避
+	RetryInterval time.Duration
+	// OnCertificate 每次获得新证书后调用
+	OnCertificate func(cert *x509.Certificate, response *EnrollmentResponse)
+	// HTTPClient 发送重签请求的客户端，为空时使用带超时的默认客户端
+	HTTPClient *http.Client
+}
+
+// NewRefresher 创建刷新器，Subject 的私钥须为登记密钥
+func NewRefresher(subject *Subject, enrollmentID string) *Refresher {
+	return &Refresher{
+		Subject:       subject,
+		EnrollmentID:  enrollmentID,
+		RetryInterval: defaultRefreshRetry,
+		HTTPClient:    enrollmentClient,
+	}
+}
+
+// Reissue 发送一次重签请求，CA拒绝继续重签时返回 ErrEnrollmentStopped
+func (r *Refresher) Reissue() (*EnrollmentResponse, *x509.Certificate, error) {
+	return r.reissue(context.Background())
+}
+
+// reissue 同 Reissue，ctx 取消时中止请求
+func (r *Refresher) reissue(ctx context.Context) (*EnrollmentResponse, *x509.Certificate, error) {
+	reissue := cer_ca_tools.ReissueRequest{EnrollmentID: r.EnrollmentID}
+	if err := reissue.Sign(r.Subject.PrivateKey); err != nil {
+		return nil, nil, err
+	}
+	jsonData, err := json.Marshal(reissue)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal reissue request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/v1/certificate/reissue", r.Subject.SubjectURL)
+	client := r.HTTPClient
+	if client == nil {
+		client = enrollmentClient
+	}
+	response, status, err := postEnrollment(ctx, client, url, jsonData)
+	if status == http.StatusForbidden || status == http.StatusNotFound {
+		return nil, nil, fmt.Errorf("%w: %v", ErrEnrollmentStopped, err)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if !response.Success {
+		return nil, nil, fmt.Errorf("reissue failed: %s", response.Message)
+	}
+
+	block, _ := pem.Decode([]byte(response.Certificate))
+	if block == nil {
+		return nil, nil, fmt.Errorf("failed to decode reissued certificate")
+	}
+	cert, err := smcrypto.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse reissued certificate: %w", err)
+	}
+	return response, cert, nil
+}
+
+// Run 在当前证书剩余有效期不足 1/3 时重签，失败后退避重试；
+// 登记被撤销或过期时返回 ErrEnrollmentStopped，ctx 取消时返回 ctx.Err()
+func (r *Refresher) Run(ctx context.Context, current *x509.Certificate) error {
+	retry := r.RetryInterval
+	if retry <= 0 {
+		retry = defaultRefreshRetry
+	}
+	backoff := retry
+	wait := time.Until(refreshTime(current))
+
+	for {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		response, cert, err := r.reissue(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, ErrEnrollmentStopped) {
+			log.Printf("enrollment %s stopped: %v", r.EnrollmentID, err)
+			return err
+		}
+		if err != nil {
+			log.Printf("reissue for enrollment %s failed, retry in %s: %v", r.EnrollmentID, backoff, err)
+			wait = backoff
+			if backoff *= 2; backoff > maxRefreshRetry {
+				backoff = maxRefreshRetry
+			}
+			continue
+		}
+
+		current, backoff = cert, retry
+		if r.OnCertificate != nil {
+			r.OnCertificate(cert, response)
+		}
+		wait = time.Until(refreshTime(current))
+	}
+}
+
+// refreshTime 剩余有效期为总有效期 1/3 的时间点
+func refreshTime(cert *x509.Certificate) time.Time {
+	lifetime := cert.NotAfter.Sub(cert.NotBefore)
+	return cert.NotAfter.Add(-lifetime / refreshFraction)
+}
+
+// postEnrollment 发送登记/重签请求，返回解析后的响应与HTTP状态码
+func postEnrollment(ctx context.Context, client *http.Client, url string, jsonData []byte) (*EnrollmentResponse, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create enrollment request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to send enrollment request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode, fmt.Errorf("failed to send enrollment request: %s", string(body))
+	}
+
+	var enrollResponse EnrollmentResponse
+	if err = json.Unmarshal(body, &enrollResponse); err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+	return &enrollResponse, resp.StatusCode, nil
+}
@@ -2,11 +2,12 @@ package cer_subject_tools
 
 import (
 	"bufio"
-	"crypto/ecdsa"
+	"crypto"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
 	"fmt"
+	"github.com/FISCO-BCOS/go-sdk/cer_ca_tools"
 	"github.com/FISCO-BCOS/go-sdk/cert_vrf"
 	"io"
 	"log"
@@ -22,9 +23,9 @@ type TLSClient struct {
 	serverAddr string
 	conn       *tls.Conn
 	tlsConfig  *tls.Config
-	publicKey  *ecdsa.PublicKey
-	privateKey *ecdsa.PrivateKey
-	VRFManager *cert_vrf.VRFManager
+	publicKey  crypto.PublicKey
+	privateKey crypto.Signer
+	VRFManager *cert_vrf.VRFManager // 加载证书后按证书密钥的曲线重新选择
 }
 
 func NewTLSClient(certFile, keyFile, caFile, serverAddr string) *TLSClient {
@@ -37,18 +38,32 @@ func NewTLSClient(certFile, keyFile, caFile, serverAddr string) *TLSClient {
 	}
 }
 
+// LoadCertificates 读取客户端证书、私钥与CA证书。crypto/tls 不支持国密证书，
+// SM2 主体无法进行 TLS 与 VRF 认证，此时返回 cer_ca_tools.ErrGMTLSUnsupported
 func (tc *TLSClient) LoadCertificates() error {
+	certPEM, err := os.ReadFile(tc.certFile)
+	if err != nil {
+		return fmt.Errorf("load client certificates %s", err)
+	}
+	if err := cer_ca_tools.CheckTLSCertificate(certPEM); err != nil {
+		return fmt.Errorf("load client certificates: %w", err)
+	}
 	cert, err := tls.LoadX509KeyPair(tc.certFile, tc.keyFile)
 	if err != nil {
 		return fmt.Errorf("load client certificates %s", err)
 	}
 
 	var ok bool
-	tc.privateKey, ok = cert.PrivateKey.(*ecdsa.PrivateKey)
+	tc.privateKey, ok = cert.PrivateKey.(crypto.Signer)
 	if !ok {
 		return fmt.Errorf("load client certificates private key")
 	}
-	tc.publicKey = &tc.privateKey.PublicKey
+	tc.publicKey = tc.privateKey.Public()
+	// VRF 使用证书密钥，曲线须与验证者从客户端证书中取得的公钥一致
+	tc.VRFManager, err = cert_vrf.NewVRFManagerForKey(tc.publicKey)
+	if err != nil {
+		return fmt.Errorf("load client certificates %s", err)
+	}
 
 	caCert, err := os.ReadFile(tc.caFile)
 	if err != nil {
@@ -86,7 +101,7 @@ func (tc *TLSClient) Connect() error {
 
 	state := conn.ConnectionState()
 	log.Printf("Connected to server %s success", tc.serverAddr)
-	log.Printf("TLS Version %s", state.Version)
+	log.Printf("TLS Version %s", tls.VersionName(state.Version))
 
 	if len(state.PeerCertificates) > 0 {
 		serverCert := state.PeerCertificates[0]
@@ -164,7 +179,7 @@ func (tc *TLSClient) SubmitVRFProof(sessionID string, challenge *cert_vrf.Challe
 		return false, fmt.Errorf("generate VRFProof %s", err)
 	}
 
-	log.Printf("Generated VRFProof %s", proof)
+	log.Printf("Generated VRFProof %+v", proof)
 
 	proofMsg := &cert_vrf.VRFMessage{
 		Type:      "proof_submission",
@@ -1,9 +1,20 @@
 package main
 
 import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/FISCO-BCOS/go-sdk/cer_ca_tools"
+	"github.com/FISCO-BCOS/go-sdk/cer_chain_tools"
 	ca_verifier_tools "github.com/FISCO-BCOS/go-sdk/cer_verify_tools"
+	"github.com/FISCO-BCOS/go-sdk/client"
+	"github.com/FISCO-BCOS/go-sdk/conf"
+	"github.com/FISCO-BCOS/go-sdk/smcrypto"
+	"github.com/ethereum/go-ethereum/common"
 	"log"
+	"net/http"
 	"os"
+	"time"
 )
 
 func main() {
@@ -20,9 +31,80 @@ func main() {
 		log.Fatal(err)
 	}
 
+	// CA启用证书透明日志时，只接受嵌入了该日志SCT的客户端证书
+	ctLogKeyFile := currentDir + "/certs/ct_log.pub"
+	if _, err := os.Stat(ctLogKeyFile); err == nil {
+		if err := verifier.LoadCTLogKey(ctLogKeyFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// 短期证书（short-lived 模板，有效期 1h）到期即失效，不查询撤销状态
+	verifier.SetShortLivedThreshold(2 * time.Hour)
+
+	verifier.SetRevocationChecker(newRevocationChecker(caFile))
+
 	log.Println("Starting server...")
 	err = verifier.StartServer()
 	if err != nil {
 		log.Fatal(err)
 	}
 }
+
+// newRevocationChecker 按环境变量配置撤销检查：
+// VERIFIER_REVOCATION_POLICY=fail-open 时无法确定撤销状态也接受连接，默认拒绝；
+// 设置 CA_URL 与 CA_NAME 时同步该CA的撤销过滤器副本；
+// 设置 REVOCATION_FILTER_CONTRACT 与 CA_CHAIN_ADDRESS 时以链上过滤器作为最后的来源
+func newRevocationChecker(caFile string) *ca_verifier_tools.RevocationChecker {
+	policy := ca_verifier_tools.FailClosed
+	if os.Getenv("VERIFIER_REVOCATION_POLICY") == "fail-open" {
+		policy = ca_verifier_tools.FailOpen
+	}
+	httpClient := &http.Client{Timeout: 5 * time.Second}
+	checker := ca_verifier_tools.NewRevocationChecker(policy,
+		&ca_verifier_tools.OCSPSource{HTTPClient: httpClient},
+		&ca_verifier_tools.CRLSource{HTTPClient: httpClient})
+
+	issuer := loadIssuer(caFile)
+	if baseURL, caName := os.Getenv("CA_URL"), os.Getenv("CA_NAME"); baseURL != "" && caName != "" {
+		replica := cer_ca_tools.NewFilterSyncClient(baseURL, caName, issuer)
+		go replica.Run(context.Background(), 5*time.Minute)
+		checker.AddFilter(issuer, replica)
+	}
+	if contract, caAddress := os.Getenv("REVOCATION_FILTER_CONTRACT"), os.Getenv("CA_CHAIN_ADDRESS"); contract != "" && caAddress != "" {
+		configs, err := conf.ParseConfigFile("config.toml")
+		if err != nil {
+			log.Fatalf("error parsing chain config: %v", err)
+		}
+		c, err := client.Dial(&configs[0])
+		if err != nil {
+			log.Fatalf("error connecting to chain: %v", err)
+		}
+		service, err := cer_chain_tools.NewRevocationFilterService(c, common.HexToAddress(contract))
+		if err != nil {
+			log.Fatal(err)
+		}
+		source := cer_chain_tools.NewChainRevocationSource(service)
+		source.AddCA(issuer, common.HexToAddress(caAddress))
+		checker.Sources = append(checker.Sources, source)
+	}
+	log.Printf("revocation check enabled, policy %s", policy)
+	return checker
+}
+
+// loadIssuer 读取信任包中的第一个证书作为撤销过滤器的签发者
+func loadIssuer(caFile string) *x509.Certificate {
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	block, _ := pem.Decode(caPEM)
+	if block == nil {
+		log.Fatalf("error decoding CA certificate")
+	}
+	issuer, err := smcrypto.ParseCertificate(block.Bytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return issuer
+}
@@ -1,12 +1,15 @@
 package ca_verifier_tools
 
 import (
-	"crypto/ecdsa"
+	"crypto"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"github.com/FISCO-BCOS/go-sdk/cer_ca_tools"
 	"github.com/FISCO-BCOS/go-sdk/cert_vrf"
+	"github.com/FISCO-BCOS/go-sdk/smcrypto"
 	"io"
 	"log"
 	"net"
@@ -15,11 +18,12 @@ import (
 )
 
 type VRFSession struct {
-	SessionID  string              `json:"session_id"`
-	Challenge  *cert_vrf.Challenge `json:"challenge,omitempty"`
-	ClientPK   *ecdsa.PublicKey    `json:"client_pk,omitempty"`
-	IsVerified bool                `json:"is_verified"`
-	CreateAT   time.Time           `json:"create_at"`
+	SessionID  string               `json:"session_id"`
+	Challenge  *cert_vrf.Challenge  `json:"challenge,omitempty"`
+	ClientPK   crypto.PublicKey     `json:"client_pk,omitempty"`
+	IsVerified bool                 `json:"is_verified"`
+	CreateAT   time.Time            `json:"create_at"`
+	vrf        *cert_vrf.VRFManager // 按客户端证书公钥的曲线选择
 }
 
 type VerifierManager struct {
@@ -29,6 +33,11 @@ type VerifierManager struct {
 	port        string
 	listener    net.Listener
 	tlsConfig   *tls.Config
+	roots       []*x509.Certificate
+	crossCerts  []*x509.Certificate
+	ctLogKeys   []crypto.PublicKey
+	shortLived  time.Duration // 总有效期不超过该值的证书不查询撤销状态，0 表示不跳过
+	revocation  *RevocationChecker
 	VRFManager  *cert_vrf.VRFManager
 	vrfSessions map[string]*VRFSession
 }
@@ -44,27 +53,36 @@ func NewVerifierManager(certFile, keyFile, caFile, port string) *VerifierManager
 	}
 }
 
+// LoadCertificates 读取验证方证书与CA信任包。crypto/tls 不支持国密证书，
+// SM2 验证方证书返回 cer_ca_tools.ErrGMTLSUnsupported；SM2 客户端证书在握手时即被拒绝
 func (vm *VerifierManager) LoadCertificates() error {
-	cert, err := tls.LoadX509KeyPair(vm.certFile, vm.keyFile)
+	certPEM, err := os.ReadFile(vm.certFile)
 	if err != nil {
 		return fmt.Errorf("error loading server certificate: %v", err)
 	}
-
-	caCert, err := os.ReadFile(vm.caFile)
+	if err := cer_ca_tools.CheckTLSCertificate(certPEM); err != nil {
+		return fmt.Errorf("error loading server certificate: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(vm.certFile, vm.keyFile)
 	if err != nil {
-		return fmt.Errorf("error loading CA certificate: %v", err)
+		return fmt.Errorf("error loading server certificate: %v", err)
 	}
 
+	if err := vm.loadTrustBundle(); err != nil {
+		return err
+	}
 	caCertPool := x509.NewCertPool()
-	if !caCertPool.AppendCertsFromPEM(caCert) {
-		return fmt.Errorf("error appending CA certificate")
+	for _, root := range vm.roots {
+		caCertPool.AddCert(root)
 	}
 
+	// 客户端证书链由 verifyClientChain 验证，以便使用CA信任包中的交叉证书
 	vm.tlsConfig = &tls.Config{
-		Certificates: []tls.Certificate{cert},
-		ClientAuth:   tls.RequireAndVerifyClientCert,
-		ClientCAs:    caCertPool,
-		MinVersion:   tls.VersionTLS12,
+		Certificates:          []tls.Certificate{cert},
+		ClientAuth:            tls.RequireAnyClientCert,
+		ClientCAs:             caCertPool,
+		VerifyPeerCertificate: vm.verifyClientChain,
+		MinVersion:            tls.VersionTLS12,
 		CipherSuites: []uint16{
 			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
 			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
@@ -74,6 +92,109 @@ func (vm *VerifierManager) LoadCertificates() error {
 	return nil
 }
 
+// loadTrustBundle 读取CA信任包（可由 /certificate/ca/bundle 获取）：自签名证书作为根证书，
+// CA密钥轮换产生的交叉证书作为中间证书，过渡期内新旧CA签发的证书都能通过验证
+func (vm *VerifierManager) loadTrustBundle() error {
+	caCert, err := os.ReadFile(vm.caFile)
+	if err != nil {
+		return fmt.Errorf("error loading CA certificate: %v", err)
+	}
+
+	vm.roots, vm.crossCerts = nil, nil
+	for block, rest := pem.Decode(caCert); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := smcrypto.ParseCertificate(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("error parsing CA certificate: %v", err)
+		}
+		if smcrypto.CheckSignatureFrom(cert, cert) == nil {
+			vm.roots = append(vm.roots, cert)
+		} else {
+			vm.crossCerts = append(vm.crossCerts, cert)
+		}
+	}
+	if len(vm.roots) == 0 {
+		return fmt.Errorf("error appending CA certificate")
+	}
+	return nil
+}
+
+// verifyClientChain 验证客户端证书链。crypto/tls 在调用本函数之前已解析证书，国密证书到不了这里
+func (vm *VerifierManager) verifyClientChain(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return fmt.Errorf("no client certificate")
+	}
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, raw := range rawCerts {
+		cert, err := smcrypto.ParseCertificate(raw)
+		if err != nil {
+			return fmt.Errorf("error parsing client certificate: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	intermediates := append(certs[1:], vm.crossCerts...)
+	chain, err := cer_ca_tools.VerifyCertChain(certs[0], intermediates, vm.roots, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth})
+	if err != nil {
+		return fmt.Errorf("client certificate verification failed: %v", err)
+	}
+	if len(vm.ctLogKeys) > 0 && len(chain) > 1 {
+		if err := cer_ca_tools.VerifyEmbeddedSCTs(chain[0], chain[1], vm.ctLogKeys); err != nil {
+			return fmt.Errorf("client certificate verification failed: %v", err)
+		}
+	}
+	// 撤销检查在握手中完成，被撤销的证书无法建立连接，也就拿不到VRF挑战
+	if vm.revocation != nil && len(chain) > 1 {
+		if vm.SkipRevocationCheck(chain[0]) {
+			log.Printf("revocation check of %s (serial %s): skipped, short-lived certificate", chain[0].Subject, chain[0].SerialNumber)
+		} else if err := vm.revocation.Check(chain[0], chain[1]); err != nil {
+			return fmt.Errorf("client certificate verification failed: %v", err)
+		}
+	}
+	return nil
+}
+
+// RequireSCT 要求客户端证书嵌入至少一个由给定证书透明日志签发的有效SCT
+func (vm *VerifierManager) RequireSCT(logKeys ...crypto.PublicKey) {
+	vm.ctLogKeys = append(vm.ctLogKeys, logKeys...)
+}
+
+// SetShortLivedThreshold 设置短期证书阈值：总有效期不超过 maxLifetime 的证书到期即失效，
+// 验证时无需查询CRL或撤销过滤器
+func (vm *VerifierManager) SetShortLivedThreshold(maxLifetime time.Duration) {
+	vm.shortLived = maxLifetime
+}
+
+// SetRevocationChecker 设置握手时使用的撤销检查器，nil 表示不检查撤销状态
+func (vm *VerifierManager) SetRevocationChecker(checker *RevocationChecker) {
+	vm.revocation = checker
+}
+
+// SkipRevocationCheck 报告该证书是否可跳过撤销状态查询
+func (vm *VerifierManager) SkipRevocationCheck(cert *x509.Certificate) bool {
+	return cer_ca_tools.IsShortLived(cert, vm.shortLived)
+}
+
+// LoadCTLogKey 读取 PEM 编码的证书透明日志公钥并要求客户端证书携带该日志的SCT
+func (vm *VerifierManager) LoadCTLogKey(path string) error {
+	keyPEM, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error loading CT log key: %v", err)
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return fmt.Errorf("error decoding CT log key")
+	}
+	logKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("error parsing CT log key: %v", err)
+	}
+	vm.RequireSCT(logKey)
+	return nil
+}
+
 func (vm *VerifierManager) StartServer() error {
 	listener, err := tls.Listen("tcp", ":"+vm.port, vm.tlsConfig)
 	if err != nil {
@@ -195,9 +316,12 @@ func (vm *VerifierManager) handleChallengeRequest(vrfMsg cert_vrf.VRFMessage, co
 		return vm.createErrorResponse("No client certificate found")
 	}
 
+	// 握手只接受 P-256、P-384 与 Ed25519 证书，VRF 曲线与证书公钥一致
 	clientCert := state.PeerCertificates[0]
-	clientPK, ok := clientCert.PublicKey.(*ecdsa.PublicKey)
-	if !ok {
+	clientPK := clientCert.PublicKey
+	sessionVRF, err := cert_vrf.NewVRFManagerForKey(clientPK)
+	if err != nil {
+		log.Printf("Unsupported client public key: %v", err)
 		return vm.createErrorResponse("Invalid client public key")
 	}
 
@@ -207,6 +331,7 @@ func (vm *VerifierManager) handleChallengeRequest(vrfMsg cert_vrf.VRFMessage, co
 		ClientPK:   clientPK,
 		IsVerified: false,
 		CreateAT:   time.Now(),
+		vrf:        sessionVRF,
 	}
 	vm.vrfSessions[sessionID] = session
 
@@ -229,7 +354,7 @@ func (vm *VerifierManager) handleProofSubmission(vrfMsg cert_vrf.VRFMessage) str
 		return vm.createErrorResponse("No proof found")
 	}
 
-	isValid, err := vm.VRFManager.VerifyVRFProof(session.ClientPK, session.Challenge, vrfMsg.Proof)
+	isValid, err := session.vrf.VerifyVRFProof(session.ClientPK, session.Challenge, vrfMsg.Proof)
 	if err != nil {
 		log.Printf("Error verifying proof: %v", err)
 		return vm.createErrorResponse("Error verifying proof")
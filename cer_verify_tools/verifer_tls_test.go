@@ -0,0 +1,118 @@
+package ca_verifier_tools
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509/pkix"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/FISCO-BCOS/go-sdk/cer_ca_tools"
+	"github.com/FISCO-BCOS/go-sdk/cer_subject_tools"
+)
+
+// writeTLSFiles 签发证书并写出证书与私钥文件，返回两个文件的路径
+func writeTLSFiles(t *testing.T, dir, name string, ca *cer_ca_tools.CA, profile string, request *cer_ca_tools.IssuanceRequest, key crypto.Signer) (string, string) {
+	response := ca.Issue(profile, request)
+	if !response.Success {
+		t.Fatalf("issue %s certificate failed: %s", name, response.Message)
+	}
+	keyPEM, err := cer_ca_tools.MarshalPrivateKeyPEM(key, nil)
+	if err != nil {
+		t.Fatalf("marshal %s key failed: %v", name, err)
+	}
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certFile, []byte(response.Certificate), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestTLSHandshakePerKeyAlgorithm(t *testing.T) {
+	algorithms := []cer_ca_tools.KeyAlgorithm{
+		cer_ca_tools.KeyAlgorithmP256,
+		cer_ca_tools.KeyAlgorithmP384,
+		cer_ca_tools.KeyAlgorithmEd25519,
+		cer_ca_tools.KeyAlgorithmSM2,
+	}
+	for _, algorithm := range algorithms {
+		t.Run(string(algorithm), func(t *testing.T) {
+			dir := t.TempDir()
+			manager := cer_ca_tools.NewCAManagerWithStore(cer_ca_tools.NewMemoryStore())
+			ca, err := manager.CreateCAWithAlgorithm("tls_test", algorithm)
+			if err != nil {
+				t.Fatalf("create CA failed: %v", err)
+			}
+			caFile := filepath.Join(dir, "ca.crt")
+			if err := os.WriteFile(caFile, []byte(ca.ChainPEM(nil)), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			serverSK, _ := cer_ca_tools.GenerateKey(algorithm)
+			serverCert, serverKey := writeTLSFiles(t, dir, "server", ca, cer_ca_tools.ProfileVerifierServer, &cer_ca_tools.IssuanceRequest{
+				Subject:     pkix.Name{CommonName: "verifier"},
+				PublicKey:   serverSK.Public(),
+				IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+			}, serverSK)
+			clientSK, _ := cer_ca_tools.GenerateKey(algorithm)
+			clientCert, clientKey := writeTLSFiles(t, dir, "client", ca, "", &cer_ca_tools.IssuanceRequest{
+				Subject:   pkix.Name{CommonName: "anonymous-tls"},
+				PublicKey: clientSK.Public(),
+			}, clientSK)
+
+			verifier := NewVerifierManager(serverCert, serverKey, caFile, "0")
+			client := cer_subject_tools.NewTLSClient(clientCert, clientKey, caFile, "")
+
+			// crypto/tls 不支持国密证书，SM2 双方在加载证书时即被拒绝
+			if algorithm == cer_ca_tools.KeyAlgorithmSM2 {
+				if err := verifier.LoadCertificates(); !errors.Is(err, cer_ca_tools.ErrGMTLSUnsupported) {
+					t.Fatalf("SM2 verifier certificate should be rejected, have %v", err)
+				}
+				if err := client.LoadCertificates(); !errors.Is(err, cer_ca_tools.ErrGMTLSUnsupported) {
+					t.Fatalf("SM2 client certificate should be rejected, have %v", err)
+				}
+				return
+			}
+
+			if err := verifier.LoadCertificates(); err != nil {
+				t.Fatalf("load verifier certificates failed: %v", err)
+			}
+			listener, err := tls.Listen("tcp", "127.0.0.1:0", verifier.tlsConfig)
+			if err != nil {
+				t.Fatalf("listen failed: %v", err)
+			}
+			t.Cleanup(func() { listener.Close() })
+			go func() {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				verifier.handleConnection(conn)
+			}()
+
+			client = cer_subject_tools.NewTLSClient(clientCert, clientKey, caFile, listener.Addr().String())
+			if err := client.LoadCertificates(); err != nil {
+				t.Fatalf("load client certificates failed: %v", err)
+			}
+			if err := client.Connect(); err != nil {
+				t.Fatalf("handshake failed: %v", err)
+			}
+			defer client.Close()
+
+			challenge, err := client.RequestVRFChallenge("session-" + string(algorithm))
+			if err != nil || challenge == nil {
+				t.Fatalf("request VRF challenge failed: %v", err)
+			}
+			verified, err := client.SubmitVRFProof("session-"+string(algorithm), challenge)
+			if err != nil || !verified {
+				t.Fatalf("VRF proof should be verified, have %v %v", verified, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package ca_verifier_tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/FISCO-BCOS/go-sdk/smcrypto"
+	"golang.org/x/crypto/ocsp"
+)
+
+// RevocationPolicy 无法确定撤销状态时的处理策略
+type RevocationPolicy int
+
+const (
+	// FailClosed 无法确定撤销状态时拒绝连接
+	FailClosed RevocationPolicy = iota
+	// FailOpen 无法确定撤销状态时接受连接
+	FailOpen
+)
+
+func (policy RevocationPolicy) String() string {
+	if policy == FailOpen {
+		return "fail-open"
+	}
+	return "fail-closed"
+}
+
+// responseLimit OCSP响应与CRL的最大长度
+const responseLimit = 16 << 20
+
+// deltaCRLRetry 增量CRL不可用时重新请求的间隔
+const deltaCRLRetry = time.Minute
+
+// reasonRemoveFromCRL 增量CRL中表示解除暂停的撤销原因 (RFC 5280 5.3.1)
+const reasonRemoveFromCRL = 8
+
+// oidExtensionDeltaCRLIndicator Delta CRL Indicator 扩展 (RFC 5280 5.2.4)
+var oidExtensionDeltaCRLIndicator = asn1.ObjectIdentifier{2, 5, 29, 27}
+
+var (
+	ErrCertificateRevoked = errors.New("client certificate is revoked")
+	ErrRevocationUnknown  = errors.New("revocation status is unknown")
+)
+
+// FilterReplica 本地撤销过滤器副本，cer_ca_tools.FilterSyncClient 实现了该接口。
+// Synced 返回 false 表示副本尚未同步或已过期，检查器按没有副本处理；MaybeRevoked 返回 false 时证书一定未被撤销
+type FilterReplica interface {
+	Synced() bool
+	MaybeRevoked(serial *big.Int) bool
+}
+
+// RevocationSource 可给出确定结论的撤销状态来源，如OCSP、CRL或链上状态。
+// 无法给出结论时返回错误，检查器继续询问下一个来源
+type RevocationSource interface {
+	Name() string
+	CheckRevocation(ctx context.Context, cert, issuer *x509.Certificate) (bool, error)
+}
+
+// RevocationChecker 在TLS握手中检查客户端证书的撤销状态：先查询签发者的本地过滤器副本，未命中即接受；
+// 命中、没有副本或副本过期时依次询问 Sources，第一个确定结论生效。全部来源都无法确定时按 Policy 处理
+type RevocationChecker struct {
+	Sources []RevocationSource
+	Policy  RevocationPolicy
+	Timeout time.Duration // 一次检查询问全部来源的总时限
+
+	mutex   sync.RWMutex
+	filters map[string]FilterReplica // 按签发者 RawSubject 索引
+}
+
+// NewRevocationChecker 创建检查器，来源按给定顺序询问，总时限默认 5 秒
+func NewRevocationChecker(policy RevocationPolicy, sources ...RevocationSource) *RevocationChecker {
+	return &RevocationChecker{
+		Sources: sources,
+		Policy:  policy,
+		Timeout: 5 * time.Second,
+		filters: make(map[string]FilterReplica),
+	}
+}
+
+// AddFilter 设置 issuer 签发证书使用的本地过滤器副本
+func (checker *RevocationChecker) AddFilter(issuer *x509.Certificate, filter FilterReplica) {
+	checker.mutex.Lock()
+	defer checker.mutex.Unlock()
+
+	checker.filters[string(issuer.RawSubject)] = filter
+}
+
+// Check 检查由 issuer 签发的证书 cert，返回 nil 表示接受
+func (checker *RevocationChecker) Check(cert, issuer *x509.Certificate) error {
+	serial := cert.SerialNumber
+	checker.mutex.RLock()
+	filter := checker.filters[string(issuer.RawSubject)]
+	checker.mutex.RUnlock()
+
+	trigger := "no filter replica"
+	if filter != nil && !filter.Synced() {
+		trigger = "stale filter replica"
+	} else if filter != nil {
+		if !filter.MaybeRevoked(serial) {
+			log.Printf("revocation check of %s (serial %s): good, not in filter replica", cert.Subject, serial)
+			return nil
+		}
+		trigger = "filter replica hit"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), checker.Timeout)
+	defer cancel()
+	var errs []error
+	for _, source := range checker.Sources {
+		revoked, err := source.CheckRevocation(ctx, cert, issuer)
+		if err != nil {
+			log.Printf("revocation check of %s (serial %s): %s unavailable: %v", cert.Subject, serial, source.Name(), err)
+			errs = append(errs, fmt.Errorf("%s: %v", source.Name(), err))
+			continue
+		}
+		if revoked {
+			log.Printf("revocation check of %s (serial %s): revoked, confirmed by %s after %s", cert.Subject, serial, source.Name(), trigger)
+			return fmt.Errorf("%w: serial %s, confirmed by %s", ErrCertificateRevoked, serial, source.Name())
+		}
+		log.Printf("revocation check of %s (serial %s): good, confirmed by %s after %s", cert.Subject, serial, source.Name(), trigger)
+		return nil
+	}
+
+	err := fmt.Errorf("%w: serial %s after %s", ErrRevocationUnknown, serial, trigger)
+	if len(errs) > 0 {
+		err = fmt.Errorf("%w: %v", err, errors.Join(errs...))
+	}
+	if checker.Policy == FailOpen {
+		log.Printf("revocation check of %s (serial %s): accepted by %s policy: %v", cert.Subject, serial, checker.Policy, err)
+		return nil
+	}
+	log.Printf("revocation check of %s (serial %s): rejected by %s policy: %v", cert.Subject, serial, checker.Policy, err)
+	return err
+}
+
+// OCSPSource 向证书 AIA 中的OCSP服务查询撤销状态
+type OCSPSource struct {
+	HTTPClient *http.Client
+}
+
+func (source *OCSPSource) Name() string {
+	return "ocsp"
+}
+
+func (source *OCSPSource) CheckRevocation(ctx context.Context, cert, issuer *x509.Certificate) (bool, error) {
+	if len(cert.OCSPServer) == 0 {
+		return false, fmt.Errorf("certificate has no OCSP server")
+	}
+	requestDER, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return false, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, cert.OCSPServer[0], bytes.NewReader(requestDER))
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Content-Type", "application/ocsp-request")
+	responseDER, err := fetch(source.HTTPClient, request)
+	if err != nil {
+		return false, err
+	}
+	response, err := ocsp.ParseResponseForCert(responseDER, cert, issuer)
+	if err != nil {
+		return false, err
+	}
+	if !response.NextUpdate.IsZero() && time.Now().After(response.NextUpdate) {
+		return false, fmt.Errorf("OCSP response expired at %s", response.NextUpdate)
+	}
+
+	switch response.Status {
+	case ocsp.Good:
+		return false, nil
+	case ocsp.Revoked:
+		return true, nil
+	default:
+		return false, fmt.Errorf("OCSP responder does not know the certificate")
+	}
+}
+
+// CRLSource 下载证书CRL分发点中的完整CRL，以及同一分发点以 delta=true 发布的增量CRL查询撤销状态。
+// 增量CRL中的条目优先于完整CRL，增量CRL不可用时只使用完整CRL。CRL在 NextUpdate 之前缓存
+type CRLSource struct {
+	HTTPClient *http.Client
+
+	mutex      sync.Mutex
+	cache      map[string]*x509.RevocationList
+	deltaRetry map[string]time.Time // 增量CRL不可用的分发点，在该时间之前不再请求
+}
+
+func (source *CRLSource) Name() string {
+	return "crl"
+}
+
+func (source *CRLSource) CheckRevocation(ctx context.Context, cert, issuer *x509.Certificate) (bool, error) {
+	if len(cert.CRLDistributionPoints) == 0 {
+		return false, fmt.Errorf("certificate has no CRL distribution point")
+	}
+	distributionPoint := cert.CRLDistributionPoints[0]
+	base, err := source.load(ctx, distributionPoint, issuer)
+	if err != nil {
+		return false, err
+	}
+	if delta, err := source.loadDelta(ctx, distributionPoint, issuer, base); err != nil {
+		log.Printf("delta CRL of %s not used, checking base CRL #%s only: %v", distributionPoint, base.Number, err)
+	} else if entry := findRevocationEntry(delta, cert.SerialNumber); entry != nil {
+		return entry.ReasonCode != reasonRemoveFromCRL, nil
+	}
+	return findRevocationEntry(base, cert.SerialNumber) != nil, nil
+}
+
+func (source *CRLSource) load(ctx context.Context, distributionPoint string, issuer *x509.Certificate) (*x509.RevocationList, error) {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+
+	if crl, exists := source.cache[distributionPoint]; exists && time.Now().Before(crl.NextUpdate) {
+		return crl, nil
+	}
+	crl, err := fetchCRL(ctx, source.HTTPClient, distributionPoint, issuer)
+	if err != nil {
+		return nil, err
+	}
+	if crl.Number == nil {
+		return nil, fmt.Errorf("CRL has no CRL number")
+	}
+	if _, err := deltaBaseNumber(crl); err == nil {
+		return nil, fmt.Errorf("distribution point returned a delta CRL")
+	}
+	source.store(distributionPoint, crl)
+	return crl, nil
+}
+
+// loadDelta 返回适用于 base 的增量CRL：其 BaseCRLNumber 不大于 base 的编号，且自身编号大于 base 的编号
+func (source *CRLSource) loadDelta(ctx context.Context, distributionPoint string, issuer *x509.Certificate, base *x509.RevocationList) (*x509.RevocationList, error) {
+	deltaURL, err := url.Parse(distributionPoint)
+	if err != nil {
+		return nil, err
+	}
+	query := deltaURL.Query()
+	query.Set("delta", "true")
+	deltaURL.RawQuery = query.Encode()
+	key := deltaURL.String()
+
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+
+	if crl, exists := source.cache[key]; exists && time.Now().Before(crl.NextUpdate) && crl.Number.Cmp(base.Number) > 0 {
+		return crl, nil
+	}
+	if retry, exists := source.deltaRetry[key]; exists && time.Now().Before(retry) {
+		return nil, fmt.Errorf("delta CRL unavailable until %s", retry.Format(time.RFC3339))
+	}
+	crl, err := fetchCRL(ctx, source.HTTPClient, key, issuer)
+	if err == nil {
+		err = checkDeltaCRL(crl, base)
+	}
+	if err != nil {
+		if source.deltaRetry == nil {
+			source.deltaRetry = make(map[string]time.Time)
+		}
+		source.deltaRetry[key] = time.Now().Add(deltaCRLRetry)
+		return nil, err
+	}
+	delete(source.deltaRetry, key)
+	source.store(key, crl)
+	return crl, nil
+}
+
+func (source *CRLSource) store(key string, crl *x509.RevocationList) {
+	if source.cache == nil {
+		source.cache = make(map[string]*x509.RevocationList)
+	}
+	source.cache[key] = crl
+}
+
+// fetchCRL 下载并校验 issuer 签发、尚未过期的CRL
+func fetchCRL(ctx context.Context, client *http.Client, crlURL string, issuer *x509.Certificate) (*x509.RevocationList, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, crlURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	der, err := fetch(client, request)
+	if err != nil {
+		return nil, err
+	}
+	crl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing CRL: %v", err)
+	}
+	if err := smcrypto.CheckRevocationListSignature(crl, issuer); err != nil {
+		return nil, fmt.Errorf("CRL signature verification failed: %v", err)
+	}
+	if !crl.NextUpdate.IsZero() && time.Now().After(crl.NextUpdate) {
+		return nil, fmt.Errorf("CRL expired at %s", crl.NextUpdate)
+	}
+	return crl, nil
+}
+
+func checkDeltaCRL(delta, base *x509.RevocationList) error {
+	baseNumber, err := deltaBaseNumber(delta)
+	if err != nil {
+		return err
+	}
+	if delta.Number == nil || baseNumber.Cmp(base.Number) > 0 || delta.Number.Cmp(base.Number) <= 0 {
+		return fmt.Errorf("delta CRL #%s (base #%s) does not apply to base CRL #%s", delta.Number, baseNumber, base.Number)
+	}
+	return nil
+}
+
+func deltaBaseNumber(crl *x509.RevocationList) (*big.Int, error) {
+	for _, ext := range crl.Extensions {
+		if !ext.Id.Equal(oidExtensionDeltaCRLIndicator) {
+			continue
+		}
+		var number *big.Int
+		if rest, err := asn1.Unmarshal(ext.Value, &number); err != nil {
+			return nil, fmt.Errorf("error parsing delta CRL indicator: %v", err)
+		} else if len(rest) > 0 {
+			return nil, fmt.Errorf("trailing data after delta CRL indicator")
+		}
+		return number, nil
+	}
+	return nil, fmt.Errorf("CRL has no delta CRL indicator")
+}
+
+func findRevocationEntry(crl *x509.RevocationList, serial *big.Int) *x509.RevocationListEntry {
+	for i := range crl.RevokedCertificateEntries {
+		if crl.RevokedCertificateEntries[i].SerialNumber.Cmp(serial) == 0 {
+			return &crl.RevokedCertificateEntries[i]
+		}
+	}
+	return nil
+}
+
+func fetch(client *http.Client, request *http.Request) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned status %d", request.URL, response.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(response.Body, responseLimit))
+}
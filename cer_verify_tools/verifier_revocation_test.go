@@ -0,0 +1,296 @@
+package ca_verifier_tools
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+type fakeReplica struct {
+	synced  bool
+	revoked map[int64]bool
+}
+
+func (replica *fakeReplica) Synced() bool { return replica.synced }
+func (replica *fakeReplica) MaybeRevoked(serial *big.Int) bool {
+	return replica.revoked[serial.Int64()]
+}
+
+// fakeSource 按序列号给出结论，err 非空时无法确定
+type fakeSource struct {
+	name    string
+	revoked map[int64]bool
+	err     error
+	calls   *[]string
+}
+
+func (source *fakeSource) Name() string { return source.name }
+func (source *fakeSource) CheckRevocation(_ context.Context, cert, _ *x509.Certificate) (bool, error) {
+	*source.calls = append(*source.calls, source.name)
+	return source.revoked[cert.SerialNumber.Int64()], source.err
+}
+
+func TestRevocationChecker(t *testing.T) {
+	issuer := &x509.Certificate{RawSubject: []byte("checker test CA")}
+	cert := func(serial int64) *x509.Certificate {
+		return &x509.Certificate{SerialNumber: big.NewInt(serial)}
+	}
+	var calls []string
+	unavailable := &fakeSource{name: "ocsp", err: errors.New("responder down"), calls: &calls}
+	crl := &fakeSource{name: "crl", revoked: map[int64]bool{2: true}, calls: &calls}
+	replica := &fakeReplica{synced: true, revoked: map[int64]bool{2: true, 3: true}}
+	checker := NewRevocationChecker(FailClosed, unavailable, crl)
+	checker.AddFilter(issuer, replica)
+
+	tests := []struct {
+		name   string
+		serial int64
+		synced bool
+		want   error
+		calls  []string
+	}{
+		{"filter miss", 1, true, nil, nil},
+		{"filter hit revoked", 2, true, ErrCertificateRevoked, []string{"ocsp", "crl"}},
+		{"filter false positive", 3, true, nil, []string{"ocsp", "crl"}},
+		{"stale replica", 1, false, nil, []string{"ocsp", "crl"}},
+	}
+	for _, test := range tests {
+		calls = nil
+		replica.synced = test.synced
+		if err := checker.Check(cert(test.serial), issuer); !errors.Is(err, test.want) || (test.want == nil) != (err == nil) {
+			t.Fatalf("%s: have %v, want %v", test.name, err, test.want)
+		}
+		if !slices.Equal(calls, test.calls) {
+			t.Fatalf("%s: sources asked %v, want %v", test.name, calls, test.calls)
+		}
+	}
+
+	// 第一个确定结论生效，之后的来源不再询问
+	calls = nil
+	checker.Sources = []RevocationSource{crl, unavailable}
+	if err := checker.Check(cert(3), &x509.Certificate{RawSubject: []byte("no replica")}); err != nil || len(calls) != 1 {
+		t.Fatalf("first definite source should decide, have %v after %v", err, calls)
+	}
+
+	checker.Sources = []RevocationSource{unavailable}
+	if err := checker.Check(cert(3), issuer); !errors.Is(err, ErrRevocationUnknown) {
+		t.Fatalf("fail-closed checker should reject unknown status, have %v", err)
+	}
+	checker.Policy = FailOpen
+	if err := checker.Check(cert(3), issuer); err != nil {
+		t.Fatalf("fail-open checker should accept unknown status, have %v", err)
+	}
+	replica.synced = true
+	if err := checker.Check(cert(1), issuer); err != nil {
+		t.Fatalf("filter miss should be accepted, have %v", err)
+	}
+}
+
+// testPKI 测试用的CA与它签发的证书，OCSP与CRL服务由 server 提供
+type testPKI struct {
+	ca     *x509.Certificate
+	key    *ecdsa.PrivateKey
+	server *httptest.Server
+
+	mutex     sync.Mutex
+	ocspCodes map[int64]int
+	crls      map[string][]byte // 按 delta 参数索引
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "revocation test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pki := &testPKI{key: key, ocspCodes: make(map[int64]int), crls: make(map[string][]byte)}
+	if pki.ca, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+
+	pki.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pki.mutex.Lock()
+		defer pki.mutex.Unlock()
+
+		switch r.URL.Path {
+		case "/ocsp":
+			request, _ := io.ReadAll(r.Body)
+			parsed, err := ocsp.ParseRequest(request)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			status, exists := pki.ocspCodes[parsed.SerialNumber.Int64()]
+			if !exists {
+				status = ocsp.Unknown
+			}
+			response, _ := ocsp.CreateResponse(pki.ca, pki.ca, ocsp.Response{
+				Status:           status,
+				SerialNumber:     parsed.SerialNumber,
+				ThisUpdate:       time.Now(),
+				NextUpdate:       time.Now().Add(time.Hour),
+				RevokedAt:        time.Now(),
+				RevocationReason: ocsp.KeyCompromise,
+			}, pki.key)
+			w.Write(response)
+		case "/crl":
+			crl, exists := pki.crls[r.URL.Query().Get("delta")]
+			if !exists {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(crl)
+		}
+	}))
+	t.Cleanup(pki.server.Close)
+	return pki
+}
+
+func (pki *testPKI) issue(t *testing.T, serial int64) *x509.Certificate {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		OCSPServer:            []string{pki.server.URL + "/ocsp"},
+		CRLDistributionPoints: []string{pki.server.URL + "/crl?caName=test"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, pki.ca, &key.PublicKey, pki.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// publishCRL 发布编号为 number 的CRL，baseNumber 非空时为增量CRL
+func (pki *testPKI) publishCRL(t *testing.T, number int64, baseNumber *big.Int, entries map[int64]int) {
+	template := &x509.RevocationList{
+		Number:     big.NewInt(number),
+		ThisUpdate: time.Now(),
+		NextUpdate: time.Now().Add(time.Hour),
+	}
+	for serial, reason := range entries {
+		template.RevokedCertificateEntries = append(template.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   big.NewInt(serial),
+			RevocationTime: time.Now(),
+			ReasonCode:     reason,
+		})
+	}
+	delta := ""
+	if baseNumber != nil {
+		value, _ := asn1.Marshal(baseNumber)
+		template.ExtraExtensions = []pkix.Extension{{Id: oidExtensionDeltaCRLIndicator, Critical: true, Value: value}}
+		delta = "true"
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, template, pki.ca, crypto.Signer(pki.key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pki.mutex.Lock()
+	defer pki.mutex.Unlock()
+	pki.crls[delta] = der
+}
+
+func TestOCSPSource(t *testing.T) {
+	pki := newTestPKI(t)
+	pki.ocspCodes[10] = ocsp.Good
+	pki.ocspCodes[11] = ocsp.Revoked
+	source := &OCSPSource{HTTPClient: pki.server.Client()}
+	ctx := context.Background()
+
+	if revoked, err := source.CheckRevocation(ctx, pki.issue(t, 10), pki.ca); err != nil || revoked {
+		t.Fatalf("good certificate: have %v %v", revoked, err)
+	}
+	if revoked, err := source.CheckRevocation(ctx, pki.issue(t, 11), pki.ca); err != nil || !revoked {
+		t.Fatalf("revoked certificate: have %v %v", revoked, err)
+	}
+	if _, err := source.CheckRevocation(ctx, pki.issue(t, 12), pki.ca); err == nil {
+		t.Fatalf("unknown certificate should not be answered")
+	}
+	noAIA := pki.issue(t, 10)
+	noAIA.OCSPServer = nil
+	if _, err := source.CheckRevocation(ctx, noAIA, pki.ca); err == nil {
+		t.Fatalf("certificate without OCSP server should not be answered")
+	}
+}
+
+func TestCRLSourceWithDelta(t *testing.T) {
+	pki := newTestPKI(t)
+	source := &CRLSource{HTTPClient: pki.server.Client()}
+	ctx := context.Background()
+	check := func(serial int64) bool {
+		t.Helper()
+		revoked, err := source.CheckRevocation(ctx, pki.issue(t, serial), pki.ca)
+		if err != nil {
+			t.Fatalf("check of %d failed: %v", serial, err)
+		}
+		return revoked
+	}
+
+	// 没有增量CRL时只使用完整CRL
+	pki.publishCRL(t, 1, nil, map[int64]int{20: ocsp.KeyCompromise, 21: ocsp.CertificateHold})
+	if !check(20) || !check(21) || check(22) {
+		t.Fatalf("base CRL entries should be revoked")
+	}
+
+	// 增量CRL新增撤销并解除暂停；负缓存期间不会重新请求
+	pki.publishCRL(t, 2, big.NewInt(1), map[int64]int{22: ocsp.Superseded, 21: reasonRemoveFromCRL})
+	if check(22) {
+		t.Fatalf("unavailable delta CRL should not be retried before %s", deltaCRLRetry)
+	}
+	clear(source.deltaRetry)
+	if !check(20) || check(21) || !check(22) || check(23) {
+		t.Fatalf("delta CRL entries should take precedence over the base CRL")
+	}
+
+	// 不适用于缓存的完整CRL的增量CRL被忽略
+	pki.publishCRL(t, 1, big.NewInt(1), map[int64]int{23: ocsp.KeyCompromise})
+	clear(source.cache)
+	if check(23) || !check(20) {
+		t.Fatalf("delta CRL with a stale CRL number should be ignored")
+	}
+	clear(source.deltaRetry)
+	pki.publishCRL(t, 3, big.NewInt(5), map[int64]int{23: ocsp.KeyCompromise})
+	if check(23) {
+		t.Fatalf("delta CRL based on a newer base CRL should be ignored")
+	}
+
+	noCDP := pki.issue(t, 20)
+	noCDP.CRLDistributionPoints = nil
+	if _, err := source.CheckRevocation(ctx, noCDP, pki.ca); err == nil {
+		t.Fatalf("certificate without CRL distribution point should not be answered")
+	}
+}
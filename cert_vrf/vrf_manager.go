@@ -1,20 +1,30 @@
 package cert_vrf
 
 import (
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/sha512"
 	"crypto/x509"
 	"encoding/hex"
 	"fmt"
 	"math/big"
 	"time"
+
+	"filippo.io/edwards25519"
+	gmecdsa "github.com/FISCO-BCOS/crypto/ecdsa"
+	gmelliptic "github.com/FISCO-BCOS/crypto/elliptic"
+	gmx509 "github.com/FISCO-BCOS/crypto/x509"
+	"github.com/FISCO-BCOS/go-sdk/smcrypto"
 )
 
+// VRFKeyPair 保存 P-256/P-384 的 *ecdsa 密钥、SM2 密钥（*smcrypto.SM2PrivateKey 或 *gmecdsa.PrivateKey）或 ed25519 密钥
 type VRFKeyPair struct {
-	PublicKey  *ecdsa.PublicKey  `json:"public_key"`
-	PrivateKey *ecdsa.PrivateKey `json:"private_key"`
+	PublicKey  crypto.PublicKey  `json:"public_key"`
+	PrivateKey crypto.PrivateKey `json:"private_key"`
 }
 
 type VRFProof struct {
@@ -55,17 +65,114 @@ func NewVRFManager() *VRFManager {
 	}
 }
 
+// NewVRFManagerWithCurve 在 elliptic.P256()、elliptic.P384()、SM2Curve() 或 Ed25519Curve() 上创建 VRF 管理器
+func NewVRFManagerWithCurve(curve elliptic.Curve) *VRFManager {
+	return &VRFManager{
+		curve: curve,
+	}
+}
+
+// NewVRFManagerForKey 在给定公钥所在的曲线上创建 VRF 管理器，证明方与验证方使用主体证书密钥的同一条曲线
+func NewVRFManagerForKey(pub crypto.PublicKey) (*VRFManager, error) {
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		if key.Curve == elliptic.P256() || key.Curve == elliptic.P384() {
+			return NewVRFManagerWithCurve(key.Curve), nil
+		}
+	case *gmecdsa.PublicKey:
+		if key.Curve == gmelliptic.Sm2p256v1() {
+			return NewVRFManagerWithCurve(SM2Curve()), nil
+		}
+	case ed25519.PublicKey:
+		return NewVRFManagerWithCurve(Ed25519Curve()), nil
+	}
+	return nil, fmt.Errorf("unsupported VRF public key %T", pub)
+}
+
+// Curve 返回管理器使用的曲线
+func (vm *VRFManager) Curve() elliptic.Curve {
+	return vm.curve
+}
+
 func (vm *VRFManager) GenerateVRFKeyPair() (*VRFKeyPair, error) {
-	priv, err := ecdsa.GenerateKey(vm.curve, rand.Reader)
+	var (
+		priv crypto.Signer
+		err  error
+	)
+	switch vm.curve {
+	case Ed25519Curve():
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	case SM2Curve():
+		priv, err = smcrypto.GenerateSM2Key()
+	default:
+		priv, err = ecdsa.GenerateKey(vm.curve, rand.Reader)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate VRF key pair: %w", err)
 	}
 	return &VRFKeyPair{
 		PrivateKey: priv,
-		PublicKey:  &priv.PublicKey,
+		PublicKey:  priv.Public(),
 	}, nil
 }
 
+// privateScalar 返回 VRF 私钥的秘密标量及对应公钥点；ed25519 为按 RFC 8032 由种子派生并钳制的标量
+func (vm *VRFManager) privateScalar(priv crypto.PrivateKey) (*big.Int, *ECPoint, error) {
+	var d *big.Int
+	switch key := priv.(type) {
+	case *ecdsa.PrivateKey:
+		d = key.D
+	case *smcrypto.SM2PrivateKey:
+		d = key.D
+	case *gmecdsa.PrivateKey:
+		d = key.D
+	case ed25519.PrivateKey:
+		digest := sha512.Sum512(key.Seed())
+		digest[0] &= 248
+		digest[31] &= 127
+		digest[31] |= 64
+		d = fromLittleEndian(digest[:32])
+	default:
+		return nil, nil, fmt.Errorf("unsupported VRF private key %T", priv)
+	}
+	signer, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported VRF private key %T", priv)
+	}
+	pk, err := vm.publicPoint(signer.Public())
+	if err != nil {
+		return nil, nil, err
+	}
+	d = new(big.Int).Mod(d, vm.curve.Params().N)
+	return d, pk, nil
+}
+
+// publicPoint 返回 VRF 公钥的仿射坐标点，并检查其在管理器的曲线上
+func (vm *VRFManager) publicPoint(pub crypto.PublicKey) (*ECPoint, error) {
+	var point *ECPoint
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		if key.Curve == vm.curve {
+			point = &ECPoint{X: key.X, Y: key.Y}
+		}
+	case *gmecdsa.PublicKey:
+		if vm.curve == SM2Curve() && key.Curve == gmelliptic.Sm2p256v1() {
+			point = &ECPoint{X: key.X, Y: key.Y}
+		}
+	case ed25519.PublicKey:
+		if vm.curve == Ed25519Curve() && len(key) == ed25519.PublicKeySize {
+			x, y, ok := decodeEd25519PublicKey(key)
+			if ok {
+				point = &ECPoint{X: x, Y: y}
+			}
+		}
+	}
+	if point == nil || !vm.curve.IsOnCurve(point.X, point.Y) {
+		return nil, fmt.Errorf("VRF public key %T does not match curve %s", pub, vm.curve.Params().Name)
+	}
+	return point, nil
+}
+
 func (vm *VRFManager) GenerateVRFChallenge(SessionID string) (*Challenge, error) {
 	randomBytes := make([]byte, 32)
 	_, err := rand.Read(randomBytes)
@@ -93,6 +200,10 @@ func (vm *VRFManager) GenerateVRFProof(vrfKeyPair *VRFKeyPair, challenge *Challe
 	if vrfKeyPair.PrivateKey == nil {
 		return nil, fmt.Errorf("VRF private pair is null")
 	}
+	d, pk, err := vm.privateScalar(vrfKeyPair.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
 	alpha := challenge.FinalHash
 
 	h := vm.hashToCurve(alpha)
@@ -100,12 +211,12 @@ func (vm *VRFManager) GenerateVRFProof(vrfKeyPair *VRFKeyPair, challenge *Challe
 		return nil, fmt.Errorf("failed to hash to curve")
 	}
 
-	gammaX, gammaY := vm.curve.ScalarMult(h.X, h.Y, vrfKeyPair.PrivateKey.D.Bytes())
+	gammaX, gammaY := vm.curve.ScalarMult(h.X, h.Y, d.Bytes())
 	gamma := &ECPoint{X: gammaX, Y: gammaY}
 
 	beta := vm.hashPoint(gamma)
 
-	proof, err := vm.generateZKProof(vrfKeyPair.PrivateKey, h, gamma, alpha)
+	proof, err := vm.generateZKProof(d, pk, h, gamma, alpha)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate proof: %w", err)
 	}
@@ -119,6 +230,9 @@ func (vm *VRFManager) GenerateVRFProof(vrfKeyPair *VRFKeyPair, challenge *Challe
 }
 
 func (vm *VRFManager) hashToCurve(data []byte) *ECPoint {
+	if curve, ok := vm.curve.(*ed25519Curve); ok {
+		return curve.hashToCurve(data)
+	}
 	hasher := sha256.New()
 	hasher.Write(data)
 
@@ -162,9 +276,13 @@ func (vm *VRFManager) hashToCurve(data []byte) *ECPoint {
 }
 
 func (vm *VRFManager) hashPoint(point *ECPoint) []byte {
+	x, y := point.X, point.Y
+	if curve, ok := vm.curve.(*ed25519Curve); ok {
+		x, y = curve.clearCofactor(x, y)
+	}
 	hasher := sha256.New()
-	hasher.Write(point.X.Bytes())
-	hasher.Write(point.Y.Bytes())
+	hasher.Write(x.Bytes())
+	hasher.Write(y.Bytes())
 	return hasher.Sum(nil)
 }
 
@@ -266,7 +384,7 @@ func (vm *VRFManager) tonelliShanks(a, p *big.Int) *big.Int {
 	}
 }
 
-func (vm *VRFManager) generateZKProof(privateKey *ecdsa.PrivateKey, h *ECPoint, gamma *ECPoint, alpha []byte) (*struct{ C, S *big.Int }, error) {
+func (vm *VRFManager) generateZKProof(d *big.Int, pk *ECPoint, h *ECPoint, gamma *ECPoint, alpha []byte) (*struct{ C, S *big.Int }, error) {
 	k, err := rand.Int(rand.Reader, vm.curve.Params().N)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate zk proof: %w", err)
@@ -280,8 +398,8 @@ func (vm *VRFManager) generateZKProof(privateKey *ecdsa.PrivateKey, h *ECPoint,
 	hasher.Write(vm.curve.Params().Gy.Bytes())
 	hasher.Write(h.X.Bytes())
 	hasher.Write(h.Y.Bytes())
-	hasher.Write(privateKey.PublicKey.X.Bytes())
-	hasher.Write(privateKey.PublicKey.Y.Bytes())
+	hasher.Write(pk.X.Bytes())
+	hasher.Write(pk.Y.Bytes())
 	hasher.Write(gamma.X.Bytes())
 	hasher.Write(gamma.Y.Bytes())
 	hasher.Write(r1x.Bytes())
@@ -293,24 +411,24 @@ func (vm *VRFManager) generateZKProof(privateKey *ecdsa.PrivateKey, h *ECPoint,
 	c := new(big.Int).SetBytes(hasher.Sum(nil))
 	c.Mod(c, vm.curve.Params().N)
 
-	s := new(big.Int).Mul(c, privateKey.D)
+	s := new(big.Int).Mul(c, d)
 	s.Add(s, k)
 	s.Mod(s, vm.curve.Params().N)
 
 	return &struct{ C, S *big.Int }{C: c, S: s}, nil
 }
 
-func (vm *VRFManager) VerifyZKProof(vrfPK *ecdsa.PublicKey, h *ECPoint, gamma *ECPoint, alpha []byte, c, s *big.Int) bool {
+func (vm *VRFManager) VerifyZKProof(vrfPK crypto.PublicKey, h *ECPoint, gamma *ECPoint, alpha []byte, c, s *big.Int) bool {
+	pk, err := vm.publicPoint(vrfPK)
+	if err != nil || c == nil || s == nil || c.Sign() < 0 || s.Sign() < 0 {
+		return false
+	}
 	sx, sy := vm.curve.ScalarBaseMult(s.Bytes())
-	cx, cy := vm.curve.ScalarMult(vrfPK.X, vrfPK.Y, c.Bytes())
-
-	cy.Sub(vm.curve.Params().P, cy)
+	cx, cy := vm.negate(vm.curve.ScalarMult(pk.X, pk.Y, c.Bytes()))
 	r1x, r1y := vm.curve.Add(sx, sy, cx, cy)
 
 	sx2, sy2 := vm.curve.ScalarMult(h.X, h.Y, s.Bytes())
-	cx2, cy2 := vm.curve.ScalarMult(gamma.X, gamma.Y, c.Bytes())
-
-	cy2.Sub(vm.curve.Params().P, cy2)
+	cx2, cy2 := vm.negate(vm.curve.ScalarMult(gamma.X, gamma.Y, c.Bytes()))
 	r2x, r2y := vm.curve.Add(sx2, sy2, cx2, cy2)
 
 	hasher := sha256.New()
@@ -318,8 +436,8 @@ func (vm *VRFManager) VerifyZKProof(vrfPK *ecdsa.PublicKey, h *ECPoint, gamma *E
 	hasher.Write(vm.curve.Params().Gy.Bytes())
 	hasher.Write(h.X.Bytes())
 	hasher.Write(h.Y.Bytes())
-	hasher.Write(vrfPK.X.Bytes())
-	hasher.Write(vrfPK.Y.Bytes())
+	hasher.Write(pk.X.Bytes())
+	hasher.Write(pk.Y.Bytes())
 	hasher.Write(gamma.X.Bytes())
 	hasher.Write(gamma.Y.Bytes())
 	hasher.Write(r1x.Bytes())
@@ -334,10 +452,13 @@ func (vm *VRFManager) VerifyZKProof(vrfPK *ecdsa.PublicKey, h *ECPoint, gamma *E
 	return c.Cmp(expectedC) == 0
 }
 
-func (vm *VRFManager) VerifyVRFProof(vrfPK *ecdsa.PublicKey, challenge *Challenge, proof *VRFProof) (bool, error) {
-	if vrfPK == nil || challenge == nil || proof == nil {
+func (vm *VRFManager) VerifyVRFProof(vrfPK crypto.PublicKey, challenge *Challenge, proof *VRFProof) (bool, error) {
+	if vrfPK == nil || challenge == nil || proof == nil || proof.Gamma == nil {
 		return false, fmt.Errorf("invalid VRF verification parameters")
 	}
+	if proof.Gamma.X == nil || proof.Gamma.Y == nil || !vm.curve.IsOnCurve(proof.Gamma.X, proof.Gamma.Y) {
+		return false, fmt.Errorf("invalid VRF proof")
+	}
 	alpha := challenge.FinalHash
 
 	h := vm.hashToCurve(alpha)
@@ -354,16 +475,40 @@ func (vm *VRFManager) VerifyVRFProof(vrfPK *ecdsa.PublicKey, challenge *Challeng
 	return true, nil
 }
 
-func (vm *VRFManager) DeserializeVRFPK(data []byte) (*ecdsa.PublicKey, error) {
+// DeserializeVRFPK 解析 PKIX 编码的 ECDSA、ed25519 或 SM2 公钥
+func (vm *VRFManager) DeserializeVRFPK(data []byte) (crypto.PublicKey, error) {
 	pkInterface, err := x509.ParsePKIXPublicKey(data)
 	if err != nil {
-		return nil, err
+		// crypto/x509 不支持 sm2p256v1 曲线
+		gmKey, gmErr := gmx509.ParsePKIXPublicKey(data)
+		if gmErr != nil {
+			return nil, err
+		}
+		pkInterface = gmKey
 	}
-	pk, ok := pkInterface.(*ecdsa.PublicKey)
-	if !ok {
-		return nil, fmt.Errorf("failed to parse public key")
+	switch pk := pkInterface.(type) {
+	case *ecdsa.PublicKey, *gmecdsa.PublicKey, ed25519.PublicKey:
+		return pk, nil
+	}
+	return nil, fmt.Errorf("failed to parse public key")
+}
+
+// negate 返回 -(x, y)：短 Weierstrass 曲线上为 (x, p-y)，edwards25519 上为 (p-x, y)
+func (vm *VRFManager) negate(x, y *big.Int) (*big.Int, *big.Int) {
+	p := vm.curve.Params().P
+	if _, ok := vm.curve.(*ed25519Curve); ok {
+		return new(big.Int).Mod(new(big.Int).Sub(p, x), p), y
+	}
+	return x, new(big.Int).Sub(p, y)
+}
+
+func decodeEd25519PublicKey(key ed25519.PublicKey) (*big.Int, *big.Int, bool) {
+	p, err := new(edwards25519.Point).SetBytes(key)
+	if err != nil {
+		return nil, nil, false
 	}
-	return pk, nil
+	x, y := edwardsAffine(p)
+	return x, y, true
 }
 
 func (vm *VRFManager) bytesEqual(a, b []byte) bool {
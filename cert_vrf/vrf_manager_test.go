@@ -0,0 +1,83 @@
+package cert_vrf
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/x509"
+	"math/big"
+	"testing"
+
+	gmx509 "github.com/FISCO-BCOS/crypto/x509"
+	"github.com/FISCO-BCOS/go-sdk/smcrypto"
+)
+
+func TestVRFProveVerify(t *testing.T) {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), SM2Curve(), Ed25519Curve()} {
+		name := curve.Params().Name
+		vm := NewVRFManagerWithCurve(curve)
+		keyPair, err := vm.GenerateVRFKeyPair()
+		if err != nil {
+			t.Fatalf("%s: generate key pair failed: %v", name, err)
+		}
+		negotiated, err := NewVRFManagerForKey(keyPair.PublicKey)
+		if err != nil || negotiated.Curve() != curve {
+			t.Fatalf("%s: key should select the same curve, have %v", name, err)
+		}
+
+		challenge, _ := vm.GenerateVRFChallenge("session")
+		proof, err := vm.GenerateVRFProof(keyPair, challenge)
+		if err != nil {
+			t.Fatalf("%s: generate proof failed: %v", name, err)
+		}
+		if ok, err := negotiated.VerifyVRFProof(keyPair.PublicKey, challenge, proof); !ok {
+			t.Fatalf("%s: proof should verify: %v", name, err)
+		}
+		// VRF 输出由密钥与输入唯一确定
+		again, _ := vm.GenerateVRFProof(keyPair, challenge)
+		if !bytes.Equal(again.Beta, proof.Beta) {
+			t.Fatalf("%s: VRF output should be deterministic", name)
+		}
+
+		tampered := *proof
+		tampered.S = new(big.Int).Add(proof.S, big.NewInt(1))
+		if ok, _ := vm.VerifyVRFProof(keyPair.PublicKey, challenge, &tampered); ok {
+			t.Fatalf("%s: tampered proof should be rejected", name)
+		}
+		other, _ := vm.GenerateVRFChallenge("other")
+		if ok, _ := vm.VerifyVRFProof(keyPair.PublicKey, other, proof); ok {
+			t.Fatalf("%s: proof for another challenge should be rejected", name)
+		}
+		otherKey, _ := vm.GenerateVRFKeyPair()
+		if ok, _ := vm.VerifyVRFProof(otherKey.PublicKey, challenge, proof); ok {
+			t.Fatalf("%s: proof should be rejected for another key", name)
+		}
+	}
+
+	// 曲线不匹配的公钥被拒绝
+	p384Key, _ := NewVRFManager().GenerateVRFKeyPair()
+	challenge, _ := NewVRFManager().GenerateVRFChallenge("session")
+	proof, _ := NewVRFManager().GenerateVRFProof(p384Key, challenge)
+	if ok, _ := NewVRFManagerWithCurve(elliptic.P256()).VerifyVRFProof(p384Key.PublicKey, challenge, proof); ok {
+		t.Fatalf("P-384 key should be rejected by a P-256 manager")
+	}
+}
+
+func TestDeserializeVRFPK(t *testing.T) {
+	vm := NewVRFManager()
+	for _, curve := range []elliptic.Curve{elliptic.P384(), Ed25519Curve()} {
+		keyPair, _ := NewVRFManagerWithCurve(curve).GenerateVRFKeyPair()
+		der, _ := x509.MarshalPKIXPublicKey(keyPair.PublicKey)
+		if _, err := vm.DeserializeVRFPK(der); err != nil {
+			t.Fatalf("%s: deserialize failed: %v", curve.Params().Name, err)
+		}
+	}
+	sm2Key, _ := smcrypto.GenerateSM2Key()
+	der, _ := gmx509.MarshalPKIXPublicKey(&sm2Key.PublicKey)
+	pk, err := vm.DeserializeVRFPK(der)
+	if err != nil {
+		t.Fatalf("SM2: deserialize failed: %v", err)
+	}
+	if negotiated, err := NewVRFManagerForKey(pk); err != nil || negotiated.Curve() != SM2Curve() {
+		t.Fatalf("SM2 public key should select the SM2 curve, have %v", err)
+	}
+}
@@ -2,18 +2,13 @@ package helloworld
 
 import (
 	"context"
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
-	"crypto/x509"
 	"crypto/x509/pkix"
 	"database/sql"
-	"encoding/hex"
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
+	"github.com/FISCO-BCOS/go-sdk/cer_ca_tools"
 	"log"
-	"math/big"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -85,21 +80,19 @@ func GenerateCAHandle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	caPrivKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	// key_algorithm 可取 P-256、P-384（默认）或 Ed25519
+	caPrivKey, algorithm, err := generateKey(r.FormValue("key_algorithm"))
 	if err != nil {
-		fail(http.StatusInternalServerError, "生成CA椭圆曲线私钥失败", err)
+		fail(http.StatusBadRequest, "不支持的密钥算法", err)
 		return
 	}
 
-	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 160)
-	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
+	profile, err := certProfiles.Get(cer_ca_tools.ProfileRootCA)
 	if err != nil {
-		fail(http.StatusInternalServerError, "生成序列号失败", err)
+		fail(http.StatusInternalServerError, "证书模板不存在", err)
 		return
 	}
-
-	tmpl := x509.Certificate{
-		SerialNumber: serialNumber,
+	tmpl, err := profile.NewTemplate(&cer_ca_tools.IssuanceRequest{
 		Subject: pkix.Name{
 			Country:            []string{"CN"},
 			Province:           []string{"Beijing"},
@@ -108,30 +101,29 @@ func GenerateCAHandle(w http.ResponseWriter, r *http.Request) {
 			OrganizationalUnit: []string{"IT"},
 			CommonName:         "Test_CA_one",
 		},
-		NotBefore:             time.Now(),
-		NotAfter:              time.Now().AddDate(10, 0, 0),
-		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
-		BasicConstraintsValid: true,
-		IsCA:                  true,
-		MaxPathLen:            2,
+		PublicKey: caPrivKey.Public(),
+	}, time.Now())
+	if err != nil {
+		fail(http.StatusInternalServerError, "生成CA证书模板失败", err)
+		return
 	}
 
-	certDER, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &caPrivKey.PublicKey, caPrivKey)
+	certDER, err := cer_ca_tools.CreateCertificate(tmpl, tmpl, caPrivKey.Public(), caPrivKey)
 	if err != nil {
 		fail(http.StatusInternalServerError, "生成CA证书失败", err)
 		return
 	}
 
 	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
-	keyDER, err := x509.MarshalPKCS8PrivateKey(caPrivKey)
+	// 设置 CA_KEY_PASSPHRASE 时私钥加密保存
+	keyPEM, err := cer_ca_tools.MarshalPrivateKeyPEM(caPrivKey, cer_ca_tools.PassphraseFromEnv())
 	if err != nil {
 		fail(http.StatusInternalServerError, "序列化CA私钥失败", err)
 		return
 	}
-	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
 
 	// 唯一文件名：使用序列号十六进制
-	serialHex := strings.ToUpper(serialNumber.Text(16))
+	serialHex := strings.ToUpper(tmpl.SerialNumber.Text(16))
 	certPath := filepath.Join("./helloworld/caCert", fmt.Sprintf("%s-%s.crt", tmpl.Subject.CommonName, serialHex))
 	keyPath := filepath.Join("./helloworld/caKey", fmt.Sprintf("ca-%s.key", serialHex))
 
@@ -144,9 +136,12 @@ func GenerateCAHandle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// —— 计算公钥字符串（未压缩点 HEX：04 || X || Y）
-	pubBytes := elliptic.Marshal(elliptic.P384(), caPrivKey.PublicKey.X, caPrivKey.PublicKey.Y)
-	pubHex := strings.ToUpper(hex.EncodeToString(pubBytes)) // 例：04A3...
+	// —— 计算公钥字符串（EC 为未压缩点 HEX：04 || X || Y）
+	pubHex, err := publicKeyHex(caPrivKey.Public())
+	if err != nil {
+		fail(http.StatusInternalServerError, "编码CA公钥失败", err)
+		return
+	}
 
 	db, err := openDB()
 	if err != nil {
@@ -159,26 +154,50 @@ func GenerateCAHandle(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, "写入数据库失败")
+		return
+	}
+	defer tx.Rollback()
+
 	const insertSQL = `INSERT INTO dpki.CAList (caPublicKey, caStatus, cert_path, key_path) VALUES (?, ?, ?, ?)`
-	res, err := db.ExecContext(ctx, insertSQL, pubHex, "active", certPath, keyPath)
+	res, err := tx.ExecContext(ctx, insertSQL, pubHex, "active", certPath, keyPath)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, "写入数据库失败")
 		return
 	}
 
+	// 审计记录写入后再提交，写入失败时不登记该CA
 	newID, _ := res.LastInsertId()
+	caCert, err := cer_ca_tools.ParseCertificate(certDER)
+	if err == nil {
+		err = recordAudit(caCert, caPrivKey, cer_ca_tools.AuditEventCACreate, serialHex, map[string]string{
+			"ca_id":     strconv.FormatInt(newID, 10),
+			"cert_path": certPath,
+		})
+	}
+	if err != nil {
+		fail(http.StatusInternalServerError, "写入审计日志失败", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, "写入数据库失败")
+		return
+	}
 
 	// 成功返回 JSON
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(http.StatusCreated) // 201
 	_ = json.NewEncoder(w).Encode(map[string]any{
-		"msg":        "ok",
-		"id":         newID,
-		"serial":     serialNumber.String(),
-		"serial_hex": serialHex,
-		"public_key": pubHex,
-		"status":     "active",
-		"cert_path":  certPath,
+		"msg":           "ok",
+		"id":            newID,
+		"serial":        tmpl.SerialNumber.String(),
+		"serial_hex":    serialHex,
+		"public_key":    pubHex,
+		"key_algorithm": algorithm,
+		"status":        "active",
+		"cert_path":     certPath,
 		// 如需前端下载私钥，绝不要直返私钥；仅返回 keyPath/受控下载接口
 		// "key_path": keyPath,
 	})
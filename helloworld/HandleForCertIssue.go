@@ -2,17 +2,14 @@ package helloworld
 
 import (
 	"context"
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
-	"crypto/x509"
 	"crypto/x509/pkix"
 	"database/sql"
 	"encoding/json"
 	"encoding/pem"
 	"errors"
 	"fmt"
-	"math/big"
+	"github.com/FISCO-BCOS/go-sdk/cer_ca_tools"
+	"github.com/FISCO-BCOS/go-sdk/smcrypto"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -283,12 +280,16 @@ func IssueCertIssuanceHandler(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	var in struct {
-		Request_ID int64 `json:"request_id"`
+		Request_ID   int64  `json:"request_id"`
+		KeyAlgorithm string `json:"key_algorithm"` // 证书主体密钥算法，为空时使用查询参数或默认 P-384
 	}
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
 		writeJSON(w, http.StatusBadRequest, err)
 		return
 	}
+	if in.KeyAlgorithm == "" {
+		in.KeyAlgorithm = r.URL.Query().Get("key_algorithm")
+	}
 
 	db, err := openDB()
 	if err != nil {
@@ -375,38 +376,34 @@ func IssueCertIssuanceHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	subPrivKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	subPrivKey, _, err := generateKey(in.KeyAlgorithm)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, "生成CA椭圆曲线私钥失败")
+		writeJSON(w, http.StatusBadRequest, "unsupported key algorithm: "+err.Error())
 		return
 	}
 
-	/***** 4) 生成并签发证书 *****/
-	serialLimit := new(big.Int).Lsh(big.NewInt(1), 128)
-	leafSerial, err := rand.Int(rand.Reader, serialLimit)
+	/***** 4) 按 anon-client 模板生成并签发证书 *****/
+	profile, err := certProfiles.Get(cer_ca_tools.ProfileAnonClient)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, "gen serial failed")
+		writeJSON(w, http.StatusInternalServerError, "certificate profile not found")
 		return
 	}
-
-	leaf := x509.Certificate{
-		SerialNumber:          leafSerial,
-		Subject:               anon, // 匿名化主题
-		NotBefore:             time.Now().Add(-5 * time.Minute),
-		NotAfter:              time.Now().AddDate(1, 0, 0), // 有效期 1 年
-		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
-		BasicConstraintsValid: true,
-		IsCA:                  false,
+	leaf, err := profile.NewTemplate(&cer_ca_tools.IssuanceRequest{
+		Subject:   anon, // 匿名化主题
+		PublicKey: subPrivKey.Public(),
+	}, time.Now())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, "build certificate template failed")
+		return
 	}
 
-	leafDER, err := x509.CreateCertificate(rand.Reader, &leaf, caCert, &subPrivKey.PublicKey, caKey)
+	leafDER, err := cer_ca_tools.CreateCertificate(leaf, caCert, subPrivKey.Public(), caKey)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, "create certificate failed")
 		return
 	}
 	leafPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER})
-	keyDER, err := x509.MarshalPKCS8PrivateKey(subPrivKey)
+	keyDER, err := smcrypto.MarshalPKCS8PrivateKey(subPrivKey)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, "序列化CA私钥失败")
 		return
@@ -418,7 +415,7 @@ func IssueCertIssuanceHandler(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusInternalServerError, "mkdir issuedCert failed")
 		return
 	}
-	serialHex := strings.ToUpper(leafSerial.Text(16))
+	serialHex := strings.ToUpper(leaf.SerialNumber.Text(16))
 	outPath := filepath.Join("./helloworld/subCert", fmt.Sprintf("req-%d-%s.crt", in.Request_ID, serialHex))
 	keyPath := filepath.Join("./helloworld/subKey", fmt.Sprintf("sub-%s.key", serialHex))
 
@@ -443,8 +440,7 @@ func IssueCertIssuanceHandler(w http.ResponseWriter, r *http.Request) {
 	defer tx.Rollback()
 
 	// 1) 写入 CertList（根据你的表结构微调列名）
-	if _, err := tx.ExecContext(ctx,
-		`INSERT INTO dpki.CertList (ca_id,serialHex, cert_path) VALUES (?, ?, ?)`, ca.CA_ID, serialHex, outPath); err != nil {
+	if _, err := tx.ExecContext(ctx, insertCertSQL, ca.CA_ID, serialHex, outPath, in.Request_ID); err != nil {
 		writeJSON(w, http.StatusInternalServerError, "insert CertList failed")
 		return
 	}
@@ -456,6 +452,14 @@ func IssueCertIssuanceHandler(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusInternalServerError, "update request status failed")
 		return
 	}
+	// 审计记录写入后再提交，写入失败时放弃签发
+	if err := recordAudit(caCert, caKey, cer_ca_tools.AuditEventIssue, serialHex, map[string]string{
+		"request_id": strconv.FormatInt(in.Request_ID, 10),
+		"cert_path":  outPath,
+	}); err != nil {
+		writeJSON(w, http.StatusInternalServerError, "record audit failed")
+		return
+	}
 	if err := tx.Commit(); err != nil {
 		writeJSON(w, http.StatusInternalServerError, "commit tx failed")
 		return
@@ -0,0 +1,196 @@
+package helloworld
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"database/sql"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/FISCO-BCOS/go-sdk/cer_ca_tools"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// /api/update/cert
+// 持有仍有效匿名证书的主体用该证书私钥签名续期请求，可选携带新公钥的 CSR；
+// 后继证书沿用原匿名主体，不需要重新提交 CRT 参数
+func UpdateCertHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, "only support POST method")
+		return
+	}
+	defer r.Body.Close()
+
+	var renewal cer_ca_tools.RenewalRequest
+	if err := json.NewDecoder(r.Body).Decode(&renewal); err != nil {
+		writeJSON(w, http.StatusBadRequest, "bad request")
+		return
+	}
+
+	/***** 1) 校验请求签名、时间戳与可选的换钥 CSR *****/
+	now := time.Now()
+	predecessor, csr, err := renewal.Verify(now)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if now.Before(predecessor.NotBefore) || now.After(predecessor.NotAfter) {
+		writeJSON(w, http.StatusBadRequest, "certificate is not within its validity period")
+		return
+	}
+
+	db, err := openDB()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, "open db error")
+		return
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+
+	/***** 2) 原证书必须由本系统签发且未撤销 *****/
+	predSerialHex := strings.ToUpper(predecessor.SerialNumber.Text(16))
+	var (
+		caID         int64
+		requestID    sql.NullInt64
+		predCertPath string
+		caCertPath   string
+		caKeyPath    string
+	)
+	err = db.QueryRowContext(ctx, `SELECT l.ca_id, l.request_id, l.cert_path, c.cert_path, c.key_path FROM dpki.CertList AS l
+		JOIN dpki.CAList AS c ON c.ca_id = l.ca_id WHERE l.serialHex = ? LIMIT 1`, predSerialHex).
+		Scan(&caID, &requestID, &predCertPath, &caCertPath, &caKeyPath)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSON(w, http.StatusNotFound, "certificate not found")
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, "query CertList failed")
+		return
+	}
+	if !sameCertificate(predCertPath, predecessor) {
+		writeJSON(w, http.StatusBadRequest, "certificate does not match the issued certificate")
+		return
+	}
+	revoked, err := certificateRevoked(ctx, db, predSerialHex)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, "query revocation status failed")
+		return
+	}
+	if revoked {
+		writeJSON(w, http.StatusBadRequest, "certificate is revoked")
+		return
+	}
+
+	caCert, caKey, err := loadCACredential(caCertPath, caKeyPath)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, "load CA credential failed")
+		return
+	}
+
+	/***** 3) 沿用原匿名主体与 SAN，换钥时使用 CSR 中的新公钥 *****/
+	request := &cer_ca_tools.IssuanceRequest{Subject: predecessor.Subject, PublicKey: predecessor.PublicKey}
+	if csr != nil {
+		if request, err = cer_ca_tools.DefaultCSRPolicy().ValidateCSR(csr, predecessor.Subject); err != nil {
+			writeJSON(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	request.DNSNames = predecessor.DNSNames
+	request.EmailAddresses = predecessor.EmailAddresses
+	request.IPAddresses = predecessor.IPAddresses
+	request.URIs = predecessor.URIs
+
+	profile, err := certProfiles.Get(cer_ca_tools.ProfileAnonClient)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, "certificate profile not found")
+		return
+	}
+	leaf, err := profile.NewTemplate(request, now)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	leafDER, err := cer_ca_tools.CreateCertificate(leaf, caCert, request.PublicKey, caKey)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, "create certificate failed")
+		return
+	}
+	leafPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER})
+
+	/***** 4) 落盘 + 入库（CertList），可选以 superseded 撤销原证书 *****/
+	if err := os.MkdirAll("./helloworld/subCert", 0o755); err != nil {
+		writeJSON(w, http.StatusInternalServerError, "创建证书目录失败")
+		return
+	}
+	serialHex := strings.ToUpper(leaf.SerialNumber.Text(16))
+	outPath := filepath.Join("./helloworld/subCert", fmt.Sprintf("renew-%s-%s.crt", predSerialHex, serialHex))
+	if err := os.WriteFile(outPath, leafPEM, 0o644); err != nil {
+		writeJSON(w, http.StatusInternalServerError, "写入主体证书失败")
+		return
+	}
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, "begin tx failed")
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, insertCertSQL, caID, serialHex, outPath, requestID); err != nil {
+		writeJSON(w, http.StatusInternalServerError, "insert CertList failed")
+		return
+	}
+	if renewal.RevokePredecessor {
+		if _, err := tx.ExecContext(ctx, revokeCertSQL, predSerialHex, caID, "superseded"); err != nil {
+			writeJSON(w, http.StatusInternalServerError, "insert revokedCertList failed")
+			return
+		}
+	}
+	// 审计记录写入后再提交，写入失败时放弃续期
+	if err := recordAudit(caCert, caKey, cer_ca_tools.AuditEventIssue, serialHex, map[string]string{
+		"predecessor": predSerialHex,
+		"cert_path":   outPath,
+	}); err != nil {
+		writeJSON(w, http.StatusInternalServerError, "record audit failed")
+		return
+	}
+	if renewal.RevokePredecessor {
+		if err := recordAudit(caCert, caKey, cer_ca_tools.AuditEventRevoke, predSerialHex, map[string]string{"reason": "superseded"}); err != nil {
+			writeJSON(w, http.StatusInternalServerError, "record audit failed")
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, "commit tx failed")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"msg":                 "renewed",
+		"serial_hex":          serialHex,
+		"predecessor_serial":  predSerialHex,
+		"predecessor_revoked": renewal.RevokePredecessor,
+		"rekey":               csr != nil,
+		"cert_path":           outPath,
+		"certificate":         string(leafPEM),
+		"ca_id":               caID,
+	})
+}
+
+// sameCertificate 判断请求中的证书与落盘的已签发证书是否一致
+func sameCertificate(certPath string, cert *x509.Certificate) bool {
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return false
+	}
+	block, _ := pem.Decode(certPEM)
+	return block != nil && bytes.Equal(block.Bytes, cert.Raw)
+}
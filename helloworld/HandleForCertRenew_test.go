@@ -0,0 +1,174 @@
+package helloworld
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+)
+
+// certStore 按本包使用的 SQL 语句模拟 dpki 库中与撤销相关的表
+type certStore struct {
+	mutex    sync.Mutex
+	certs    map[string]certRow // CertList，按 serialHex 索引
+	requests map[string]string  // requestCertList.Status，按 ID 索引
+	revoked  map[string]string  // revokedCertList.reason，按 serialHex 索引
+}
+
+type certRow struct {
+	caID      int64
+	requestID string // 空串表示 NULL
+}
+
+func (store *certStore) exec(query string, args []driver.Value) (int64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	arg := func(i int) string {
+		if args[i] == nil {
+			return ""
+		}
+		return fmt.Sprint(args[i])
+	}
+	switch query {
+	case insertCertSQL:
+		store.certs[arg(1)] = certRow{caID: args[0].(int64), requestID: arg(3)}
+	case revokeCertSQL:
+		if _, exists := store.revoked[arg(0)]; !exists {
+			store.revoked[arg(0)] = arg(2)
+		}
+	case revokeRequestSQL:
+		store.requests[arg(0)] = "revoked"
+	case revokeRequestCertSQL:
+		for serial, cert := range store.certs {
+			if _, exists := store.revoked[serial]; !exists && cert.requestID == arg(0) {
+				store.revoked[serial] = "unspecified"
+			}
+		}
+	case certRevokedSQL:
+		cert, exists := store.certs[arg(0)]
+		_, revoked := store.revoked[arg(0)]
+		if exists && (revoked || cert.requestID != "" && store.requests[cert.requestID] == "revoked") {
+			return 1, nil
+		}
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("unexpected query %q", query)
+	}
+	return 0, nil
+}
+
+func (store *certStore) Open(string) (driver.Conn, error) { return &certConn{store}, nil }
+
+type certConn struct{ store *certStore }
+
+func (conn *certConn) Prepare(query string) (driver.Stmt, error) {
+	return &certStmt{conn.store, query}, nil
+}
+func (conn *certConn) Close() error              { return nil }
+func (conn *certConn) Begin() (driver.Tx, error) { return conn, nil }
+func (conn *certConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return conn, nil
+}
+func (conn *certConn) Commit() error   { return nil }
+func (conn *certConn) Rollback() error { return nil }
+
+type certStmt struct {
+	store *certStore
+	query string
+}
+
+func (stmt *certStmt) Close() error  { return nil }
+func (stmt *certStmt) NumInput() int { return -1 }
+func (stmt *certStmt) Exec(args []driver.Value) (driver.Result, error) {
+	_, err := stmt.store.exec(stmt.query, args)
+	return driver.RowsAffected(1), err
+}
+func (stmt *certStmt) Query(args []driver.Value) (driver.Rows, error) {
+	count, err := stmt.store.exec(stmt.query, args)
+	return &countRows{count: count}, err
+}
+
+type countRows struct {
+	count int64
+	done  bool
+}
+
+func (rows *countRows) Columns() []string { return []string{"COUNT(*)"} }
+func (rows *countRows) Close() error      { return nil }
+func (rows *countRows) Next(dest []driver.Value) error {
+	if rows.done {
+		return io.EOF
+	}
+	rows.done = true
+	dest[0] = rows.count
+	return nil
+}
+
+func openCertStore(t *testing.T) *sql.DB {
+	name := "certstore-" + t.Name()
+	sql.Register(name, &certStore{
+		certs:    make(map[string]certRow),
+		requests: make(map[string]string),
+		revoked:  make(map[string]string),
+	})
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatalf("open fake db failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestRevokedCertificateCannotBeRenewed(t *testing.T) {
+	ctx := context.Background()
+	db := openCertStore(t)
+
+	// 申请单 7 签发 AA，AA 续期得到 BB（沿用申请单）；CC 属于另一申请单
+	for _, cert := range []struct {
+		serial    string
+		requestID sql.NullInt64
+	}{
+		{"AA", sql.NullInt64{Int64: 7, Valid: true}},
+		{"BB", sql.NullInt64{Int64: 7, Valid: true}},
+		{"CC", sql.NullInt64{Int64: 8, Valid: true}},
+		{"DD", sql.NullInt64{}},
+		{"EE", sql.NullInt64{Int64: 9, Valid: true}},
+	} {
+		if _, err := db.ExecContext(ctx, insertCertSQL, int64(1), cert.serial, "cert.crt", cert.requestID); err != nil {
+			t.Fatalf("insert certificate failed: %v", err)
+		}
+	}
+	if revoked, err := certificateRevoked(ctx, db, "AA"); err != nil || revoked {
+		t.Fatalf("certificate should be renewable before revocation: %v", err)
+	}
+
+	// 通过 /api/revoke/cert 使用的 revokeRequest 撤销申请单后，其全部证书都不能续期
+	if err := revokeRequest(ctx, db, "7", nil); err != nil {
+		t.Fatalf("revoke request failed: %v", err)
+	}
+	for serial, want := range map[string]bool{"AA": true, "BB": true, "CC": false, "DD": false, "EE": false} {
+		if revoked, err := certificateRevoked(ctx, db, serial); err != nil || revoked != want {
+			t.Fatalf("certificate %s revoked = %v, want %v: %v", serial, revoked, want, err)
+		}
+	}
+
+	// 续期时以 superseded 撤销的原证书同样不能再次续期
+	if _, err := db.ExecContext(ctx, revokeCertSQL, "CC", int64(1), "superseded"); err != nil {
+		t.Fatalf("revoke superseded certificate failed: %v", err)
+	}
+	if revoked, err := certificateRevoked(ctx, db, "CC"); err != nil || !revoked {
+		t.Fatalf("superseded certificate should not be renewable: %v", err)
+	}
+
+	// 迁移前撤销的申请单只有 requestCertList.Status，没有 revokedCertList 记录
+	if _, err := db.ExecContext(ctx, revokeRequestSQL, "9"); err != nil {
+		t.Fatalf("revoke request status failed: %v", err)
+	}
+	if revoked, err := certificateRevoked(ctx, db, "EE"); err != nil || !revoked {
+		t.Fatalf("certificate of a revoked request should not be renewable: %v", err)
+	}
+}
@@ -1,12 +1,60 @@
 package helloworld
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"github.com/FISCO-BCOS/go-sdk/cer_ca_tools"
 	"net/http"
+	"strconv"
 )
 
+// 证书级撤销状态保存在 dpki.revokedCertList，表结构见 migrations/001_revoked_cert_list.sql
+const (
+	insertCertSQL        = `INSERT INTO dpki.CertList (ca_id, serialHex, cert_path, request_id) VALUES (?, ?, ?, ?)`
+	revokeCertSQL        = `INSERT IGNORE INTO dpki.revokedCertList (serialHex, ca_id, reason) VALUES (?, ?, ?)`
+	revokeRequestSQL     = `UPDATE dpki.requestCertList SET Status='revoked' WHERE ID=?`
+	revokeRequestCertSQL = `INSERT IGNORE INTO dpki.revokedCertList (serialHex, ca_id, reason)
+		SELECT serialHex, ca_id, 'unspecified' FROM dpki.CertList WHERE request_id = ?`
+	// 迁移前签发的证书没有 revokedCertList 记录，同时检查其申请单状态
+	certRevokedSQL = `SELECT COUNT(*) FROM dpki.CertList AS l
+		LEFT JOIN dpki.requestCertList AS r ON r.ID = l.request_id
+		LEFT JOIN dpki.revokedCertList AS v ON v.serialHex = l.serialHex
+		WHERE l.serialHex = ? AND (v.serialHex IS NOT NULL OR r.Status = 'revoked')`
+)
+
+// revokeRequest 撤销申请单及其签发（含续期）的全部证书，audit 非空时在提交前调用，返回错误则放弃撤销
+func revokeRequest(ctx context.Context, db *sql.DB, requestID string, audit func() error) error {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, revokeRequestSQL, requestID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, revokeRequestCertSQL, requestID); err != nil {
+		return err
+	}
+	if audit != nil {
+		if err := audit(); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// certificateRevoked 证书是否已撤销
+func certificateRevoked(ctx context.Context, db *sql.DB, serialHex string) (bool, error) {
+	var revoked int
+	if err := db.QueryRowContext(ctx, certRevokedSQL, serialHex).Scan(&revoked); err != nil {
+		return false, err
+	}
+	return revoked > 0, nil
+}
+
 // /api/revoke/list
 func RevocationListHandler(w http.ResponseWriter, r *http.Request) {
 	db, err := openDB()
@@ -86,8 +134,21 @@ func RevocationCertHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = db.Exec(`UPDATE requestCertList SET Status='revoked' WHERE ID=?`, in.Request_ID)
-	if err != nil {
+	audit := func() error {
+		if auditLog == nil {
+			return nil
+		}
+		requestID, err := strconv.ParseInt(in.Request_ID, 10, 64)
+		if err != nil {
+			return err
+		}
+		ca, err := findCAForRequest(r.Context(), db, requestID)
+		if err != nil {
+			return err
+		}
+		return recordAuditForCA(ca, cer_ca_tools.AuditEventRevoke, "", map[string]string{"request_id": in.Request_ID})
+	}
+	if err := revokeRequest(r.Context(), db, in.Request_ID, audit); err != nil {
 		http.Error(w, "update error", http.StatusInternalServerError)
 		return
 	}
@@ -0,0 +1,164 @@
+package helloworld
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/FISCO-BCOS/go-sdk/cer_ca_tools"
+)
+
+// auditLog DPKI 操作审计日志，为 nil 时不记录
+var auditLog *cer_ca_tools.AuditLog
+
+// OpenAuditLog 打开审计日志，此后CA生成、签发、更新、撤销与模数分发都会记录到日志
+func OpenAuditLog(path string) error {
+	l, err := cer_ca_tools.OpenAuditLog(path)
+	if err != nil {
+		return err
+	}
+	auditLog = l
+	return nil
+}
+
+// recordAudit 以执行操作的CA私钥签名并追加审计记录
+func recordAudit(caCert *x509.Certificate, caKey crypto.Signer, event, serial string, details map[string]string) error {
+	if auditLog == nil {
+		return nil
+	}
+	_, err := auditLog.Append(caKey, cer_ca_tools.AuditKeyID(caCert), caCert.Subject.CommonName, event, serial, details)
+	if err != nil {
+		log.Printf("record %s audit entry failed: %v", event, err)
+	}
+	return err
+}
+
+// recordAuditForCA 加载CA证书与私钥后记录审计
+func recordAuditForCA(ca caRow, event, serial string, details map[string]string) error {
+	if auditLog == nil {
+		return nil
+	}
+	caCert, caKey, err := loadCACredential(ca.CertPath, ca.KeyPath)
+	if err != nil {
+		log.Printf("record %s audit entry failed: %v", event, err)
+		return err
+	}
+	return recordAudit(caCert, caKey, event, serial, details)
+}
+
+// RecordRequestAudit 由申请单对应的CA记录审计，用于 cmd 中的模数分发
+func RecordRequestAudit(ctx context.Context, requestID int64, event string, details map[string]string) error {
+	if auditLog == nil {
+		return nil
+	}
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ca, err := findCAForRequest(ctx, db, requestID)
+	if err != nil {
+		return fmt.Errorf("find CA for request %d: %w", requestID, err)
+	}
+	return recordAuditForCA(ca, event, "", details)
+}
+
+// auditPublicKeys 按密钥标识索引 CAList 中全部CA证书的公钥
+func auditPublicKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	db, err := openDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, `SELECT cert_path FROM dpki.CAList`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make(map[string]crypto.PublicKey)
+	for rows.Next() {
+		var certPath string
+		if err := rows.Scan(&certPath); err != nil {
+			return nil, err
+		}
+		caCert, err := readCertificate(certPath)
+		if err != nil {
+			log.Printf("load CA certificate %s failed: %v", certPath, err)
+			continue
+		}
+		keys[cer_ca_tools.AuditKeyID(caCert)] = caCert.PublicKey
+	}
+	return keys, rows.Err()
+}
+
+// AuditExportHandler 导出审计日志 /api/audit/export
+func AuditExportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, "only support GET method")
+		return
+	}
+	if auditLog == nil {
+		writeJSON(w, http.StatusNotFound, "audit log is not enabled")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit.json"`)
+	if err := auditLog.Export(w); err != nil {
+		log.Printf("export audit log failed: %v", err)
+	}
+}
+
+// AuditVerifyHandler 校验审计日志的哈希链与签名 /api/audit/verify
+func AuditVerifyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, "only support GET method")
+		return
+	}
+	if auditLog == nil {
+		writeJSON(w, http.StatusNotFound, "audit log is not enabled")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	keys, err := auditPublicKeys(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, "query CAList failed")
+		return
+	}
+
+	sequence, head := auditLog.Head()
+	out := map[string]any{
+		"valid":   true,
+		"seq":     sequence,
+		"head":    head,
+		"anchors": auditLog.Anchors(),
+	}
+	if err := auditLog.Verify(func(keyID string) crypto.PublicKey { return keys[keyID] }); err != nil {
+		out["valid"] = false
+		out["msg"] = err.Error()
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func readCertificate(certPath string) (*x509.Certificate, error) {
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("invalid certificate PEM")
+	}
+	return cer_ca_tools.ParseCertificate(block.Bytes)
+}
@@ -2,7 +2,9 @@ package helloworld
 
 import (
 	"context"
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/x509"
 	"crypto/x509/pkix"
@@ -13,10 +15,30 @@ import (
 	"encoding/pem"
 	"errors"
 	"fmt"
+	gmecdsa "github.com/FISCO-BCOS/crypto/ecdsa"
+	gmelliptic "github.com/FISCO-BCOS/crypto/elliptic"
+	"github.com/FISCO-BCOS/go-sdk/cer_ca_tools"
+	"github.com/FISCO-BCOS/go-sdk/smcrypto"
 	"os"
 	"strings"
 )
 
+// certProfiles 签发证书使用的命名模板，默认使用 cer_ca_tools 的内置模板
+var certProfiles = cer_ca_tools.DefaultCertProfiles()
+
+// LoadCertProfiles 从配置文件加载证书模板，文件不存在时保留内置模板
+func LoadCertProfiles(path string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	config, err := cer_ca_tools.LoadProfileConfig(path)
+	if err != nil {
+		return err
+	}
+	certProfiles = config.ProfileSet()
+	return nil
+}
+
 type xorFunc func([]byte) []byte
 
 // 将任意可 JSON 的值：v -> JSON -> XOR -> Base64 字符串
@@ -69,7 +91,60 @@ func buildAnonymousSubject(subject pkix.Name, xor xorFunc) (pkix.Name, error) {
 	return anon, nil
 }
 
-/********** 辅助函数：解析申请者公钥（PEM 或 HEX 未压缩点） **********/
+// defaultKeyAlgorithm 未指定 key_algorithm 时CA与证书主体使用的密钥算法
+var defaultKeyAlgorithm = cer_ca_tools.KeyAlgorithmP384
+
+// smCrypto 链为国密链（[Chain] SMCrypto=true）时为 true，此时默认使用 SM2 并签发国密证书
+var smCrypto bool
+
+// SetSMCrypto 按链配置切换国密模式：国密链的CA与证书主体默认使用 SM2，
+// HEX 公钥中 32 字节坐标的点按 SM2 曲线解析
+func SetSMCrypto(enabled bool) {
+	smCrypto = enabled
+	if enabled {
+		defaultKeyAlgorithm = cer_ca_tools.KeyAlgorithmSM2
+	} else {
+		defaultKeyAlgorithm = cer_ca_tools.KeyAlgorithmP384
+	}
+}
+
+/********** 辅助函数：按 key_algorithm 生成私钥 **********/
+func generateKey(name string) (crypto.Signer, cer_ca_tools.KeyAlgorithm, error) {
+	algorithm := defaultKeyAlgorithm
+	if name != "" {
+		var err error
+		if algorithm, err = cer_ca_tools.ParseKeyAlgorithm(name); err != nil {
+			return nil, "", err
+		}
+	}
+	key, err := cer_ca_tools.GenerateKey(algorithm)
+	if err != nil {
+		return nil, "", err
+	}
+	return key, algorithm, nil
+}
+
+/********** 辅助函数：公钥字符串（EC 与 SM2 为未压缩点 HEX：04 || X || Y，Ed25519 为 32 字节公钥 HEX） **********/
+func publicKeyHex(pub crypto.PublicKey) (string, error) {
+	var raw []byte
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		ecdhKey, err := key.ECDH()
+		if err != nil {
+			return "", err
+		}
+		raw = ecdhKey.Bytes()
+	case ed25519.PublicKey:
+		raw = key
+	case *gmecdsa.PublicKey:
+		raw = gmelliptic.Marshal(key.Curve, key.X, key.Y)
+	default:
+		return "", fmt.Errorf("unsupported public key %T", pub)
+	}
+	return strings.ToUpper(hex.EncodeToString(raw)), nil
+}
+
+/********** 辅助函数：解析申请者公钥（PEM、HEX 未压缩点或 Ed25519 公钥） **********/
 func parseApplicantPubKey(s string) (any, error) {
 	ss := strings.TrimSpace(s)
 	if ss == "" {
@@ -81,7 +156,7 @@ func parseApplicantPubKey(s string) (any, error) {
 		if block == nil {
 			return nil, errors.New("invalid PEM")
 		}
-		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		pub, err := smcrypto.ParsePKIXPublicKey(block.Bytes)
 		if err != nil {
 			return nil, fmt.Errorf("parse PKIX public key failed: %w", err)
 		}
@@ -92,6 +167,9 @@ func parseApplicantPubKey(s string) (any, error) {
 	if err != nil {
 		return nil, fmt.Errorf("decode hex failed: %w", err)
 	}
+	if len(raw) == ed25519.PublicKeySize {
+		return ed25519.PublicKey(raw), nil
+	}
 	if len(raw) < 2 || raw[0] != 0x04 {
 		return nil, errors.New("expect uncompressed EC point (starts with 0x04)")
 	}
@@ -99,7 +177,14 @@ func parseApplicantPubKey(s string) (any, error) {
 	l := len(raw) - 1
 	var curve elliptic.Curve
 	switch l {
-	case 64: // 32+32
+	case 64: // 32+32，国密链上为 SM2
+		if smCrypto {
+			x, y := gmelliptic.Unmarshal(gmelliptic.Sm2p256v1(), raw)
+			if x == nil || y == nil {
+				return nil, errors.New("elliptic.Unmarshal failed")
+			}
+			return &gmecdsa.PublicKey{Curve: gmelliptic.Sm2p256v1(), X: x, Y: y}, nil
+		}
 		curve = elliptic.P256()
 	case 96: // 48+48
 		curve = elliptic.P384()
@@ -134,8 +219,8 @@ func findCAForRequest(ctx context.Context, db *sql.DB, reqID int64) (caRow, erro
 	return row, err
 }
 
-/********** 辅助函数：读取并解析 CA 证书与私钥（PKCS#8） **********/
-func loadCACredential(certPath, keyPath string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+/********** 辅助函数：读取并解析 CA 证书与私钥（PKCS#8，可加密） **********/
+func loadCACredential(certPath, keyPath string) (*x509.Certificate, crypto.Signer, error) {
 	fmt.Println(certPath, keyPath)
 	certPEM, err := os.ReadFile(certPath)
 	if err != nil {
@@ -147,7 +232,7 @@ func loadCACredential(certPath, keyPath string) (*x509.Certificate, *ecdsa.Priva
 		return nil, nil, errors.New("invalid ca cert pem")
 	}
 
-	caCert, err := x509.ParseCertificate(block.Bytes)
+	caCert, err := cer_ca_tools.ParseCertificate(block.Bytes)
 	if err != nil {
 		return nil, nil, fmt.Errorf("parse ca cert: %w", err)
 	}
@@ -156,17 +241,9 @@ func loadCACredential(certPath, keyPath string) (*x509.Certificate, *ecdsa.Priva
 	if err != nil {
 		return nil, nil, fmt.Errorf("read ca key: %w", err)
 	}
-	kb, _ := pem.Decode(keyPEM)
-	if kb == nil {
-		return nil, nil, errors.New("invalid ca key pem")
-	}
-	privAny, err := x509.ParsePKCS8PrivateKey(kb.Bytes)
+	priv, err := cer_ca_tools.ParsePrivateKeyPEM(keyPEM, cer_ca_tools.PassphraseFromEnv())
 	if err != nil {
-		return nil, nil, fmt.Errorf("parse pkcs8 key: %w", err)
-	}
-	priv, ok := privAny.(*ecdsa.PrivateKey)
-	if !ok {
-		return nil, nil, errors.New("ca key is not ECDSA")
+		return nil, nil, fmt.Errorf("parse ca key: %w", err)
 	}
 	return caCert, priv, nil
 }
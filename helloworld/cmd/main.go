@@ -3,12 +3,15 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/FISCO-BCOS/go-sdk/cer_ca_tools"
 	"github.com/FISCO-BCOS/go-sdk/client"
 	"github.com/FISCO-BCOS/go-sdk/conf"
 	hellowrold "github.com/FISCO-BCOS/go-sdk/helloworld"
 	contractGo "github.com/FISCO-BCOS/go-sdk/helloworld/contractFile"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -42,6 +45,23 @@ func main() {
 
 	mux.HandleFunc("/api/revoke/list", hellowrold.RevocationListHandler)
 	mux.HandleFunc("/api/revoke/cert", hellowrold.RevocationCertHandler)
+	mux.HandleFunc("/api/update/cert", hellowrold.UpdateCertHandler)
+
+	// 国密链（[Chain] SMCrypto=true）上CA与证书主体默认使用 SM2，签发国密证书
+	if configs, err := conf.ParseConfigFile("config.toml"); err == nil && len(configs) > 0 {
+		hellowrold.SetSMCrypto(configs[0].IsSMCrypto)
+	}
+
+	if err := hellowrold.LoadCertProfiles("./helloworld/profiles.json"); err != nil {
+		log.Fatalf("加载证书模板失败: %v", err)
+	}
+
+	mux.HandleFunc("/api/audit/export", hellowrold.AuditExportHandler)
+	mux.HandleFunc("/api/audit/verify", hellowrold.AuditVerifyHandler)
+
+	if err := hellowrold.OpenAuditLog("./helloworld/audit.log"); err != nil {
+		log.Fatalf("打开审计日志失败: %v", err)
+	}
 
 	primePool = hellowrold.NewPrimePool()
 	err := primePool.GeneratePrimes(1000, 64) // 生成5个64位的质数
@@ -80,6 +100,19 @@ func RequestCRTHandler(w http.ResponseWriter, r *http.Request) {
 	crtParameter.Moduli, err = primePool.RandomModuli(3)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	moduli := make([]string, len(crtParameter.Moduli))
+	for i, modulus := range crtParameter.Moduli {
+		moduli[i] = modulus.String()
+	}
+	if err := hellowrold.RecordRequestAudit(r.Context(), crtParameter.ID, cer_ca_tools.AuditEventModulus, map[string]string{
+		"request_id": strconv.FormatInt(crtParameter.ID, 10),
+		"moduli":     strings.Join(moduli, ","),
+	}); err != nil {
+		log.Printf("record modulus hand-out failed: %v", err)
+		writeJSON(w, http.StatusInternalServerError, "record audit failed")
+		return
 	}
 	crtParameter.GenerateRandomRemainders()
 
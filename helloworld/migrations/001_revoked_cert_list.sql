@@ -0,0 +1,12 @@
+-- 证书级撤销记录。/api/revoke/cert 撤销申请单时写入该申请签发（含续期）的全部证书，
+-- /api/update/cert 以 superseded 撤销被续期的原证书；续期前检查原证书不在该表中。
+CREATE TABLE IF NOT EXISTS dpki.revokedCertList (
+  serialHex  VARCHAR(64) PRIMARY KEY,
+  ca_id      BIGINT      NOT NULL,
+  reason     VARCHAR(32) NOT NULL,
+  revoked_at TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 证书所属的申请单，续期证书沿用原证书的申请单；迁移前签发的证书为 NULL
+ALTER TABLE dpki.CertList ADD COLUMN request_id BIGINT NULL;
+CREATE INDEX idx_certlist_request_id ON dpki.CertList (request_id);
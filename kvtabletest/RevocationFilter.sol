@@ -0,0 +1,112 @@
+pragma solidity >=0.6.10 <0.8.20;
+
+// ca_register 中查询CA状态的接口，枚举按 uint8 返回
+interface ICARegister {
+    function getCAInfo(address caAddress) external view returns (uint256, string memory, uint8, uint8, uint8);
+}
+
+// 按CA存储撤销过滤器：计数器按 32 字节分块保存，只写入变化的块；
+// 也可以只发布各版本过滤器的摘要。只有在 ca_register 中状态为 Normal 的CA可以更新自己的过滤器。
+contract RevocationFilter {
+    uint8 constant CA_STATUS_NORMAL = 1;
+
+    struct FilterState {
+        uint64 version;       // 最近发布的过滤器版本
+        bytes32 digest;       // 该版本过滤器编码的 SHA-256
+        uint64 cellsVersion;  // 链上计数器对应的版本，与 version 不同时计数器不是最新的
+        bytes header;         // 过滤器头部（参数、元素数）
+        uint256 chunkCount;
+        uint256 updatedAt;
+        mapping(uint256 => bytes32) chunks;
+    }
+
+    ICARegister public registry;
+    mapping(address => FilterState) private filters;
+
+    event FilterChunksEvent(address indexed caAddress, uint256 chunks, uint256 timeStamp);
+    event FilterUpdateEvent(address indexed caAddress, uint64 version, bytes32 digest, bool withCells, uint256 timeStamp);
+
+    constructor(address registryAddress) public {
+        registry = ICARegister(registryAddress);
+    }
+
+    modifier onlyNormalCA(){
+        (, , uint8 status, , ) = registry.getCAInfo(msg.sender);
+        require(status == CA_STATUS_NORMAL, "permission denied, only Normal CA can update revocation filter");
+        _;
+    }
+
+    // writeChunks 写入变化的计数器块，之后需 commitFilter；期间 cellsVersion 为 0
+    function writeChunks(uint256[] memory indexes, bytes32[] memory chunks)
+        public onlyNormalCA returns (bool){
+        require(indexes.length == chunks.length, "indexes and chunks length mismatch");
+        FilterState storage state = filters[msg.sender];
+        for (uint256 i = 0; i < indexes.length; i++) {
+            state.chunks[indexes[i]] = chunks[i];
+        }
+        state.cellsVersion = 0;
+        emit FilterChunksEvent(msg.sender, indexes.length, block.timestamp);
+        return true;
+    }
+
+    // commitFilter 发布与已写入计数器一致的新版本，chunkCount 之后的旧块被清除
+    function commitFilter(uint64 version, bytes32 digest, bytes memory header, uint256 chunkCount)
+        public onlyNormalCA returns (bool){
+        FilterState storage state = filters[msg.sender];
+        require(version > state.version, "filter version must increase");
+        for (uint256 i = chunkCount; i < state.chunkCount; i++) {
+            delete state.chunks[i];
+        }
+        state.version = version;
+        state.digest = digest;
+        state.cellsVersion = version;
+        state.header = header;
+        state.chunkCount = chunkCount;
+        state.updatedAt = block.timestamp;
+        emit FilterUpdateEvent(msg.sender, version, digest, true, block.timestamp);
+        return true;
+    }
+
+    // publishDigest 只发布新版本的摘要，计数器保持旧版本
+    function publishDigest(uint64 version, bytes32 digest)
+        public onlyNormalCA returns (bool){
+        FilterState storage state = filters[msg.sender];
+        require(version > state.version, "filter version must increase");
+        state.version = version;
+        state.digest = digest;
+        state.updatedAt = block.timestamp;
+        emit FilterUpdateEvent(msg.sender, version, digest, false, block.timestamp);
+        return true;
+    }
+
+    function getFilter(address caAddress)
+        public view returns(
+            uint64 version,
+            bytes32 digest,
+            uint64 cellsVersion,
+            bytes memory header,
+            uint256 chunkCount,
+            uint256 updatedAt
+    ){
+        FilterState storage state = filters[caAddress];
+        return (
+            state.version,
+            state.digest,
+            state.cellsVersion,
+            state.header,
+            state.chunkCount,
+            state.updatedAt
+        );
+    }
+
+    function getChunks(address caAddress, uint256 start, uint256 count)
+        public view returns (bytes32[] memory){
+        FilterState storage state = filters[caAddress];
+        require(start + count <= state.chunkCount, "chunk range out of bounds");
+        bytes32[] memory chunks = new bytes32[](count);
+        for (uint256 i = 0; i < count; i++) {
+            chunks[i] = state.chunks[start + i];
+        }
+        return chunks;
+    }
+}
@@ -0,0 +1,120 @@
+package smcrypto
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"io"
+	"math/big"
+
+	"github.com/FISCO-BCOS/crypto/ecdsa"
+	"github.com/FISCO-BCOS/crypto/elliptic"
+	gmx509 "github.com/FISCO-BCOS/crypto/x509"
+	"github.com/FISCO-BCOS/go-sdk/smcrypto/sm3"
+)
+
+// SM2PrivateKey is a sm2p256v1 private key implementing crypto.Signer with SM2 signatures
+type SM2PrivateKey struct {
+	*ecdsa.PrivateKey
+}
+
+type sm2Signature struct {
+	R, S *big.Int
+}
+
+// GenerateSM2Key generates a new sm2p256v1 signing key.
+func GenerateSM2Key() (*SM2PrivateKey, error) {
+	key, err := GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	return &SM2PrivateKey{PrivateKey: key}, nil
+}
+
+// Sign calculates an ASN.1 DER encoded SM2 signature of msg with the default user ID.
+//
+// SM2 hashes Z||M with SM3 itself, so msg is signed as is and opts is ignored.
+// Callers holding a digest sign the digest as the message.
+func (key *SM2PrivateKey) Sign(_ io.Reader, msg []byte, _ crypto.SignerOpts) ([]byte, error) {
+	r, s, err := SM2Sign(msg, key.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(sm2Signature{R: r, S: s})
+}
+
+// IsSM2PublicKey reports whether pub is a public key on the sm2p256v1 curve.
+func IsSM2PublicKey(pub crypto.PublicKey) bool {
+	key, ok := pub.(*ecdsa.PublicKey)
+	return ok && key.Curve == elliptic.Sm2p256v1()
+}
+
+// SM2Verify verifies the SM2 signature (r, s) of src with the default user ID.
+func SM2Verify(src []byte, pub *ecdsa.PublicKey, r, s *big.Int) bool {
+	curve := elliptic.Sm2p256v1()
+	n := curve.Params().N
+	if pub == nil || pub.X == nil || pub.Y == nil || !curve.IsOnCurve(pub.X, pub.Y) {
+		return false
+	}
+	if r.Sign() <= 0 || s.Sign() <= 0 || r.Cmp(n) >= 0 || s.Cmp(n) >= 0 {
+		return false
+	}
+
+	data, err := SM2PreProcess(src, defaultSM2ID, &ecdsa.PrivateKey{PublicKey: *pub})
+	if err != nil {
+		return false
+	}
+	e := new(big.Int).SetBytes(sm3.Hash(data))
+
+	t := new(big.Int).Add(r, s)
+	t.Mod(t, n)
+	if t.Sign() == 0 {
+		return false
+	}
+	x1, y1 := curve.ScalarBaseMult(s.Bytes())
+	x2, y2 := curve.ScalarMult(pub.X, pub.Y, t.Bytes())
+	x, _ := curve.Add(x1, y1, x2, y2)
+
+	x.Add(x, e)
+	x.Mod(x, n)
+	return x.Cmp(r) == 0
+}
+
+// SM2VerifyASN1 verifies an ASN.1 DER encoded SM2 signature of src.
+func SM2VerifyASN1(pub *ecdsa.PublicKey, src, sig []byte) bool {
+	var signature sm2Signature
+	rest, err := asn1.Unmarshal(sig, &signature)
+	if err != nil || len(rest) != 0 || signature.R == nil || signature.S == nil {
+		return false
+	}
+	return SM2Verify(src, pub, signature.R, signature.S)
+}
+
+// MarshalPKCS8PrivateKey encodes SM2 private keys with the sm2p256v1 curve OID
+// and other private keys with crypto/x509.
+func MarshalPKCS8PrivateKey(key crypto.PrivateKey) ([]byte, error) {
+	switch k := key.(type) {
+	case *SM2PrivateKey:
+		return gmx509.MarshalPKCS8PrivateKey(k.PrivateKey)
+	case *ecdsa.PrivateKey:
+		return gmx509.MarshalPKCS8PrivateKey(k)
+	}
+	return x509.MarshalPKCS8PrivateKey(key)
+}
+
+// ParsePKCS8PrivateKey parses a PKCS #8 private key. SM2 keys are returned as
+// *SM2PrivateKey so that they can be used as a crypto.Signer.
+func ParsePKCS8PrivateKey(der []byte) (crypto.PrivateKey, error) {
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err == nil {
+		return key, nil
+	}
+	gmKey, gmErr := gmx509.ParsePKCS8PrivateKey(der)
+	if gmErr != nil {
+		return nil, err
+	}
+	if sm2Key, ok := gmKey.(*ecdsa.PrivateKey); ok && IsSM2PublicKey(&sm2Key.PublicKey) {
+		return &SM2PrivateKey{PrivateKey: sm2Key}, nil
+	}
+	return nil, err
+}
@@ -0,0 +1,533 @@
+package smcrypto
+
+import (
+	"bytes"
+	"crypto"
+	stdecdsa "crypto/ecdsa"
+	stdelliptic "crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/FISCO-BCOS/crypto/ecdsa"
+	gmx509 "github.com/FISCO-BCOS/crypto/x509"
+)
+
+// GM/T 0006 object identifiers.
+var (
+	OIDNamedCurveSM2       = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 301}
+	OIDSignatureSM2WithSM3 = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 501}
+	OIDHashSM3             = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 401}
+
+	oidPublicKeyECDSA   = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidECDSAWithSHA256  = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	sm2WithSM3Algorithm = pkix.AlgorithmIdentifier{Algorithm: OIDSignatureSM2WithSM3}
+)
+
+// ErrSM2Signer is returned when a GM certificate, CSR or CRL is to be signed by a non-SM2 key.
+var ErrSM2Signer = errors.New("smcrypto: signer is not an SM2 key")
+
+// GM certificates, CSRs and CRLs share the X.509 structures and differ only in the
+// SM2 SubjectPublicKeyInfo and the SM2-with-SM3 signature, which crypto/x509 does
+// not support. They are therefore built by crypto/x509 with a stub P-256 key, after
+// which the SM2 public key and signature algorithm are spliced into the TBS structure
+// and the result is signed with SM2. Parsing reverses the splice so that every other
+// field is decoded by crypto/x509.
+var (
+	stubOnce sync.Once
+	stubKey  *stdecdsa.PrivateKey
+	stubSPKI []byte
+)
+
+func stub() (*stdecdsa.PrivateKey, []byte) {
+	stubOnce.Do(func() {
+		var err error
+		if stubKey, err = stdecdsa.GenerateKey(stdelliptic.P256(), rand.Reader); err != nil {
+			panic(err)
+		}
+		if stubSPKI, err = x509.MarshalPKIXPublicKey(&stubKey.PublicKey); err != nil {
+			panic(err)
+		}
+	})
+	return stubKey, stubSPKI
+}
+
+type publicKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// MarshalPKIXPublicKey encodes SM2 public keys with the sm2p256v1 curve OID and
+// other public keys with crypto/x509.
+func MarshalPKIXPublicKey(pub crypto.PublicKey) ([]byte, error) {
+	if IsSM2PublicKey(pub) {
+		return gmx509.MarshalPKIXPublicKey(pub.(*ecdsa.PublicKey))
+	}
+	return x509.MarshalPKIXPublicKey(pub)
+}
+
+// ParsePKIXPublicKey parses SM2 public keys as *ecdsa.PublicKey on sm2p256v1 and
+// other public keys with crypto/x509.
+func ParsePKIXPublicKey(der []byte) (crypto.PublicKey, error) {
+	if isSM2SPKI(der) {
+		return gmx509.ParsePKIXPublicKey(der)
+	}
+	return x509.ParsePKIXPublicKey(der)
+}
+
+func isSM2SPKI(der []byte) bool {
+	var info publicKeyInfo
+	if rest, err := asn1.Unmarshal(der, &info); err != nil || len(rest) != 0 || !info.Algorithm.Algorithm.Equal(oidPublicKeyECDSA) {
+		return false
+	}
+	var curve asn1.ObjectIdentifier
+	_, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &curve)
+	return err == nil && curve.Equal(OIDNamedCurveSM2)
+}
+
+// subjectKeyID is method 1 of RFC 5280 section 4.2.1.2.
+func subjectKeyID(spki []byte) []byte {
+	var info publicKeyInfo
+	asn1.Unmarshal(spki, &info)
+	h := sha1.Sum(info.PublicKey.Bytes)
+	return h[:]
+}
+
+// IsSM2Signed reports whether the DER encoded certificate, CSR or CRL is signed with SM2-with-SM3.
+func IsSM2Signed(der []byte) bool {
+	signed, err := splitSigned(der)
+	return err == nil && signed.algorithm.Algorithm.Equal(OIDSignatureSM2WithSM3)
+}
+
+// CreateCertificate creates a certificate signed by priv with SM2-with-SM3, as
+// x509.CreateCertificate does for the algorithms it supports. priv must hold an
+// SM2 key; pub may be an SM2 key or any public key supported by crypto/x509.
+func CreateCertificate(template, parent *x509.Certificate, pub crypto.PublicKey, priv crypto.Signer) ([]byte, error) {
+	if !IsSM2PublicKey(priv.Public()) {
+		return nil, ErrSM2Signer
+	}
+	if parent.PublicKey != nil && !IsSM2PublicKey(parent.PublicKey) {
+		return nil, errors.New("smcrypto: parent certificate does not have an SM2 key")
+	}
+	key, _ := stub()
+
+	tbsTemplate := *template
+	stdPub := pub
+	var spki []byte
+	if IsSM2PublicKey(pub) {
+		var err error
+		if spki, err = MarshalPKIXPublicKey(pub); err != nil {
+			return nil, err
+		}
+		stdPub = key.Public()
+		if len(tbsTemplate.SubjectKeyId) == 0 && tbsTemplate.IsCA {
+			tbsTemplate.SubjectKeyId = subjectKeyID(spki)
+		}
+	}
+	issuer := *parent
+	if parent == template {
+		issuer = tbsTemplate
+	}
+	issuer.PublicKey = key.Public()
+
+	der, err := x509.CreateCertificate(rand.Reader, &tbsTemplate, &issuer, stdPub, key)
+	if err != nil {
+		return nil, err
+	}
+	signed, err := splitSigned(der)
+	if err != nil {
+		return nil, err
+	}
+	elements, err := splitSequence(signed.tbs)
+	if err != nil {
+		return nil, err
+	}
+	offset := tbsCertificateOffset(elements)
+	if elements[offset+1], err = asn1.Marshal(sm2WithSM3Algorithm); err != nil {
+		return nil, err
+	}
+	if spki != nil {
+		elements[offset+5] = spki
+	}
+	return signSM2(elements, priv)
+}
+
+// ParseCertificate parses a certificate that may carry an SM2 public key or an
+// SM2-with-SM3 signature. Other certificates are parsed by crypto/x509.
+//
+// For GM certificates PublicKey is an *ecdsa.PublicKey on sm2p256v1 and
+// SignatureAlgorithm is x509.UnknownSignatureAlgorithm; use CheckSignatureFrom
+// to verify them.
+func ParseCertificate(der []byte) (*x509.Certificate, error) {
+	signed, err := splitSigned(der)
+	if err != nil {
+		return x509.ParseCertificate(der)
+	}
+	elements, err := splitSequence(signed.tbs)
+	if err != nil || len(elements) < 6+tbsCertificateOffset(elements) {
+		return x509.ParseCertificate(der)
+	}
+	offset := tbsCertificateOffset(elements)
+	sm2Signed := signed.algorithm.Algorithm.Equal(OIDSignatureSM2WithSM3)
+	spki := elements[offset+5]
+	sm2Key := isSM2SPKI(spki)
+	if !sm2Signed && !sm2Key {
+		return x509.ParseCertificate(der)
+	}
+
+	if sm2Signed {
+		if elements[offset+1], err = asn1.Marshal(pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256}); err != nil {
+			return nil, err
+		}
+		signed.algorithmDER = elements[offset+1]
+	}
+	if sm2Key {
+		_, elements[offset+5] = stub()
+	}
+	patched, err := signed.replaceTBS(elements)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(patched)
+	if err != nil {
+		return nil, err
+	}
+	cert.Raw = der
+	cert.RawTBSCertificate = signed.tbs
+	cert.Signature = signed.signature
+	if sm2Signed {
+		cert.SignatureAlgorithm = x509.UnknownSignatureAlgorithm
+	}
+	if sm2Key {
+		cert.RawSubjectPublicKeyInfo = spki
+		if cert.PublicKey, err = ParsePKIXPublicKey(spki); err != nil {
+			return nil, err
+		}
+	}
+	return cert, nil
+}
+
+// CheckSignatureFrom verifies that parent signed cert, with SM2-with-SM3 for GM
+// certificates and with crypto/x509 otherwise. Like x509.Certificate.CheckSignatureFrom
+// it requires parent to be a CA allowed to sign certificates.
+func CheckSignatureFrom(cert, parent *x509.Certificate) error {
+	if !IsSM2Signed(cert.Raw) {
+		return cert.CheckSignatureFrom(parent)
+	}
+	if parent.Version == 3 && !parent.BasicConstraintsValid || parent.BasicConstraintsValid && !parent.IsCA {
+		return x509.ConstraintViolationError{}
+	}
+	if parent.KeyUsage != 0 && parent.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return x509.ConstraintViolationError{}
+	}
+	return checkSM2Signature(parent.PublicKey, cert.RawTBSCertificate, cert.Signature)
+}
+
+func checkSM2Signature(pub crypto.PublicKey, signed, signature []byte) error {
+	if !IsSM2PublicKey(pub) {
+		return fmt.Errorf("smcrypto: SM2-with-SM3 signature requires an SM2 public key, have %T", pub)
+	}
+	if !SM2VerifyASN1(pub.(*ecdsa.PublicKey), signed, signature) {
+		return x509.ErrUnsupportedAlgorithm
+	}
+	return nil
+}
+
+// VerifyOptions configures VerifyCertificate. The pools are slices because
+// x509.CertPool cannot be enumerated.
+type VerifyOptions struct {
+	Roots         []*x509.Certificate
+	Intermediates []*x509.Certificate
+	CurrentTime   time.Time // zero means time.Now()
+}
+
+const maxChainLength = 8
+
+// VerifyCertificate builds a chain from cert to one of opts.Roots, checking
+// signatures with CheckSignatureFrom and the validity period of every certificate.
+// It returns the chain starting with cert. Name constraints, policies and extended
+// key usages are not checked.
+func VerifyCertificate(cert *x509.Certificate, opts VerifyOptions) ([]*x509.Certificate, error) {
+	now := opts.CurrentTime
+	if now.IsZero() {
+		now = time.Now()
+	}
+	return buildChain([]*x509.Certificate{cert}, &opts, now)
+}
+
+func buildChain(chain []*x509.Certificate, opts *VerifyOptions, now time.Time) ([]*x509.Certificate, error) {
+	cert := chain[len(chain)-1]
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return nil, x509.CertificateInvalidError{Cert: cert, Reason: x509.Expired}
+	}
+	for _, root := range opts.Roots {
+		if root.Equal(cert) {
+			return chain, nil
+		}
+	}
+	if len(chain) >= maxChainLength {
+		return nil, x509.CertificateInvalidError{Cert: cert, Reason: x509.TooManyIntermediates}
+	}
+
+	var lastErr error = x509.UnknownAuthorityError{Cert: cert}
+	for _, candidates := range [][]*x509.Certificate{opts.Roots, opts.Intermediates} {
+		for _, candidate := range candidates {
+			if !bytes.Equal(cert.RawIssuer, candidate.RawSubject) || inChain(chain, candidate) {
+				continue
+			}
+			if err := CheckSignatureFrom(cert, candidate); err != nil {
+				lastErr = err
+				continue
+			}
+			next := append(append([]*x509.Certificate{}, chain...), candidate)
+			verified, err := buildChain(next, opts, now)
+			if err == nil {
+				return verified, nil
+			}
+			lastErr = err
+		}
+	}
+	return nil, lastErr
+}
+
+func inChain(chain []*x509.Certificate, cert *x509.Certificate) bool {
+	for _, c := range chain {
+		if c.Equal(cert) {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateCertificateRequest creates a CSR for an SM2 key signed with SM2-with-SM3.
+func CreateCertificateRequest(template *x509.CertificateRequest, priv crypto.Signer) ([]byte, error) {
+	if !IsSM2PublicKey(priv.Public()) {
+		return nil, ErrSM2Signer
+	}
+	spki, err := MarshalPKIXPublicKey(priv.Public())
+	if err != nil {
+		return nil, err
+	}
+	key, _ := stub()
+	der, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+	if err != nil {
+		return nil, err
+	}
+	signed, err := splitSigned(der)
+	if err != nil {
+		return nil, err
+	}
+	// CertificationRequestInfo: version, subject, subjectPKInfo, attributes
+	elements, err := splitSequence(signed.tbs)
+	if err != nil || len(elements) < 3 {
+		return nil, fmt.Errorf("smcrypto: malformed certificate request")
+	}
+	elements[2] = spki
+	return signSM2(elements, priv)
+}
+
+// ParseCertificateRequest parses a CSR that may carry an SM2 public key and an
+// SM2-with-SM3 signature. Use CheckCertificateRequestSignature to verify it.
+func ParseCertificateRequest(der []byte) (*x509.CertificateRequest, error) {
+	signed, err := splitSigned(der)
+	if err != nil {
+		return x509.ParseCertificateRequest(der)
+	}
+	elements, err := splitSequence(signed.tbs)
+	if err != nil || len(elements) < 3 {
+		return x509.ParseCertificateRequest(der)
+	}
+	sm2Signed := signed.algorithm.Algorithm.Equal(OIDSignatureSM2WithSM3)
+	spki := elements[2]
+	sm2Key := isSM2SPKI(spki)
+	if !sm2Signed && !sm2Key {
+		return x509.ParseCertificateRequest(der)
+	}
+
+	if sm2Signed {
+		if signed.algorithmDER, err = asn1.Marshal(pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256}); err != nil {
+			return nil, err
+		}
+	}
+	if sm2Key {
+		_, elements[2] = stub()
+	}
+	patched, err := signed.replaceTBS(elements)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := x509.ParseCertificateRequest(patched)
+	if err != nil {
+		return nil, err
+	}
+	csr.Raw = der
+	csr.RawTBSCertificateRequest = signed.tbs
+	csr.Signature = signed.signature
+	if sm2Signed {
+		csr.SignatureAlgorithm = x509.UnknownSignatureAlgorithm
+	}
+	if sm2Key {
+		csr.RawSubjectPublicKeyInfo = spki
+		if csr.PublicKey, err = ParsePKIXPublicKey(spki); err != nil {
+			return nil, err
+		}
+	}
+	return csr, nil
+}
+
+// CheckCertificateRequestSignature verifies the proof of possession of a CSR
+// parsed by ParseCertificateRequest.
+func CheckCertificateRequestSignature(csr *x509.CertificateRequest) error {
+	if !IsSM2Signed(csr.Raw) {
+		return csr.CheckSignature()
+	}
+	return checkSM2Signature(csr.PublicKey, csr.RawTBSCertificateRequest, csr.Signature)
+}
+
+// CreateRevocationList creates a CRL signed by priv with SM2-with-SM3, as
+// x509.CreateRevocationList does for the algorithms it supports.
+func CreateRevocationList(template *x509.RevocationList, issuer *x509.Certificate, priv crypto.Signer) ([]byte, error) {
+	if !IsSM2PublicKey(priv.Public()) {
+		return nil, ErrSM2Signer
+	}
+	key, _ := stub()
+	der, err := x509.CreateRevocationList(rand.Reader, template, issuer, key)
+	if err != nil {
+		return nil, err
+	}
+	signed, err := splitSigned(der)
+	if err != nil {
+		return nil, err
+	}
+	// TBSCertList: version (optional INTEGER), signature, issuer, ...
+	elements, err := splitSequence(signed.tbs)
+	if err != nil || len(elements) < 3 {
+		return nil, fmt.Errorf("smcrypto: malformed revocation list")
+	}
+	offset := 0
+	if elements[0][0] == asn1.TagInteger {
+		offset = 1
+	}
+	if elements[offset], err = asn1.Marshal(sm2WithSM3Algorithm); err != nil {
+		return nil, err
+	}
+	return signSM2(elements, priv)
+}
+
+// CheckRevocationListSignature verifies that issuer signed crl, with SM2-with-SM3
+// for GM CRLs and with crypto/x509 otherwise.
+func CheckRevocationListSignature(crl *x509.RevocationList, issuer *x509.Certificate) error {
+	if !IsSM2Signed(crl.Raw) {
+		return crl.CheckSignatureFrom(issuer)
+	}
+	if issuer.Version == 3 && !issuer.BasicConstraintsValid || issuer.BasicConstraintsValid && !issuer.IsCA {
+		return x509.ConstraintViolationError{}
+	}
+	if issuer.KeyUsage != 0 && issuer.KeyUsage&x509.KeyUsageCRLSign == 0 {
+		return x509.ConstraintViolationError{}
+	}
+	return checkSM2Signature(issuer.PublicKey, crl.RawTBSRevocationList, crl.Signature)
+}
+
+// signed is the common outer structure of certificates, CSRs and CRLs:
+// SEQUENCE { tbs, signatureAlgorithm, signatureValue BIT STRING }.
+type signed struct {
+	tbs          []byte
+	algorithmDER []byte
+	signatureDER []byte
+	algorithm    pkix.AlgorithmIdentifier
+	signature    []byte
+}
+
+func splitSigned(der []byte) (*signed, error) {
+	elements, err := splitSequence(der)
+	if err != nil {
+		return nil, err
+	}
+	if len(elements) != 3 {
+		return nil, errors.New("smcrypto: malformed signed structure")
+	}
+	result := &signed{tbs: elements[0], algorithmDER: elements[1], signatureDER: elements[2]}
+	if _, err := asn1.Unmarshal(elements[1], &result.algorithm); err != nil {
+		return nil, err
+	}
+	var signature asn1.BitString
+	if _, err := asn1.Unmarshal(elements[2], &signature); err != nil {
+		return nil, err
+	}
+	result.signature = signature.RightAlign()
+	return result, nil
+}
+
+func (s *signed) replaceTBS(elements [][]byte) ([]byte, error) {
+	tbs, err := joinSequence(elements)
+	if err != nil {
+		return nil, err
+	}
+	return joinSequence([][]byte{tbs, s.algorithmDER, s.signatureDER})
+}
+
+// signSM2 assembles the TBS elements, signs them with SM2-with-SM3 and returns the signed structure.
+func signSM2(elements [][]byte, priv crypto.Signer) ([]byte, error) {
+	tbs, err := joinSequence(elements)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := priv.Sign(rand.Reader, tbs, crypto.Hash(0))
+	if err != nil {
+		return nil, fmt.Errorf("smcrypto: failed to sign: %w", err)
+	}
+	algorithm, err := asn1.Marshal(sm2WithSM3Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	bitString, err := asn1.Marshal(asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)})
+	if err != nil {
+		return nil, err
+	}
+	return joinSequence([][]byte{tbs, algorithm, bitString})
+}
+
+// tbsCertificateOffset is 1 when the TBSCertificate starts with the explicit [0] version.
+func tbsCertificateOffset(elements [][]byte) int {
+	if len(elements) > 0 && elements[0][0] == 0xa0 {
+		return 1
+	}
+	return 0
+}
+
+// splitSequence returns the DER encoding of each element of a SEQUENCE.
+func splitSequence(der []byte) ([][]byte, error) {
+	var sequence asn1.RawValue
+	rest, err := asn1.Unmarshal(der, &sequence)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 || sequence.Class != asn1.ClassUniversal || sequence.Tag != asn1.TagSequence || !sequence.IsCompound {
+		return nil, errors.New("smcrypto: expected a DER SEQUENCE")
+	}
+	var elements [][]byte
+	for data := sequence.Bytes; len(data) > 0; {
+		var element asn1.RawValue
+		if data, err = asn1.Unmarshal(data, &element); err != nil {
+			return nil, err
+		}
+		elements = append(elements, element.FullBytes)
+	}
+	return elements, nil
+}
+
+func joinSequence(elements [][]byte) ([]byte, error) {
+	return asn1.Marshal(asn1.RawValue{
+		Class:      asn1.ClassUniversal,
+		Tag:        asn1.TagSequence,
+		IsCompound: true,
+		Bytes:      bytes.Join(elements, nil),
+	})
+}
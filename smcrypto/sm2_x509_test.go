@@ -0,0 +1,175 @@
+package smcrypto
+
+import (
+	"bytes"
+	stdecdsa "crypto/ecdsa"
+	stdelliptic "crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/FISCO-BCOS/crypto/ecdsa"
+)
+
+func sm2TestCertificate(t *testing.T, name string, serial int64, isCA bool, parent *x509.Certificate, parentKey *SM2PrivateKey) (*x509.Certificate, *SM2PrivateKey) {
+	key, err := GenerateSM2Key()
+	if err != nil {
+		t.Fatalf("generate SM2 key failed: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if isCA {
+		template.KeyUsage |= x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := CreateCertificate(template, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatalf("create %s failed: %v", name, err)
+	}
+	if !IsSM2Signed(der) {
+		t.Fatalf("%s should be signed with SM2-with-SM3", name)
+	}
+	cert, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse %s failed: %v", name, err)
+	}
+	if !bytes.Equal(cert.Raw, der) || cert.Subject.CommonName != name || !IsSM2PublicKey(cert.PublicKey) {
+		t.Fatalf("%s did not round trip", name)
+	}
+	if pub := cert.PublicKey.(*ecdsa.PublicKey); pub.X.Cmp(key.X) != 0 || pub.Y.Cmp(key.Y) != 0 {
+		t.Fatalf("%s public key mismatch", name)
+	}
+	return cert, key
+}
+
+func TestSM2CertificateChain(t *testing.T) {
+	root, rootKey := sm2TestCertificate(t, "gm root", 1, true, nil, nil)
+	if len(root.SubjectKeyId) == 0 {
+		t.Fatalf("CA certificate should have a subject key identifier")
+	}
+	intermediate, intermediateKey := sm2TestCertificate(t, "gm intermediate", 2, true, root, rootKey)
+	leaf, _ := sm2TestCertificate(t, "gm leaf", 3, false, intermediate, intermediateKey)
+
+	if err := CheckSignatureFrom(root, root); err != nil {
+		t.Fatalf("root should be self-signed: %v", err)
+	}
+	opts := VerifyOptions{Roots: []*x509.Certificate{root}, Intermediates: []*x509.Certificate{intermediate}}
+	chain, err := VerifyCertificate(leaf, opts)
+	if err != nil || len(chain) != 3 || chain[2] != root {
+		t.Fatalf("leaf should chain to the root, have %d %v", len(chain), err)
+	}
+	if _, err := VerifyCertificate(leaf, VerifyOptions{Roots: []*x509.Certificate{root}}); err == nil {
+		t.Fatalf("leaf without its intermediate should not verify")
+	}
+	if err := CheckSignatureFrom(intermediate, leaf); err == nil {
+		t.Fatalf("a leaf certificate should not be accepted as an issuer")
+	}
+	opts.CurrentTime = time.Now().Add(2 * time.Hour)
+	if _, err := VerifyCertificate(leaf, opts); err == nil {
+		t.Fatalf("expired chain should not verify")
+	}
+
+	tampered := bytes.Replace(leaf.Raw, []byte("gm leaf"), []byte("gm leaF"), 1)
+	tamperedCert, err := ParseCertificate(tampered)
+	if err != nil {
+		t.Fatalf("parse tampered certificate failed: %v", err)
+	}
+	if err := CheckSignatureFrom(tamperedCert, intermediate); err == nil {
+		t.Fatalf("tampered certificate should not verify")
+	}
+
+	// an SM2 CA may also certify NIST curve keys
+	nistKey, _ := stdecdsa.GenerateKey(stdelliptic.P256(), rand.Reader)
+	der, err := CreateCertificate(&x509.Certificate{SerialNumber: big.NewInt(4), NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour)}, root, nistKey.Public(), rootKey)
+	if err != nil {
+		t.Fatalf("create certificate for a P-256 key failed: %v", err)
+	}
+	cert, err := ParseCertificate(der)
+	if err != nil || !nistKey.PublicKey.Equal(cert.PublicKey) {
+		t.Fatalf("P-256 subject key did not round trip: %v", err)
+	}
+	if err := CheckSignatureFrom(cert, root); err != nil {
+		t.Fatalf("certificate should be signed by the SM2 root: %v", err)
+	}
+}
+
+func TestSM2CertificateRequestAndCRL(t *testing.T) {
+	key, _ := GenerateSM2Key()
+	der, err := CreateCertificateRequest(&x509.CertificateRequest{Subject: pkix.Name{CommonName: "gm subject"}}, key)
+	if err != nil {
+		t.Fatalf("create CSR failed: %v", err)
+	}
+	csr, err := ParseCertificateRequest(der)
+	if err != nil || csr.Subject.CommonName != "gm subject" || !IsSM2PublicKey(csr.PublicKey) {
+		t.Fatalf("CSR did not round trip: %v", err)
+	}
+	if err := CheckCertificateRequestSignature(csr); err != nil {
+		t.Fatalf("CSR signature should verify: %v", err)
+	}
+	other, _ := GenerateSM2Key()
+	csr.PublicKey = other.Public()
+	if err := CheckCertificateRequestSignature(csr); err == nil {
+		t.Fatalf("CSR signature should not verify with another key")
+	}
+
+	root, rootKey := sm2TestCertificate(t, "gm crl issuer", 1, true, nil, nil)
+	crlDER, err := CreateRevocationList(&x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now(),
+		NextUpdate: time.Now().Add(time.Hour),
+		RevokedCertificateEntries: []x509.RevocationListEntry{
+			{SerialNumber: big.NewInt(7), RevocationTime: time.Now()},
+		},
+	}, root, rootKey)
+	if err != nil {
+		t.Fatalf("create CRL failed: %v", err)
+	}
+	crl, err := x509.ParseRevocationList(crlDER)
+	if err != nil || len(crl.RevokedCertificateEntries) != 1 {
+		t.Fatalf("parse CRL failed: %v", err)
+	}
+	if err := CheckRevocationListSignature(crl, root); err != nil {
+		t.Fatalf("CRL signature should verify: %v", err)
+	}
+	otherRoot, _ := sm2TestCertificate(t, "gm other issuer", 2, true, nil, nil)
+	if err := CheckRevocationListSignature(crl, otherRoot); err == nil {
+		t.Fatalf("CRL signature should not verify with another issuer")
+	}
+}
+
+func TestSM2KeyEncoding(t *testing.T) {
+	key, _ := GenerateSM2Key()
+	der, err := MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal PKCS8 failed: %v", err)
+	}
+	parsed, err := ParsePKCS8PrivateKey(der)
+	if err != nil {
+		t.Fatalf("parse PKCS8 failed: %v", err)
+	}
+	if sm2Key, ok := parsed.(*SM2PrivateKey); !ok || sm2Key.D.Cmp(key.D) != 0 {
+		t.Fatalf("expected the SM2 key back, have %T", parsed)
+	}
+	spki, _ := MarshalPKIXPublicKey(key.Public())
+	if pub, err := ParsePKIXPublicKey(spki); err != nil || !IsSM2PublicKey(pub) {
+		t.Fatalf("SM2 public key did not round trip: %v", err)
+	}
+
+	nistKey, _ := stdecdsa.GenerateKey(stdelliptic.P256(), rand.Reader)
+	der, _ = MarshalPKCS8PrivateKey(nistKey)
+	if parsed, err := ParsePKCS8PrivateKey(der); err != nil || !nistKey.Equal(parsed) {
+		t.Fatalf("P-256 key did not round trip: %v", err)
+	}
+}
@@ -153,3 +153,28 @@ func TestHexToSM2(t *testing.T) {
 // 	sm2pk, _ := gmssl.NewPublicKeyFromPEM(sm2pkpem)
 // 	return sm2pk.Verify("sm2sign", tbs, sig, nil)
 // }
+
+func TestSM2SignVerify(t *testing.T) {
+	key, err := GenerateSM2Key()
+	if err != nil {
+		t.Fatalf("generate sm2 key error: %v", err)
+	}
+	message := []byte("message digest")
+	sig, err := key.Sign(nil, message, nil)
+	if err != nil {
+		t.Fatalf("sm2 sign error: %v", err)
+	}
+	if !IsSM2PublicKey(key.Public()) {
+		t.Fatalf("generated key is not on sm2p256v1")
+	}
+	if !SM2VerifyASN1(&key.PublicKey, message, sig) {
+		t.Fatalf("sm2 verify failed")
+	}
+	if SM2VerifyASN1(&key.PublicKey, []byte("another message"), sig) {
+		t.Fatalf("sm2 signature verified for a different message")
+	}
+	other, _ := GenerateSM2Key()
+	if SM2VerifyASN1(&other.PublicKey, message, sig) {
+		t.Fatalf("sm2 signature verified with another key")
+	}
+}